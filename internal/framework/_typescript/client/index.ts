@@ -1,21 +1,28 @@
 export {
 	addBuildIDListener,
+	addDeferredListener,
 	addRouteChangeListener,
 	addStatusListener,
+	type DeferredEvent,
 	devRevalidate,
 	getBuildID,
 	getCurrentRiverData,
 	getHistoryInstance,
+	getIsServerRendered,
 	getPrefetchHandlers,
 	getRootEl,
 	getStatus,
 	initClient,
 	makeLinkClickListenerFn,
 	navigate,
+	openWebSocket,
 	type RouteChangeEvent,
 	revalidate,
 	type StatusEvent,
+	stream,
 	submit,
+	submitForm,
+	type WebSocketChannel,
 } from "./src/client.ts";
 export { internal_RiverClientGlobal } from "./src/river_ctx.ts";
 export type { Routes } from "./src/route_def_helpers.ts";
@@ -11,6 +11,7 @@ import { parseFetchResponseForRedirectData, type RedirectData } from "./redirect
 import {
 	type GetRouteDataOutput,
 	type HeadBlock,
+	type LoaderError,
 	internal_RiverClientGlobal,
 	type RiverClientGlobal,
 } from "./river_ctx.ts";
@@ -168,7 +169,10 @@ async function __fetchRouteData(
 		});
 
 		const redirected = redirectData?.status === "did";
-		const responseNotOK = !response?.ok && response?.status !== 304;
+		// Loader errors still come back as renderable route data (with the
+		// status of the outermost failing loader), so only bail on non-JSON
+		const isRouteData = response?.headers.get("Content-Type")?.includes("application/json");
+		const responseNotOK = !response?.ok && response?.status !== 304 && !isRouteData;
 		if (redirected || !response || responseNotOK) {
 			setLoadingStatus({ type: props.navigationType, value: false });
 			return;
@@ -599,6 +603,292 @@ async function submitInner(
 	}
 }
 
+/////////////////////////////////////////////////////////////////////
+// FORM ACTIONS
+/////////////////////////////////////////////////////////////////////
+
+/**
+ * Progressively enhances a native form submission to a River form action.
+ * Call it from a form's submit handler (after `e.preventDefault()`). The
+ * form is POSTed to its action URL (or the current URL) exactly as the
+ * browser would without JavaScript, and the re-rendered route data that
+ * comes back (fresh loaders plus `actionData` / `actionError`) is applied
+ * without a full page load. Redirects issued by the action are followed.
+ */
+export async function submitForm(
+	form: HTMLFormElement,
+	submitter?: HTMLElement | null,
+): Promise<{ success: true } | { success: false; error: LoaderError | string }> {
+	const url = new URL(form.getAttribute("action") || window.location.href, window.location.href);
+	const formData = new FormData(form, submitter);
+
+	const headers = new Headers();
+	let body: FormData | string;
+	if (form.enctype === "multipart/form-data") {
+		body = formData;
+	} else {
+		headers.set("Content-Type", "application/x-www-form-urlencoded");
+		body = new URLSearchParams(formData as unknown as Record<string, string>).toString();
+	}
+
+	setLoadingStatus({ type: "submission", value: true });
+
+	const submissionKey = url.href + "POST";
+	const { abortController } = handleSubmissionController(submissionKey);
+
+	const urlToUse = new URL(url);
+	urlToUse.searchParams.set("river-json", "1");
+
+	try {
+		const { redirectData, response } = await handleRedirects({
+			abortController,
+			url: urlToUse,
+			requestInit: { method: "POST", body, headers },
+		});
+
+		navigationState.submissions.delete(submissionKey);
+
+		// Action errors still come back as renderable route data (with the
+		// action's status), so only bail on non-JSON responses
+		const isRouteData = response?.headers.get("Content-Type")?.includes("application/json");
+
+		// Either the action redirected (already handled), or something went
+		// wrong before the route could be re-rendered
+		if (redirectData?.status === "did" || !response || !isRouteData) {
+			setLoadingStatus({ type: "submission", value: false });
+			if (response && !response.ok) {
+				return { success: false, error: String(response.status) };
+			}
+			return { success: true };
+		}
+
+		const json = (await response.json()) as GetRouteDataOutput;
+		const isCurrent = url.href === window.location.href;
+
+		await __reRenderApp({
+			json,
+			navigationType: isCurrent ? "revalidation" : "userNavigation",
+			runHistoryOptions: { href: url.href },
+		});
+
+		setLoadingStatus({ type: "submission", value: false });
+
+		return json.actionError ? { success: false, error: json.actionError } : { success: true };
+	} catch (error) {
+		if (isAbortError(error)) {
+			return { success: false, error: "Aborted" };
+		}
+
+		LogError(error);
+		setLoadingStatus({ type: "submission", value: false });
+
+		return { success: false, error: error instanceof Error ? error.message : "Unknown error" };
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+// STREAMS
+/////////////////////////////////////////////////////////////////////
+
+type StreamOptions = {
+	requestInit?: RequestInit;
+	/**
+	 * Resume from a previously received event ID.
+	 */
+	lastEventID?: string;
+	/**
+	 * How many times to reconnect (resuming from the last received event
+	 * ID) if the connection drops before the stream is done. Defaults to 3.
+	 */
+	maxReconnects?: number;
+};
+
+/**
+ * Consumes a mux stream handler (Server-Sent Events) as an async iterator
+ * of parsed values. Use the generated `StreamOutput<P>` type for `T`.
+ * Iteration ends when the server signals the stream is done, throws if
+ * the server signals an error, and can be cancelled with an abort signal
+ * (or by breaking out of the loop).
+ */
+export async function* stream<T = any>(url: string | URL, options?: StreamOptions): AsyncGenerator<T> {
+	let lastEventID = options?.lastEventID ?? "";
+	let reconnectsLeft = options?.maxReconnects ?? 3;
+
+	while (true) {
+		const headers = new Headers(options?.requestInit?.headers);
+		headers.set("Accept", "text/event-stream");
+		if (lastEventID) {
+			headers.set("Last-Event-ID", lastEventID);
+		}
+
+		const controller = new AbortController();
+		const signal = options?.requestInit?.signal;
+		const onAbort = () => controller.abort();
+		signal?.addEventListener("abort", onAbort);
+
+		try {
+			const response = await fetch(url, { ...options?.requestInit, headers, signal: controller.signal });
+			if (!response.ok || !response.body) {
+				throw new Error(`stream failed with status ${response.status}`);
+			}
+
+			for await (const event of parseSSE(response.body)) {
+				if (event.event === "done") {
+					return;
+				}
+				if (event.event === "error") {
+					throw new Error(JSON.parse(event.data));
+				}
+				if (event.id) {
+					lastEventID = event.id;
+				}
+				yield JSON.parse(event.data) as T;
+			}
+		} catch (error) {
+			if (isAbortError(error) || signal?.aborted || reconnectsLeft <= 0) {
+				throw error;
+			}
+			if (!(error instanceof TypeError)) {
+				// Not a network error, so reconnecting won't help
+				throw error;
+			}
+		} finally {
+			signal?.removeEventListener("abort", onAbort);
+			controller.abort();
+		}
+
+		// Connection dropped before the server said it was done
+		if (reconnectsLeft <= 0) {
+			throw new Error("stream ended unexpectedly");
+		}
+		reconnectsLeft--;
+	}
+}
+
+type SSEEvent = { id: string; event: string; data: string };
+
+async function* parseSSE(body: ReadableStream<Uint8Array>): AsyncGenerator<SSEEvent> {
+	const reader = body.pipeThrough(new TextDecoderStream()).getReader();
+	let buffer = "";
+	let current: SSEEvent = { id: "", event: "", data: "" };
+
+	while (true) {
+		const { done, value } = await reader.read();
+		if (done) {
+			return;
+		}
+		buffer += value;
+
+		let newlineIdx = buffer.indexOf("\n");
+		while (newlineIdx !== -1) {
+			const line = buffer.slice(0, newlineIdx).replace(/\r$/, "");
+			buffer = buffer.slice(newlineIdx + 1);
+			newlineIdx = buffer.indexOf("\n");
+
+			if (line === "") {
+				if (current.data || current.event) {
+					yield current;
+				}
+				current = { id: "", event: "", data: "" };
+				continue;
+			}
+			if (line.startsWith(":")) {
+				continue; // heartbeat
+			}
+
+			const colonIdx = line.indexOf(":");
+			const field = colonIdx === -1 ? line : line.slice(0, colonIdx);
+			const fieldValue = colonIdx === -1 ? "" : line.slice(colonIdx + 1).replace(/^ /, "");
+
+			if (field === "data") {
+				current.data = current.data ? `${current.data}\n${fieldValue}` : fieldValue;
+			} else if (field === "id") {
+				current.id = fieldValue;
+			} else if (field === "event") {
+				current.event = fieldValue;
+			}
+		}
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+// WEBSOCKETS
+/////////////////////////////////////////////////////////////////////
+
+export type WebSocketChannel<I, O> = {
+	/**
+	 * Resolves once the connection is open. Rejects if it fails to open.
+	 */
+	ready: Promise<void>;
+	send: (msg: I) => void;
+	close: () => void;
+	/**
+	 * Iterates over inbound messages until the connection closes.
+	 */
+	[Symbol.asyncIterator]: () => AsyncIterator<O>;
+};
+
+/**
+ * Opens a typed channel to a mux websocket handler. Use the generated
+ * `WebSocketInput<P>` and `WebSocketOutput<P>` types for `I` and `O`.
+ * Relative URLs are resolved against the current location (with the
+ * protocol switched to ws/wss).
+ */
+export function openWebSocket<I = any, O = any>(url: string | URL): WebSocketChannel<I, O> {
+	const wsURL = new URL(url, window.location.href);
+	wsURL.protocol = wsURL.protocol === "https:" ? "wss:" : "ws:";
+
+	const ws = new WebSocket(wsURL);
+	const queue: Array<O> = [];
+	const waiters: Array<(result: IteratorResult<O>) => void> = [];
+	let closed = false;
+
+	const ready = new Promise<void>((resolve, reject) => {
+		ws.addEventListener("open", () => resolve());
+		ws.addEventListener("error", () => reject(new Error("websocket failed to open")));
+	});
+	// Avoid unhandled rejections if the caller never awaits ready
+	ready.catch(() => {});
+
+	ws.addEventListener("message", (e) => {
+		const msg = JSON.parse(e.data) as O;
+		const waiter = waiters.shift();
+		if (waiter) {
+			waiter({ value: msg, done: false });
+		} else {
+			queue.push(msg);
+		}
+	});
+
+	ws.addEventListener("close", () => {
+		closed = true;
+		for (const waiter of waiters.splice(0)) {
+			waiter({ value: undefined, done: true });
+		}
+	});
+
+	return {
+		ready,
+		send: (msg) => ws.send(JSON.stringify(msg)),
+		close: () => ws.close(),
+		[Symbol.asyncIterator]: () => ({
+			next: () => {
+				if (queue.length > 0) {
+					return Promise.resolve({ value: queue.shift() as O, done: false });
+				}
+				if (closed) {
+					return Promise.resolve({ value: undefined, done: true });
+				}
+				return new Promise((resolve) => waiters.push(resolve));
+			},
+			return: () => {
+				ws.close();
+				return Promise.resolve({ value: undefined, done: true });
+			},
+		}),
+	};
+}
+
 /////////////////////////////////////////////////////////////////////
 // STATUS
 /////////////////////////////////////////////////////////////////////
@@ -717,6 +1007,7 @@ async function __reRenderApp({
 		const newLink = document.createElement("link");
 		newLink.rel = "modulepreload";
 		newLink.href = href;
+		setIntegrity(newLink, json.integrity?.[x]);
 		document.head.appendChild(newLink);
 	}
 
@@ -733,6 +1024,7 @@ async function __reRenderApp({
 		newLink.rel = "preload";
 		newLink.href = href;
 		newLink.as = "style";
+		setIntegrity(newLink, json.integrity?.[x]);
 		document.head.appendChild(newLink);
 
 		// Create a promise for this CSS bundle preload
@@ -746,18 +1038,24 @@ async function __reRenderApp({
 	// NOW ACTUALLY SET EVERYTHING
 	const identicalKeysToSet = [
 		"loadersData",
+		"loadersErrs",
 		"importURLs",
 		"exportKeys",
 		"outermostErrorIndex",
 		"splatValues",
 		"params",
 		"coreData",
+		"actionData",
+		"actionError",
 	] as const satisfies ReadonlyArray<keyof RiverClientGlobal>;
 
 	for (const key of identicalKeysToSet) {
 		internal_RiverClientGlobal.set(key, json[key]);
 	}
 
+	// Any chunks still streaming in for the initial document are now stale
+	internal_RiverClientGlobal.set("pendingDeferredIndices", null);
+
 	await handleComponents();
 
 	const oldID = internal_RiverClientGlobal.get("buildID");
@@ -815,6 +1113,7 @@ async function __reRenderApp({
 			const newLink = document.createElement("link");
 			newLink.rel = "stylesheet";
 			newLink.href = "/public/" + x;
+			setIntegrity(newLink, json.integrity?.[x]);
 			newLink.setAttribute(cssBundleDataAttr, x);
 			document.head.appendChild(newLink);
 		}
@@ -830,6 +1129,12 @@ async function __reRenderApp({
 
 const cssBundleDataAttr = "data-river-css-bundle";
 
+function setIntegrity(link: HTMLLinkElement, integrity: string | undefined) {
+	if (integrity) {
+		link.integrity = integrity;
+	}
+}
+
 /////////////////////////////////////////////////////////////////////
 // SIMPLE WRAPPERS
 /////////////////////////////////////////////////////////////////////
@@ -1037,6 +1342,16 @@ export function getRootEl() {
 	return document.getElementById("river-root") as HTMLDivElement;
 }
 
+/**
+ * Returns true if the root element already contains server-rendered
+ * markup (i.e., an SSRRenderer is configured on the server), in which
+ * case your render function should hydrate instead of rendering from
+ * scratch (e.g., `hydrateRoot` in React, `hydrate` in Preact or Solid).
+ */
+export function getIsServerRendered(): boolean {
+	return (getRootEl()?.childElementCount ?? 0) > 0;
+}
+
 export async function initClient(renderFn: () => void) {
 	if (import.meta.hot) {
 		import.meta.hot.on("vite:afterUpdate", () => {
@@ -1055,6 +1370,7 @@ export async function initClient(renderFn: () => void) {
 
 	// INSTANTIATE GLOBAL EVENT LISTENERS
 	__addAnchorClickListener();
+	__addDeferredListener();
 }
 
 async function handleComponents() {
@@ -1085,6 +1401,8 @@ export function getCurrentRiverData<T = any>() {
 		splatValues: internal_RiverClientGlobal.get("splatValues") || [],
 		params: internal_RiverClientGlobal.get("params") || {},
 		coreData: (internal_RiverClientGlobal.get("coreData") || null) as T | null,
+		actionData: internal_RiverClientGlobal.get("actionData") ?? null,
+		actionError: internal_RiverClientGlobal.get("actionError") ?? null,
 	};
 }
 
@@ -1106,6 +1424,36 @@ export function getBuildID() {
 	return internal_RiverClientGlobal.get("buildID");
 }
 
+/////////////////////////////////////////////////////////////////////
+// DEFERRED LOADER DATA
+/////////////////////////////////////////////////////////////////////
+
+const DEFERRED_EVENT_KEY = "river:deferred";
+
+type DeferredEventDetail = { index: number; ok: boolean };
+export type DeferredEvent = CustomEvent<DeferredEventDetail>;
+
+export const addDeferredListener = makeListenerAdder<DeferredEventDetail>(DEFERRED_EVENT_KEY);
+
+function dispatchDeferredRouteChange() {
+	window.dispatchEvent(
+		new CustomEvent<RouteChangeEventDetail>(RIVER_ROUTE_CHANGE_EVENT_KEY, { detail: {} }),
+	);
+}
+
+function __addDeferredListener() {
+	addDeferredListener(dispatchDeferredRouteChange);
+
+	// Chunks that streamed in before the listener was attached have already
+	// updated the loaders data, so nudge the UI once it has had a chance to
+	// mount its own route change listeners.
+	const deferred = internal_RiverClientGlobal.get("deferredIndices") ?? [];
+	const pending = internal_RiverClientGlobal.get("pendingDeferredIndices") ?? [];
+	if (deferred.length > pending.length) {
+		setTimeout(dispatchDeferredRouteChange, 0);
+	}
+}
+
 /////////////////////////////////////////////////////////////////////
 // LISTENER UTILS
 /////////////////////////////////////////////////////////////////////
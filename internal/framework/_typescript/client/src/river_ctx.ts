@@ -7,10 +7,17 @@ export type HeadBlock = {
 	innerHTML?: string;
 };
 
+export type LoaderError = {
+	status: number;
+	message: string;
+	data?: unknown;
+};
+
 type Meta = { title: string; metaHeadBlocks: Array<HeadBlock>; restHeadBlocks: Array<HeadBlock> };
 
 type shared = {
 	loadersData: Array<any>;
+	loadersErrs: Array<LoaderError | null> | null;
 	importURLs: Array<string>;
 	exportKeys: Array<string>;
 	outermostErrorIndex: number;
@@ -20,12 +27,17 @@ type shared = {
 	buildID: string;
 	activeErrorBoundaries: Array<any> | null;
 	activeComponents: Array<any> | null;
+	// Only set when the route was re-rendered in response to a form action
+	actionData?: unknown;
+	actionError?: LoaderError | null;
 };
 
 export type GetRouteDataOutput = shared &
 	Meta & {
 		deps: Array<string>;
 		cssBundles: Array<string>;
+		// SRI values of the deps and CSS bundles, keyed by filename (prod only)
+		integrity?: Record<string, string>;
 	};
 
 export const RIVER_SYMBOL = Symbol.for("__river_internal__");
@@ -33,6 +45,8 @@ export const RIVER_SYMBOL = Symbol.for("__river_internal__");
 export type RiverClientGlobal = shared & {
 	isDev: boolean;
 	viteDevURL: string;
+	deferredIndices: Array<number> | null;
+	pendingDeferredIndices: Array<number> | null;
 };
 
 export function __getRiverClientGlobal() {
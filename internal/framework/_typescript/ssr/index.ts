@@ -0,0 +1,48 @@
+import { createInterface } from "node:readline";
+
+export type SSRRequest = {
+	id: number;
+	url: string;
+	// Shaped like the UIRouteOutput Go type (same as River JSON responses)
+	routeData: Record<string, unknown>;
+};
+
+export type SSRRenderFn = (req: SSRRequest) => string | Promise<string>;
+
+/**
+ * Implements the JS side of River's NodeSSRRenderer protocol. Reads
+ * newline-delimited JSON render requests from stdin, calls `render` for
+ * each one (concurrently), and writes one JSON response line per request
+ * to stdout. Anything you need to log should go to stderr, because stdout
+ * is reserved for the protocol.
+ */
+export function serveSSR(render: SSRRenderFn): void {
+	const rl = createInterface({ input: process.stdin, crlfDelay: Number.POSITIVE_INFINITY });
+
+	rl.on("line", async (line) => {
+		if (!line.trim()) return;
+
+		let req: SSRRequest;
+		try {
+			req = JSON.parse(line);
+		} catch (e) {
+			console.error("river ssr: invalid request line", e);
+			return;
+		}
+
+		try {
+			const html = await render(req);
+			write({ id: req.id, html });
+		} catch (e) {
+			write({ id: req.id, error: e instanceof Error ? e.message : String(e) });
+		}
+	});
+
+	rl.on("close", () => {
+		process.exit(0);
+	});
+}
+
+function write(resp: { id: number; html?: string; error?: string }) {
+	process.stdout.write(`${JSON.stringify(resp)}\n`);
+}
@@ -0,0 +1,46 @@
+package framework
+
+import (
+	"slices"
+
+	"github.com/sjc5/river/kit/bytesutil"
+	"github.com/sjc5/river/kit/cryptoutil"
+	"github.com/sjc5/river/kit/middleware/csp"
+)
+
+// Adds the hashes of the inline scripts and styles rendered into a document
+// to the request's CSP. A no-op unless the request went through a
+// csp.NewMiddleware. Deferred loader chunks are streamed after the headers
+// are sent, so they can only be allowed with a nonce (see csp.Opts.UseNonce).
+// In dev, the policy must also allow the Vite dev server's scripts.
+func (h *River[C]) addCSPHashes(b *csp.Builder, routeData *UIRouteOutput, ssrScriptSha256Hash string) {
+	if b == nil {
+		return
+	}
+
+	b.AddScriptHash(ssrScriptSha256Hash)
+	b.AddStyleHash(h.Kiruna.GetCriticalCSSStyleElementSha256Hash())
+	for _, name := range routeData.kirunaCSSBundles {
+		b.AddStyleHash(h.Kiruna.GetCSSBundleStyleElementSha256Hash(name))
+	}
+	if h._isDev {
+		b.AddScriptHash(h.Kiruna.GetRefreshScriptSha256Hash())
+	}
+
+	// Inline head blocks
+	for _, el := range slices.Concat(routeData.Meta, routeData.Rest) {
+		if el == nil || el.InnerHTML == "" {
+			continue
+		}
+		switch {
+		case el.Tag == "script" && el.Attributes["src"] == "" && el.TrustedAttributes["src"] == "":
+			b.AddScriptHash(toSha256Base64(string(el.InnerHTML)))
+		case el.Tag == "style":
+			b.AddStyleHash(toSha256Base64(string(el.InnerHTML)))
+		}
+	}
+}
+
+func toSha256Base64(s string) string {
+	return bytesutil.ToBase64(cryptoutil.Sha256Hash([]byte(s)))
+}
@@ -0,0 +1,143 @@
+package framework
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/sjc5/river/kit/bytesutil"
+	"github.com/sjc5/river/kit/htmlutil"
+	"github.com/sjc5/river/kit/middleware/csp"
+	"github.com/sjc5/river/kit/mux"
+)
+
+const testClientEntryContent = "console.log('client entry');"
+
+var (
+	inlineScriptRegex = regexp.MustCompile(`(?s)<script([^>]*)>(.*?)</script>`)
+	inlineStyleRegex  = regexp.MustCompile(`(?s)<style[^>]*>(.*?)</style>`)
+	nonceRegex        = regexp.MustCompile(`'nonce-([^']+)'`)
+)
+
+// A test River with a critical CSS file, inline head blocks, and a client
+// entry in its public dir.
+func newCSPTestRiver(t *testing.T, pattern string) *testRiver {
+	t.Helper()
+	tr := newTestRiver(t, pattern)
+	tr.addLoader(pattern, func(*mux.NestedReqData) (any, error) { return "csp", nil })
+	tr.h.GetDefaultHeadBlocks = func(*http.Request) ([]*htmlutil.Element, error) {
+		return []*htmlutil.Element{
+			{Tag: "script", InnerHTML: "window.inlineHeadScript = true;"},
+			{Tag: "script", Attributes: map[string]string{"src": "/external.js"}, InnerHTML: "ignored"},
+			{Tag: "style", InnerHTML: "body { color: red; }"},
+		}, nil
+	}
+
+	distStatic := filepath.Join(tr.dir, "dist", "static")
+	for path, content := range map[string]string{
+		filepath.Join(distStatic, "internal", "critical.css"):             "html { margin: 0; }",
+		filepath.Join(distStatic, "assets", "public", testClientEntryOut): testClientEntryContent,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tr
+}
+
+func testIntegrity(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256-" + bytesutil.ToBase64(sum[:])
+}
+
+func TestCSPHashesMatchRenderedElements(t *testing.T) {
+	tr := newCSPTestRiver(t, "/csp-test")
+
+	handler := csp.NewMiddleware(csp.Opts{
+		Directives: csp.Directives{"default-src": {"'self'"}},
+		UseNonce:   true,
+	})(tr.h.GetUIHandler(tr.nestedRouter, tr.coreDataTask))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/csp-test", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want: 200", w.Code)
+	}
+
+	policy := w.Header().Get("Content-Security-Policy")
+	directives := map[string]string{}
+	for _, d := range strings.Split(policy, ";") {
+		name, sources, _ := strings.Cut(strings.TrimSpace(d), " ")
+		directives[name] = sources
+	}
+	body := w.Body.String()
+
+	var inlineScripts int
+	for _, m := range inlineScriptRegex.FindAllStringSubmatch(body, -1) {
+		if strings.Contains(m[1], "src=") {
+			continue
+		}
+		inlineScripts++
+		if hash := "'" + testIntegrity(m[2]) + "'"; !strings.Contains(directives["script-src"], hash) {
+			t.Errorf("script-src %q is missing %s for inline script %q", directives["script-src"], hash, m[2])
+		}
+	}
+	// The head block script and the SSR script
+	if inlineScripts != 2 {
+		t.Errorf("expected 2 inline scripts, found %d in:\n%s", inlineScripts, body)
+	}
+
+	styles := inlineStyleRegex.FindAllStringSubmatch(body, -1)
+	// The head block style and the critical CSS
+	if len(styles) != 2 {
+		t.Errorf("expected 2 inline styles, found %d in:\n%s", len(styles), body)
+	}
+	for _, m := range styles {
+		if hash := "'" + testIntegrity(m[1]) + "'"; !strings.Contains(directives["style-src"], hash) {
+			t.Errorf("style-src %q is missing %s for inline style %q", directives["style-src"], hash, m[1])
+		}
+	}
+
+	nonce := nonceRegex.FindStringSubmatch(directives["script-src"])
+	if nonce == nil {
+		t.Fatalf("expected a nonce in script-src %q", directives["script-src"])
+	}
+	if !strings.Contains(body, `nonce="`+nonce[1]+`"`) {
+		t.Errorf("expected the client entry script to carry the policy's nonce")
+	}
+}
+
+func TestGetClientEntryScript(t *testing.T) {
+	tr := newCSPTestRiver(t, "/csp-entry-test")
+	integrity := testIntegrity(testClientEntryContent)
+
+	for _, nonce := range []string{"", "abc123"} {
+		script, err := tr.h.getClientEntryScript(nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(script)
+		for _, want := range []string{`type="module"`, `src="/public/` + testClientEntryOut + `"`, `integrity="` + integrity + `"`} {
+			if !strings.Contains(s, want) {
+				t.Errorf("nonce %q: expected %s to contain %s", nonce, s, want)
+			}
+		}
+		if hasNonce := strings.Contains(s, "nonce="); hasNonce != (nonce != "") {
+			t.Errorf("nonce %q: unexpected nonce attribute in %s", nonce, s)
+		}
+		if nonce != "" && !strings.Contains(s, `nonce="`+nonce+`"`) {
+			t.Errorf("expected %s to contain the nonce", s)
+		}
+	}
+
+	// Rendered into the document too
+	w := tr.serve(httptest.NewRequest("GET", "/csp-entry-test", nil))
+	if !strings.Contains(w.Body.String(), `integrity="`+integrity+`"`) {
+		t.Errorf("expected the document to render the client entry with its integrity:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,141 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/response"
+	"github.com/sjc5/river/kit/tasks"
+	"github.com/sjc5/river/kit/validate"
+)
+
+// NewFormActionsRouter returns a router suitable for River.FormActions. Its
+// task handler inputs are parsed from the request's form body (urlencoded
+// or multipart) and validated via kit/validate, so validation failures
+// surface as 400 action errors. Register POST task handlers on it at the
+// same paths as the UI routes whose forms submit to them.
+func NewFormActionsRouter(tasksRegistry *tasks.Registry) *mux.Router {
+	return mux.NewRouter(&mux.Options{
+		TasksRegistry: tasksRegistry,
+		MarshalInput:  validate.FormInto,
+	})
+}
+
+type formActionResult struct {
+	data json.RawMessage
+	err  *LoaderError
+}
+
+// Runs the form action matching a POST to a UI route path. If the second
+// return value is true, a response has already been written (a redirect,
+// or an error that prevents re-rendering) and the caller must return.
+// Otherwise, the caller should re-render the route (re-running its
+// loaders) with the returned result attached.
+func (h *River[C]) runFormAction(w http.ResponseWriter, r *http.Request, isJSONRequest bool) (*formActionResult, bool) {
+	res := response.New(w)
+
+	if h.FormActions == nil || !getIsFormRequest(r) || !h.FormActions.HasMatch(r) {
+		res.MethodNotAllowed()
+		return nil, true
+	}
+
+	captured := newCapturedResponse()
+	h.FormActions.ServeHTTP(captured, r)
+
+	if location := captured.getRedirectLocation(); location != "" {
+		copyHeaders(w.Header(), captured.header, nil)
+		if isJSONRequest {
+			// The River client can't meaningfully follow server redirects
+			// from fetch POSTs, so always hand it a client redirect
+			w.Header().Del("Location")
+			w.Header().Set(response.ClientRedirectHeader, location)
+			w.WriteHeader(http.StatusOK)
+		} else {
+			// Post/Redirect/Get, regardless of the code the action used
+			w.Header().Set("Location", location)
+			w.WriteHeader(http.StatusSeeOther)
+		}
+		return nil, true
+	}
+
+	// Keep side effects like cookies, but not anything describing the
+	// action's own body, which is replaced by the re-rendered route
+	copyHeaders(w.Header(), captured.header, []string{"Content-Type", "Content-Length", "Etag"})
+
+	result := &formActionResult{}
+
+	switch {
+	case captured.status >= 500:
+		Log.Error("Form action failed", "path", r.URL.Path, "status", captured.status)
+		result.err = NewLoaderError(captured.status, "", nil)
+	case captured.status >= 400:
+		result.err = NewLoaderError(captured.status, strings.TrimSpace(captured.body.String()), nil)
+	case strings.HasPrefix(captured.header.Get("Content-Type"), "application/json"):
+		result.data = captured.body.Bytes()
+	}
+
+	return result, false
+}
+
+func getIsFormRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+func copyHeaders(dst, src http.Header, skip []string) {
+outer:
+	for k, vs := range src {
+		for _, s := range skip {
+			if http.CanonicalHeaderKey(s) == k {
+				continue outer
+			}
+		}
+		for _, v := range vs {
+			dst.Add(k, v)
+		}
+	}
+}
+
+// A minimal http.ResponseWriter that buffers everything, so that the
+// result of a form action can be inspected before anything is written.
+type capturedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newCapturedResponse() *capturedResponse {
+	return &capturedResponse{header: make(http.Header)}
+}
+
+func (c *capturedResponse) Header() http.Header { return c.header }
+
+func (c *capturedResponse) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *capturedResponse) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return c.body.Write(b)
+}
+
+func (c *capturedResponse) getRedirectLocation() string {
+	if location := c.header.Get(response.ClientRedirectHeader); location != "" {
+		return location
+	}
+	if c.status >= 300 && c.status < 400 {
+		return c.header.Get("Location")
+	}
+	return ""
+}
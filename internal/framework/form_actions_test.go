@@ -0,0 +1,167 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/response"
+)
+
+type formActionInput struct {
+	Name string `json:"name"`
+}
+
+func (i *formActionInput) Validate() error {
+	if i.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+func newFormActionsTestRiver(t *testing.T) *testRiver {
+	t.Helper()
+	tr := newTestRiver(t, "/fa-test")
+	tr.addLoader("/fa-test", func(*mux.NestedReqData) (any, error) { return "loader", nil })
+
+	actions := NewFormActionsRouter(tr.tasks)
+	mux.RegisterTaskHandler(actions, "POST", "/fa-test", mux.TaskHandlerFromFunc(tr.tasks,
+		func(rd *mux.ReqData[formActionInput]) (map[string]string, error) {
+			if rd.Input().Name == "redirect" {
+				rd.ResponseProxy().Redirect(rd.Request(), "/fa-test/done")
+				return nil, nil
+			}
+			rd.ResponseProxy().SetCookie(&http.Cookie{Name: "flash", Value: "saved"})
+			return map[string]string{"greeting": "hi " + rd.Input().Name}, nil
+		},
+	))
+	tr.h.FormActions = actions
+	return tr
+}
+
+func newURLEncodedRequest(target string, values url.Values) *http.Request {
+	r := httptest.NewRequest("POST", target, strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func newMultipartRequest(t *testing.T, target string, values map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range values {
+		if err := mw.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", target, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func decodeUIRouteOutput(t *testing.T, w *httptest.ResponseRecorder) UIRouteOutput {
+	t.Helper()
+	var output UIRouteOutput
+	if err := json.Unmarshal(w.Body.Bytes(), &output); err != nil {
+		t.Fatalf("could not decode route output %q: %v", w.Body.String(), err)
+	}
+	return output
+}
+
+func TestFormActions(t *testing.T) {
+	tr := newFormActionsTestRiver(t)
+
+	t.Run("urlencoded submission", func(t *testing.T) {
+		w := tr.serve(newURLEncodedRequest("/fa-test", url.Values{"name": {"Ann"}}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want: 200", w.Code)
+		}
+		body := w.Body.String()
+		if !strings.HasPrefix(body, "<!DOCTYPE html>") || !strings.Contains(body, "hi Ann") {
+			t.Errorf("expected the route to be re-rendered with the action data, got %s", body)
+		}
+		if !strings.Contains(w.Header().Get("Set-Cookie"), "flash=saved") {
+			t.Errorf("expected the action's cookie to be kept, got headers %v", w.Header())
+		}
+	})
+
+	t.Run("multipart submission", func(t *testing.T) {
+		w := tr.serve(newMultipartRequest(t, "/fa-test?river-json=1", map[string]string{"name": "Bob"}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want: 200", w.Code)
+		}
+		output := decodeUIRouteOutput(t, w)
+		if string(output.ActionData) != `{"greeting":"hi Bob"}` || output.ActionError != nil {
+			t.Errorf("unexpected action result: data %s, error %v", output.ActionData, output.ActionError)
+		}
+		if data, _ := json.Marshal(output.LoadersData); string(data) != `["loader"]` {
+			t.Errorf("expected fresh loader data, got %s", data)
+		}
+	})
+
+	t.Run("redirect after post", func(t *testing.T) {
+		w := tr.serve(newURLEncodedRequest("/fa-test", url.Values{"name": {"redirect"}}))
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/fa-test/done" {
+			t.Errorf("expected a 303 to /fa-test/done, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("expected no body, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("client redirect after post", func(t *testing.T) {
+		w := tr.serve(newURLEncodedRequest("/fa-test?river-json=1", url.Values{"name": {"redirect"}}))
+		if w.Code != http.StatusOK || w.Header().Get(response.ClientRedirectHeader) != "/fa-test/done" {
+			t.Errorf("expected a client redirect to /fa-test/done, got %d %v", w.Code, w.Header())
+		}
+		if w.Header().Get("Location") != "" {
+			t.Error("expected no Location header on a client redirect")
+		}
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		w := tr.serve(newURLEncodedRequest("/fa-test?river-json=1", url.Values{"name": {""}}))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want: 400", w.Code)
+		}
+		output := decodeUIRouteOutput(t, w)
+		if output.ActionError == nil || output.ActionError.Status != http.StatusBadRequest || output.ActionData != nil {
+			t.Errorf("expected a 400 action error, got %+v", output.ActionError)
+		}
+		if data, _ := json.Marshal(output.LoadersData); string(data) != `["loader"]` {
+			t.Errorf("expected the route to be re-rendered with loader data, got %s", data)
+		}
+
+		w = tr.serve(newURLEncodedRequest("/fa-test", url.Values{"name": {""}}))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "x.actionError = {\"status\":400") {
+			t.Errorf("expected the document to be re-rendered with the action error, got %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("non-form submission", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/fa-test", strings.NewReader(`{"name":"Ann"}`))
+		r.Header.Set("Content-Type", "application/json")
+		if w := tr.serve(r); w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want: 405", w.Code)
+		}
+	})
+
+	t.Run("no matching action", func(t *testing.T) {
+		tr := newTestRiver(t, "/fa-test-none")
+		tr.addLoader("/fa-test-none", func(*mux.NestedReqData) (any, error) { return "loader", nil })
+		tr.h.FormActions = NewFormActionsRouter(tr.tasks)
+		if w := tr.serve(newURLEncodedRequest("/fa-test-none", url.Values{"name": {"Ann"}})); w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want: 405", w.Code)
+		}
+	})
+}
@@ -1,6 +1,11 @@
 package framework
 
-import "github.com/sjc5/river/kit/matcher"
+import (
+	"html/template"
+
+	"github.com/sjc5/river/kit/htmlutil"
+	"github.com/sjc5/river/kit/matcher"
+)
 
 func (h *River[C]) getDeps(_matches []*matcher.Match) []string {
 	var deps []string
@@ -41,3 +46,83 @@ func (h *River[C]) getCSSBundles(deps []string) []string {
 	}
 	return cssBundles
 }
+
+// SRI values of the given public files (e.g., deps and CSS bundles), keyed
+// by filename, for the client to set on the elements it adds. Empty in dev.
+func (h *River[C]) getIntegrity(filenameLists ...[]string) map[string]string {
+	if h._isDev {
+		return nil
+	}
+	integrity := make(map[string]string)
+	for _, filenames := range filenameLists {
+		for _, filename := range filenames {
+			if x := h.Kiruna.GetPublicFileIntegrity(filename); x != "" {
+				integrity[filename] = x
+			}
+		}
+	}
+	return integrity
+}
+
+// Names of the Kiruna CSS bundles attached to the matched patterns, deduped,
+// outermost first
+func (h *River[C]) getKirunaCSSBundles(_matches []*matcher.Match) []string {
+	if len(h.CSSBundlesByPattern) == 0 {
+		return nil
+	}
+	var names []string
+	seen := make(map[string]struct{})
+	for _, match := range _matches {
+		for _, name := range h.CSSBundlesByPattern[match.OriginalPattern()] {
+			if _, ok := seen[name]; !ok {
+				names = append(names, name)
+				seen[name] = struct{}{}
+			}
+		}
+	}
+	return names
+}
+
+func (h *River[C]) getKirunaCSSBundleFilenames(names []string) []string {
+	filenames := make([]string, 0, len(names))
+	for _, name := range names {
+		if x := h.Kiruna.GetCSSBundleFilename(name); x != "" {
+			filenames = append(filenames, x)
+		}
+	}
+	return filenames
+}
+
+// Critical bundles are inlined. Others are linked, tagged the same way the
+// client tags the CSS bundles it adds, so that it doesn't add them again.
+func (h *River[C]) getKirunaCSSBundleElements(names []string) (template.HTML, error) {
+	var result template.HTML
+	for _, name := range names {
+		if h.Kiruna.GetCSSBundleIsCritical(name) {
+			result += "\n" + h.Kiruna.GetCSSBundleStyleElement(name)
+			continue
+		}
+		filename := h.Kiruna.GetCSSBundleFilename(name)
+		if filename == "" {
+			continue
+		}
+		el := &htmlutil.Element{
+			Tag: "link",
+			Attributes: map[string]string{
+				"rel":                   "stylesheet",
+				"href":                  h.Kiruna.GetCSSBundleURL(name),
+				"id":                    h.Kiruna.GetCSSBundleElementID(name),
+				"data-river-css-bundle": filename,
+			},
+		}
+		if integrity := h.Kiruna.GetPublicFileIntegrity(filename); integrity != "" {
+			el.Attributes["integrity"] = integrity
+		}
+		rendered, err := htmlutil.RenderElement(el)
+		if err != nil {
+			return "", err
+		}
+		result += "\n" + rendered
+	}
+	return result, nil
+}
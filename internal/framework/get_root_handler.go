@@ -10,6 +10,8 @@ import (
 	"github.com/sjc5/river/kit/cryptoutil"
 	"github.com/sjc5/river/kit/genericsutil"
 	"github.com/sjc5/river/kit/headblocks"
+	"github.com/sjc5/river/kit/htmlutil"
+	"github.com/sjc5/river/kit/middleware/csp"
 	"github.com/sjc5/river/kit/mux"
 	"github.com/sjc5/river/kit/response"
 	"github.com/sjc5/river/kit/tasks"
@@ -27,16 +29,29 @@ func (h *River[C]) GetUIHandler(nestedRouter *mux.NestedRouter, coreDataTask *Co
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		res := response.New(w)
 
-		uiRouteData, err := h.getUIRouteData(w, r, nestedRouter, coreDataTask)
+		isJSONRequest := GetIsJSONRequest(r)
+
+		// Non-GET requests to UI routes are only valid as form actions, in
+		// which case the route is re-rendered (with fresh loader data) below
+		var actionResult *formActionResult
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			var done bool
+			if actionResult, done = h.runFormAction(w, r, isJSONRequest); done {
+				return
+			}
+		}
+
+		// Deferrable loaders are only streamed for full document requests.
+		// Client-side navigations (JSON requests) always wait on everything.
+		uiRouteData, err := h.getUIRouteData(w, r, nestedRouter, coreDataTask, !isJSONRequest)
 
 		if err != nil && isErrNotFound(err) {
-			// __TODO -- optionally client redirect to a specific 404 page
 			Log.Error("Not found", "path", r.URL.Path)
 			res.NotFound()
 			return
 		}
 
-		if uiRouteData.didRedirect {
+		if err == nil && (uiRouteData.didRedirect || uiRouteData.didErr) {
 			return
 		}
 
@@ -48,6 +63,18 @@ func (h *River[C]) GetUIHandler(nestedRouter *mux.NestedRouter, coreDataTask *Co
 
 		routeData := uiRouteData.uiRouteOutput
 
+		if actionResult != nil {
+			routeData.ActionData = actionResult.data
+			routeData.ActionError = actionResult.err
+		}
+
+		// Full documents get attached Kiruna CSS bundles in their head. On
+		// client-side navigations, the client loads any it doesn't have yet.
+		if isJSONRequest {
+			routeData.CSSBundles = append(routeData.CSSBundles, h.getKirunaCSSBundleFilenames(routeData.kirunaCSSBundles)...)
+		}
+		routeData.Integrity = h.getIntegrity(routeData.Deps, routeData.CSSBundles)
+
 		// Used for eTag handling for both JSON and HTTP responses
 		jsonBytes, err := json.Marshal(routeData)
 		if err != nil {
@@ -63,8 +90,18 @@ func (h *River[C]) GetUIHandler(nestedRouter *mux.NestedRouter, coreDataTask *Co
 			routeDataHash = cryptoutil.Sha256Hash(jsonBytes)
 		}
 
-		if GetIsJSONRequest(r) {
-			if h.Kiruna.GetRiverAutoETags() {
+		// Derived from the outermost failing loader, if any
+		status := routeData.getStatus()
+		if uiRouteData.routeType == RouteTypes.NotFound {
+			status = http.StatusNotFound
+		}
+		if routeData.ActionError != nil {
+			status = routeData.ActionError.Status
+		}
+		useETags := h.Kiruna.GetRiverAutoETags() && status == http.StatusOK && actionResult == nil
+
+		if isJSONRequest {
+			if useETags {
 				etag = fmt.Sprintf(`"json-%x"`, routeDataHash)
 				res.SetETag(etag)
 				if response.ShouldReturn304Conservative(r, etag) {
@@ -73,7 +110,7 @@ func (h *River[C]) GetUIHandler(nestedRouter *mux.NestedRouter, coreDataTask *Co
 				}
 			}
 
-			res.JSONBytes(jsonBytes)
+			writeBytesWithStatus(&res, "application/json", status, jsonBytes)
 			return
 		}
 
@@ -81,6 +118,7 @@ func (h *River[C]) GetUIHandler(nestedRouter *mux.NestedRouter, coreDataTask *Co
 		var ssrScript *template.HTML
 		var ssrScriptSha256Hash string
 		var headElements template.HTML
+		var ssrBody template.HTML
 
 		eg.Go(func() error {
 			he, err := headblocksInstance.Render(&headblocks.HeadBlocks{
@@ -95,6 +133,12 @@ func (h *River[C]) GetUIHandler(nestedRouter *mux.NestedRouter, coreDataTask *Co
 			headElements += "\n" + h.Kiruna.GetCriticalCSSStyleElement()
 			headElements += "\n" + h.Kiruna.GetStyleSheetLinkElement()
 
+			bundleElements, err := h.getKirunaCSSBundleElements(routeData.kirunaCSSBundles)
+			if err != nil {
+				return fmt.Errorf("error getting CSS bundle elements: %v", err)
+			}
+			headElements += bundleElements
+
 			return nil
 		})
 
@@ -108,6 +152,11 @@ func (h *River[C]) GetUIHandler(nestedRouter *mux.NestedRouter, coreDataTask *Co
 			return nil
 		})
 
+		eg.Go(func() error {
+			ssrBody = h.renderSSRBody(r, routeData)
+			return nil
+		})
+
 		if err := eg.Wait(); err != nil {
 			Log.Error(fmt.Sprintf("Error getting route data: %v\n", err))
 			res.InternalServerError()
@@ -126,15 +175,26 @@ func (h *River[C]) GetUIHandler(nestedRouter *mux.NestedRouter, coreDataTask *Co
 			return
 		}
 
+		// Collects everything inline rendered above into the CSP, if any
+		cspBuilder := csp.GetBuilder(r)
+		h.addCSPHashes(cspBuilder, routeData, ssrScriptSha256Hash)
+		cspNonce := cspBuilder.Nonce()
+
 		rootTemplateData["RiverHeadBlocks"] = headElements
 		rootTemplateData["RiverSSRScript"] = ssrScript
 		rootTemplateData["RiverSSRScriptSha256Hash"] = ssrScriptSha256Hash
 		rootTemplateData["RiverRootID"] = "river-root"
+		rootTemplateData["RiverSSRBody"] = ssrBody
+		rootTemplateData["RiverCSPNonce"] = cspNonce
 
 		if !h._isDev {
-			rootTemplateData["RiverBodyScripts"] = template.HTML(
-				fmt.Sprintf(`<script type="module" src="/public/%s"></script>`, h._clientEntryOut),
-			)
+			bodyScripts, err := h.getClientEntryScript(cspNonce)
+			if err != nil {
+				Log.Error(fmt.Sprintf("Error getting client entry script: %v\n", err))
+				res.InternalServerError()
+				return
+			}
+			rootTemplateData["RiverBodyScripts"] = bodyScripts
 		} else {
 			opts := viteutil.ToDevScriptsOptions{ClientEntry: h._clientEntrySrc}
 			if UIVariant(h.Kiruna.GetRiverUIVariant()) == UIVariants.React {
@@ -159,9 +219,20 @@ func (h *River[C]) GetUIHandler(nestedRouter *mux.NestedRouter, coreDataTask *Co
 		if err != nil {
 			Log.Error(fmt.Sprintf("Error executing template: %v\n", err))
 			res.InternalServerError()
+			return
 		}
 
-		if h.Kiruna.GetRiverAutoETags() {
+		// The route data hash does not cover deferred loader data, so streamed
+		// responses never get an ETag.
+		if len(uiRouteData.deferredResults) > 0 {
+			err = h.streamUIResponse(w, r, status, buf.Bytes(), routeData.DeferredIndices, uiRouteData.deferredResults, cspNonce)
+			if err != nil {
+				Log.Error(fmt.Sprintf("Error streaming response: %v\n", err))
+			}
+			return
+		}
+
+		if useETags {
 			etag = fmt.Sprintf(`"html-%x"`, routeDataHash)
 			res.SetETag(etag)
 			if response.ShouldReturn304Conservative(r, etag) {
@@ -170,10 +241,36 @@ func (h *River[C]) GetUIHandler(nestedRouter *mux.NestedRouter, coreDataTask *Co
 			}
 		}
 
-		res.HTMLBytes(buf.Bytes())
+		writeBytesWithStatus(&res, "text/html", status, buf.Bytes())
 	})
 }
 
+// Same as res.JSONBytes / res.HTMLBytes, except that a non-200 status
+// is written after the content type header is set.
+func writeBytesWithStatus(res *response.Response, contentType string, status int, bytes []byte) {
+	res.SetHeader("Content-Type", contentType)
+	if status != http.StatusOK {
+		res.SetStatus(status)
+	}
+	res.Writer.Write(bytes)
+}
+
+// The client entry module script, with its integrity (and the CSP nonce, if
+// any) attached.
+func (h *River[C]) getClientEntryScript(cspNonce string) (template.HTML, error) {
+	el := &htmlutil.Element{
+		Tag:        "script",
+		Attributes: map[string]string{"type": "module", "src": "/public/" + h._clientEntryOut},
+	}
+	if integrity := h.Kiruna.GetPublicFileIntegrity(h._clientEntryOut); integrity != "" {
+		el.Attributes["integrity"] = integrity
+	}
+	if cspNonce != "" {
+		el.Attributes["nonce"] = cspNonce
+	}
+	return htmlutil.RenderElement(el)
+}
+
 func GetIsJSONRequest(r *http.Request) bool {
 	return r.URL.Query().Get("river-json") == "1"
 }
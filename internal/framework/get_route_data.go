@@ -1,6 +1,7 @@
 package framework
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -22,9 +23,9 @@ var (
 type UIRouteOutput struct {
 	BuildID string `json:"buildID,omitempty"`
 
-	CoreData    any     `json:"coreData,omitempty"`
-	LoadersData []any   `json:"loadersData,omitempty"`
-	LoadersErrs []error `json:"loadersErrs,omitempty"`
+	CoreData    any            `json:"coreData,omitempty"`
+	LoadersData []any          `json:"loadersData,omitempty"`
+	LoadersErrs []*LoaderError `json:"loadersErrs,omitempty"`
 
 	Params      mux.Params  `json:"params,omitempty"`
 	SplatValues SplatValues `json:"splatValues,omitempty"`
@@ -33,24 +34,44 @@ type UIRouteOutput struct {
 	Meta  []*htmlutil.Element `json:"metaHeadBlocks,omitempty"`
 	Rest  []*htmlutil.Element `json:"restHeadBlocks,omitempty"`
 
-	// LoadersErrorMessages []string            `json:"loadersErrorMessages,omitempty"`
-	OutermostErrorIndex int `json:"outermostErrorIndex,omitempty"`
+	// -1 if no loaders failed. Not omitted when empty, because 0 is meaningful.
+	OutermostErrorIndex int `json:"outermostErrorIndex"`
 
 	ImportURLs []string `json:"importURLs,omitempty"`
 	ExportKeys []string `json:"exportKeys,omitempty"`
 	Deps       []string `json:"deps,omitempty"`
 	CSSBundles []string `json:"cssBundles,omitempty"`
 
+	// SRI values of the deps and CSS bundles, keyed by filename (prod only)
+	Integrity map[string]string `json:"integrity,omitempty"`
+
 	ViteDevURL string `json:"viteDevURL,omitempty"`
+
+	// Indices of loaders whose data was not yet available when the
+	// response began, and which will be streamed in afterwards.
+	DeferredIndices []int `json:"deferredIndices,omitempty"`
+
+	// Only set when the route is re-rendered in response to a form action.
+	// ActionData is the action's JSON output, and ActionError is set if the
+	// action failed (e.g., with a 400 for invalid form input).
+	ActionData  json.RawMessage `json:"actionData,omitempty"`
+	ActionError *LoaderError    `json:"actionError,omitempty"`
+
+	// Names of the Kiruna CSS bundles attached to the matched patterns
+	kirunaCSSBundles []string
 }
 
 type getUIRouteDataOutput struct {
-	uiRouteOutput *UIRouteOutput
-	didRedirect   bool
+	uiRouteOutput   *UIRouteOutput
+	didRedirect     bool
+	didErr          bool
+	routeType       RouteType
+	deferredResults []*mux.NestedTasksResult
 }
 
 func (h *River[C]) getUIRouteData(w http.ResponseWriter, r *http.Request,
 	nestedRouter *mux.NestedRouter, coreDataTask *tasks.RegisteredTask[genericsutil.None, C],
+	allowDeferrals bool,
 ) (*getUIRouteDataOutput, error) {
 
 	tasksCtx := nestedRouter.TasksRegistry().NewCtxFromRequest(r)
@@ -68,7 +89,12 @@ func (h *River[C]) getUIRouteData(w http.ResponseWriter, r *http.Request,
 		return nil
 	})
 
-	uiRoutesData := h.getUIRoutesData(w, r, nestedRouter, tasksCtx)
+	uiRoutesData := h.getUIRoutesData(w, r, nestedRouter, tasksCtx, allowDeferrals, RouteTypes.Loader)
+	if !uiRoutesData.found && h.NotFoundPattern != "" {
+		uiRoutesData = h.getUIRoutesData(
+			w, toNotFoundRequest(r, h.NotFoundPattern), nestedRouter, tasksCtx, allowDeferrals, RouteTypes.NotFound,
+		)
+	}
 	if !uiRoutesData.found {
 		return nil, errNotFound
 	}
@@ -77,6 +103,12 @@ func (h *River[C]) getUIRouteData(w http.ResponseWriter, r *http.Request,
 		return &getUIRouteDataOutput{didRedirect: true}, nil
 	}
 
+	// A task middleware or loader set an error status on its response
+	// proxy, which has already been written to the response.
+	if uiRoutesData.didErr {
+		return &getUIRouteDataOutput{didErr: true}, nil
+	}
+
 	err := eg.Wait()
 	if err != nil {
 		Log.Error(err.Error())
@@ -112,7 +144,7 @@ func (h *River[C]) getUIRouteData(w http.ResponseWriter, r *http.Request,
 
 		CoreData:    coreData,
 		LoadersData: activePathData.LoadersData,
-		LoadersErrs: activePathData.LoadersErrs,
+		LoadersErrs: toPublicLoaderErrors(activePathData.LoadersErrs),
 
 		Params:      activePathData.Params,
 		SplatValues: activePathData.SplatValues,
@@ -121,7 +153,6 @@ func (h *River[C]) getUIRouteData(w http.ResponseWriter, r *http.Request,
 		Meta:  headBlocks.Meta,
 		Rest:  headBlocks.Rest,
 
-		// LoadersErrorMessages: activePathData.LoadersErrMsgs,
 		OutermostErrorIndex: activePathData.OutermostErrorIndex,
 
 		ImportURLs: activePathData.ImportURLs,
@@ -130,7 +161,24 @@ func (h *River[C]) getUIRouteData(w http.ResponseWriter, r *http.Request,
 		CSSBundles: h.getCSSBundles(activePathData.Deps),
 
 		ViteDevURL: h.getViteDevURL(),
+
+		DeferredIndices: activePathData.DeferredIndices,
+
+		kirunaCSSBundles: activePathData.kirunaCSSBundles,
 	}
 
-	return &getUIRouteDataOutput{uiRouteOutput: uiRouteOutput}, nil
+	return &getUIRouteDataOutput{
+		uiRouteOutput:   uiRouteOutput,
+		routeType:       uiRoutesData.routeType,
+		deferredResults: activePathData.deferredResults,
+	}, nil
+}
+
+// Returns a shallow clone of the request whose path points at the
+// not-found pattern, so that it can be matched like any other route.
+func toNotFoundRequest(r *http.Request, notFoundPattern string) *http.Request {
+	r2 := r.Clone(r.Context())
+	r2.URL.Path = notFoundPattern
+	r2.URL.RawPath = ""
+	return r2
 }
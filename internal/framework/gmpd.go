@@ -3,6 +3,7 @@ package framework
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/sjc5/river/kit/htmlutil"
 	"github.com/sjc5/river/kit/lru"
@@ -15,9 +16,8 @@ import (
 type SplatValues []string
 
 type ActivePathData struct {
-	HeadBlocks  []*htmlutil.Element
-	LoadersData []any
-	// LoadersErrMsgs      []string
+	HeadBlocks          []*htmlutil.Element
+	LoadersData         []any
 	LoadersErrs         []error
 	ImportURLs          []string
 	ExportKeys          []string
@@ -25,6 +25,10 @@ type ActivePathData struct {
 	SplatValues         SplatValues
 	Params              mux.Params
 	Deps                []string
+	DeferredIndices     []int
+
+	deferredResults  []*mux.NestedTasksResult
+	kirunaCSSBundles []string
 }
 
 type gmpdItem struct {
@@ -35,7 +39,8 @@ type gmpdItem struct {
 	ImportURLs     []string
 	ExportKeys     []string
 	Deps           []string
-	routeType      RouteType
+
+	kirunaCSSBundles []string
 }
 
 var gmpdCache = lru.NewCache[string, *gmpdItem](500_000)
@@ -45,11 +50,18 @@ type uiRoutesData struct {
 	didRedirect    bool
 	didErr         bool
 	found          bool
+	routeType      RouteType
 }
 
-// Returns nil if no match is found
+// Returns nil if no match is found. If allowDeferrals is true, loaders
+// belonging to deferrable nested routes are not waited on, and their
+// pending results are returned on the ActivePathData instead. The
+// routeType is either RouteTypes.Loader, or RouteTypes.NotFound when
+// rendering the configured not-found pattern (in place of a missing route,
+// or because it was requested directly).
 func (h *River[C]) getUIRoutesData(
 	w http.ResponseWriter, r *http.Request, nestedRouter *mux.NestedRouter, tasksCtx *tasks.TasksCtx,
+	allowDeferrals bool, routeType RouteType,
 ) *uiRoutesData {
 
 	realPath := matcher.StripTrailingSlash(r.URL.Path)
@@ -57,15 +69,23 @@ func (h *River[C]) getUIRoutesData(
 		realPath = "/"
 	}
 
+	// Host params are merged into the cached match results, so when there
+	// are any (or the nested router matches hosts at all), the same path
+	// can match differently from one host to the next
+	cacheKey := realPath
+	if nestedRouter.HasHostPattern() || len(mux.GetHostParams(r)) > 0 {
+		cacheKey = strings.ToLower(r.Host) + realPath
+	}
+
 	var itemIsCached bool
 	var item *gmpdItem
 
-	if item, itemIsCached = gmpdCache.Get(realPath); !itemIsCached {
+	if item, itemIsCached = gmpdCache.Get(cacheKey); !itemIsCached {
 		item = new(gmpdItem)
 		_match_results, found := mux.FindNestedMatches(nestedRouter, r)
 		if !found {
 			item.found = false
-			gmpdCache.Set(realPath, item, true)
+			gmpdCache.Set(cacheKey, item, true)
 			return &uiRoutesData{}
 		}
 		item.found = true
@@ -74,7 +94,6 @@ func (h *River[C]) getUIRoutesData(
 		_matches_len := len(_matches)
 		item.SplatValues = _match_results.SplatValues
 		item.Params = _match_results.Params
-		item.routeType = RouteTypes.Loader
 		item.ImportURLs = make([]string, 0, _matches_len)
 		item.ExportKeys = make([]string, 0, _matches_len)
 		for _, path := range _matches {
@@ -90,25 +109,36 @@ func (h *River[C]) getUIRoutesData(
 			item.ExportKeys = append(item.ExportKeys, foundPath.ExportKey)
 		}
 		item.Deps = h.getDeps(_matches)
-		gmpdCache.Set(realPath, item, false)
+		item.kirunaCSSBundles = h.getKirunaCSSBundles(_matches)
+		gmpdCache.Set(cacheKey, item, false)
 	}
 
 	if !item.found {
 		return &uiRoutesData{}
 	}
 
-	_tasks_results := mux.RunNestedTasks(nestedRouter, tasksCtx, r, item._match_results)
+	// The not-found pattern is a 404 even when requested directly
+	if routeType == RouteTypes.Loader && h.getIsNotFoundMatch(item._match_results) {
+		routeType = RouteTypes.NotFound
+	}
+
+	var _tasks_results *mux.NestedTasksResults
+	if allowDeferrals {
+		_tasks_results = mux.RunNestedTasksWithDeferrals(nestedRouter, tasksCtx, r, item._match_results)
+	} else {
+		_tasks_results = mux.RunNestedTasks(nestedRouter, tasksCtx, r, item._match_results)
+	}
 
 	_merged_response_proxy := response.MergeProxyResponses(_tasks_results.ResponseProxies...)
 	if _merged_response_proxy != nil {
 		_merged_response_proxy.ApplyToResponseWriter(w, r)
 
 		if _merged_response_proxy.IsError() {
-			return &uiRoutesData{didErr: true, found: true}
+			return &uiRoutesData{didErr: true, found: true, routeType: routeType}
 		}
 
 		if _merged_response_proxy.IsRedirect() {
-			return &uiRoutesData{didRedirect: true, found: true}
+			return &uiRoutesData{didRedirect: true, found: true, routeType: routeType}
 		}
 	}
 
@@ -118,15 +148,23 @@ func (h *River[C]) getUIRoutesData(
 	}
 
 	loadersData := make([]any, numberOfLoaders)
-	// loadersErrMsgs := make([]string, numberOfLoaders)
 	loadersErrs := make([]error, numberOfLoaders)
 
+	var deferredIndices []int
+	var deferredResults []*mux.NestedTasksResult
+
 	if numberOfLoaders > 0 {
 		for i, result := range _tasks_results.Slice {
-			if result != nil {
-				loadersData[i] = result.Data()
-				loadersErrs[i] = result.Err()
+			if result == nil {
+				continue
+			}
+			if result.IsDeferred() {
+				deferredIndices = append(deferredIndices, i)
+				deferredResults = append(deferredResults, result)
+				continue
 			}
+			loadersData[i] = result.Data()
+			loadersErrs[i] = result.Err()
 		}
 	}
 
@@ -141,20 +179,31 @@ func (h *River[C]) getUIRoutesData(
 		}
 	}
 
+	// Indexed by loader so that slicing at the error boundary lines up
 	loadersHeadBlocks := make([][]*htmlutil.Element, numberOfLoaders)
-	for _, _response_proxy := range _tasks_results.ResponseProxies {
-		if _response_proxy != nil {
-			loadersHeadBlocks = append(loadersHeadBlocks, _response_proxy.GetHeadElements())
+	for i, _response_proxy := range _tasks_results.ResponseProxies {
+		if _response_proxy != nil && i < numberOfLoaders {
+			loadersHeadBlocks[i] = _response_proxy.GetHeadElements()
 		}
 	}
 
-	if thereAreErrors && item.routeType == RouteTypes.Loader {
+	if thereAreErrors && routeType == RouteTypes.Loader {
 		headBlocksDoubleSlice := loadersHeadBlocks[:outermostErrorIndex]
 		headblocks := make([]*htmlutil.Element, 0, len(headBlocksDoubleSlice))
 		for _, slice := range headBlocksDoubleSlice {
 			headblocks = append(headblocks, slice...)
 		}
 
+		// Any deferred loaders below the error boundary will never be rendered
+		var keptDeferredIndices []int
+		var keptDeferredResults []*mux.NestedTasksResult
+		for i, idx := range deferredIndices {
+			if idx < outermostErrorIndex {
+				keptDeferredIndices = append(keptDeferredIndices, idx)
+				keptDeferredResults = append(keptDeferredResults, deferredResults[i])
+			}
+		}
+
 		apd := &ActivePathData{
 			LoadersData:         loadersData[:outermostErrorIndex],
 			ImportURLs:          item.ImportURLs[:outermostErrorIndex+1],
@@ -162,12 +211,14 @@ func (h *River[C]) getUIRoutesData(
 			OutermostErrorIndex: outermostErrorIndex,
 			SplatValues:         item.SplatValues,
 			Params:              item.Params,
-			// LoadersErrMsgs:      loadersErrs[:outermostErrorIndex+1],
-			LoadersErrs: loadersErrs[:outermostErrorIndex+1],
-			HeadBlocks:  headblocks,
+			LoadersErrs:         loadersErrs[:outermostErrorIndex+1],
+			HeadBlocks:          headblocks,
+			DeferredIndices:     keptDeferredIndices,
+			deferredResults:     keptDeferredResults,
+			kirunaCSSBundles:    item.kirunaCSSBundles,
 		}
 
-		return &uiRoutesData{activePathData: apd, found: true}
+		return &uiRoutesData{activePathData: apd, found: true, routeType: routeType}
 	}
 
 	headblocks := make([]*htmlutil.Element, 0, len(loadersHeadBlocks))
@@ -183,10 +234,19 @@ func (h *River[C]) getUIRoutesData(
 		SplatValues:         item.SplatValues,
 		Params:              item.Params,
 		Deps:                item.Deps,
-		// LoadersErrMsgs:      loadersErrMsgs,
-		LoadersErrs: loadersErrs,
-		HeadBlocks:  headblocks,
+		LoadersErrs:         loadersErrs,
+		HeadBlocks:          headblocks,
+		DeferredIndices:     deferredIndices,
+		deferredResults:     deferredResults,
+		kirunaCSSBundles:    item.kirunaCSSBundles,
 	}
 
-	return &uiRoutesData{activePathData: apd, found: true}
+	return &uiRoutesData{activePathData: apd, found: true, routeType: routeType}
+}
+
+func (h *River[C]) getIsNotFoundMatch(_match_results *matcher.FindNestedMatchesResults) bool {
+	if h.NotFoundPattern == "" || _match_results == nil || len(_match_results.Matches) == 0 {
+		return false
+	}
+	return _match_results.Matches[len(_match_results.Matches)-1].OriginalPattern() == h.NotFoundPattern
 }
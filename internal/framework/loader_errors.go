@@ -0,0 +1,93 @@
+package framework
+
+import (
+	"errors"
+	"net/http"
+)
+
+// LoaderError is a typed error that UI loaders can return in order to
+// control what the client sees when they fail. Status is used to derive
+// the HTTP status of the response (when the loader is the outermost
+// failing loader), Message is a public-safe message, and Data is an
+// optional JSON-serializable payload. Any non-LoaderError error returned
+// from a loader is treated as a 500 with a generic message, and is only
+// ever logged on the server.
+type LoaderError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+
+	cause error
+}
+
+func (e *LoaderError) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *LoaderError) Unwrap() error {
+	return e.cause
+}
+
+// NewLoaderError creates a new LoaderError. If message is empty, the
+// standard status text for the provided status is used. The optional
+// cause is kept for server-side logging and errors.Is/As, but is never
+// serialized.
+func NewLoaderError(status int, message string, data any, cause ...error) *LoaderError {
+	if status < 400 || status > 599 {
+		status = http.StatusInternalServerError
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	e := &LoaderError{Status: status, Message: message, Data: data}
+	if len(cause) > 0 {
+		e.cause = cause[0]
+	}
+	return e
+}
+
+// Converts an arbitrary loader error into its public representation.
+// Returns nil if err is nil.
+func toPublicLoaderError(err error) *LoaderError {
+	if err == nil {
+		return nil
+	}
+	var loaderErr *LoaderError
+	if errors.As(err, &loaderErr) {
+		return NewLoaderError(loaderErr.Status, loaderErr.Message, loaderErr.Data)
+	}
+	return NewLoaderError(http.StatusInternalServerError, "", nil)
+}
+
+func toPublicLoaderErrors(errs []error) []*LoaderError {
+	if len(errs) == 0 {
+		return nil
+	}
+	publicErrs := make([]*LoaderError, len(errs))
+	var hasAny bool
+	for i, err := range errs {
+		publicErrs[i] = toPublicLoaderError(err)
+		if publicErrs[i] != nil {
+			hasAny = true
+		}
+	}
+	if !hasAny {
+		return nil
+	}
+	return publicErrs
+}
+
+// Returns the status of the outermost failing loader, or 200 if no
+// loaders failed.
+func (o *UIRouteOutput) getStatus() int {
+	if o.OutermostErrorIndex < 0 || o.OutermostErrorIndex >= len(o.LoadersErrs) {
+		return http.StatusOK
+	}
+	if e := o.LoadersErrs[o.OutermostErrorIndex]; e != nil {
+		return e.Status
+	}
+	return http.StatusOK
+}
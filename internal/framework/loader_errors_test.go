@@ -0,0 +1,89 @@
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestToPublicLoaderError(t *testing.T) {
+	secret := errors.New("db password is hunter2")
+
+	tests := []struct {
+		name string
+		err  error
+		want *LoaderError
+	}{
+		{"nil", nil, nil},
+		{"plain error", secret, &LoaderError{Status: 500, Message: "Internal Server Error"}},
+		{"loader error", NewLoaderError(404, "no such user", map[string]any{"id": "1"}),
+			&LoaderError{Status: 404, Message: "no such user", Data: map[string]any{"id": "1"}}},
+		{"loader error with cause", NewLoaderError(403, "", nil, secret), &LoaderError{Status: 403, Message: "Forbidden"}},
+		{"wrapped loader error", fmt.Errorf("loading: %w", NewLoaderError(409, "conflict", nil)),
+			&LoaderError{Status: 409, Message: "conflict"}},
+		{"invalid status", NewLoaderError(200, "", nil), &LoaderError{Status: 500, Message: "Internal Server Error"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := toPublicLoaderError(tt.err)
+			if tt.want == nil {
+				if got != nil {
+					t.Fatalf("toPublicLoaderError() = %+v, want: nil", got)
+				}
+				return
+			}
+			if got.Status != tt.want.Status || got.Message != tt.want.Message || fmt.Sprint(got.Data) != fmt.Sprint(tt.want.Data) {
+				t.Errorf("toPublicLoaderError() = %+v, want: %+v", got, tt.want)
+			}
+			if got.Unwrap() != nil {
+				t.Error("expected the public error not to carry its cause")
+			}
+			serialized, err := json.Marshal(got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(serialized), "hunter2") {
+				t.Errorf("internal error message leaked to the client: %s", serialized)
+			}
+		})
+	}
+}
+
+func TestToPublicLoaderErrors(t *testing.T) {
+	if toPublicLoaderErrors([]error{nil, nil}) != nil {
+		t.Error("expected nil when no loaders failed")
+	}
+	errs := toPublicLoaderErrors([]error{nil, errors.New("boom")})
+	if len(errs) != 2 || errs[0] != nil || errs[1].Status != http.StatusInternalServerError {
+		t.Errorf("unexpected public errors: %+v", errs)
+	}
+}
+
+func TestUIRouteOutputGetStatus(t *testing.T) {
+	notFound := NewLoaderError(404, "", nil)
+	badRequest := NewLoaderError(400, "", nil)
+
+	tests := []struct {
+		name   string
+		output UIRouteOutput
+		want   int
+	}{
+		{"no errors", UIRouteOutput{OutermostErrorIndex: -1}, 200},
+		{"outermost error", UIRouteOutput{OutermostErrorIndex: 0, LoadersErrs: []*LoaderError{notFound, badRequest}}, 404},
+		{"inner error", UIRouteOutput{OutermostErrorIndex: 1, LoadersErrs: []*LoaderError{nil, badRequest}}, 400},
+		{"index out of range", UIRouteOutput{OutermostErrorIndex: 2, LoadersErrs: []*LoaderError{notFound}}, 200},
+		{"nil error at index", UIRouteOutput{OutermostErrorIndex: 0, LoadersErrs: []*LoaderError{nil}}, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.output.getStatus(); got != tt.want {
+				t.Errorf("getStatus() = %d, want: %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -63,6 +63,36 @@ type River[C any] struct {
 	GetDefaultHeadBlocks func(r *http.Request) ([]*htmlutil.Element, error)
 	GetRootTemplateData  func(r *http.Request) (map[string]any, error)
 
+	// Optional. A static nested route pattern (e.g., "/_not-found") to render,
+	// along with its matching parent layouts, whenever a request matches no
+	// UI route. It goes through the normal template, head block and SSR
+	// pipeline, and responds with a 404 status for both HTML and JSON
+	// requests (including requests for the pattern itself). If empty,
+	// unmatched requests get an empty 404 response.
+	NotFoundPattern string
+
+	// Optional. Renders the body HTML of UI routes on the server for full
+	// document requests, exposed to the root template as {{.RiverSSRBody}}.
+	// See NodeSSRRenderer for a reference implementation.
+	SSRRenderer SSRRenderer
+
+	// Optional. Handles native form submissions (urlencoded or multipart
+	// POSTs) to UI route paths, so that forms work without JavaScript.
+	// Create it with NewFormActionsRouter. If an action redirects, the
+	// response is a 303 (or a client redirect for the River client).
+	// Otherwise, the route is re-rendered with fresh loader data and the
+	// action's output (or error) attached as actionData (or actionError).
+	// The UI handler must be mounted for POST requests as well as GETs.
+	FormActions *mux.Router
+
+	// Optional. Attaches Kiruna's named CSS bundles (see Core.CSSBundles in
+	// your Kiruna config) to nested route patterns, so that pages only ship
+	// the CSS they use. A page gets the bundles attached to every pattern
+	// it matches (i.e., including its parent layouts), outermost first.
+	// Critical bundles are inlined into full document responses, and all
+	// bundles are linked as stylesheets on client-side navigations.
+	CSSBundlesByPattern map[string][]string
+
 	mu                 sync.RWMutex
 	_isDev             bool
 	_paths             map[string]*Path
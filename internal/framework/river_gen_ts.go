@@ -2,7 +2,10 @@ package framework
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/sjc5/river/kit/matcher"
 	"github.com/sjc5/river/kit/mux"
 	"github.com/sjc5/river/kit/rpc"
 	"github.com/sjc5/river/kit/tsgen"
@@ -43,12 +46,18 @@ func (h *River[C]) GenerateTypeScript(opts *TSGenOptions) (string, error) {
 			ArbitraryProperties: map[string]any{
 				base.DiscriminatorStr:     pattern,
 				base.CategoryPropertyName: "loader",
+				"hrefTemplate":            opts.UIRouter.NormalizedPattern(pattern),
+			},
+			PhantomTypes: map[string]AdHocType{
+				"phantomOutputType": {TypeInstance: mux.None{}},
+			},
+			PhantomTSTypes: map[string]string{
+				"phantomParamsType": paramsTSType(opts.UIRouter.ParamSpecs(pattern)),
 			},
 		}
 		if loader != nil {
-			item.PhantomTypes = map[string]AdHocType{
-				"phantomOutputType": {TypeInstance: loader.O()},
-			}
+			item.ArbitraryProperties["hrefTemplate"] = loader.URLBuilder().Template()
+			item.PhantomTypes["phantomOutputType"] = AdHocType{TypeInstance: loader.O()}
 		}
 		collection = append(collection, item)
 		seen[pattern] = struct{}{}
@@ -65,16 +74,20 @@ func (h *River[C]) GenerateTypeScript(opts *TSGenOptions) (string, error) {
 			ArbitraryProperties: map[string]any{
 				base.DiscriminatorStr:     path.Pattern,
 				base.CategoryPropertyName: "loader",
+				"hrefTemplate":            opts.UIRouter.NormalizedPattern(path.Pattern),
 			},
 			PhantomTypes: map[string]AdHocType{
 				"phantomOutputType": {TypeInstance: mux.None{}},
 			},
+			PhantomTSTypes: map[string]string{
+				"phantomParamsType": paramsTSType(opts.UIRouter.ParamSpecs(path.Pattern)),
+			},
 		}
 		collection = append(collection, item)
 		seen[path.Pattern] = struct{}{}
 	}
 
-	hasQueries, hasMutations := false, false
+	hasQueries, hasMutations, hasStreams, hasWebSockets := false, false, false, false
 
 	for _, action := range allActions {
 		method, pattern := action.Method(), action.Pattern()
@@ -83,23 +96,38 @@ func (h *River[C]) GenerateTypeScript(opts *TSGenOptions) (string, error) {
 		if !isQuery && !isMutation {
 			continue
 		}
-		hasQueries = hasQueries || isQuery
-		hasMutations = hasMutations || isMutation
+		// Stream and websocket handlers get their own categories regardless
+		// of method. A stream's output type is the type of each emitted
+		// value, and a websocket's input and output types are the types of
+		// client-to-server and server-to-client messages, respectively.
+		isStream, isWebSocket := action.IsStream(), action.IsWebSocket()
+		isPlain := !isStream && !isWebSocket
+		hasStreams = hasStreams || isStream
+		hasWebSockets = hasWebSockets || isWebSocket
+		hasQueries = hasQueries || (isQuery && isPlain)
+		hasMutations = hasMutations || (isMutation && isPlain)
 		categoryPropertyName := "query"
-		if isMutation {
+		switch {
+		case isStream:
+			categoryPropertyName = "stream"
+		case isWebSocket:
+			categoryPropertyName = "websocket"
+		case isMutation:
 			categoryPropertyName = "mutation"
 		}
 		item := tsgen.CollectionItem{
 			ArbitraryProperties: map[string]any{
 				base.DiscriminatorStr:     pattern,
 				base.CategoryPropertyName: categoryPropertyName,
+				"hrefTemplate":            action.URLBuilder().Template(),
 			},
-		}
-		if action != nil {
-			item.PhantomTypes = map[string]AdHocType{
+			PhantomTypes: map[string]AdHocType{
 				"phantomInputType":  {TypeInstance: action.I()},
 				"phantomOutputType": {TypeInstance: action.O()},
-			}
+			},
+			PhantomTSTypes: map[string]string{
+				"phantomParamsType": paramsTSType(action.ParamSpecs()),
+			},
 		}
 		collection = append(collection, item)
 	}
@@ -114,6 +142,7 @@ func (h *River[C]) GenerateTypeScript(opts *TSGenOptions) (string, error) {
 			KeyUnionTypeName:     "LoaderPattern",
 			InputUnionTypeName:   "",
 			OutputUnionTypeName:  "LoaderOutput",
+			ParamsUnionTypeName:  "LoaderParams",
 			SkipInput:            true,
 		})
 	}
@@ -126,6 +155,7 @@ func (h *River[C]) GenerateTypeScript(opts *TSGenOptions) (string, error) {
 			KeyUnionTypeName:     "QueryPattern",
 			InputUnionTypeName:   "QueryInput",
 			OutputUnionTypeName:  "QueryOutput",
+			ParamsUnionTypeName:  "QueryParams",
 		})
 	}
 	if hasMutations {
@@ -137,11 +167,40 @@ func (h *River[C]) GenerateTypeScript(opts *TSGenOptions) (string, error) {
 			KeyUnionTypeName:     "MutationPattern",
 			InputUnionTypeName:   "MutationInput",
 			OutputUnionTypeName:  "MutationOutput",
+			ParamsUnionTypeName:  "MutationParams",
+		})
+	}
+	if hasStreams {
+		categories = append(categories, rpc.CategorySpecificOptions{
+			BaseOptions:          base,
+			CategoryValue:        "stream",
+			ItemTypeNameSingular: "Stream",
+			ItemTypeNamePlural:   "Streams",
+			KeyUnionTypeName:     "StreamPattern",
+			InputUnionTypeName:   "StreamInput",
+			OutputUnionTypeName:  "StreamOutput",
+			ParamsUnionTypeName:  "StreamParams",
+		})
+	}
+	if hasWebSockets {
+		categories = append(categories, rpc.CategorySpecificOptions{
+			BaseOptions:          base,
+			CategoryValue:        "websocket",
+			ItemTypeNameSingular: "WebSocket",
+			ItemTypeNamePlural:   "WebSockets",
+			KeyUnionTypeName:     "WebSocketPattern",
+			InputUnionTypeName:   "WebSocketInput",
+			OutputUnionTypeName:  "WebSocketOutput",
+			ParamsUnionTypeName:  "WebSocketParams",
 		})
 	}
 
 	extraTSToUse := rpc.BuildFromCategories(categories)
 
+	if len(allLoaders) > 0 {
+		extraTSToUse += "\n" + hrefTSCode
+	}
+
 	if opts.ExtraTSCode != "" {
 		extraTSToUse += "\n" + opts.ExtraTSCode
 	}
@@ -149,6 +208,9 @@ func (h *River[C]) GenerateTypeScript(opts *TSGenOptions) (string, error) {
 	adHocTypes := append(opts.AdHocTypes, &AdHocType{
 		TypeInstance: h._get_core_data_zero(),
 		TSTypeName:   "CoreData",
+	}, &AdHocType{
+		TypeInstance: LoaderError{},
+		TSTypeName:   "LoaderError",
 	})
 
 	return tsgen.GenerateTSContent(tsgen.Opts{
@@ -158,3 +220,69 @@ func (h *River[C]) GenerateTypeScript(opts *TSGenOptions) (string, error) {
 		ExtraTSCode:       extraTSToUse,
 	})
 }
+
+// A typed URL builder for UI routes, mirroring mux.URLBuilder. Unknown
+// patterns and missing required params are type errors.
+const hrefTSCode = `type HrefArgs<P extends LoaderPattern> = LoaderParams<P> extends Record<string, never>
+	? [params?: LoaderParams<P>, splatValues?: ReadonlyArray<string>]
+	: [params: LoaderParams<P>, splatValues?: ReadonlyArray<string>];
+
+export function href<P extends LoaderPattern>(pattern: P, ...[params, splatValues]: HrefArgs<P>): string {
+	const route = routes.find((r) => r._type === "loader" && r.pattern === pattern);
+	if (!route) {
+		throw new Error(` + "`" + `href: unknown pattern "${pattern}"` + "`" + `);
+	}
+	const parts: Array<string> = [];
+	for (const seg of route.hrefTemplate.split("/").slice(1)) {
+		if (seg === "*") {
+			for (const value of splatValues ?? []) {
+				parts.push(encodeURIComponent(value));
+			}
+		} else if (seg.startsWith(":")) {
+			const name = seg.slice(1).replace(/<.*>/, "").replace(/\?$/, "");
+			const value = (params as Record<string, string> | undefined)?.[name];
+			if (value === undefined || value === "") {
+				if (seg.endsWith("?")) {
+					continue;
+				}
+				throw new Error(` + "`" + `href: missing param "${name}" for pattern "${pattern}"` + "`" + `);
+			}
+			parts.push(encodeURIComponent(value));
+		} else {
+			parts.push(seg);
+		}
+	}
+	return "/" + parts.join("/");
+}
+`
+
+// Returns the TypeScript type of a pattern's params object. Params are always
+// strings at runtime, so constrained params are narrowed with template literal
+// types rather than converted.
+func paramsTSType(specs []matcher.ParamSpec) string {
+	if len(specs) == 0 {
+		return "Record<string, never>"
+	}
+	var sb strings.Builder
+	sb.WriteString("{ ")
+	for i, spec := range specs {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(strconv.Quote(spec.Name))
+		if spec.Optional {
+			sb.WriteString("?")
+		}
+		sb.WriteString(": ")
+		switch spec.Constraint {
+		case matcher.ConstraintInt:
+			sb.WriteString("`${number}`")
+		case matcher.ConstraintUUID:
+			sb.WriteString("`${string}-${string}-${string}-${string}-${string}`")
+		default:
+			sb.WriteString("string")
+		}
+	}
+	sb.WriteString(" }")
+	return sb.String()
+}
@@ -7,6 +7,7 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"strings"
 
 	"github.com/sjc5/river/kit/mux"
 )
@@ -42,6 +43,24 @@ func (h *River[C]) validateAndDecorateNestedRouter(nestedRouter *mux.NestedRoute
 			Log.Error(fmt.Sprintf("Warning: no client-side route found for pattern %v.", pattern))
 		}
 	}
+	for pattern, names := range h.CSSBundlesByPattern {
+		if !nestedRouter.IsRegistered(pattern) {
+			Log.Error(fmt.Sprintf("Warning: CSS bundles attached to unregistered pattern %v.", pattern))
+		}
+		for _, name := range names {
+			if !h.Kiruna.GetCSSBundleExists(name) {
+				Log.Error(fmt.Sprintf("Warning: no CSS bundle named %v (attached to pattern %v).", name, pattern))
+			}
+		}
+	}
+	if h.NotFoundPattern != "" {
+		if strings.ContainsAny(h.NotFoundPattern, ":*") {
+			panic(fmt.Sprintf("not found pattern must be static: %v", h.NotFoundPattern))
+		}
+		if !nestedRouter.IsRegistered(h.NotFoundPattern) {
+			Log.Error(fmt.Sprintf("Warning: not found pattern %v is not registered.", h.NotFoundPattern))
+		}
+	}
 }
 
 func PrettyPrintFS(fsys fs.FS) error {
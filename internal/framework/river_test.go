@@ -0,0 +1,205 @@
+package framework
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sjc5/river/kiruna"
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+const testRootTemplate = `<!DOCTYPE html>
+<html>
+	<head>
+		{{.RiverHeadBlocks}}
+		{{.RiverSSRScript}}
+	</head>
+	<body>
+		<div id="{{.RiverRootID}}">{{.RiverSSRBody}}</div>
+		{{.RiverBodyScripts}}
+	</body>
+</html>`
+
+const testClientEntryOut = "river_client_entry_1234.js"
+
+// A River instance in prod mode, backed by a real Kiruna instance whose
+// dist dir lives in a temp dir. Route matches are cached by path across
+// River instances (see gmpdCache), so each test should use its own paths.
+type testRiver struct {
+	h            *River[any]
+	tasks        *tasks.Registry
+	nestedRouter *mux.NestedRouter
+	coreDataTask *CoreDataTask[any]
+	dir          string
+}
+
+func newTestRiver(t *testing.T, patterns ...string) *testRiver {
+	t.Helper()
+
+	dir := t.TempDir()
+	privateDir := filepath.Join(dir, "dist", "static", "assets", "private")
+	for _, d := range []string{
+		filepath.Join(privateDir, "river_out"),
+		filepath.Join(dir, "dist", "static", "assets", "public"),
+		filepath.Join(dir, "dist", "static", "internal"),
+	} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := make(map[string]*Path, len(patterns))
+	for _, pattern := range patterns {
+		paths[pattern] = &Path{Pattern: pattern, SrcPath: "routes.tsx", OutPath: "routes_1234.js", ExportKey: "default"}
+	}
+
+	mustWriteJSON(t, filepath.Join(dir, "kiruna.json"), map[string]any{
+		"Core": map[string]any{
+			"DistDir":       filepath.Join(dir, "dist"),
+			"MainAppEntry":  "./cmd/app",
+			"CSSEntryFiles": map[string]any{"Critical": "critical.css"},
+			"StaticAssetDirs": map[string]any{
+				"Private": filepath.Join(dir, "private"),
+				"Public":  filepath.Join(dir, "public"),
+			},
+		},
+		"River": map[string]any{"HTMLTemplateLocation": "entry.go.html"},
+	})
+	mustWriteJSON(t, filepath.Join(privateDir, "river_out", RiverPathsStageTwoJSONFileName), PathsFile{
+		Stage:          "two",
+		BuildID:        "test-build",
+		Paths:          paths,
+		ClientEntryOut: testClientEntryOut,
+	})
+	if err := os.WriteFile(filepath.Join(privateDir, "entry.go.html"), []byte(testRootTemplate), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	k := kiruna.New(&kiruna.Config{
+		ConfigFile:     filepath.Join(dir, "kiruna.json"),
+		DistFS:         os.DirFS(filepath.Join(dir, "dist")),
+		EmbedDirective: "static",
+		Logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+
+	h := &River[any]{Kiruna: k}
+	if err := h.initInner(false); err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRegistry := tasks.NewRegistry()
+	return &testRiver{
+		h:            h,
+		tasks:        tasksRegistry,
+		nestedRouter: mux.NewNestedRouter(&mux.NestedOptions{TasksRegistry: tasksRegistry}),
+		coreDataTask: tasks.Register(tasksRegistry, func(*tasks.ArgNoInput) (any, error) { return "core", nil }),
+		dir:          dir,
+	}
+}
+
+func (tr *testRiver) addLoader(pattern string, loader func(rd *mux.NestedReqData) (any, error)) *mux.NestedRoute[any] {
+	return mux.RegisterNestedTaskHandler(tr.nestedRouter, pattern, mux.TaskHandlerFromFunc(tr.tasks, loader))
+}
+
+func (tr *testRiver) serve(r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	tr.h.GetUIHandler(tr.nestedRouter, tr.coreDataTask).ServeHTTP(w, r)
+	return w
+}
+
+func mustWriteJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNotFoundPattern(t *testing.T) {
+	tr := newTestRiver(t, "/nf-test-home", "/nf-test-404")
+	tr.h.NotFoundPattern = "/nf-test-404"
+	tr.addLoader("/nf-test-home", func(*mux.NestedReqData) (any, error) { return "home", nil })
+	tr.addLoader("/nf-test-404", func(*mux.NestedReqData) (any, error) { return "not found", nil })
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		data   string
+	}{
+		{"matched path", "/nf-test-home", http.StatusOK, `["home"]`},
+		{"unmatched path", "/nf-test-missing", http.StatusNotFound, `["not found"]`},
+		{"not-found pattern", "/nf-test-404", http.StatusNotFound, `["not found"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, isJSON := range []bool{false, true} {
+				target := tt.path
+				if isJSON {
+					target += "?river-json=1"
+				}
+				w := tr.serve(httptest.NewRequest("GET", target, nil))
+				if w.Code != tt.status {
+					t.Errorf("GET %s: status = %d, want: %d", target, w.Code, tt.status)
+				}
+				if !isJSON {
+					continue
+				}
+				var output UIRouteOutput
+				if err := json.Unmarshal(w.Body.Bytes(), &output); err != nil {
+					t.Fatal(err)
+				}
+				if data, _ := json.Marshal(output.LoadersData); string(data) != tt.data {
+					t.Errorf("GET %s: loaders data = %s, want: %s", target, data, tt.data)
+				}
+			}
+		})
+	}
+}
+
+func TestHostParamsAreNotSharedAcrossHosts(t *testing.T) {
+	tr := newTestRiver(t, "/host-test")
+	tr.nestedRouter = mux.NewNestedRouter(&mux.NestedOptions{TasksRegistry: tr.tasks, Host: ":tenant.example.com"})
+	tr.addLoader("/host-test", func(rd *mux.NestedReqData) (any, error) { return rd.Params()["tenant"], nil })
+
+	get := func(host string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/host-test?river-json=1", nil)
+		r.Host = host
+		return tr.serve(r)
+	}
+
+	// Each host twice, so that the second request of each is served from
+	// the match cache
+	for _, tenant := range []string{"a", "b", "a", "b"} {
+		w := get(tenant + ".example.com")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want: 200", tenant, w.Code)
+		}
+		var output UIRouteOutput
+		if err := json.Unmarshal(w.Body.Bytes(), &output); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := json.Marshal(output.LoadersData); string(data) != `["`+tenant+`"]` {
+			t.Errorf("%s: loaders data = %s, want: [%q]", tenant, data, tenant)
+		}
+	}
+
+	// A host mismatch must not hide the path from matching hosts
+	if w := get("example.org"); w.Code == http.StatusOK {
+		t.Errorf("expected no match for a foreign host, got status %d", w.Code)
+	}
+	if w := get("c.example.com"); w.Code != http.StatusOK {
+		t.Errorf("expected a match after a foreign host's miss, got status %d", w.Code)
+	}
+}
@@ -0,0 +1,214 @@
+package framework
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/response"
+)
+
+type RoutesDebugOptions struct {
+	UIRouter      *mux.NestedRouter
+	ActionsRouter *mux.Router
+}
+
+type UIRouteInfo struct {
+	mux.RouteInfo
+	SrcPath   string `json:"srcPath,omitempty"`
+	ExportKey string `json:"exportKey,omitempty"`
+}
+
+type RoutesReport struct {
+	UIRoutes         []UIRouteInfo   `json:"uiRoutes"`
+	ActionRoutes     []mux.RouteInfo `json:"actionRoutes"`
+	FormActionRoutes []mux.RouteInfo `json:"formActionRoutes,omitempty"`
+}
+
+type RouteMatchReport struct {
+	UI         *mux.MatchExplanation `json:"ui,omitempty"`
+	Actions    *mux.MatchExplanation `json:"actions,omitempty"`
+	FormAction *mux.MatchExplanation `json:"formAction,omitempty"`
+}
+
+// Lists every UI, action and form action route, including each UI route's
+// client source file and export key.
+func (h *River[C]) DescribeRoutes(opts *RoutesDebugOptions) *RoutesReport {
+	report := &RoutesReport{UIRoutes: []UIRouteInfo{}, ActionRoutes: []mux.RouteInfo{}}
+
+	if opts.UIRouter != nil {
+		h.mu.RLock()
+		for _, info := range opts.UIRouter.Describe() {
+			uiInfo := UIRouteInfo{RouteInfo: info}
+			if path, ok := h._paths[info.Pattern]; ok {
+				uiInfo.SrcPath = path.SrcPath
+				uiInfo.ExportKey = path.ExportKey
+			}
+			report.UIRoutes = append(report.UIRoutes, uiInfo)
+		}
+		h.mu.RUnlock()
+	}
+	if opts.ActionsRouter != nil {
+		report.ActionRoutes = opts.ActionsRouter.Describe()
+	}
+	if h.FormActions != nil {
+		report.FormActionRoutes = h.FormActions.Describe()
+	}
+
+	return report
+}
+
+// Reports which UI, action and form action routes would handle a request
+// with the given method and URL, and why. The URL must either be absolute or
+// start with a slash. UI routes are only considered for GETs, and form
+// actions only for POSTs.
+func (h *River[C]) ExplainRouteMatch(opts *RoutesDebugOptions, method, host, rawURL string) (*RouteMatchReport, error) {
+	r, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request to match: %w", err)
+	}
+	if r.URL.Host == "" && !strings.HasPrefix(r.URL.Path, "/") {
+		return nil, fmt.Errorf("invalid URL to match %q: must be absolute or start with a slash", rawURL)
+	}
+	if host != "" {
+		r.Host = host
+	}
+
+	report := &RouteMatchReport{}
+	if opts.UIRouter != nil && method == http.MethodGet {
+		report.UI = opts.UIRouter.Explain(r)
+	}
+	if opts.ActionsRouter != nil {
+		report.Actions = opts.ActionsRouter.Explain(r)
+	}
+	if h.FormActions != nil && method == http.MethodPost {
+		report.FormAction = h.FormActions.Explain(r)
+	}
+	return report, nil
+}
+
+// GetRoutesDebugHandler returns a handler that lists every route as JSON
+// (see DescribeRoutes). With a "match" query param (e.g.,
+// "?match=/users/123&method=GET"), it instead explains which routes would
+// match that URL (see ExplainRouteMatch), using the request's own host, or
+// responds with a 400 if the URL or method is invalid. Only available in dev mode; otherwise, it always responds with a 404.
+func (h *River[C]) GetRoutesDebugHandler(opts *RoutesDebugOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := response.New(w)
+
+		if !h._isDev {
+			res.NotFound()
+			return
+		}
+
+		res.SetHeader("Cache-Control", "no-store")
+
+		query := r.URL.Query()
+		if url := query.Get("match"); url != "" {
+			method := query.Get("method")
+			if method == "" {
+				method = http.MethodGet
+			}
+			report, err := h.ExplainRouteMatch(opts, method, r.Host, url)
+			if err != nil {
+				res.BadRequest(err.Error())
+				return
+			}
+			res.JSON(report)
+			return
+		}
+
+		res.JSON(h.DescribeRoutes(opts))
+	})
+}
+
+// RunRoutesCommand is a command-line entry point for route introspection,
+// for use from your app's main function (e.g., when os.Args[1] is
+// "routes", pass os.Args[2:]). By default, it prints a table of every
+// route. Flags:
+//
+//	-json            print JSON instead of a table
+//	-match <url>     explain which routes would match the URL
+//	-method <method> the method to match with (defaults to GET)
+//	-host <host>     the host to match with, for host-routed routers
+func (h *River[C]) RunRoutesCommand(opts *RoutesDebugOptions, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("routes", flag.ContinueOnError)
+	flags.SetOutput(out)
+	jsonFlag := flags.Bool("json", false, "print JSON instead of a table")
+	matchFlag := flags.String("match", "", "explain which routes would match this URL")
+	methodFlag := flags.String("method", http.MethodGet, "the method to match with")
+	hostFlag := flags.String("host", "", "the host to match with")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *matchFlag != "" {
+		report, err := h.ExplainRouteMatch(opts, *methodFlag, *hostFlag, *matchFlag)
+		if err != nil {
+			return err
+		}
+		if *jsonFlag {
+			return writeIndentedJSON(out, report)
+		}
+		for _, section := range []struct {
+			name        string
+			explanation *mux.MatchExplanation
+		}{
+			{"UI", report.UI}, {"ACTIONS", report.Actions}, {"FORM ACTIONS", report.FormAction},
+		} {
+			if section.explanation != nil {
+				fmt.Fprintf(out, "%s\n%s\n", section.name, section.explanation)
+			}
+		}
+		return nil
+	}
+
+	report := h.DescribeRoutes(opts)
+	if *jsonFlag {
+		return writeIndentedJSON(out, report)
+	}
+
+	uiInfos := make([]mux.RouteInfo, 0, len(report.UIRoutes))
+	for _, uiInfo := range report.UIRoutes {
+		info := uiInfo.RouteInfo
+		if uiInfo.SrcPath != "" {
+			info.Handler = fmt.Sprintf("%s (client: %s#%s)", orNone(info.Handler), uiInfo.SrcPath, uiInfo.ExportKey)
+		}
+		uiInfos = append(uiInfos, info)
+	}
+
+	for _, section := range []struct {
+		name  string
+		infos []mux.RouteInfo
+	}{
+		{"UI ROUTES", uiInfos}, {"ACTION ROUTES", report.ActionRoutes}, {"FORM ACTION ROUTES", report.FormActionRoutes},
+	} {
+		if len(section.infos) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "%s\n", section.name)
+		if err := mux.WriteRoutesTable(out, section.infos); err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
+
+func writeIndentedJSON(out io.Writer, v any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(v)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
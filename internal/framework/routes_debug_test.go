@@ -0,0 +1,203 @@
+package framework
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sjc5/river/kit/mux"
+)
+
+func newRoutesDebugTestRiver(t *testing.T) (*testRiver, *RoutesDebugOptions) {
+	t.Helper()
+	tr := newTestRiver(t, "/rd-test/:id")
+	tr.h._isDev = true
+	tr.addLoader("/rd-test/:id", func(*mux.NestedReqData) (any, error) { return "user", nil })
+
+	actions := mux.NewRouter(&mux.Options{TasksRegistry: tr.tasks})
+	mux.RegisterHandlerFunc(actions, "POST", "/api/rd-test", func(http.ResponseWriter, *http.Request) {})
+
+	return tr, &RoutesDebugOptions{UIRouter: tr.nestedRouter, ActionsRouter: actions}
+}
+
+func TestDescribeRoutes(t *testing.T) {
+	tr, opts := newRoutesDebugTestRiver(t)
+
+	report := tr.h.DescribeRoutes(opts)
+	if len(report.UIRoutes) != 1 || len(report.ActionRoutes) != 1 || report.FormActionRoutes != nil {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	ui := report.UIRoutes[0]
+	if ui.Pattern != "/rd-test/:id" || ui.SrcPath != "routes.tsx" || ui.ExportKey != "default" {
+		t.Errorf("unexpected UI route: %+v", ui)
+	}
+	if action := report.ActionRoutes[0]; action.Method != "POST" || action.Pattern != "/api/rd-test" {
+		t.Errorf("unexpected action route: %+v", action)
+	}
+
+	empty := tr.h.DescribeRoutes(&RoutesDebugOptions{})
+	if empty.UIRoutes == nil || empty.ActionRoutes == nil {
+		t.Error("expected empty (non-nil) route lists")
+	}
+}
+
+func TestExplainRouteMatch(t *testing.T) {
+	tr, opts := newRoutesDebugTestRiver(t)
+
+	report, err := tr.h.ExplainRouteMatch(opts, "GET", "", "/rd-test/123?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.UI == nil || !report.UI.Matched || report.UI.Params["id"] != "123" {
+		t.Errorf("expected the UI route to match, got %+v", report.UI)
+	}
+	if report.Actions == nil || report.Actions.Matched {
+		t.Errorf("expected no action match, got %+v", report.Actions)
+	}
+
+	report, err = tr.h.ExplainRouteMatch(opts, "POST", "", "http://example.com/api/rd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.UI != nil || report.Actions == nil || !report.Actions.Matched {
+		t.Errorf("expected only the action route to be considered and match, got %+v", report)
+	}
+
+	for _, tt := range []struct{ method, url string }{
+		{"GET", "rd-test/123"},
+		{"GET", "%zz"},
+		{"BAD METHOD", "/rd-test/123"},
+	} {
+		if _, err := tr.h.ExplainRouteMatch(opts, tt.method, "", tt.url); err == nil {
+			t.Errorf("ExplainRouteMatch(%q, %q): expected an error", tt.method, tt.url)
+		}
+	}
+}
+
+func TestGetRoutesDebugHandler(t *testing.T) {
+	tr, opts := newRoutesDebugTestRiver(t)
+	handler := tr.h.GetRoutesDebugHandler(opts)
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	t.Run("describe", func(t *testing.T) {
+		w := serve("/__routes")
+		if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+			t.Fatalf("unexpected response: %d %v", w.Code, w.Header())
+		}
+		var report RoutesReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if len(report.UIRoutes) != 1 || len(report.ActionRoutes) != 1 {
+			t.Errorf("unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("match", func(t *testing.T) {
+		w := serve("/__routes?match=/api/rd-test&method=POST")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want: 200", w.Code)
+		}
+		var report RouteMatchReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.Actions == nil || report.Actions.MatchedPattern != "/api/rd-test" {
+			t.Errorf("unexpected match report: %+v", report)
+		}
+	})
+
+	t.Run("invalid match", func(t *testing.T) {
+		for _, target := range []string{"/__routes?match=rd-test/1", "/__routes?match=/rd-test/1&method=a%20b"} {
+			if w := serve(target); w.Code != http.StatusBadRequest {
+				t.Errorf("GET %s: status = %d, want: 400", target, w.Code)
+			}
+		}
+	})
+
+	t.Run("prod mode", func(t *testing.T) {
+		tr.h._isDev = false
+		defer func() { tr.h._isDev = true }()
+		if w := serve("/__routes"); w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want: 404", w.Code)
+		}
+	})
+}
+
+func TestRunRoutesCommand(t *testing.T) {
+	tr, opts := newRoutesDebugTestRiver(t)
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := tr.h.RunRoutesCommand(opts, args, &out)
+		return out.String(), err
+	}
+
+	t.Run("table", func(t *testing.T) {
+		out, err := run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"UI ROUTES", "/rd-test/:id", "client: routes.tsx#default", "ACTION ROUTES", "/api/rd-test"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected output to contain %q, got:\n%s", want, out)
+			}
+		}
+		if strings.Contains(out, "FORM ACTION ROUTES") {
+			t.Error("expected no form action section")
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		out, err := run("-json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report RoutesReport
+		if err := json.Unmarshal([]byte(out), &report); err != nil {
+			t.Fatalf("could not decode %q: %v", out, err)
+		}
+		if len(report.UIRoutes) != 1 {
+			t.Errorf("unexpected report: %+v", report)
+		}
+	})
+
+	t.Run("match", func(t *testing.T) {
+		out, err := run("-match", "/rd-test/7")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, "UI\nGET /rd-test/7 -> GET /rd-test/:id") || !strings.Contains(out, "id=7") {
+			t.Errorf("unexpected output:\n%s", out)
+		}
+	})
+
+	t.Run("invalid match", func(t *testing.T) {
+		for _, args := range [][]string{{"-match", "rd-test/7"}, {"-match", "%zz"}, {"-match", "/rd-test/7", "-method", "a b"}} {
+			if _, err := run(args...); err == nil {
+				t.Errorf("%v: expected an error", args)
+			}
+		}
+	})
+
+	t.Run("unescaped match", func(t *testing.T) {
+		out, err := run("-match", "/a b")
+		if err != nil || !strings.Contains(out, "GET /a b -> no match") {
+			t.Errorf("unexpected output %q, %v", out, err)
+		}
+	})
+
+	t.Run("bad flag", func(t *testing.T) {
+		if _, err := run("-nope"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
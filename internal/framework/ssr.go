@@ -18,6 +18,7 @@ type SSRInnerHTMLInput struct {
 	BuildID             string
 	ViteDevURL          string
 	LoadersData         []any
+	LoadersErrs         []*LoaderError
 	ImportURLs          []string
 	ExportKeys          []string
 	OutermostErrorIndex int
@@ -26,6 +27,10 @@ type SSRInnerHTMLInput struct {
 	CoreData            any
 	Deps                []string
 	CSSBundles          []string
+	Integrity           map[string]string
+	DeferredIndices     []int
+	ActionData          any
+	ActionError         *LoaderError
 }
 
 // Sadly, must include the script tags so html/template parses this correctly.
@@ -38,18 +43,25 @@ const ssrInnerHTMLTmplStr = `<script>
 	x.buildID = {{.BuildID}};
 	x.viteDevURL = {{.ViteDevURL}};
 	x.loadersData = {{.LoadersData}};
+	x.loadersErrs = {{.LoadersErrs}};
 	x.importURLs = {{.ImportURLs}};
 	x.exportKeys = {{.ExportKeys}};
 	x.outermostErrorIndex = {{.OutermostErrorIndex}};
 	x.splatValues = {{.SplatValues}};
 	x.params = {{.Params}};
 	x.coreData = {{.CoreData}};
+	x.deferredIndices = {{.DeferredIndices}};
+	x.pendingDeferredIndices = {{.DeferredIndices}};
+	x.actionData = {{.ActionData}};
+	x.actionError = {{.ActionError}};
 	if (!x.isDev) {
+		const integrity = {{.Integrity}} ?? {};
 		const deps = {{.Deps}};
 		deps.forEach(x => {
 			const link = document.createElement('link');
 			link.rel = 'modulepreload';
 			link.href = "/public/" + x;
+			if (integrity[x]) link.integrity = integrity[x];
 			document.head.appendChild(link);
 		});
 		const cssBundles = {{.CSSBundles}};
@@ -57,6 +69,7 @@ const ssrInnerHTMLTmplStr = `<script>
 			const link = document.createElement('link');
 			link.rel = 'stylesheet';
 			link.href = "/public/" + x;
+			if (integrity[x]) link.integrity = integrity[x];
 			link.setAttribute("data-river-css-bundle", x);
 			document.head.appendChild(link);
 		});
@@ -79,6 +92,7 @@ func (h *River[C]) GetSSRInnerHTML(routeData *UIRouteOutput) (*GetSSRInnerHTMLOu
 		BuildID:             routeData.BuildID,
 		ViteDevURL:          routeData.ViteDevURL,
 		LoadersData:         routeData.LoadersData,
+		LoadersErrs:         routeData.LoadersErrs,
 		ImportURLs:          routeData.ImportURLs,
 		ExportKeys:          routeData.ExportKeys,
 		OutermostErrorIndex: routeData.OutermostErrorIndex,
@@ -87,6 +101,10 @@ func (h *River[C]) GetSSRInnerHTML(routeData *UIRouteOutput) (*GetSSRInnerHTMLOu
 		CoreData:            routeData.CoreData,
 		Deps:                routeData.Deps,
 		CSSBundles:          routeData.CSSBundles,
+		Integrity:           routeData.Integrity,
+		DeferredIndices:     routeData.DeferredIndices,
+		ActionData:          routeData.ActionData,
+		ActionError:         routeData.ActionError,
 	}
 	if err := ssrInnerTmpl.Execute(&htmlBuilder, dto); err != nil {
 		errMsg := fmt.Sprintf("could not execute SSR inner HTML template: %v", err)
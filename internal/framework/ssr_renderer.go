@@ -0,0 +1,255 @@
+package framework
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sjc5/river/kit/grace"
+)
+
+// SSRRenderer renders the body HTML of a UI route on the server. The
+// returned markup is made available to the root template as
+// {{.RiverSSRBody}}, and should generally be placed inside the root
+// element (e.g., <div id="{{.RiverRootID}}">{{.RiverSSRBody}}</div>) so
+// that the client can hydrate it. If rendering fails, the error is logged
+// and the response falls back to client-side rendering.
+type SSRRenderer interface {
+	RenderUI(r *http.Request, routeData *UIRouteOutput) (template.HTML, error)
+}
+
+// Renders the route body if an SSRRenderer is configured. Never returns an
+// error, because a failed server render is recoverable on the client.
+func (h *River[C]) renderSSRBody(r *http.Request, routeData *UIRouteOutput) template.HTML {
+	if h.SSRRenderer == nil {
+		return ""
+	}
+	body, err := h.SSRRenderer.RenderUI(r, routeData)
+	if err != nil {
+		Log.Error(fmt.Sprintf("Error rendering SSR body (falling back to client rendering): %v", err))
+		return ""
+	}
+	return body
+}
+
+// Shutdown releases anything River's optional components hold onto outside
+// of the Go process (e.g., a NodeSSRRenderer's JS process). Call it from
+// your server's shutdown logic, such as grace.OrchestrateOptions'
+// ShutdownCallback, after the HTTP server has stopped accepting requests.
+func (h *River[C]) Shutdown() {
+	if cleaner, ok := h.SSRRenderer.(interface{ Cleanup() }); ok {
+		cleaner.Cleanup()
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// NODE SSR RENDERER
+/////////////////////////////////////////////////////////////////////
+
+// NodeSSRRenderer is a reference SSRRenderer that delegates rendering to a
+// long-lived JS process (e.g., a Node script using react-dom/server,
+// preact-render-to-string or solid-js/web). The process is started lazily
+// on the first render, and is restarted on the next render if it exits.
+// Call River.Shutdown (or Cleanup) on shutdown to terminate it.
+//
+// Requests and responses are newline-delimited JSON over stdin and stdout.
+// Each request line is {"id":number,"url":string,"routeData":UIRouteOutput},
+// and the process must eventually write back exactly one line for that id,
+// shaped {"id":number,"html":string} or {"id":number,"error":string}.
+// Anything written to stderr is passed through to the parent's stderr.
+// The "@sjc5/river/ssr" package exports a helper that implements the
+// JS side of this protocol.
+type NodeSSRRenderer struct {
+	mu      sync.Mutex
+	opts    *NodeSSRRendererOptions
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	pending map[uint64]chan nodeSSRResponse
+	nextID  uint64
+}
+
+type NodeSSRRendererOptions struct {
+	// required -- e.g., "node ./dist/ssr/entry.js"
+	Cmd string
+	// optional -- the working directory of the process
+	CmdDir string
+	// optional -- default is 5 seconds
+	Timeout time.Duration
+}
+
+type nodeSSRRequest struct {
+	ID        uint64         `json:"id"`
+	URL       string         `json:"url"`
+	RouteData *UIRouteOutput `json:"routeData"`
+}
+
+type nodeSSRResponse struct {
+	ID    uint64 `json:"id"`
+	HTML  string `json:"html"`
+	Error string `json:"error,omitempty"`
+}
+
+func NewNodeSSRRenderer(opts *NodeSSRRendererOptions) *NodeSSRRenderer {
+	return &NodeSSRRenderer{
+		opts:    opts,
+		pending: make(map[uint64]chan nodeSSRResponse),
+	}
+}
+
+func (n *NodeSSRRenderer) RenderUI(r *http.Request, routeData *UIRouteOutput) (template.HTML, error) {
+	ch := make(chan nodeSSRResponse, 1)
+
+	n.mu.Lock()
+	if err := n.start_if_needed(); err != nil {
+		n.mu.Unlock()
+		return "", err
+	}
+	n.nextID++
+	id := n.nextID
+	n.pending[id] = ch
+	line, err := json.Marshal(nodeSSRRequest{ID: id, URL: r.URL.String(), RouteData: routeData})
+	if err == nil {
+		_, err = n.stdin.Write(append(line, '\n'))
+	}
+	if err != nil {
+		delete(n.pending, id)
+		n.mu.Unlock()
+		return "", fmt.Errorf("could not send SSR request: %v", err)
+	}
+	n.mu.Unlock()
+
+	timeout := n.opts.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-ch:
+		if resp.Error != "" {
+			return "", errors.New(resp.Error)
+		}
+		return template.HTML(resp.HTML), nil
+	case <-timer.C:
+		n.forget(id)
+		return "", fmt.Errorf("SSR request timed out after %s", timeout)
+	case <-r.Context().Done():
+		n.forget(id)
+		return "", r.Context().Err()
+	}
+}
+
+// Cleanup terminates the JS process, if running. A later render starts a
+// new one.
+func (n *NodeSSRRenderer) Cleanup() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.cmd != nil && n.cmd.Process != nil {
+		if err := grace.TerminateProcess(n.cmd.Process, 3*time.Second, nil); err != nil {
+			Log.Warn(fmt.Sprintf("Cleanup: Error terminating SSR process: %s", err))
+		} else {
+			Log.Info("Cleanup: Terminated SSR process", "pid", n.cmd.Process.Pid)
+		}
+	}
+	n.cmd, n.stdin = nil, nil
+	n.fail_pending("SSR process was terminated before responding")
+}
+
+func (n *NodeSSRRenderer) forget(id uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.pending, id)
+}
+
+// Must be called with n.mu held.
+func (n *NodeSSRRenderer) start_if_needed() error {
+	if n.cmd != nil {
+		return nil
+	}
+
+	split_cmd := strings.Fields(n.opts.Cmd)
+	if len(split_cmd) == 0 {
+		return errors.New("NodeSSRRendererOptions.Cmd is required")
+	}
+
+	cmd := exec.Command(split_cmd[0], split_cmd[1:]...)
+	cmd.Stderr = os.Stderr
+	if n.opts.CmdDir != "" {
+		cmd.Dir = n.opts.CmdDir
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("could not get SSR process stdin: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("could not get SSR process stdout: %v", err)
+	}
+
+	Log.Info("Starting SSR process...", "command", fmt.Sprintf(`"%s"`, n.opts.Cmd))
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start SSR process: %v", err)
+	}
+
+	n.cmd, n.stdin = cmd, stdin
+
+	go n.read_loop(cmd, stdout)
+
+	return nil
+}
+
+func (n *NodeSSRRenderer) read_loop(cmd *exec.Cmd, stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for scanner.Scan() {
+		var resp nodeSSRResponse
+		if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+			Log.Warn(fmt.Sprintf("Ignoring invalid SSR process output: %s", scanner.Text()))
+			continue
+		}
+		n.mu.Lock()
+		ch, ok := n.pending[resp.ID]
+		delete(n.pending, resp.ID)
+		n.mu.Unlock()
+		if ok {
+			ch <- resp
+		}
+	}
+
+	waitErr := cmd.Wait()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// Only reset state if the process was not already replaced or cleaned up
+	if n.cmd != cmd {
+		return
+	}
+
+	Log.Warn("SSR process exited; it will be restarted on the next render", "error", waitErr)
+
+	n.cmd, n.stdin = nil, nil
+	n.fail_pending("SSR process exited before responding")
+}
+
+// Must be called with n.mu held.
+func (n *NodeSSRRenderer) fail_pending(msg string) {
+	for id, ch := range n.pending {
+		ch <- nodeSSRResponse{ID: id, Error: msg}
+		delete(n.pending, id)
+	}
+}
@@ -0,0 +1,85 @@
+package framework
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Answers every request with the same markup. Request lines start with
+// {"id":N, so the id can be pulled out without a JSON parser.
+const fakeSSRScript = `while read -r line; do
+	id=$(printf '%s' "$line" | sed 's/^{"id":\([0-9]*\).*/\1/')
+	printf '{"id":%s,"html":"<p>rendered</p>"}\n' "$id"
+done
+`
+
+func newFakeNodeSSRRenderer(t *testing.T) *NodeSSRRenderer {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "ssr.sh")
+	if err := os.WriteFile(script, []byte(fakeSSRScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return NewNodeSSRRenderer(&NodeSSRRendererOptions{Cmd: "sh " + script, Timeout: 5 * time.Second})
+}
+
+func (n *NodeSSRRenderer) getPID() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.cmd == nil || n.cmd.Process == nil {
+		return 0
+	}
+	return n.cmd.Process.Pid
+}
+
+func TestNodeSSRRendererShutdown(t *testing.T) {
+	renderer := newFakeNodeSSRRenderer(t)
+	h := &River[any]{SSRRenderer: renderer}
+
+	render := func() {
+		t.Helper()
+		body := h.renderSSRBody(httptest.NewRequest("GET", "/", nil), &UIRouteOutput{})
+		if body != "<p>rendered</p>" {
+			t.Fatalf("renderSSRBody() = %q, want: <p>rendered</p>", body)
+		}
+	}
+
+	render()
+	pid := renderer.getPID()
+	if pid == 0 {
+		t.Fatal("expected a running SSR process")
+	}
+
+	h.Shutdown()
+
+	if renderer.getPID() != 0 {
+		t.Error("expected the SSR process to be forgotten")
+	}
+	// The process has been waited on, so it is gone rather than a zombie
+	if process, err := os.FindProcess(pid); err == nil && process.Signal(syscall.Signal(0)) == nil {
+		t.Errorf("expected SSR process %d to be terminated", pid)
+	}
+
+	// A later render starts a new process
+	render()
+	if newPID := renderer.getPID(); newPID == 0 || newPID == pid {
+		t.Errorf("expected a new SSR process, got pid %d", newPID)
+	}
+	h.Shutdown()
+}
+
+func TestRiverShutdownWithoutCleanup(t *testing.T) {
+	(&River[any]{}).Shutdown()
+	(&River[any]{SSRRenderer: staticSSRRenderer{}}).Shutdown()
+}
+
+type staticSSRRenderer struct{}
+
+func (staticSSRRenderer) RenderUI(*http.Request, *UIRouteOutput) (template.HTML, error) {
+	return "<p>static</p>", nil
+}
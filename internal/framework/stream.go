@@ -0,0 +1,161 @@
+package framework
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/sjc5/river/kit/htmlutil"
+	"github.com/sjc5/river/kit/mux"
+)
+
+// Same trick as the SSR inner HTML template: the script tags are needed so
+// that html/template escapes the data values for a JS context.
+const deferredChunkTmplStr = `<script>
+	(() => {
+		const x = globalThis[Symbol.for("{{.RiverSymbolStr}}")];
+		if (!x.pendingDeferredIndices?.includes({{.Index}})) return;
+		x.pendingDeferredIndices = x.pendingDeferredIndices.filter((i) => i !== {{.Index}});
+		x.loadersData = [...x.loadersData];
+		x.loadersData[{{.Index}}] = {{.Data}};
+		const err = {{.Err}};
+		if (err) {
+			x.loadersErrs = [...(x.loadersErrs ?? [])];
+			x.loadersErrs[{{.Index}}] = err;
+			if (x.outermostErrorIndex === -1 || x.outermostErrorIndex > {{.Index}}) {
+				x.outermostErrorIndex = {{.Index}};
+			}
+		}
+		dispatchEvent(new CustomEvent("river:deferred", { detail: { index: {{.Index}}, ok: !err } }));
+	})();
+</script>`
+
+var deferredChunkTmpl = template.Must(template.New("deferred").Parse(deferredChunkTmplStr))
+
+type deferredChunkInput struct {
+	RiverSymbolStr string
+	Index          int
+	Data           any
+	Err            *LoaderError
+}
+
+type deferredLoader struct {
+	index  int
+	result *mux.NestedTasksResult
+}
+
+// Writes everything up to the closing body tag of the rendered root
+// template, flushes, then writes an inline script chunk for each deferred
+// loader as soon as it resolves (in completion order), and finally writes
+// the remainder of the document.
+// The status must be known before the head is flushed, so errors from
+// deferred loaders are only reported to the client, never via the status.
+func (h *River[C]) streamUIResponse(
+	w http.ResponseWriter, r *http.Request, status int, doc []byte,
+	deferredIndices []int, deferredResults []*mux.NestedTasksResult, cspNonce string,
+) error {
+	rc := http.NewResponseController(w)
+
+	head, tail := splitAtClosingBodyTag(doc)
+
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(status)
+
+	if _, err := w.Write(head); err != nil {
+		return fmt.Errorf("could not write streamed document head: %v", err)
+	}
+	if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return fmt.Errorf("could not flush streamed document head: %v", err)
+	}
+
+	resolved := make(chan deferredLoader, len(deferredResults))
+	for i, result := range deferredResults {
+		go func() {
+			select {
+			case <-result.Done():
+			case <-r.Context().Done():
+			}
+			resolved <- deferredLoader{index: deferredIndices[i], result: result}
+		}()
+	}
+
+	for range deferredResults {
+		d := <-resolved
+
+		if r.Context().Err() != nil {
+			return r.Context().Err()
+		}
+
+		chunk, err := renderDeferredChunk(d, cspNonce)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return fmt.Errorf("could not write deferred chunk: %v", err)
+		}
+		if err := rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return fmt.Errorf("could not flush deferred chunk: %v", err)
+		}
+	}
+
+	if _, err := w.Write(tail); err != nil {
+		return fmt.Errorf("could not write streamed document tail: %v", err)
+	}
+
+	return nil
+}
+
+// Chunks are written after the headers are sent, so their hashes can't be
+// part of the CSP. If there is a CSP nonce, they carry it instead.
+func renderDeferredChunk(d deferredLoader, cspNonce string) ([]byte, error) {
+	input := deferredChunkInput{
+		RiverSymbolStr: RiverSymbolStr,
+		Index:          d.index,
+	}
+
+	if err := d.result.Err(); err != nil {
+		Log.Error(fmt.Sprintf("ERROR (deferred): %s", err))
+		input.Err = toPublicLoaderError(err)
+	} else {
+		input.Data = d.result.Data()
+	}
+
+	var b strings.Builder
+	if err := deferredChunkTmpl.Execute(&b, input); err != nil {
+		return nil, fmt.Errorf("could not execute deferred chunk template: %v", err)
+	}
+
+	innerHTML := b.String()
+	innerHTML = strings.TrimPrefix(innerHTML, "<script>")
+	innerHTML = strings.TrimSuffix(innerHTML, "</script>")
+
+	el := &htmlutil.Element{
+		Tag:       "script",
+		InnerHTML: template.HTML(innerHTML),
+	}
+	if cspNonce != "" {
+		el.Attributes = map[string]string{"nonce": cspNonce}
+	}
+
+	rendered, err := htmlutil.RenderElement(el)
+	if err != nil {
+		return nil, fmt.Errorf("could not render deferred chunk: %v", err)
+	}
+
+	return []byte(rendered), nil
+}
+
+var closingBodyTag = []byte("</body>")
+
+// If the document has no closing body tag, everything is treated as head,
+// and chunks are simply appended to the end of the document.
+func splitAtClosingBodyTag(doc []byte) (head, tail []byte) {
+	idx := bytes.LastIndex(bytes.ToLower(doc), closingBodyTag)
+	if idx == -1 {
+		return doc, nil
+	}
+	return doc[:idx], doc[idx:]
+}
@@ -0,0 +1,196 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+// Runs one deferrable nested route per loader (nested as "/0", "/0/1", ...)
+// and returns their deferred results, in order.
+func newDeferredResults(t *testing.T, loaders ...func() (any, error)) []*mux.NestedTasksResult {
+	t.Helper()
+	tasksRegistry := tasks.NewRegistry()
+	router := mux.NewNestedRouter(&mux.NestedOptions{TasksRegistry: tasksRegistry})
+
+	var pattern string
+	for i, loader := range loaders {
+		pattern += "/" + string(rune('0'+i))
+		handler := mux.TaskHandlerFromFunc(tasksRegistry, func(*mux.NestedReqData) (any, error) {
+			return loader()
+		})
+		mux.SetNestedRouteDeferrable(mux.RegisterNestedTaskHandler(router, pattern, handler))
+	}
+
+	r := httptest.NewRequest("GET", pattern, nil)
+	matches, ok := mux.FindNestedMatches(router, r)
+	if !ok {
+		t.Fatal("expected matches")
+	}
+	results := mux.RunNestedTasksWithDeferrals(router, tasksRegistry.NewCtxFromRequest(r), r, matches)
+	return results.Slice
+}
+
+func TestSplitAtClosingBodyTag(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		head string
+		tail string
+	}{
+		{"closing body tag", "<html><body>hi</body></html>", "<html><body>hi", "</body></html>"},
+		{"uppercase closing body tag", "<HTML><BODY>hi</BODY></HTML>", "<HTML><BODY>hi", "</BODY></HTML>"},
+		{"last closing body tag", "<body><script>'</body>'</script></body>", "<body><script>'</body>'</script>", "</body>"},
+		{"no closing body tag", "<p>hi</p>", "<p>hi</p>", ""},
+		{"empty", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			head, tail := splitAtClosingBodyTag([]byte(tt.doc))
+			if string(head) != tt.head || string(tail) != tt.tail {
+				t.Errorf("splitAtClosingBodyTag() = (%q, %q), want: (%q, %q)", head, tail, tt.head, tt.tail)
+			}
+		})
+	}
+}
+
+func TestRenderDeferredChunk(t *testing.T) {
+	results := newDeferredResults(t,
+		func() (any, error) { return map[string]string{"msg": "</script><b>"}, nil },
+		func() (any, error) { return nil, errors.New("db password is hunter2") },
+		func() (any, error) { return nil, NewLoaderError(404, "no such user", nil) },
+	)
+	for _, result := range results {
+		<-result.Done()
+	}
+
+	tests := []struct {
+		name     string
+		d        deferredLoader
+		nonce    string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "data",
+			d:        deferredLoader{index: 3, result: results[0]},
+			contains: []string{"x.loadersData[ 3 ] =", `\u003c/script\u003e\u003cb\u003e`, "const err =  null ;"},
+			excludes: []string{"</script><b>", "nonce="},
+		},
+		{
+			name:     "internal error",
+			d:        deferredLoader{index: 1, result: results[1]},
+			contains: []string{`"status":500`, `"message":"Internal Server Error"`, "x.outermostErrorIndex =  1 ;"},
+			excludes: []string{"hunter2"},
+		},
+		{
+			name:     "loader error",
+			d:        deferredLoader{index: 2, result: results[2]},
+			contains: []string{`"status":404`, `"message":"no such user"`},
+		},
+		{
+			name:     "nonce",
+			d:        deferredLoader{index: 0, result: results[0]},
+			nonce:    "abc123",
+			contains: []string{`nonce="abc123"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk, err := renderDeferredChunk(tt.d, tt.nonce)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := string(chunk)
+			if !strings.HasPrefix(s, "<script") || !strings.HasSuffix(s, "</script>") {
+				t.Errorf("expected a single script element, got %s", s)
+			}
+			for _, x := range tt.contains {
+				if !strings.Contains(s, x) {
+					t.Errorf("expected chunk to contain %q, got %s", x, s)
+				}
+			}
+			for _, x := range tt.excludes {
+				if strings.Contains(s, x) {
+					t.Errorf("expected chunk not to contain %q, got %s", x, s)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamUIResponse(t *testing.T) {
+	h := &River[any]{}
+
+	t.Run("chunks before closing body tag", func(t *testing.T) {
+		release := make(chan struct{})
+		results := newDeferredResults(t,
+			func() (any, error) { <-release; return "slow", nil },
+			func() (any, error) { return nil, errors.New("boom") },
+		)
+		close(release)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		doc := []byte("<html><body><div id=root></div></body></html>")
+		if err := h.streamUIResponse(w, r, 404, doc, []int{1, 2}, results, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		if w.Code != 404 {
+			t.Errorf("status = %d, want: 404", w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "text/html" {
+			t.Errorf("Content-Type = %q, want: text/html", ct)
+		}
+		body := w.Body.String()
+		if !strings.HasPrefix(body, "<html><body><div id=root></div><script>") || !strings.HasSuffix(body, "</script></body></html>") {
+			t.Errorf("expected chunks between the content and the closing body tag, got %s", body)
+		}
+		if strings.Count(body, "<script>") != 2 {
+			t.Errorf("expected 2 chunks, got %s", body)
+		}
+		if !strings.Contains(body, `x.loadersData[ 1 ] = "slow"`) || !strings.Contains(body, "x.loadersErrs[ 2 ] = err") {
+			t.Errorf("expected data and error chunks, got %s", body)
+		}
+	})
+
+	t.Run("no closing body tag", func(t *testing.T) {
+		results := newDeferredResults(t, func() (any, error) { return "x", nil })
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		if err := h.streamUIResponse(w, r, 200, []byte("<p>hi</p>"), []int{0}, results, ""); err != nil {
+			t.Fatal(err)
+		}
+		body := w.Body.String()
+		if !strings.HasPrefix(body, "<p>hi</p><script>") || !strings.HasSuffix(body, "</script>") {
+			t.Errorf("expected the chunk to be appended, got %s", body)
+		}
+	})
+
+	t.Run("canceled request", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		results := newDeferredResults(t, func() (any, error) { <-release; return "never", nil })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequestWithContext(ctx, "GET", "/", nil)
+		err := h.streamUIResponse(w, r, 200, []byte("<body></body>"), []int{0}, results, "")
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		if body := w.Body.String(); body != "<body>" {
+			t.Errorf("expected only the head to be written, got %s", body)
+		}
+	})
+}
@@ -16,6 +16,7 @@ var tsConfigs = []string{
 	"./internal/framework/_typescript/client/tsconfig.json",
 	"./internal/framework/_typescript/react/tsconfig.json",
 	"./internal/framework/_typescript/solid/tsconfig.json",
+	"./internal/framework/_typescript/ssr/tsconfig.json",
 	"./kit/_typescript/tsconfig.json",
 }
 
@@ -64,6 +65,7 @@ func main() {
 			"./internal/framework/_typescript/client/index.ts",
 			"./internal/framework/_typescript/react/index.tsx",
 			"./internal/framework/_typescript/solid/index.tsx",
+			"./internal/framework/_typescript/ssr/index.ts",
 			"./kit/_typescript/converters/converters.ts",
 			"./kit/_typescript/debounce/debounce.ts",
 			"./kit/_typescript/fmt/fmt.ts",
@@ -78,6 +80,7 @@ func main() {
 			"react",
 			"react-dom",
 			"preact",
+			"node:*",
 		},
 		Outdir: "./npm_dist",
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"hash"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -139,6 +140,12 @@ func (c *Config) buildCSS() error {
 		return fmt.Errorf("error processing normal CSS: %v", err)
 	}
 
+	for name := range c.cleanSources.CSSBundles {
+		if err := c.processCSSBundle(name); err != nil {
+			return fmt.Errorf("error processing CSS bundle %q: %v", name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -147,14 +154,15 @@ type esbuildCtxSafe struct {
 	mu  sync.Mutex
 }
 
+// Both keyed by "critical", "normal", or cssBundleKey(name)
 var (
-	cssImportURLsMu         *sync.RWMutex  = &sync.RWMutex{}
-	criticalReliedUponFiles                = map[string]struct{}{}
-	normalReliedUponFiles                  = map[string]struct{}{}
-	esbuildCtxCritical      esbuildCtxSafe = esbuildCtxSafe{}
-	esbuildCtxNormal        esbuildCtxSafe = esbuildCtxSafe{}
+	cssImportURLsMu    *sync.RWMutex = &sync.RWMutex{}
+	cssReliedUponFiles               = map[string]map[string]struct{}{}
+	esbuildCSSCtxs                   = map[string]*esbuildCtxSafe{}
 )
 
+func cssBundleKey(name string) string { return "bundle:" + name }
+
 func (c *Config) processCSSCritical() error { return c.__processCSS("critical") }
 func (c *Config) processCSSNormal() error   { return c.__processCSS("normal") }
 
@@ -169,6 +177,98 @@ func (c *Config) __processCSS(nature string) error {
 		return nil
 	}
 
+	contents, err := c.bundleCSS(nature, entryPoint)
+	if err != nil {
+		return err
+	}
+
+	if nature == "critical" {
+		outputPath := c._dist.S().Static.S().Internal.FullPath()
+		if err := os.MkdirAll(outputPath, 0755); err != nil {
+			return fmt.Errorf("error creating output directory: %v", err)
+		}
+		return os.WriteFile(filepath.Join(outputPath, nature+".css"), contents, 0644)
+	}
+
+	outputFileName, err := c.writeHashedCSS(contents, "normal")
+	if err != nil {
+		return err
+	}
+
+	// Also write the hashed filename to normal_css_file_ref.txt
+	hashFile := c._dist.S().Static.S().Internal.S().NormalCSSFileRefDotTXT.FullPath()
+	if err := os.WriteFile(hashFile, []byte(outputFileName), 0644); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+
+	return nil
+}
+
+// Named bundles, critical or not, are always written to the public dir
+// with a hashed filename, so that critical bundles can still be linked
+// (e.g., when navigating client-side to a route that uses one).
+func (c *Config) processCSSBundle(name string) error {
+	bundle, ok := c.cleanSources.CSSBundles[name]
+	if !ok {
+		return fmt.Errorf("no such CSS bundle: %s", name)
+	}
+
+	contents, err := c.bundleCSS(cssBundleKey(name), bundle.Entry)
+	if err != nil {
+		return err
+	}
+
+	outputFileName, err := c.writeHashedCSS(contents, cssBundleFilePrefix+name)
+	if err != nil {
+		return err
+	}
+
+	internalPath := c._dist.S().Static.S().Internal.FullPath()
+	if err := os.MkdirAll(internalPath, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %v", err)
+	}
+	refFile := filepath.Join(internalPath, toCSSBundleRefFileName(name))
+	if err := os.WriteFile(refFile, []byte(outputFileName), 0644); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+
+	return nil
+}
+
+// Writes the CSS to the public dist dir as "{basename}_{hash}.css",
+// deleting any previous versions, and returns the new filename.
+func (c *Config) writeHashedCSS(contents []byte, basename string) (string, error) {
+	outputPath := c._dist.S().Static.S().Assets.S().Public.FullPath()
+
+	oldFiles, err := filepath.Glob(filepath.Join(outputPath, basename+"_*.css*"))
+	if err != nil {
+		return "", fmt.Errorf("error finding old %s CSS files: %v", basename, err)
+	}
+	for _, oldFile := range oldFiles {
+		if err := os.Remove(oldFile); err != nil {
+			return "", fmt.Errorf("error removing old %s CSS file: %v", basename, err)
+		}
+	}
+
+	if err := os.MkdirAll(outputPath, 0755); err != nil {
+		return "", fmt.Errorf("error creating output directory: %v", err)
+	}
+
+	outputFileName := getHashedFilenameFromBytes(contents, basename+".css")
+	outputFile := filepath.Join(outputPath, outputFileName)
+	if err := os.WriteFile(outputFile, contents, 0644); err != nil {
+		return "", fmt.Errorf("error writing CSS file: %v", err)
+	}
+	if err := c.precompressFile(outputFile, contents); err != nil {
+		return "", err
+	}
+
+	return outputFileName, nil
+}
+
+// Bundles the CSS entry point, recording the files it imports under the
+// given key so that changes to them trigger a rebuild in dev.
+func (c *Config) bundleCSS(key, entryPoint string) ([]byte, error) {
 	isDev := GetIsDev()
 
 	ctx, ctxErr := esbuild.Context(esbuild.BuildOptions{
@@ -199,104 +299,44 @@ func (c *Config) __processCSS(nature string) error {
 		},
 	})
 	if ctxErr != nil {
-		return fmt.Errorf("error creating esbuild context: %v", ctxErr.Errors)
+		return nil, fmt.Errorf("error creating esbuild context: %v", ctxErr.Errors)
 	}
 
-	if nature == "critical" {
-		esbuildCtxCritical.mu.Lock()
-		esbuildCtxCritical.ctx = ctx
-		esbuildCtxCritical.mu.Unlock()
-	} else {
-		esbuildCtxNormal.mu.Lock()
-		esbuildCtxNormal.ctx = ctx
-		esbuildCtxNormal.mu.Unlock()
+	cssImportURLsMu.Lock()
+	ctxSafe, ok := esbuildCSSCtxs[key]
+	if !ok {
+		ctxSafe = &esbuildCtxSafe{}
+		esbuildCSSCtxs[key] = ctxSafe
 	}
+	cssImportURLsMu.Unlock()
+
+	ctxSafe.mu.Lock()
+	ctxSafe.ctx = ctx
+	ctxSafe.mu.Unlock()
 
 	result := ctx.Rebuild()
 	if err := esbuildutil.CollectErrors(result); err != nil {
-		return fmt.Errorf("error building CSS: %v", err)
+		return nil, fmt.Errorf("error building CSS: %v", err)
 	}
 
 	var metafile esbuildutil.ESBuildMetafileSubset
 	if err := json.Unmarshal([]byte(result.Metafile), &metafile); err != nil {
-		return fmt.Errorf("error unmarshalling esbuild metafile: %v", err)
+		return nil, fmt.Errorf("error unmarshalling esbuild metafile: %v", err)
 	}
 
-	srcURL := c.cleanSources.NonCriticalCSSEntry
-	if nature == "critical" {
-		srcURL = c.cleanSources.CriticalCSSEntry
-	}
-
-	imports := metafile.Inputs[srcURL].Imports
-
-	cssImportURLsMu.Lock()
-
-	if nature == "critical" {
-		criticalReliedUponFiles = map[string]struct{}{}
-	} else {
-		normalReliedUponFiles = map[string]struct{}{}
-	}
-
-	for _, imp := range imports {
+	reliedUponFiles := map[string]struct{}{}
+	for _, imp := range metafile.Inputs[entryPoint].Imports {
 		if imp.Kind != "import-rule" {
 			continue
 		}
-
-		if nature == "critical" {
-			criticalReliedUponFiles[imp.Path] = struct{}{}
-		} else {
-			normalReliedUponFiles[imp.Path] = struct{}{}
-		}
+		reliedUponFiles[imp.Path] = struct{}{}
 	}
 
+	cssImportURLsMu.Lock()
+	cssReliedUponFiles[key] = reliedUponFiles
 	cssImportURLsMu.Unlock()
 
-	// Determine output path and filename
-	var outputPath string
-
-	switch nature {
-	case "critical":
-		outputPath = c._dist.S().Static.S().Internal.FullPath()
-	case "normal":
-		outputPath = c._dist.S().Static.S().Assets.S().Public.FullPath()
-	}
-
-	outputFileName := nature + ".css" // Default for 'critical'
-
-	if nature == "normal" {
-		// first, delete the old normal.css file(s)
-		oldNormalPath := filepath.Join(outputPath, "normal_*.css")
-		oldNormalFiles, err := filepath.Glob(oldNormalPath)
-		if err != nil {
-			return fmt.Errorf("error finding old normal CSS files: %v", err)
-		}
-		for _, oldNormalFile := range oldNormalFiles {
-			if err := os.Remove(oldNormalFile); err != nil {
-				return fmt.Errorf("error removing old normal CSS file: %v", err)
-			}
-		}
-
-		// Hash the css output
-		outputFileName = getHashedFilenameFromBytes(result.OutputFiles[0].Contents, "normal.css")
-	}
-
-	// Ensure output directory exists
-	if err := os.MkdirAll(outputPath, 0755); err != nil {
-		return fmt.Errorf("error creating output directory: %v", err)
-	}
-
-	// Write css to file
-	outputFile := filepath.Join(outputPath, outputFileName)
-
-	// If normal, also write to a file called normal_css_ref.txt with the hash
-	if nature == "normal" {
-		hashFile := c._dist.S().Static.S().Internal.S().NormalCSSFileRefDotTXT.FullPath()
-		if err := os.WriteFile(hashFile, []byte(outputFileName), 0644); err != nil {
-			return fmt.Errorf("error writing to file: %v", err)
-		}
-	}
-
-	return os.WriteFile(outputFile, result.OutputFiles[0].Contents, 0644)
+	return result.OutputFiles[0].Contents, nil
 }
 
 type staticFileProcessorOpts struct {
@@ -307,6 +347,7 @@ type staticFileProcessorOpts struct {
 	is_dev_rebuild bool
 	getIsNoHashDir func(string) (bool, uint8)
 	writeWithHash  bool
+	withImages     bool
 }
 
 func (c *Config) handlePublicFiles(isDevRebuild bool) error {
@@ -326,6 +367,7 @@ func (c *Config) handlePublicFiles(isDevRebuild bool) error {
 			return false, 0
 		},
 		writeWithHash: true,
+		withImages:    c._uc.Core.ImagePipeline != nil,
 	})
 }
 
@@ -358,9 +400,16 @@ func (c *Config) processStaticFiles(opts *staticFileProcessorOpts) error {
 	if _, err := os.Stat(opts.srcDir); os.IsNotExist(err) {
 		return nil
 	}
+	if opts.withImages {
+		if err := c.validateImagePipeline(); err != nil {
+			return err
+		}
+	}
 
 	newFileMap := typed.SyncMap[string, fileVal]{}
 	oldFileMap := typed.SyncMap[string, fileVal]{}
+	newImageMap := typed.SyncMap[string, ImageInfo]{}
+	var oldImageMap ImageMap
 
 	// Load old file map if granular updates are enabled
 	if opts.is_dev_rebuild {
@@ -372,6 +421,10 @@ func (c *Config) processStaticFiles(opts *staticFileProcessorOpts) error {
 		for k, v := range oldMap {
 			oldFileMap.Store(k, v)
 		}
+		if opts.withImages {
+			// May not exist yet, if the image pipeline was just enabled
+			oldImageMap, _ = c.loadImageMapFromGob(true)
+		}
 	}
 
 	fileChan := make(chan fileInfo, 100)
@@ -414,7 +467,7 @@ func (c *Config) processStaticFiles(opts *staticFileProcessorOpts) error {
 		go func() {
 			defer wg.Done()
 			for fi := range fileChan {
-				if err := c.processFile(fi, opts, &newFileMap, &oldFileMap, opts.distDir); err != nil {
+				if err := c.processFile(fi, opts, &newFileMap, &oldFileMap, &newImageMap, oldImageMap, opts.distDir); err != nil {
 					errChan <- err
 					return
 				}
@@ -450,6 +503,20 @@ func (c *Config) processStaticFiles(opts *staticFileProcessorOpts) error {
 		if oldMapErr != nil {
 			return oldMapErr
 		}
+		if err := removeMootImageVariants(oldImageMap, &newImageMap, opts.distDir); err != nil {
+			return err
+		}
+	}
+
+	if opts.withImages {
+		imageMap := make(ImageMap)
+		newImageMap.Range(func(k string, v ImageInfo) bool {
+			imageMap[k] = v
+			return true
+		})
+		if err := c.saveImageMapToGob(imageMap); err != nil {
+			return fmt.Errorf("error saving image map: %v", err)
+		}
 	}
 
 	// Save the updated file map
@@ -473,6 +540,8 @@ func (c *Config) processFile(
 	opts *staticFileProcessorOpts,
 	newFileMap,
 	oldFileMap *typed.SyncMap[string, fileVal],
+	newImageMap *typed.SyncMap[string, ImageInfo],
+	oldImageMap ImageMap,
 	distDir string,
 ) error {
 	if err := c.fileSemaphore.Acquire(context.Background(), 1); err != nil {
@@ -482,21 +551,46 @@ func (c *Config) processFile(
 
 	relativePathUnderscores := strings.ReplaceAll(fi.relativePath, "/", "_")
 
+	// Public files always need their hash, for their integrity value
+	var hash hash.Hash
+	if !fi.isNoHashDir || opts.basename == PUBLIC {
+		var err error
+		hash, err = getSha256FromPath(fi.path)
+		if err != nil {
+			return fmt.Errorf("error hashing file: %v", err)
+		}
+	}
+
 	var fileIdentifier fileVal
 	if fi.isNoHashDir {
 		fileIdentifier.Val = fi.relativePath
 		fileIdentifier.IsPrehashed = true
 	} else {
-		var err error
-		name, err := getHashedFilenameFromPath(fi.path, relativePathUnderscores)
+		fileIdentifier.Val = toOutputFileName(hash, relativePathUnderscores)
+	}
+
+	if opts.basename == PUBLIC {
+		fileIdentifier.Integrity = toIntegrity(hash.Sum(nil))
+
+		stat, err := os.Stat(fi.path)
 		if err != nil {
-			return fmt.Errorf("error getting hashed filename: %v", err)
+			return fmt.Errorf("error getting file info: %v", err)
 		}
-		fileIdentifier.Val = name
+		fileIdentifier.HasGzip, fileIdentifier.HasBrotli = c.getPrecompressedSiblings(fi.relativePath, stat.Size())
 	}
 
 	newFileMap.Store(fi.relativePath, fileIdentifier)
 
+	// Runs even if the original is unchanged, since the new image map needs
+	// its info. During granular updates, only missing variants are encoded.
+	if opts.withImages && !fi.isNoHashDir && c.getIsImagePipelineMatch(fi.relativePath) {
+		info, err := c.processImage(fi, fileIdentifier.Val, distDir, opts.is_dev_rebuild, oldImageMap)
+		if err != nil {
+			return err
+		}
+		newImageMap.Store(fi.relativePath, *info)
+	}
+
 	// Skip unchanged files if granular updates are enabled
 	if opts.is_dev_rebuild {
 		if oldHash, exists := oldFileMap.Load(fi.relativePath); exists && oldHash == fileIdentifier {
@@ -523,6 +617,16 @@ func (c *Config) processFile(
 		return fmt.Errorf("error copying file: %v", err)
 	}
 
+	if fileIdentifier.HasGzip || fileIdentifier.HasBrotli {
+		contents, err := os.ReadFile(fi.path)
+		if err != nil {
+			return fmt.Errorf("error reading file: %v", err)
+		}
+		if err := c.precompressFile(distPath, contents); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -9,7 +9,9 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/sjc5/river/kit/dirs"
+	"github.com/sjc5/river/kit/imageutil"
 	"github.com/sjc5/river/kit/safecache"
+	"github.com/sjc5/river/kit/typed"
 	"golang.org/x/sync/semaphore"
 )
 
@@ -29,6 +31,9 @@ type dev struct {
 	naiveIgnoreDirPatterns []string
 	defaultWatchedFiles    []WatchedFile
 	matchResults           *safecache.CacheMap[potentialMatch, string, bool]
+	contentHashes          typed.SyncMap[string, [32]byte]
+	goDepGraph             *goDepGraph
+	goDepGraphMu           sync.RWMutex
 }
 
 /////////////////////////////////////////////////////////////////////
@@ -50,12 +55,20 @@ type runtimeCache struct {
 	stylesheet_link_el *safecache.Cache[*template.HTML]
 	stylesheet_url     *safecache.Cache[string]
 	critical_css       *safecache.Cache[*criticalCSSStatus]
+	css_bundles        *safecache.CacheMap[string, string, *cssBundleStatus]
 
 	// Public URLs
 	public_filemap_from_gob *safecache.Cache[FileMap]
 	public_filemap_url      *safecache.Cache[string]
 	public_filemap_details  *safecache.Cache[*publicFileMapDetails]
 	public_urls             *safecache.CacheMap[string, string, string]
+	public_imagemap         *safecache.Cache[ImageMap]
+
+	// Precompression
+	precompressed_encodings *safecache.Cache[map[string][]encoding]
+
+	// Subresource integrity
+	public_integrities *safecache.CacheMap[string, string, string]
 }
 
 func (c *Config) InitRuntimeCache() {
@@ -70,6 +83,9 @@ func (c *Config) InitRuntimeCache() {
 		stylesheet_link_el: safecache.New(c.getInitialStyleSheetLinkElement, GetIsDev),
 		stylesheet_url:     safecache.New(c.getInitialStyleSheetURL, GetIsDev),
 		critical_css:       safecache.New(c.getInitialCriticalCSSStatus, GetIsDev),
+		css_bundles: safecache.NewMap(c.getInitialCSSBundleStatus, cssBundlesKeyMaker, func(string) bool {
+			return GetIsDev()
+		}),
 
 		// Public URLs
 		public_filemap_from_gob: safecache.New(c.getInitialPublicFileMapFromGobRuntime, GetIsDev),
@@ -78,6 +94,15 @@ func (c *Config) InitRuntimeCache() {
 		public_urls: safecache.NewMap(c.getInitialPublicURL, publicURLsKeyMaker, func(string) bool {
 			return GetIsDev()
 		}),
+		public_imagemap: safecache.New(c.getInitialPublicImageMap, GetIsDev),
+
+		// Precompression
+		precompressed_encodings: safecache.New(c.getInitialPrecompressedEncodings, GetIsDev),
+
+		// Subresource integrity
+		public_integrities: safecache.NewMap(c.getInitialPublicFileIntegrity, publicIntegritiesKeyMaker, func(string) bool {
+			return GetIsDev()
+		}),
 	}
 }
 
@@ -97,6 +122,19 @@ type Config struct {
 	Logger         *slog.Logger
 	FilesToVendor  [][2]string // __TODO move to json config
 
+	// Optional. Encoders for the image pipeline's output formats, keyed by
+	// format (e.g., "webp", "avif"). Registered encoders take precedence
+	// over the built-in ones: JPEG, PNG and lossless WebP. Register your
+	// own for lossy WebP (smaller for photos) or for AVIF. The build fails
+	// if a format listed in Core.ImagePipeline.Formats has no encoder.
+	ImageEncoders map[string]imageutil.Encoder
+
+	// Optional. Used to write brotli (".br") siblings of public files when
+	// Core.PrecompressPublicFiles is set. Defaults to kit/brotli's pure-Go
+	// compressor, whose output is about the size of gzip's. Set this to
+	// wrap a dedicated brotli encoder for smaller files.
+	BrotliCompressor Compressor
+
 	dev
 	runtime
 	cleanSources   CleanSources
@@ -113,6 +151,7 @@ type CleanSources struct {
 	PublicStatic        string
 	CriticalCSSEntry    string
 	NonCriticalCSSEntry string
+	CSSBundles          map[string]CSSBundle // entries cleaned
 }
 
 func (c *Config) GetPrivateStaticDir() string {
@@ -160,8 +199,15 @@ type UserConfigCore struct {
 	DistDir          string
 	StaticAssetDirs  StaticAssetDirs
 	CSSEntryFiles    CSSEntryFiles
+	CSSBundles       map[string]CSSBundle
+	ImagePipeline    *ImagePipeline
 	PublicPathPrefix string
 	ServerOnlyMode   bool
+
+	// Writes compressed siblings (".gz" and ".br") of compressible public
+	// files in prod builds, for the static handler to serve to clients that
+	// accept them.
+	PrecompressPublicFiles bool
 }
 
 func (c *Config) GetConfigFile() string {
@@ -178,6 +224,17 @@ type CSSEntryFiles struct {
 	NonCritical string
 }
 
+// CSSBundle is a named CSS entry point, built alongside (and independently
+// of) the CSSEntryFiles. Bundle names may only contain letters, digits and
+// hyphens.
+type CSSBundle struct {
+	Entry string
+
+	// If true, the bundle is meant to be inlined into a <style> element
+	// instead of linked as a stylesheet.
+	Critical bool
+}
+
 type UserConfigVite struct {
 	JSPackageManagerBaseCmd string
 	JSPackageManagerCmdDir  string
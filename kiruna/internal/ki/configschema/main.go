@@ -70,8 +70,12 @@ var Core_Schema = jsonschema.RequiredObject(jsonschema.Def{
 		DistDir          jsonschema.Entry
 		StaticAssetDirs  jsonschema.Entry
 		CSSEntryFiles    jsonschema.Entry
+		CSSBundles       jsonschema.Entry
+		ImagePipeline    jsonschema.Entry
 		PublicPathPrefix jsonschema.Entry
 		ServerOnlyMode   jsonschema.Entry
+
+		PrecompressPublicFiles jsonschema.Entry
 	}{
 		DevBuildHook:     DevBuildHook_Schema,
 		ProdBuildHook:    ProdBuildHook_Schema,
@@ -79,8 +83,12 @@ var Core_Schema = jsonschema.RequiredObject(jsonschema.Def{
 		DistDir:          DistDir_Schema,
 		StaticAssetDirs:  StaticAssetDirs_Schema,
 		CSSEntryFiles:    CSSEntryFiles_Schema,
+		CSSBundles:       CSSBundles_Schema,
+		ImagePipeline:    ImagePipeline_Schema,
 		PublicPathPrefix: PublicPathPrefix_Schema,
 		ServerOnlyMode:   ServerOnlyMode_Schema,
+
+		PrecompressPublicFiles: PrecompressPublicFiles_Schema,
 	},
 })
 
@@ -170,6 +178,67 @@ var NonCritical_Schema = jsonschema.OptionalString(jsonschema.Def{
 	Examples:    []string{"./styles/main.css"},
 })
 
+/////////////////////////////////////////////////////////////////////
+/////// CORE SETTINGS -- CSS BUNDLES
+/////////////////////////////////////////////////////////////////////
+
+var CSSBundles_Schema = jsonschema.OptionalObject(jsonschema.Def{
+	Description:          `Named CSS bundles, each built to its own hashed file, keyed by name. Names may only contain letters, digits and hyphens. With River, bundles can be attached to specific routes, so that pages only ship the CSS they use.`,
+	AdditionalProperties: CSSBundle_Schema,
+})
+
+var CSSBundle_Schema = jsonschema.OptionalObject(jsonschema.Def{
+	RequiredChildren: []string{"Entry"},
+	Properties: struct {
+		Entry    jsonschema.Entry
+		Critical jsonschema.Entry
+	}{
+		Entry: jsonschema.RequiredString(jsonschema.Def{
+			Description: `Path to the bundle's CSS entry file.`,
+			Examples:    []string{"./styles/admin.css"},
+		}),
+		Critical: jsonschema.OptionalBoolean(jsonschema.Def{
+			Description: `If true, the bundle is inlined into a style element instead of linked as a stylesheet.`,
+			Default:     false,
+		}),
+	},
+})
+
+/////////////////////////////////////////////////////////////////////
+/////// CORE SETTINGS -- IMAGE PIPELINE
+/////////////////////////////////////////////////////////////////////
+
+var ImagePipeline_Schema = jsonschema.OptionalObject(jsonschema.Def{
+	Description:      `Opt-in processing of images in your public static dir into resized (and optionally re-encoded) variants, for responsive images.`,
+	RequiredChildren: []string{"Include", "Widths"},
+	Properties: struct {
+		Include jsonschema.Entry
+		Widths  jsonschema.Entry
+		Formats jsonschema.Entry
+		Quality jsonschema.Entry
+	}{
+		Include: jsonschema.RequiredArray(jsonschema.Def{
+			Description: `Glob patterns, relative to your public static dir, of the images to process. JPEG and PNG sources are supported.`,
+			Examples:    []string{`["images/**/*.jpg", "images/**/*.png"]`},
+			Items:       jsonschema.OptionalString(jsonschema.Def{}),
+		}),
+		Widths: jsonschema.RequiredArray(jsonschema.Def{
+			Description: `Widths, in pixels, to generate variants at. Widths at or above an image's own width are skipped.`,
+			Examples:    []string{`[320, 640, 1280]`},
+			Items:       jsonschema.OptionalNumber(jsonschema.Def{}),
+		}),
+		Formats: jsonschema.OptionalArray(jsonschema.Def{
+			Description: `Formats to generate variants in, in addition to each image's own format. JPEG, PNG and lossless WebP encoders are built in. Lossless WebP is usually larger than JPEG for photos, so register a lossy WebP encoder in your Kiruna Go config for those. Others (e.g., "avif") need an encoder registered there too, or the build fails.`,
+			Examples:    []string{`["webp"]`},
+			Items:       jsonschema.OptionalString(jsonschema.Def{}),
+		}),
+		Quality: jsonschema.OptionalNumber(jsonschema.Def{
+			Description: `Encoding quality, from 1 to 100.`,
+			Default:     80,
+		}),
+	},
+})
+
 /////////////////////////////////////////////////////////////////////
 /////// CORE SETTINGS -- PUBLIC PATH PREFIX
 /////////////////////////////////////////////////////////////////////
@@ -188,6 +257,15 @@ var ServerOnlyMode_Schema = jsonschema.OptionalBoolean(jsonschema.Def{
 	Default:     false,
 })
 
+/////////////////////////////////////////////////////////////////////
+/////// CORE SETTINGS -- PRECOMPRESS PUBLIC FILES
+/////////////////////////////////////////////////////////////////////
+
+var PrecompressPublicFiles_Schema = jsonschema.OptionalBoolean(jsonschema.Def{
+	Description: `If true, prod builds write gzip-compressed (".gz") siblings of compressible public files (JS, CSS, SVG, JSON, etc.) of at least 1 KB, and brotli-compressed (".br") ones, and the static handler serves them to clients that accept them. The built-in brotli compressor can be swapped out by setting a BrotliCompressor in your Kiruna Go config.`,
+	Default:     false,
+})
+
 /////////////////////////////////////////////////////////////////////
 /////// VITE SETTINGS
 /////////////////////////////////////////////////////////////////////
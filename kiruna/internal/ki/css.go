@@ -5,6 +5,8 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/sjc5/river/kit/htmlutil"
@@ -26,13 +28,8 @@ func (c *Config) getInitialStyleSheetLinkElement() (*template.HTML, error) {
 	url := c.GetStyleSheetURL()
 
 	if url != "" {
-		var sb strings.Builder
-		sb.WriteString(`<link rel="stylesheet" href="`)
-		sb.WriteString(url)
-		sb.WriteString(`" id="`)
-		sb.WriteString(StyleSheetElementID)
-		sb.WriteString(`" />`)
-		result = template.HTML(sb.String())
+		filename := strings.TrimPrefix(url, "/"+PUBLIC+"/")
+		result = c.toStyleSheetLinkElement(filename, url, StyleSheetElementID)
 	}
 
 	return &result, nil
@@ -156,3 +153,171 @@ func (c *Config) GetCriticalCSSStyleElementSha256Hash() string {
 	result, _ := c.runtime_cache.critical_css.Get()
 	return result.sha_256_hash
 }
+
+/////////////////////////////////////////////////////////////////////
+/////// NAMED CSS BUNDLES
+/////////////////////////////////////////////////////////////////////
+
+const cssBundleFilePrefix = "kiruna_css_"
+
+var cssBundleNameRegex = regexp.MustCompile(`^[a-zA-Z0-9-]+$`)
+
+func toCSSBundleRefFileName(name string) string {
+	return cssBundleFilePrefix + name + "_ref.txt"
+}
+
+// Returns the ID of the <style> or <link> element rendered for the named
+// CSS bundle (e.g., "kiruna-css-bundle-admin").
+func ToCSSBundleElementID(name string) string {
+	return "kiruna-css-bundle-" + name
+}
+
+type cssBundleStatus struct {
+	exists       bool
+	is_critical  bool
+	filename     string
+	url          string
+	code_str     string
+	link_el      template.HTML
+	style_el     template.HTML
+	sha_256_hash string
+}
+
+func cssBundlesKeyMaker(name string) string { return name }
+
+func (c *Config) getInitialCSSBundleStatus(name string) (*cssBundleStatus, error) {
+	bundle, ok := c._uc.Core.CSSBundles[name]
+	if !ok {
+		c.Logger.Error(fmt.Sprintf("no such CSS bundle: %s", name))
+		return &cssBundleStatus{}, nil
+	}
+
+	result := &cssBundleStatus{exists: true, is_critical: bundle.Critical}
+
+	base_fs, err := c.GetBaseFS()
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error getting FS: %v", err))
+		return nil, err
+	}
+
+	dist_kiruna_internal := c._dist.S().Static.S().Internal
+
+	// __LOCATION_ASSUMPTION: Inside "dist/static"
+	filename, err := fs.ReadFile(base_fs, filepath.Join(
+		dist_kiruna_internal.LastSegment(),
+		toCSSBundleRefFileName(name),
+	))
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error reading CSS bundle %s ref: %v", name, err))
+		return nil, err
+	}
+
+	result.filename = string(filename)
+	result.url = "/" + filepath.Join(PUBLIC, result.filename)
+
+	result.link_el = c.toStyleSheetLinkElement(result.filename, result.url, ToCSSBundleElementID(name))
+
+	if !bundle.Critical {
+		return result, nil
+	}
+
+	public_fs, err := c.GetPublicFS()
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error getting public FS: %v", err))
+		return nil, err
+	}
+
+	content, err := fs.ReadFile(public_fs, result.filename)
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error reading CSS bundle %s: %v", name, err))
+		return nil, err
+	}
+
+	result.code_str = string(content)
+
+	el := htmlutil.Element{
+		Tag:               "style",
+		TrustedAttributes: map[string]string{"id": ToCSSBundleElementID(name)},
+		InnerHTML:         template.HTML(result.code_str),
+	}
+
+	sha256Hash, err := htmlutil.AddSha256HashInline(&el, true)
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error handling CSP: %v", err))
+		return nil, err
+	}
+	result.sha_256_hash = sha256Hash
+
+	tpmlRes, err := htmlutil.RenderElement(&el)
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error rendering element: %v", err))
+		return nil, err
+	}
+
+	result.style_el = tpmlRes
+
+	return result, nil
+}
+
+func (c *Config) getCSSBundleStatus(name string) *cssBundleStatus {
+	result, _ := c.runtime_cache.css_bundles.Get(name)
+	if result == nil {
+		return &cssBundleStatus{}
+	}
+	return result
+}
+
+func (c *Config) GetCSSBundleNames() []string {
+	names := make([]string, 0, len(c._uc.Core.CSSBundles))
+	for name := range c._uc.Core.CSSBundles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (c *Config) GetCSSBundleExists(name string) bool {
+	return c.getCSSBundleStatus(name).exists
+}
+
+func (c *Config) GetCSSBundleIsCritical(name string) bool {
+	return c.getCSSBundleStatus(name).is_critical
+}
+
+// Returns the hashed filename of the bundle within the public static dir.
+func (c *Config) GetCSSBundleFilename(name string) string {
+	return c.getCSSBundleStatus(name).filename
+}
+
+func (c *Config) GetCSSBundleURL(name string) string {
+	return c.getCSSBundleStatus(name).url
+}
+
+func (c *Config) GetCSSBundleLinkElement(name string) template.HTML {
+	return c.getCSSBundleStatus(name).link_el
+}
+
+// Only available for critical bundles. Returns an empty string otherwise.
+func (c *Config) GetCSSBundleCSS(name string) string {
+	return c.getCSSBundleStatus(name).code_str
+}
+
+// Only available for critical bundles. Returns an empty string otherwise.
+func (c *Config) GetCSSBundleStyleElement(name string) template.HTML {
+	return c.getCSSBundleStatus(name).style_el
+}
+
+// Only available for critical bundles. Returns an empty string otherwise.
+func (c *Config) GetCSSBundleStyleElementSha256Hash(name string) string {
+	return c.getCSSBundleStatus(name).sha_256_hash
+}
+
+// Returns the bundle's <style> element if it is critical, or its <link>
+// element otherwise.
+func (c *Config) GetCSSBundleElement(name string) template.HTML {
+	status := c.getCSSBundleStatus(name)
+	if status.is_critical {
+		return status.style_el
+	}
+	return status.link_el
+}
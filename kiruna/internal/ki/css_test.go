@@ -1,6 +1,8 @@
 package ki
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"html/template"
 	"os"
 	"path/filepath"
@@ -126,3 +128,77 @@ func TestBuildCSS(t *testing.T) {
 		t.Errorf("Processed normal CSS = %v, want: %v", string(processedNormalCSS), minimizedNormalCSS)
 	}
 }
+
+func TestBuildCSSBundles(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.createTestFile(t, "critical.css", "body { color: red; }")
+	env.createTestFile(t, "main.css", "p { font-size: 16px; }")
+	env.createTestFile(t, "admin.css", `@import "./admin-tables.css"; h1 { color: blue; }`)
+	env.createTestFile(t, "admin-tables.css", "table { width: 100%; }")
+	env.createTestFile(t, "above-fold.css", "header { color: red; }")
+
+	bundles := map[string]CSSBundle{
+		"admin":      {Entry: filepath.Join(testRootDir, "admin.css")},
+		"above-fold": {Entry: filepath.Join(testRootDir, "above-fold.css"), Critical: true},
+	}
+	env.config._uc.Core.CSSBundles = bundles
+	env.config.cleanSources.CSSBundles = bundles
+
+	if err := env.config.buildCSS(); err != nil {
+		t.Fatalf("buildCSS() error = %v", err)
+	}
+
+	// Non-critical bundles are linked
+	filename := env.config.GetCSSBundleFilename("admin")
+	if !strings.HasPrefix(filename, "kiruna_css_admin_") || !strings.HasSuffix(filename, ".css") {
+		t.Fatalf("Invalid admin bundle filename: %v", filename)
+	}
+	content, err := os.ReadFile(filepath.Join(testRootDir, "dist/static/assets/public", filename))
+	if err != nil {
+		t.Fatalf("Failed to read admin bundle: %v", err)
+	}
+	if string(content) != "table{width:100%}h1{color:#00f}\n" {
+		t.Errorf("Processed admin bundle = %v", string(content))
+	}
+	if url := env.config.GetCSSBundleURL("admin"); url != "/public/"+filename {
+		t.Errorf("GetCSSBundleURL() = %v", url)
+	}
+	sum := sha256.Sum256(content)
+	integrity := "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+	if x := env.config.GetPublicFileIntegrity(filename); x != integrity {
+		t.Errorf("GetPublicFileIntegrity() = %v, want: %v", x, integrity)
+	}
+	expectedLink := template.HTML(`<link rel="stylesheet" href="/public/` + filename + `" id="kiruna-css-bundle-admin" integrity="` + integrity + `" />`)
+	if el := env.config.GetCSSBundleElement("admin"); el != expectedLink {
+		t.Errorf("GetCSSBundleElement() = %v, want: %v", el, expectedLink)
+	}
+	if env.config.GetCSSBundleStyleElement("admin") != "" {
+		t.Error("Expected no style element for a non-critical bundle")
+	}
+
+	// Critical bundles are inlined, but still written to a public file
+	if !env.config.GetCSSBundleIsCritical("above-fold") || env.config.GetCSSBundleCSS("above-fold") != "header{color:red}\n" {
+		t.Errorf("Unexpected critical bundle CSS: %v", env.config.GetCSSBundleCSS("above-fold"))
+	}
+	if el := string(env.config.GetCSSBundleElement("above-fold")); !strings.HasPrefix(el, "<style ") ||
+		!strings.Contains(el, `id="kiruna-css-bundle-above-fold"`) {
+		t.Errorf("Unexpected critical bundle element: %v", env.config.GetCSSBundleElement("above-fold"))
+	}
+	if env.config.GetCSSBundleStyleElementSha256Hash("above-fold") == "" {
+		t.Error("Expected a hash for the critical bundle's style element")
+	}
+	if !strings.HasPrefix(env.config.GetCSSBundleFilename("above-fold"), "kiruna_css_above-fold_") {
+		t.Errorf("Invalid above-fold bundle filename: %v", env.config.GetCSSBundleFilename("above-fold"))
+	}
+
+	// Imports are tracked per bundle, for dev rebuilds
+	if _, ok := cssReliedUponFiles[cssBundleKey("admin")][filepath.Join(testRootDir, "admin-tables.css")]; !ok {
+		t.Errorf("Expected admin-tables.css to be tracked as an import of the admin bundle")
+	}
+
+	if env.config.GetCSSBundleExists("missing") || env.config.GetCSSBundleURL("missing") != "" {
+		t.Error("Expected nothing for a missing bundle")
+	}
+}
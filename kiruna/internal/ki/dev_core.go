@@ -54,6 +54,8 @@ func (c *Config) MustStartDev(_isRebuild ...bool) {
 		c.panic("failed to compile go binary", err)
 	}
 
+	go c.refreshGoDepGraph()
+
 	go c.run_go_binary()
 	go c.setup_browser_refresh_mux()
 
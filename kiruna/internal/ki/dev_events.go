@@ -40,7 +40,7 @@ func (c *Config) process_batched_events(events []fsnotify.Event) {
 
 		if fileInfo.IsDir() {
 			if evt.Has(fsnotify.Create) || evt.Has(fsnotify.Rename) {
-				if err := c.add_directory_to_watcher(evt.Name); err != nil {
+				if err := c.add_directory_to_watcher(evt.Name, false); err != nil {
 					c.Logger.Error(fmt.Sprintf("error: failed to add directory to watcher: %v", err))
 					continue
 				}
@@ -53,17 +53,30 @@ func (c *Config) process_batched_events(events []fsnotify.Event) {
 			continue
 		}
 
+		// Skips no-op saves (including formatter-only changes to Go files)
+		if c.getIsContentUnchanged(evt.Name) {
+			continue
+		}
+
+		// Skips Go files that can't affect the app's binary, unless the
+		// user explicitly watches them
+		if evtDetails.isGo && !evtDetails.isGoEmbed && !evtDetails.isUserWatched && c.getIsOutsideGoDepGraph(evt.Name) {
+			continue
+		}
+
 		wfc := evtDetails.wfc
 		if wfc == nil {
 			wfc = &WatchedFile{}
 		}
 
-		if _, alreadyHandled := wfcsAlreadyHandled[wfc.Pattern]; alreadyHandled {
-			continue
+		// Plain CSS changes are merged below rather than deduped by pattern
+		if !getIsPlainCSSChange(evtDetails) {
+			if _, alreadyHandled := wfcsAlreadyHandled[wfc.Pattern]; alreadyHandled {
+				continue
+			}
+			wfcsAlreadyHandled[wfc.Pattern] = true
 		}
 
-		wfcsAlreadyHandled[wfc.Pattern] = true
-
 		if !isGoOrNeedsHardReloadEvenIfNonGo {
 			isGoOrNeedsHardReloadEvenIfNonGo = evtDetails.isGo
 		}
@@ -78,6 +91,13 @@ func (c *Config) process_batched_events(events []fsnotify.Event) {
 		return
 	}
 
+	// A batch of nothing but plain CSS changes is hot reloaded as one change
+	if len(relevantFileChanges) > 1 {
+		if merged := mergePlainCSSChanges(relevantFileChanges); merged != nil {
+			relevantFileChanges = map[string]*EvtDetails{merged.evt.Name: merged}
+		}
+	}
+
 	hasMultipleEvents := len(relevantFileChanges) > 1
 
 	if !hasMultipleEvents {
@@ -252,27 +272,45 @@ func (c *Config) mustHandleFileChange(
 	}
 	// At this point, we know it's a CSS file
 
-	cssType := changeTypeNormalCSS
-	if evtDetails.isCriticalCSS {
-		cssType = changeTypeCriticalCSS
-	}
-
 	c.Logger.Info("Hot reloading browser (CSS)")
-	rfp := refreshFilePayload{
-		ChangeType: cssType,
 
-		// These must be called AFTER ProcessCSS
-		CriticalCSS:  base64.StdEncoding.EncodeToString([]byte(c.GetCriticalCSS())),
-		NormalCSSURL: c.GetStyleSheetURL(),
+	if evtDetails.isCriticalCSS || evtDetails.isNormalCSS {
+		cssType := changeTypeNormalCSS
+		if evtDetails.isCriticalCSS {
+			cssType = changeTypeCriticalCSS
+		}
+
+		rfp := refreshFilePayload{
+			ChangeType: cssType,
+
+			// These must be called AFTER ProcessCSS
+			CriticalCSS:  base64.StdEncoding.EncodeToString([]byte(c.GetCriticalCSS())),
+			NormalCSSURL: c.GetStyleSheetURL(),
+		}
+		c.must_reload_broadcast(rfp, false)
+	}
+
+	for _, name := range evtDetails.cssBundleNames {
+		rfp := refreshFilePayload{
+			ChangeType:     changeTypeCSSBundle,
+			CSSBundleName:  name,
+			CSSBundleURL:   c.GetCSSBundleURL(name),
+			CSSBundleStyle: base64.StdEncoding.EncodeToString([]byte(c.GetCSSBundleCSS(name))),
+		}
+		c.must_reload_broadcast(rfp, false)
 	}
-	c.must_reload_broadcast(rfp, false)
 
 	return nil
 }
 
 func (c *Config) callback(wfc *WatchedFile, evtDetails *EvtDetails) error {
 	if evtDetails.isGo {
-		return c.compile_go_binary()
+		if err := c.compile_go_binary(); err != nil {
+			return err
+		}
+		// Imports (and embeds) may have changed
+		go c.refreshGoDepGraph()
+		return nil
 	}
 
 	if evtDetails.isKirunaCSS {
@@ -281,9 +319,13 @@ func (c *Config) callback(wfc *WatchedFile, evtDetails *EvtDetails) error {
 		}
 		if evtDetails.isCriticalCSS {
 			c.processCSSCritical()
-		} else {
+		}
+		if evtDetails.isNormalCSS {
 			c.processCSSNormal()
 		}
+		for _, name := range evtDetails.cssBundleNames {
+			c.processCSSBundle(name)
+		}
 	}
 
 	return c.runOtherFileBuild(wfc, evtDetails)
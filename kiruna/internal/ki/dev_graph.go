@@ -0,0 +1,232 @@
+package ki
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// CONTENT HASHES
+/////////////////////////////////////////////////////////////////////
+
+// Go files are hashed by their token stream, so that changes a formatter
+// would make (whitespace, alignment, line breaks that don't end statements)
+// don't count as changes. Comments are kept, since they can carry
+// directives (e.g., "//go:build" or "//go:embed").
+func getContentHash(path string) ([32]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if filepath.Ext(path) != ".go" {
+		return sha256.Sum256(content), nil
+	}
+
+	var normalized bytes.Buffer
+	var s scanner.Scanner
+	var hadErrs bool
+	fset := token.NewFileSet()
+	file := fset.AddFile(path, fset.Base(), len(content))
+	s.Init(file, content, func(token.Position, string) { hadErrs = true }, scanner.ScanComments)
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		normalized.WriteString(tok.String())
+		// Automatically inserted semicolons have a literal of "\n"
+		if tok != token.SEMICOLON {
+			normalized.WriteString(lit)
+		}
+		normalized.WriteByte(0)
+	}
+	if hadErrs {
+		return sha256.Sum256(content), nil
+	}
+	return sha256.Sum256(normalized.Bytes()), nil
+}
+
+// Records the content hashes of files that exist before any changes are
+// made, so that even the first no-op save of a file is recognized.
+func (c *Config) seedContentHashes(paths []string) {
+	for _, path := range paths {
+		if hash, err := getContentHash(path); err == nil {
+			c.contentHashes.LoadOrStore(path, hash)
+		}
+	}
+}
+
+// Returns true if the file's content is the same as the last time it was
+// seen. Always records the current content hash.
+func (c *Config) getIsContentUnchanged(path string) bool {
+	hash, err := getContentHash(path)
+	if err != nil {
+		c.contentHashes.Delete(path)
+		return false
+	}
+	oldHash, existed := c.contentHashes.Load(path)
+	c.contentHashes.Store(path, hash)
+	return existed && oldHash == hash
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// GO DEPENDENCY GRAPH
+/////////////////////////////////////////////////////////////////////
+
+type goDepGraph struct {
+	// Dirs of the app's non-standard-library packages (and their
+	// transitive dependencies)
+	appPkgDirs map[string]struct{}
+	// Dirs of all packages in the main module
+	modulePkgDirs map[string]struct{}
+	// Non-Go files embedded (via go:embed) into the app's packages
+	embedFiles map[string]struct{}
+}
+
+// Loads the app's package graph with "go list". Should be called again
+// whenever the app's imports might have changed (i.e., after compiling).
+func (c *Config) refreshGoDepGraph() {
+	if c._uc.Core.MainAppEntry == "" {
+		return
+	}
+
+	graph := &goDepGraph{
+		appPkgDirs:    make(map[string]struct{}),
+		modulePkgDirs: make(map[string]struct{}),
+		embedFiles:    make(map[string]struct{}),
+	}
+
+	err := runGoList(func(line string) {
+		dir, embedFiles, _ := strings.Cut(line, "|")
+		graph.appPkgDirs[dir] = struct{}{}
+		if embedFiles == "" {
+			return
+		}
+		for _, f := range strings.Split(embedFiles, "|") {
+			graph.embedFiles[filepath.Join(dir, f)] = struct{}{}
+		}
+	}, "-e", "-deps", "-f", `{{if not .Standard}}{{.Dir}}{{range .EmbedFiles}}|{{.}}{{end}}{{end}}`, c._uc.Core.MainAppEntry)
+	if err == nil {
+		err = runGoList(func(line string) {
+			graph.modulePkgDirs[line] = struct{}{}
+		}, "-e", "-f", "{{.Dir}}", "./...")
+	}
+	if err != nil {
+		// Without a graph, every Go change is treated as relevant
+		c.Logger.Warn(fmt.Sprintf("could not load Go dependency graph: %v", err))
+		graph = nil
+	}
+
+	c.goDepGraphMu.Lock()
+	c.goDepGraph = graph
+	c.goDepGraphMu.Unlock()
+}
+
+func runGoList(onLine func(line string), args ...string) error {
+	cmd := exec.Command("go", append([]string{"list"}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	lines := bufio.NewScanner(bytes.NewReader(output))
+	for lines.Scan() {
+		if line := strings.TrimSpace(lines.Text()); line != "" {
+			onLine(line)
+		}
+	}
+	return lines.Err()
+}
+
+// Returns true if a change to the Go file can't affect the app's binary,
+// because it's a test file, or because it belongs to a package in the
+// main module that the app doesn't import. Files in packages the graph
+// doesn't know about (e.g., new ones) are assumed to be relevant. The graph
+// only covers MainAppEntry, and a DevBuildHook can be any command (e.g.,
+// "go run ./cmd/build"), so with one set, every package in the module is
+// assumed to be relevant.
+func (c *Config) getIsOutsideGoDepGraph(path string) bool {
+	if strings.HasSuffix(path, "_test.go") {
+		return true
+	}
+	if c._uc.Core.DevBuildHook != "" {
+		return false
+	}
+	c.goDepGraphMu.RLock()
+	defer c.goDepGraphMu.RUnlock()
+	if c.goDepGraph == nil {
+		return false
+	}
+	dir := filepath.Dir(toAbsPath(path))
+	_, isModulePkg := c.goDepGraph.modulePkgDirs[dir]
+	_, isAppPkg := c.goDepGraph.appPkgDirs[dir]
+	return isModulePkg && !isAppPkg
+}
+
+// Returns true if the file is embedded into one of the app's packages, in
+// which case changing it requires recompiling the app.
+func (c *Config) getIsGoEmbedFile(path string) bool {
+	c.goDepGraphMu.RLock()
+	defer c.goDepGraphMu.RUnlock()
+	if c.goDepGraph == nil {
+		return false
+	}
+	_, ok := c.goDepGraph.embedFiles[toAbsPath(path)]
+	return ok
+}
+
+func toAbsPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// BATCHES
+/////////////////////////////////////////////////////////////////////
+
+// Returns true if the change only needs Kiruna's CSS processing and a CSS
+// hot reload (i.e., no hooks, rebuild flags or revalidation apply to it).
+func getIsPlainCSSChange(evtDetails *EvtDetails) bool {
+	if !evtDetails.isKirunaCSS || evtDetails.isGo {
+		return false
+	}
+	wfc := evtDetails.wfc
+	return wfc == nil || (len(wfc.OnChangeHooks) == 0 && !wfc.RecompileGoBinary && !wfc.RestartApp &&
+		!wfc.RunClientDefinedRevalidateFunc && !wfc.RunOnChangeOnly)
+}
+
+// If every change in the batch is a plain CSS change, merges them into a
+// single change covering every affected stylesheet, so that the batch can
+// be hot reloaded instead of hard reloaded. Returns nil otherwise.
+func mergePlainCSSChanges(changes map[string]*EvtDetails) *EvtDetails {
+	var merged *EvtDetails
+	seenBundles := make(map[string]struct{})
+	for _, evtDetails := range changes {
+		if !getIsPlainCSSChange(evtDetails) {
+			return nil
+		}
+		if merged == nil {
+			merged = &EvtDetails{evt: evtDetails.evt, isKirunaCSS: true}
+		}
+		merged.isCriticalCSS = merged.isCriticalCSS || evtDetails.isCriticalCSS
+		merged.isNormalCSS = merged.isNormalCSS || evtDetails.isNormalCSS
+		for _, name := range evtDetails.cssBundleNames {
+			if _, ok := seenBundles[name]; !ok {
+				seenBundles[name] = struct{}{}
+				merged.cssBundleNames = append(merged.cssBundleNames, name)
+			}
+		}
+	}
+	return merged
+}
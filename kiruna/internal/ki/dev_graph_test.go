@@ -0,0 +1,199 @@
+package ki
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sjc5/river/kit/colorlog"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetContentHash(t *testing.T) {
+	dir := t.TempDir()
+	hashOf := func(name, content string) [32]byte {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		writeFile(t, path, content)
+		hash, err := getContentHash(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hash
+	}
+
+	original := hashOf("a.go", "package a\n\nfunc A() int {\n\treturn 1\n}\n")
+	if hashOf("a.go", "package a\nfunc A() int   { return 1; }") != original {
+		t.Error("expected formatting changes not to change the hash of a Go file")
+	}
+	if hashOf("a.go", "package a\n\nfunc A() int {\n\treturn 2\n}\n") == original {
+		t.Error("expected code changes to change the hash of a Go file")
+	}
+	if hashOf("a.go", "//go:build ignore\n\npackage a\n\nfunc A() int {\n\treturn 1\n}\n") == original {
+		t.Error("expected comment changes to change the hash of a Go file")
+	}
+
+	css := hashOf("a.css", "body{color:red}")
+	if hashOf("a.css", "body { color: red }") == css {
+		t.Error("expected any change to change the hash of a non-Go file")
+	}
+}
+
+func TestGetIsContentUnchanged(t *testing.T) {
+	c := &Config{}
+	path := filepath.Join(t.TempDir(), "main.go")
+
+	writeFile(t, path, "package main\n")
+	if c.getIsContentUnchanged(path) {
+		t.Error("expected an unseen file to count as changed")
+	}
+	if !c.getIsContentUnchanged(path) {
+		t.Error("expected a no-op save to count as unchanged")
+	}
+	writeFile(t, path, "package main\n\nfunc main() {}\n")
+	if c.getIsContentUnchanged(path) {
+		t.Error("expected a real change to count as changed")
+	}
+
+	seeded := filepath.Join(t.TempDir(), "seeded.css")
+	writeFile(t, seeded, "body{}")
+	c.seedContentHashes([]string{seeded})
+	if !c.getIsContentUnchanged(seeded) {
+		t.Error("expected the first no-op save of a seeded file to count as unchanged")
+	}
+}
+
+func TestGoDepGraph(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/app\n\ngo 1.24\n")
+	writeFile(t, filepath.Join(dir, "cmd/app/main.go"), "package main\n\nimport _ \"example.com/app/used\"\n\nfunc main() {}\n")
+	writeFile(t, filepath.Join(dir, "used/used.go"), "package used\n\nimport _ \"embed\"\n\n//go:embed tmpl.html\nvar Tmpl string\n")
+	writeFile(t, filepath.Join(dir, "used/tmpl.html"), "<p></p>")
+	writeFile(t, filepath.Join(dir, "used/used_test.go"), "package used\n")
+	writeFile(t, filepath.Join(dir, "unused/unused.go"), "package unused\n")
+	t.Chdir(dir)
+
+	c := &Config{
+		Logger: colorlog.New("ik_test"),
+		_uc:    &UserConfig{Core: &UserConfigCore{MainAppEntry: "./cmd/app"}},
+	}
+
+	// Before the graph is loaded, nothing but test files is skipped
+	if c.getIsOutsideGoDepGraph("unused/unused.go") {
+		t.Error("expected Go files to be relevant without a graph")
+	}
+
+	c.refreshGoDepGraph()
+	if c.goDepGraph == nil {
+		t.Fatal("expected a Go dependency graph")
+	}
+
+	tests := []struct {
+		path    string
+		outside bool
+	}{
+		{"cmd/app/main.go", false},
+		{"used/used.go", false},
+		{"used/used_test.go", true},
+		{"unused/unused.go", true},
+		{"brandnew/new.go", false},
+	}
+	for _, tt := range tests {
+		if got := c.getIsOutsideGoDepGraph(tt.path); got != tt.outside {
+			t.Errorf("getIsOutsideGoDepGraph(%s) = %v, want: %v", tt.path, got, tt.outside)
+		}
+	}
+
+	if !c.getIsGoEmbedFile("used/tmpl.html") {
+		t.Error("expected used/tmpl.html to be an embed file")
+	}
+	if c.getIsGoEmbedFile("used/used.go") {
+		t.Error("expected used/used.go not to be an embed file")
+	}
+
+	// The dev build hook may import anything in the module
+	c._uc.Core.DevBuildHook = "go run ./cmd/build"
+	if c.getIsOutsideGoDepGraph("unused/unused.go") {
+		t.Error("expected every package to be relevant with a DevBuildHook")
+	}
+	if !c.getIsOutsideGoDepGraph("used/used_test.go") {
+		t.Error("expected test files to be skipped with a DevBuildHook")
+	}
+}
+
+func TestAddDirectoryToWatcherSeedsHashes(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "nested", "existing.css")
+	writeFile(t, existing, "body{}")
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+	c := &Config{}
+	c.watcher = watcher
+
+	if err := c.add_directory_to_watcher(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	// Seeded by the time the directories are watched, with no goroutine to
+	// wait for
+	if !c.getIsContentUnchanged(existing) {
+		t.Error("expected an existing file to be seeded before add_directory_to_watcher returns")
+	}
+	if got := len(watcher.WatchList()); got != 2 {
+		t.Errorf("expected 2 watched dirs, got %d", got)
+	}
+
+	later := filepath.Join(t.TempDir(), "later.css")
+	writeFile(t, later, "body{}")
+	if err := c.add_directory_to_watcher(filepath.Dir(later), false); err != nil {
+		t.Fatal(err)
+	}
+	if c.getIsContentUnchanged(later) {
+		t.Error("expected files in later directories not to be seeded")
+	}
+}
+
+func TestMergePlainCSSChanges(t *testing.T) {
+	evt := func(name string) *fsnotify.Event { return &fsnotify.Event{Name: name, Op: fsnotify.Write} }
+
+	merged := mergePlainCSSChanges(map[string]*EvtDetails{
+		"a.css": {evt: evt("a.css"), isKirunaCSS: true, isCriticalCSS: true},
+		"b.css": {evt: evt("b.css"), isKirunaCSS: true, cssBundleNames: []string{"admin"}},
+		"c.css": {evt: evt("c.css"), isKirunaCSS: true, cssBundleNames: []string{"admin", "docs"}, wfc: &WatchedFile{}},
+	})
+	if merged == nil {
+		t.Fatal("expected CSS-only changes to merge")
+	}
+	if !merged.isKirunaCSS || !merged.isCriticalCSS || merged.isNormalCSS || len(merged.cssBundleNames) != 2 {
+		t.Errorf("unexpected merged change %+v", merged)
+	}
+
+	if mergePlainCSSChanges(map[string]*EvtDetails{
+		"a.css":   {evt: evt("a.css"), isKirunaCSS: true, isNormalCSS: true},
+		"main.go": {evt: evt("main.go"), isGo: true},
+	}) != nil {
+		t.Error("expected a batch with Go changes not to merge")
+	}
+
+	if mergePlainCSSChanges(map[string]*EvtDetails{
+		"a.css": {evt: evt("a.css"), isKirunaCSS: true, isNormalCSS: true},
+		"b.css": {evt: evt("b.css"), isKirunaCSS: true, isNormalCSS: true, wfc: &WatchedFile{
+			OnChangeHooks: []OnChangeHook{{Cmd: "echo"}},
+		}},
+	}) != nil {
+		t.Error("expected a batch with hooked CSS changes not to merge")
+	}
+}
@@ -86,8 +86,13 @@ func (c *Config) kill_browser_refresh_mux() {
 /////// ADD DIRECTORY TO WATCHER
 /////////////////////////////////////////////////////////////////////
 
-func (c *Config) add_directory_to_watcher(path string) error {
-	return filepath.Walk(path, func(walkedPath string, info os.FileInfo, err error) error {
+// Only seed content hashes on the initial walk. Files in directories created
+// later are new, and must not look unchanged when their events come in.
+// Seeding finishes before any directory is watched, so every event compares
+// against a file's content from before the change.
+func (c *Config) add_directory_to_watcher(path string, seedContentHashes bool) error {
+	var dirs, files []string
+	err := filepath.Walk(path, func(walkedPath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return fmt.Errorf("error walking path: %v", err)
 		}
@@ -95,13 +100,24 @@ func (c *Config) add_directory_to_watcher(path string) error {
 			if c.get_is_ignored(walkedPath, c.ignoredDirPatterns) {
 				return filepath.SkipDir
 			}
-			err := c.watcher.Add(walkedPath)
-			if err != nil {
-				return fmt.Errorf("error adding directory to watcher: %v", err)
-			}
+			dirs = append(dirs, walkedPath)
+		} else if info.Mode().IsRegular() && !c.get_is_ignored(walkedPath, c.ignoredFilePatterns) {
+			files = append(files, walkedPath)
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	if seedContentHashes {
+		c.seedContentHashes(files)
+	}
+	for _, dir := range dirs {
+		if err := c.watcher.Add(dir); err != nil {
+			return fmt.Errorf("error adding directory to watcher: %v", err)
+		}
+	}
+	return nil
 }
 
 /////////////////////////////////////////////////////////////////////
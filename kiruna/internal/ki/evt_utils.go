@@ -15,8 +15,11 @@ type EvtDetails struct {
 	isOther             bool
 	isCriticalCSS       bool
 	isNormalCSS         bool
+	cssBundleNames      []string
 	isKirunaCSS         bool
+	isGoEmbed           bool // embedded into the app's binary via go:embed
 	wfc                 *WatchedFile
+	isUserWatched       bool // wfc is from the user's Watch.Include
 	isNonEmptyCHMODOnly bool
 }
 
@@ -25,15 +28,23 @@ func (c *Config) getEvtDetails(evt fsnotify.Event) *EvtDetails {
 		return nil
 	}
 
+	var cssBundleNames []string
+
 	cssImportURLsMu.RLock()
-	_, isImportedCritical := criticalReliedUponFiles[evt.Name]
-	_, isImportedNormal := normalReliedUponFiles[evt.Name]
+	_, isImportedCritical := cssReliedUponFiles["critical"][evt.Name]
+	_, isImportedNormal := cssReliedUponFiles["normal"][evt.Name]
+	for name, bundle := range c.cleanSources.CSSBundles {
+		_, isImported := cssReliedUponFiles[cssBundleKey(name)][evt.Name]
+		if evt.Name == bundle.Entry || isImported {
+			cssBundleNames = append(cssBundleNames, name)
+		}
+	}
 	cssImportURLsMu.RUnlock()
 
 	isCriticalCSS := evt.Name == c.cleanSources.CriticalCSSEntry || isImportedCritical
 	isNormalCSS := evt.Name == c.cleanSources.NonCriticalCSSEntry || isImportedNormal
 
-	isKirunaCSS := isCriticalCSS || isNormalCSS
+	isKirunaCSS := isCriticalCSS || isNormalCSS || len(cssBundleNames) > 0
 
 	var matchingWatchedFile *WatchedFile
 
@@ -45,6 +56,8 @@ func (c *Config) getEvtDetails(evt fsnotify.Event) *EvtDetails {
 		}
 	}
 
+	isUserWatched := matchingWatchedFile != nil
+
 	if matchingWatchedFile == nil {
 		for _, wfc := range c.defaultWatchedFiles {
 			isMatch := c.get_is_match(potentialMatch{pattern: wfc.Pattern, path: evt.Name})
@@ -56,6 +69,11 @@ func (c *Config) getEvtDetails(evt fsnotify.Event) *EvtDetails {
 	}
 
 	isGo := filepath.Ext(evt.Name) == ".go"
+
+	// Embedded files are part of the binary, so they are handled like Go
+	isGoEmbed := !isGo && !isKirunaCSS && c.getIsGoEmbedFile(evt.Name)
+	isGo = isGo || isGoEmbed
+
 	if isGo && matchingWatchedFile != nil && matchingWatchedFile.TreatAsNonGo {
 		isGo = false
 	}
@@ -75,7 +93,10 @@ func (c *Config) getEvtDetails(evt fsnotify.Event) *EvtDetails {
 		isIgnored:           isIgnored,
 		isCriticalCSS:       isCriticalCSS,
 		isNormalCSS:         isNormalCSS,
+		cssBundleNames:      cssBundleNames,
+		isGoEmbed:           isGoEmbed,
 		wfc:                 matchingWatchedFile,
+		isUserWatched:       isUserWatched,
 		isNonEmptyCHMODOnly: c.getIsNonEmptyCHMODOnly(evt),
 	}
 }
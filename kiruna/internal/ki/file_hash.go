@@ -11,10 +11,18 @@ import (
 )
 
 func getHashedFilenameFromPath(filePath string, originalFileName string) (string, error) {
-	file, err := os.Open(filePath)
+	hash, err := getSha256FromPath(filePath)
 	if err != nil {
 		return "", err
 	}
+	return toOutputFileName(hash, originalFileName), nil
+}
+
+func getSha256FromPath(filePath string) (hash.Hash, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
 	defer file.Close()
 
 	hash := sha256.New()
@@ -28,11 +36,11 @@ func getHashedFilenameFromPath(filePath string, originalFileName string) (string
 			break
 		}
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 
-	return toOutputFileName(hash, originalFileName), nil
+	return hash, nil
 }
 
 func getHashedFilenameFromBytes(content []byte, originalFileName string) string {
@@ -82,10 +82,14 @@ func (c *Config) savePublicFileMapJSToInternalPublicDir(mapToSave FileMap) error
 		return fmt.Errorf("error writing to file: %v", err)
 	}
 
-	return os.WriteFile(filepath.Join(
+	outputFile := filepath.Join(
 		c._dist.S().Static.S().Assets.S().Public.S().PublicInternal.FullPath(),
 		hashedFilename,
-	), bytes, 0644)
+	)
+	if err := os.WriteFile(outputFile, bytes, 0644); err != nil {
+		return err
+	}
+	return c.precompressFile(outputFile, bytes)
 }
 
 type publicFileMapDetails struct {
@@ -109,6 +113,9 @@ func (c *Config) getInitialPublicFileMapDetails() (*publicFileMapDetails, error)
 		Tag:        "link",
 		Attributes: map[string]string{"rel": "modulepreload", "href": publicFileMapURL},
 	}
+	if integrity := c.GetPublicFileIntegrity(strings.TrimPrefix(publicFileMapURL, "/"+PUBLIC+"/")); integrity != "" {
+		linkEl.Attributes["integrity"] = integrity
+	}
 
 	scriptEl := htmlutil.Element{
 		Tag:        "script",
@@ -85,9 +85,13 @@ func setupTestEnv(t *testing.T) *testEnv {
 		stylesheet_link_el:      safecache.New(c.getInitialStyleSheetLinkElement, GetIsDev),
 		stylesheet_url:          safecache.New(c.getInitialStyleSheetURL, GetIsDev),
 		critical_css:            safecache.New(c.getInitialCriticalCSSStatus, GetIsDev),
+		css_bundles:             safecache.NewMap(c.getInitialCSSBundleStatus, cssBundlesKeyMaker, nil),
 		public_filemap_from_gob: safecache.New(c.getInitialPublicFileMapFromGobRuntime, nil),
 		public_filemap_url:      safecache.New(c.getInitialPublicFileMapURL, GetIsDev),
 		public_urls:             safecache.NewMap(c.getInitialPublicURL, publicURLsKeyMaker, nil),
+		public_imagemap:         safecache.New(c.getInitialPublicImageMap, nil),
+		precompressed_encodings: safecache.New(c.getInitialPrecompressedEncodings, nil),
+		public_integrities:      safecache.NewMap(c.getInitialPublicFileIntegrity, publicIntegritiesKeyMaker, nil),
 	}
 
 	// Initialize dev cache if needed
@@ -0,0 +1,437 @@
+package ki
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/sjc5/river/kit/fsutil"
+	"github.com/sjc5/river/kit/imageutil"
+	"github.com/sjc5/river/kit/tsgen"
+	"github.com/sjc5/river/kit/typed"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// IMAGE PIPELINE
+/////////////////////////////////////////////////////////////////////
+
+const PublicImageMapGobName = "public_imagemap.gob"
+
+// ImagePipeline configures the opt-in processing of images in the public
+// static dir. Each matching image is still copied as-is (with a hashed
+// filename), and resized variants are written alongside it.
+type ImagePipeline struct {
+	// Glob patterns, relative to the public static dir, of the images to
+	// process (e.g., "images/**/*.jpg"). JPEG and PNG sources are supported.
+	Include []string
+
+	// Widths, in pixels, to generate variants at. Widths at or above an
+	// image's own width are skipped.
+	Widths []int
+
+	// Formats to generate variants in, in addition to the source's own
+	// format (e.g., "webp", "avif"). JPEG, PNG and lossless WebP encoders
+	// are built in. Lossless WebP suits PNG sources, but is usually larger
+	// than a JPEG of a photo, so register a lossy WebP encoder in
+	// Config.ImageEncoders for those. Other formats (e.g., AVIF) must be
+	// registered there too, or the build fails.
+	Formats []string
+
+	// Encoding quality, from 1 to 100. Defaults to 80.
+	Quality int
+}
+
+type ImageVariant struct {
+	Val    string // filename within the public dist dir
+	Width  int
+	Format string
+}
+
+// ImageInfo describes a processed public image and its variants.
+type ImageInfo struct {
+	Val      string // the original's hashed filename, as in the FileMap
+	Width    int
+	Height   int
+	Format   string
+	Variants []ImageVariant
+}
+
+type ImageMap map[string]ImageInfo
+
+var imageMimeTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"webp": "image/webp",
+	"avif": "image/avif",
+}
+
+var imageFormatExts = map[string]string{"jpeg": ".jpg"}
+
+// Modern formats are preferred, when a browser supports them
+var imageFormatPreference = []string{"avif", "webp"}
+
+func (c *Config) getIsImagePipelineMatch(relativePath string) bool {
+	pipeline := c._uc.Core.ImagePipeline
+	if pipeline == nil {
+		return false
+	}
+	for _, pattern := range pipeline.Include {
+		if isMatch, _ := doublestar.Match(pattern, relativePath); isMatch {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) getImageEncoder(format string) imageutil.Encoder {
+	if enc, ok := c.ImageEncoders[format]; ok {
+		return enc
+	}
+	enc, _ := imageutil.GetBuiltInEncoder(format)
+	return enc
+}
+
+func normalizeImageFormat(format string) string {
+	format = strings.ToLower(format)
+	if format == "jpg" {
+		return "jpeg"
+	}
+	return format
+}
+
+// Fails if any of the pipeline's extra formats has no encoder, so that a
+// missing encoder doesn't silently ship pages without those variants.
+func (c *Config) validateImagePipeline() error {
+	var missing []string
+	for _, f := range c._uc.Core.ImagePipeline.Formats {
+		if f = normalizeImageFormat(f); c.getImageEncoder(f) == nil && !slices.Contains(missing, f) {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf(
+			"image pipeline: no encoder for format(s) %s (register one in Config.ImageEncoders)",
+			strings.Join(missing, ", "),
+		)
+	}
+	return nil
+}
+
+// Writes the image's variants to distDir and returns its info. Variant
+// filenames derive from the original's hashed filename, so during dev
+// rebuilds, variants that already exist are up to date and are skipped.
+// Unchanged originals (per oldImageMap) aren't even read, unless one of
+// their variants is missing.
+func (c *Config) processImage(fi fileInfo, hashedName, distDir string, isDevRebuild bool, oldImageMap ImageMap) (*ImageInfo, error) {
+	var src []byte
+	readSrc := func() error {
+		var err error
+		if src, err = os.ReadFile(fi.path); err != nil {
+			return fmt.Errorf("error reading image: %v", err)
+		}
+		return nil
+	}
+
+	var info *ImageInfo
+	if oldInfo, ok := oldImageMap[fi.relativePath]; isDevRebuild && ok && oldInfo.Val == hashedName {
+		info = &ImageInfo{Val: hashedName, Width: oldInfo.Width, Height: oldInfo.Height, Format: oldInfo.Format}
+	} else {
+		if err := readSrc(); err != nil {
+			return nil, err
+		}
+		cfg, srcFormat, err := image.DecodeConfig(bytes.NewReader(src))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding image %s: %v", fi.relativePath, err)
+		}
+		info = &ImageInfo{Val: hashedName, Width: cfg.Width, Height: cfg.Height, Format: srcFormat}
+	}
+	srcFormat := info.Format
+
+	pipeline := c._uc.Core.ImagePipeline
+	quality := pipeline.Quality
+	if quality <= 0 {
+		quality = 80
+	}
+
+	var widths []int
+	for _, w := range pipeline.Widths {
+		if w > 0 && w < info.Width && !slices.Contains(widths, w) {
+			widths = append(widths, w)
+		}
+	}
+	slices.Sort(widths)
+
+	formats := []string{srcFormat}
+	for _, f := range pipeline.Formats {
+		if f = normalizeImageFormat(f); !slices.Contains(formats, f) {
+			formats = append(formats, f)
+		}
+	}
+
+	var decoded image.Image
+	basename := strings.TrimSuffix(hashedName, filepath.Ext(hashedName))
+
+	for _, format := range formats {
+		enc := c.getImageEncoder(format)
+		if enc == nil {
+			// Only possible for the source's own format, since
+			// validateImagePipeline has checked the others
+			c.Logger.Warn(fmt.Sprintf("no image encoder registered for format %s, skipping", format))
+			continue
+		}
+
+		formatWidths := widths
+		if format != srcFormat {
+			// The original already covers its own format at full width
+			formatWidths = append(slices.Clone(widths), info.Width)
+		}
+
+		ext, ok := imageFormatExts[format]
+		if !ok {
+			ext = "." + format
+		}
+
+		for _, width := range formatWidths {
+			variant := ImageVariant{
+				Val:    fmt.Sprintf("%s_w%d%s", basename, width, ext),
+				Width:  width,
+				Format: format,
+			}
+			info.Variants = append(info.Variants, variant)
+
+			distPath := filepath.Join(distDir, variant.Val)
+			if isDevRebuild {
+				if _, err := os.Stat(distPath); err == nil {
+					continue
+				}
+			}
+
+			if decoded == nil {
+				if src == nil {
+					if err := readSrc(); err != nil {
+						return nil, err
+					}
+				}
+				var err error
+				if decoded, _, err = image.Decode(bytes.NewReader(src)); err != nil {
+					return nil, fmt.Errorf("error decoding image %s: %v", fi.relativePath, err)
+				}
+			}
+
+			resized := decoded
+			if width != info.Width {
+				resized = imageutil.ResizeToWidth(decoded, width)
+			}
+
+			var buf bytes.Buffer
+			if err := enc(&buf, resized, quality); err != nil {
+				return nil, fmt.Errorf("error encoding %s variant of %s: %v", format, fi.relativePath, err)
+			}
+			if err := os.WriteFile(distPath, buf.Bytes(), 0644); err != nil {
+				return nil, fmt.Errorf("error writing image variant: %v", err)
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// Removes variant files referenced by the old image map but not the new.
+func removeMootImageVariants(oldImageMap ImageMap, newImageMap *typed.SyncMap[string, ImageInfo], distDir string) error {
+	for k, oldInfo := range oldImageMap {
+		newInfo, _ := newImageMap.Load(k)
+		for _, v := range oldInfo.Variants {
+			if slices.ContainsFunc(newInfo.Variants, func(x ImageVariant) bool { return x.Val == v.Val }) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(distDir, v.Val)); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error removing old image variant from dist (%s): %v", v.Val, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) saveImageMapToGob(imageMap ImageMap) error {
+	file, err := os.Create(filepath.Join(c._dist.S().Static.S().Internal.FullPath(), PublicImageMapGobName))
+	if err != nil {
+		return fmt.Errorf("error creating file: %v", err)
+	}
+	defer file.Close()
+	return gob.NewEncoder(file).Encode(imageMap)
+}
+
+func (c *Config) loadImageMapFromGob(isBuildTime bool) (ImageMap, error) {
+	appropriateFS, err := c.getAppropriateFSMaybeBuildTime(isBuildTime)
+	if err != nil {
+		return nil, fmt.Errorf("error getting FS: %v", err)
+	}
+
+	distKirunaInternal := c._dist.S().Static.S().Internal
+
+	// __LOCATION_ASSUMPTION: Inside "dist/static"
+	file, err := appropriateFS.Open(filepath.Join(distKirunaInternal.LastSegment(), PublicImageMapGobName))
+	if err != nil {
+		return nil, fmt.Errorf("error opening file %s: %v", PublicImageMapGobName, err)
+	}
+	defer file.Close()
+
+	var imageMap ImageMap
+	if err := fsutil.FromGobInto(file, &imageMap); err != nil {
+		return nil, fmt.Errorf("error decoding gob: %v", err)
+	}
+	return imageMap, nil
+}
+
+func (c *Config) getInitialPublicImageMap() (ImageMap, error) {
+	if c._uc.Core.ImagePipeline == nil {
+		return ImageMap{}, nil
+	}
+	imageMap, err := c.loadImageMapFromGob(false)
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error loading public image map: %v", err))
+		return ImageMap{}, err
+	}
+	return imageMap, nil
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// RESPONSIVE IMAGE ATTRIBUTES
+/////////////////////////////////////////////////////////////////////
+
+// ImageAttrs holds the attributes for a responsive <img> element. Sources
+// holds the image's modern-format alternatives, best first, for <source>
+// elements inside a <picture>.
+type ImageAttrs struct {
+	Src     string        `json:"src"`
+	SrcSet  string        `json:"srcSet,omitempty"`
+	Sizes   string        `json:"sizes,omitempty"`
+	Width   int           `json:"width,omitempty"`
+	Height  int           `json:"height,omitempty"`
+	Sources []ImageSource `json:"sources,omitempty"`
+}
+
+type ImageSource struct {
+	Type   string `json:"type"`
+	SrcSet string `json:"srcSet"`
+}
+
+// Returns the <img> attributes as a map, e.g., for htmlutil.Element.
+func (a *ImageAttrs) ToMap() map[string]string {
+	m := map[string]string{"src": a.Src}
+	if a.SrcSet != "" {
+		m["srcset"] = a.SrcSet
+	}
+	if a.Sizes != "" {
+		m["sizes"] = a.Sizes
+	}
+	if a.Width > 0 {
+		m["width"] = fmt.Sprint(a.Width)
+	}
+	if a.Height > 0 {
+		m["height"] = fmt.Sprint(a.Height)
+	}
+	return m
+}
+
+func toImageAttrs(info ImageInfo, sizes string) *ImageAttrs {
+	toURL := func(val string) string { return "/" + filepath.Join(PUBLIC, val) }
+
+	srcSets := map[string][]string{}
+	for _, v := range info.Variants {
+		srcSets[v.Format] = append(srcSets[v.Format], fmt.Sprintf("%s %dw", toURL(v.Val), v.Width))
+	}
+	srcSets[info.Format] = append(srcSets[info.Format], fmt.Sprintf("%s %dw", toURL(info.Val), info.Width))
+
+	attrs := &ImageAttrs{
+		Src:    toURL(info.Val),
+		SrcSet: strings.Join(srcSets[info.Format], ", "),
+		Sizes:  sizes,
+		Width:  info.Width,
+		Height: info.Height,
+	}
+
+	formats := make([]string, 0, len(srcSets))
+	for format := range srcSets {
+		if format != info.Format {
+			formats = append(formats, format)
+		}
+	}
+	slices.SortFunc(formats, func(a, b string) int {
+		ai, bi := slices.Index(imageFormatPreference, a), slices.Index(imageFormatPreference, b)
+		if ai == -1 {
+			ai = len(imageFormatPreference)
+		}
+		if bi == -1 {
+			bi = len(imageFormatPreference)
+		}
+		if ai != bi {
+			return ai - bi
+		}
+		return strings.Compare(a, b)
+	})
+	for _, format := range formats {
+		mimeType, ok := imageMimeTypes[format]
+		if !ok {
+			mimeType = "image/" + format
+		}
+		attrs.Sources = append(attrs.Sources, ImageSource{Type: mimeType, SrcSet: strings.Join(srcSets[format], ", ")})
+	}
+
+	return attrs
+}
+
+// Returns responsive <img> attributes for an original public image path
+// (e.g., "images/hero.jpg"). The sizes argument is optional. Images not
+// processed by the image pipeline get just their (hashed) src.
+func (c *Config) GetImageAttrs(originalPublicURL string, sizes string) *ImageAttrs {
+	imageMap, _ := c.runtime_cache.public_imagemap.Get()
+	if info, ok := imageMap[cleanURL(originalPublicURL)]; ok {
+		return toImageAttrs(info, sizes)
+	}
+	return &ImageAttrs{Src: c.GetPublicURL(originalPublicURL), Sizes: sizes}
+}
+
+func (c *Config) GetPublicImageMap() (ImageMap, error) {
+	return c.runtime_cache.public_imagemap.Get()
+}
+
+// If you pass nil to this function, it will return a pointer to a new Statements
+// object. If you pass a pointer to an existing Statements object, it will mutate
+// that object and return it. The generated getKirunaImageAttrs helper mirrors
+// GetImageAttrs, with attribute names suitable for JSX. Must be called after
+// the public files have been built (e.g., from a build hook).
+func (c *Config) AddImageAttrs(statements *tsgen.Statements) (*tsgen.Statements, error) {
+	a := statements
+	if a == nil {
+		a = &tsgen.Statements{}
+	}
+
+	images := map[string]*ImageAttrs{}
+	if c._uc.Core.ImagePipeline != nil {
+		imageMap, err := c.loadImageMapFromGob(true)
+		if err != nil {
+			return nil, fmt.Errorf("error loading public image map: %v", err)
+		}
+		for k, info := range imageMap {
+			images[k] = toImageAttrs(info, "")
+		}
+	}
+
+	a.Serialize("const KIRUNA_IMAGES", images)
+	a.Raw("export type KirunaImage", "`${\"/\" | \"\"}${keyof typeof KIRUNA_IMAGES}`")
+	a.Raw("export const getKirunaImageAttrs", `(image: KirunaImage, sizes?: string) => ({
+	...KIRUNA_IMAGES[(image.startsWith("/") ? image.slice(1) : image) as keyof typeof KIRUNA_IMAGES],
+	sizes,
+})`)
+
+	return a, nil
+}
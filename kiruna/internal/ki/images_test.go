@@ -0,0 +1,199 @@
+package ki
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sjc5/river/kit/imageutil"
+)
+
+func writeTestPNG(t *testing.T, relativePath string, width, height int, c color.Color) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	fullPath := filepath.Join(testRootDir, "public-static", relativePath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fullPath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImagePipeline(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	if err := os.MkdirAll(filepath.Join(testRootDir, "dist/static/assets/public/internal"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	env.config._uc.Core.ImagePipeline = &ImagePipeline{
+		Include: []string{"images/**/*.png"},
+		Widths:  []int{200, 400, 1600},
+		Formats: []string{"webp"},
+	}
+	env.config.ImageEncoders = map[string]imageutil.Encoder{
+		"webp": func(w io.Writer, img image.Image, quality int) error {
+			_, err := w.Write([]byte("fake webp"))
+			return err
+		},
+	}
+
+	writeTestPNG(t, "images/hero.png", 800, 400, color.RGBA{255, 0, 0, 255})
+	writeTestPNG(t, "logo.png", 100, 100, color.RGBA{0, 0, 255, 255})
+
+	if err := env.config.handlePublicFiles(false); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+
+	attrs := env.config.GetImageAttrs("/images/hero.png", "100vw")
+	if attrs.Width != 800 || attrs.Height != 400 || attrs.Sizes != "100vw" {
+		t.Errorf("unexpected attrs %+v", attrs)
+	}
+	if !strings.HasPrefix(attrs.Src, "/public/images_hero_") {
+		t.Errorf("unexpected src %s", attrs.Src)
+	}
+
+	// Widths at or above the original's are skipped, and the original
+	// completes the srcset of its own format
+	srcSet := strings.Split(attrs.SrcSet, ", ")
+	if len(srcSet) != 3 || !strings.HasSuffix(srcSet[0], " 200w") || srcSet[2] != attrs.Src+" 800w" {
+		t.Errorf("unexpected srcset %q", attrs.SrcSet)
+	}
+	if len(attrs.Sources) != 1 || attrs.Sources[0].Type != "image/webp" || strings.Count(attrs.Sources[0].SrcSet, "w,") != 2 {
+		t.Errorf("unexpected sources %+v", attrs.Sources)
+	}
+
+	pngVariant := strings.TrimPrefix(strings.Fields(srcSet[0])[0], "/public/")
+	f, err := os.Open(filepath.Join(testRootDir, "dist/static/assets/public", pngVariant))
+	if err != nil {
+		t.Fatalf("expected variant to be written: %v", err)
+	}
+	cfg, _, err := image.DecodeConfig(f)
+	f.Close()
+	if err != nil || cfg.Width != 200 || cfg.Height != 100 {
+		t.Errorf("unexpected variant %+v %v", cfg, err)
+	}
+
+	// Images not matching the pipeline are left alone
+	if logo := env.config.GetImageAttrs("logo.png", ""); logo.SrcSet != "" || !strings.HasPrefix(logo.Src, "/public/logo_") {
+		t.Errorf("unexpected attrs for unprocessed image %+v", logo)
+	}
+
+	// Granular rebuilds remove the variants of changed images
+	writeTestPNG(t, "images/hero.png", 800, 400, color.RGBA{0, 255, 0, 255})
+	if err := env.config.handlePublicFiles(true); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(testRootDir, "dist/static/assets/public", pngVariant)); !os.IsNotExist(err) {
+		t.Error("expected the old variant to be removed")
+	}
+	imageMap, err := env.config.loadImageMapFromGob(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := imageMap["images/hero.png"]; "/public/"+info.Val == attrs.Src || len(info.Variants) != 5 {
+		t.Errorf("expected new variants, got %+v", info)
+	}
+
+	// Unchanged originals are taken from the old image map rather than
+	// decoded again (the tampered height shows which one was used)
+	tampered := imageMap["images/hero.png"]
+	tampered.Height = 401
+	imageMap["images/hero.png"] = tampered
+	if err := env.config.saveImageMapToGob(imageMap); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.config.handlePublicFiles(true); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+	if imageMap, err = env.config.loadImageMapFromGob(true); err != nil {
+		t.Fatal(err)
+	}
+	if info := imageMap["images/hero.png"]; info.Height != 401 || len(info.Variants) != 5 {
+		t.Errorf("expected the old image info to be reused, got %+v", info)
+	}
+
+	statements, err := env.config.AddImageAttrs(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := statements.BuildString()
+	if !strings.Contains(ts, `"images/hero.png": {`) || !strings.Contains(ts, "export const getKirunaImageAttrs") {
+		t.Errorf("unexpected TS:\n%s", ts)
+	}
+
+	if err := os.Remove(filepath.Join(testRootDir, "dist/static/internal", PublicImageMapGobName)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.config.AddImageAttrs(nil); err == nil {
+		t.Error("expected an error when the image map is missing")
+	}
+}
+
+func TestImagePipelineMissingEncoder(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	env.config._uc.Core.ImagePipeline = &ImagePipeline{
+		Include: []string{"**/*.png"},
+		Formats: []string{"jpg", "WebP", "avif", "heic", "avif"},
+	}
+	writeTestPNG(t, "hero.png", 10, 10, color.RGBA{255, 0, 0, 255})
+
+	err := env.config.handlePublicFiles(false)
+	if err == nil || !strings.Contains(err.Error(), "no encoder for format(s) avif, heic") {
+		t.Errorf("expected a missing encoder error, got %v", err)
+	}
+}
+
+func TestImagePipelineBuiltInWebP(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	if err := os.MkdirAll(filepath.Join(testRootDir, "dist/static/assets/public/internal"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	env.config._uc.Core.ImagePipeline = &ImagePipeline{
+		Include: []string{"**/*.png"},
+		Widths:  []int{50},
+		Formats: []string{"webp"},
+	}
+	writeTestPNG(t, "icon.png", 100, 100, color.RGBA{0, 0, 255, 255})
+
+	if err := env.config.handlePublicFiles(false); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+
+	attrs := env.config.GetImageAttrs("icon.png", "")
+	if len(attrs.Sources) != 1 || attrs.Sources[0].Type != "image/webp" {
+		t.Fatalf("unexpected sources %+v", attrs.Sources)
+	}
+	for _, candidate := range strings.Split(attrs.Sources[0].SrcSet, ", ") {
+		name := strings.TrimPrefix(strings.Fields(candidate)[0], "/public/")
+		b, err := os.ReadFile(filepath.Join(testRootDir, "dist/static/assets/public", name))
+		if err != nil {
+			t.Fatalf("expected variant to be written: %v", err)
+		}
+		if len(b) < 16 || string(b[:4]) != "RIFF" || string(b[8:16]) != "WEBPVP8L" {
+			t.Errorf("expected %s to be a lossless WebP file", name)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package ki
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"strings"
+
+	"github.com/sjc5/river/kit/bytesutil"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// SUBRESOURCE INTEGRITY
+/////////////////////////////////////////////////////////////////////
+
+func toIntegrity(sha256Sum []byte) string {
+	return "sha256-" + bytesutil.ToBase64(sha256Sum)
+}
+
+func publicIntegritiesKeyMaker(filename string) string { return filename }
+
+// Uses the value recorded in the public file map if there is one (which
+// covers hashed public files and prehashed files, such as Vite chunks).
+// Otherwise (e.g., for CSS files written by Kiruna), hashes the file itself.
+func (c *Config) getInitialPublicFileIntegrity(filename string) (string, error) {
+	if fileMap, err := c.GetPublicFileMap(); err == nil {
+		for _, v := range fileMap {
+			if v.Val == filename && v.Integrity != "" {
+				return v.Integrity, nil
+			}
+		}
+	}
+
+	publicFS, err := c.GetPublicFS()
+	if err != nil {
+		c.Logger.Error(fmt.Sprintf("error getting public FS: %v", err))
+		return "", err
+	}
+	content, err := fs.ReadFile(publicFS, filename)
+	if err != nil {
+		return "", nil
+	}
+	sum := sha256.Sum256(content)
+	return toIntegrity(sum[:]), nil
+}
+
+// Returns the SRI value (e.g., "sha256-...") of a file within the public
+// static dir, by its hashed filename (the part of its URL after the public
+// path prefix). Returns an empty string if the file doesn't exist, and
+// always in dev mode, where files change from one rebuild to the next.
+func (c *Config) GetPublicFileIntegrity(hashedFilename string) string {
+	if GetIsDev() {
+		return ""
+	}
+	hashedFilename = strings.TrimPrefix(hashedFilename, "/")
+	integrity, _ := c.runtime_cache.public_integrities.Get(hashedFilename)
+	return integrity
+}
+
+// Renders a stylesheet link element, with an integrity attribute if the
+// file's integrity is known.
+func (c *Config) toStyleSheetLinkElement(filename, url, id string) template.HTML {
+	var sb strings.Builder
+	sb.WriteString(`<link rel="stylesheet" href="`)
+	sb.WriteString(url)
+	sb.WriteString(`" id="`)
+	sb.WriteString(id)
+	if integrity := c.GetPublicFileIntegrity(filename); integrity != "" {
+		sb.WriteString(`" integrity="`)
+		sb.WriteString(integrity)
+	}
+	sb.WriteString(`" />`)
+	return template.HTML(sb.String())
+}
@@ -0,0 +1,59 @@
+package ki
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPublicFileIntegrity(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	if err := os.MkdirAll(filepath.Join(testRootDir, "dist/static/assets/public/internal"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	toIntegrity := func(content string) string {
+		sum := sha256.Sum256([]byte(content))
+		return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+	}
+
+	env.createTestFile(t, "public-static/app.js", "console.log('app');")
+	env.createTestFile(t, "public-static/prehashed/river_out_main-abc123.js", "console.log('chunk');")
+
+	if err := env.config.handlePublicFiles(false); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+
+	fileMap, err := env.config.loadMapFromGob(PublicFileMapGobName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		original string
+		content  string
+	}{
+		{"app.js", "console.log('app');"},
+		{"river_out_main-abc123.js", "console.log('chunk');"},
+	}
+	for _, tt := range tests {
+		v, ok := fileMap[tt.original]
+		if !ok {
+			t.Fatalf("expected %s in file map", tt.original)
+		}
+		if v.Integrity != toIntegrity(tt.content) {
+			t.Errorf("file map integrity for %s = %v, want: %v", tt.original, v.Integrity, toIntegrity(tt.content))
+		}
+		if got := env.config.GetPublicFileIntegrity(v.Val); got != v.Integrity {
+			t.Errorf("GetPublicFileIntegrity(%s) = %v, want: %v", v.Val, got, v.Integrity)
+		}
+	}
+
+	if got := env.config.GetPublicFileIntegrity("does-not-exist.js"); got != "" {
+		t.Errorf("expected no integrity for a missing file, got %v", got)
+	}
+}
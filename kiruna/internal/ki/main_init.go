@@ -2,6 +2,7 @@ package ki
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -52,6 +53,19 @@ func (c *Config) MainInit(opts MainInitOptions, calledFrom string) {
 	if c._uc.Core.CSSEntryFiles.NonCritical != "" {
 		c.cleanSources.NonCriticalCSSEntry = filepath.Clean(c._uc.Core.CSSEntryFiles.NonCritical)
 	}
+	if len(c._uc.Core.CSSBundles) > 0 {
+		c.cleanSources.CSSBundles = make(map[string]CSSBundle, len(c._uc.Core.CSSBundles))
+		for name, bundle := range c._uc.Core.CSSBundles {
+			if !cssBundleNameRegex.MatchString(name) {
+				c.panic("invalid CSS bundles config", fmt.Errorf("bundle name %q may only contain letters, digits and hyphens", name))
+			}
+			if bundle.Entry == "" {
+				c.panic("invalid CSS bundles config", fmt.Errorf("bundle %q has no entry", name))
+			}
+			bundle.Entry = filepath.Clean(bundle.Entry)
+			c.cleanSources.CSSBundles[name] = bundle
+		}
+	}
 
 	// DIST LAYOUT
 	c._dist = toDistLayout(c.cleanSources.Dist)
@@ -183,7 +197,7 @@ func (c *Config) MainInit(opts MainInitOptions, calledFrom string) {
 
 	c.watcher = watcher
 
-	if err := c.add_directory_to_watcher(c.cleanWatchRoot); err != nil {
+	if err := c.add_directory_to_watcher(c.cleanWatchRoot, true); err != nil {
 		c.panic("failed to add directory to watcher", err)
 	}
 }
@@ -0,0 +1,248 @@
+package ki
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sjc5/river/kit/brotli"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// PRECOMPRESSION
+/////////////////////////////////////////////////////////////////////
+
+// Compressor writes the compressed form of src to dst.
+type Compressor func(dst io.Writer, src []byte) error
+
+const precompressMinSize = 1024
+
+var precompressibleExts = map[string]struct{}{
+	".js": {}, ".mjs": {}, ".css": {}, ".html": {}, ".svg": {}, ".json": {}, ".map": {},
+	".txt": {}, ".xml": {}, ".wasm": {}, ".ico": {}, ".webmanifest": {},
+}
+
+type encoding struct {
+	name string // as in Accept-Encoding and Content-Encoding
+	ext  string
+}
+
+// In order of preference
+var (
+	encodingBrotli = encoding{name: "br", ext: ".br"}
+	encodingGzip   = encoding{name: "gzip", ext: ".gz"}
+	encodings      = []encoding{encodingBrotli, encodingGzip}
+)
+
+func gzipCompress(dst io.Writer, src []byte) error {
+	zw, err := gzip.NewWriterLevel(dst, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := zw.Write(src); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func getIsPrecompressible(name string) bool {
+	_, ok := precompressibleExts[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+func (c *Config) getCompressor(enc encoding) Compressor {
+	if enc == encodingGzip {
+		return gzipCompress
+	}
+	if c.BrotliCompressor != nil {
+		return c.BrotliCompressor
+	}
+	return brotli.Compress
+}
+
+// Precompression is skipped in dev, to keep rebuilds fast.
+func (c *Config) getShouldPrecompress(name string, size int64) bool {
+	return c._uc.Core.PrecompressPublicFiles && !GetIsDev() &&
+		size >= precompressMinSize && getIsPrecompressible(name)
+}
+
+// Returns which compressed siblings precompressFile writes for a file.
+func (c *Config) getPrecompressedSiblings(name string, size int64) (hasGzip, hasBrotli bool) {
+	should := c.getShouldPrecompress(name, size)
+	return should, should
+}
+
+// Writes compressed siblings of the file at distPath (e.g., "main.js.gz"),
+// for each encoding, if it should be precompressed.
+func (c *Config) precompressFile(distPath string, contents []byte) error {
+	if !c.getShouldPrecompress(distPath, int64(len(contents))) {
+		return nil
+	}
+	for _, enc := range encodings {
+		compress := c.getCompressor(enc)
+		var buf bytes.Buffer
+		if err := compress(&buf, contents); err != nil {
+			return fmt.Errorf("error compressing %s (%s): %v", distPath, enc.name, err)
+		}
+		if err := os.WriteFile(distPath+enc.ext, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("error writing precompressed file: %v", err)
+		}
+	}
+	return nil
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// SERVING
+/////////////////////////////////////////////////////////////////////
+
+// Maps the hashed filename of each public file with compressed siblings to
+// its encodings, in order of preference, per the public file map. Only
+// files in the map (and the public file map script itself) are included,
+// so request paths can't grow it.
+func (c *Config) getInitialPrecompressedEncodings() (map[string][]encoding, error) {
+	fileMap, err := c.GetPublicFileMap()
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string][]encoding{}
+	for _, v := range fileMap {
+		var available []encoding
+		if v.HasBrotli {
+			available = append(available, encodingBrotli)
+		}
+		if v.HasGzip {
+			available = append(available, encodingGzip)
+		}
+		if len(available) > 0 {
+			byName[v.Val] = available
+		}
+	}
+
+	// The public file map script is written after the map, so it isn't in
+	// it. Its siblings are looked up on disk instead.
+	if c._uc.Core.PrecompressPublicFiles {
+		publicFS, err := c.GetPublicFS()
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(c.GetPublicFileMapURL(), "/"+PUBLIC+"/")
+		var available []encoding
+		for _, enc := range encodings {
+			if _, err := fs.Stat(publicFS, name+enc.ext); err == nil {
+				available = append(available, enc)
+			}
+		}
+		if len(available) > 0 {
+			byName[name] = available
+		}
+	}
+
+	return byName, nil
+}
+
+// Serves a precompressed sibling of the requested file, if the client
+// accepts one and it exists. Returns false if the request should be served
+// as usual instead.
+func (c *Config) servePrecompressed(w http.ResponseWriter, r *http.Request, publicFS fs.FS) bool {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if !getIsPrecompressible(name) {
+		return false
+	}
+
+	// Responses for compressible files vary either way
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("Range") != "" {
+		return false
+	}
+
+	byName, _ := c.runtime_cache.precompressed_encodings.Get()
+	accepted := parseAcceptEncoding(r.Header.Get("Accept-Encoding"))
+
+	for _, enc := range byName[name] {
+		if accepted[enc.name] && serveEncodedFile(w, r, publicFS, name, enc) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Serves the enc sibling of the file at name, if it can be opened. The
+// sibling is closed before returning, so that the caller can move on to
+// its next candidate.
+func serveEncodedFile(w http.ResponseWriter, r *http.Request, publicFS fs.FS, name string, enc encoding) bool {
+	f, err := publicFS.Open(name + enc.ext)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return false
+		}
+		content = bytes.NewReader(b)
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Encoding", enc.name)
+
+	// ServeContent leaves Content-Length unset for encoded content
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+
+	http.ServeContent(w, r, name, info.ModTime(), content)
+	return true
+}
+
+// Returns the accepted content codings (with non-zero q-values).
+func parseAcceptEncoding(header string) map[string]bool {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			k, v, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.TrimSpace(k) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if coding == "*" {
+			for _, enc := range encodings {
+				if _, explicit := accepted[enc.name]; !explicit {
+					accepted[enc.name] = q > 0
+				}
+			}
+			continue
+		}
+		accepted[coding] = q > 0
+	}
+	return accepted
+}
@@ -0,0 +1,261 @@
+package ki
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPrecompressPublicFiles(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	if err := os.MkdirAll(filepath.Join(testRootDir, "dist/static/assets/public/internal"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	env.config._uc.Core.PrecompressPublicFiles = true
+	env.config._uc.Core.PublicPathPrefix = "/public/"
+	env.config.BrotliCompressor = func(dst io.Writer, src []byte) error {
+		_, err := dst.Write([]byte("fake brotli"))
+		return err
+	}
+
+	bigJS := strings.Repeat("console.log('hello world');\n", 100)
+	env.createTestFile(t, "public-static/app.js", bigJS)
+	env.createTestFile(t, "public-static/small.js", "console.log('hi');")
+	env.createTestFile(t, "public-static/big.png", strings.Repeat("x", 2048))
+
+	if err := env.config.handlePublicFiles(false); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+
+	fileMap, err := env.config.loadMapFromGob(PublicFileMapGobName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := fileMap["app.js"]; !v.HasGzip || !v.HasBrotli {
+		t.Errorf("expected app.js to have compressed siblings, got %+v", v)
+	}
+	for _, name := range []string{"small.js", "big.png"} {
+		if v := fileMap[name]; v.HasGzip || v.HasBrotli {
+			t.Errorf("expected %s to have no compressed siblings, got %+v", name, v)
+		}
+	}
+
+	distPublic := env.config._dist.S().Static.S().Assets.S().Public.FullPath()
+	hashedJS := fileMap["app.js"].Val
+	gzInfo, err := os.Stat(filepath.Join(distPublic, hashedJS+".gz"))
+	if err != nil {
+		t.Fatalf("expected gzip sibling: %v", err)
+	}
+
+	handler, err := env.config.GetServeStaticHandler(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serve := func(acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/public/"+hashedJS, nil)
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("gzip, br;q=0")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Errorf("expected gzip encoding, got %q", got)
+	}
+	if got := rr.Header().Get("Content-Length"); got != strconv.FormatInt(gzInfo.Size(), 10) {
+		t.Errorf("expected Content-Length %d, got %s", gzInfo.Size(), got)
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/javascript") {
+		t.Errorf("unexpected Content-Type %q", rr.Header().Get("Content-Type"))
+	}
+	if rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("expected Vary header, got %q", rr.Header().Get("Vary"))
+	}
+	if !strings.Contains(rr.Header().Get("Cache-Control"), "immutable") {
+		t.Errorf("expected immutable cache headers, got %q", rr.Header().Get("Cache-Control"))
+	}
+
+	rr = serve("gzip, br")
+	if got := rr.Header().Get("Content-Encoding"); got != "br" || rr.Body.String() != "fake brotli" {
+		t.Errorf("expected brotli to be preferred, got %q", got)
+	}
+
+	rr = serve("")
+	if got := rr.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("expected no encoding, got %q", got)
+	}
+	if rr.Body.String() != bigJS {
+		t.Errorf("expected original body")
+	}
+	if rr.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("expected Vary header on uncompressed response, got %q", rr.Header().Get("Vary"))
+	}
+
+	// Encodings come from the public file map, so only files with siblings
+	// are tracked, however many distinct paths are requested
+	for i := range 10 {
+		req := httptest.NewRequest(http.MethodGet, "/public/missing-"+strconv.Itoa(i)+".js", nil)
+		req.Header.Set("Accept-Encoding", "gzip, br")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	byName, err := env.config.runtime_cache.precompressed_encodings.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byName) != 1 || len(byName[hashedJS]) != 2 || byName[hashedJS][0] != encodingBrotli {
+		t.Errorf("expected only %s (brotli, then gzip), got %v", hashedJS, byName)
+	}
+}
+
+func TestPrecompressDefaultBrotli(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	if err := os.MkdirAll(filepath.Join(testRootDir, "dist/static/assets/public/internal"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	env.config._uc.Core.PrecompressPublicFiles = true
+	env.config._uc.Core.PublicPathPrefix = "/public/"
+
+	bigJS := strings.Repeat("console.log('hello world');\n", 100)
+	env.createTestFile(t, "public-static/app.js", bigJS)
+
+	if err := env.config.handlePublicFiles(false); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+
+	fileMap, err := env.config.loadMapFromGob(PublicFileMapGobName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := fileMap["app.js"]
+	if !v.HasGzip || !v.HasBrotli {
+		t.Fatalf("expected app.js to have compressed siblings without a BrotliCompressor, got %+v", v)
+	}
+
+	distPublic := env.config._dist.S().Static.S().Assets.S().Public.FullPath()
+	br, err := os.ReadFile(filepath.Join(distPublic, v.Val+".br"))
+	if err != nil {
+		t.Fatalf("expected brotli sibling: %v", err)
+	}
+	if len(br) == 0 || len(br) >= len(bigJS) {
+		t.Errorf("expected brotli sibling to be compressed, got %d bytes for %d", len(br), len(bigJS))
+	}
+
+	handler, err := env.config.GetServeStaticHandler(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/public/"+v.Val, nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Header().Get("Content-Encoding") != "br" || !bytes.Equal(rr.Body.Bytes(), br) {
+		t.Errorf("expected the brotli sibling to be served, got %q", rr.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestPrecompressPublicFileMapScript(t *testing.T) {
+	env := setupTestEnv(t)
+	defer teardownTestEnv(t)
+
+	if err := os.MkdirAll(filepath.Join(testRootDir, "dist/static/assets/public/internal"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	env.config._uc.Core.PrecompressPublicFiles = true
+	env.config._uc.Core.PublicPathPrefix = "/public/"
+
+	// Enough files for the file map script to be worth compressing
+	for i := range 40 {
+		env.createTestFile(t, "public-static/images/photo-"+strconv.Itoa(i)+".png", "png")
+	}
+
+	if err := env.config.handlePublicFiles(false); err != nil {
+		t.Fatalf("handlePublicFiles() error = %v", err)
+	}
+
+	scriptURL := env.config.GetPublicFileMapURL()
+	name := strings.TrimPrefix(scriptURL, "/public/")
+	distPublic := env.config._dist.S().Static.S().Assets.S().Public.FullPath()
+	original, err := os.ReadFile(filepath.Join(distPublic, name))
+	if err != nil || len(original) < precompressMinSize {
+		t.Fatalf("expected a file map script of at least %d bytes: %v", precompressMinSize, err)
+	}
+
+	handler, err := env.config.GetServeStaticHandler(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, scriptURL, nil)
+		req.Header.Set("Accept-Encoding", "gzip, br")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := serve(); rr.Header().Get("Content-Encoding") != "br" {
+		t.Errorf("expected the file map script to be served with brotli, got %q", rr.Header().Get("Content-Encoding"))
+	}
+
+	// A sibling that has gone missing falls through to the next candidate
+	if err := os.Remove(filepath.Join(distPublic, name+".br")); err != nil {
+		t.Fatal(err)
+	}
+	rr := serve()
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a fallback to gzip, got %q", rr.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := io.ReadAll(zr); err != nil || !bytes.Equal(body, original) {
+		t.Errorf("expected the gzipped file map script, got %v", err)
+	}
+}
+
+func TestParseAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   map[string]bool
+	}{
+		{"", map[string]bool{}},
+		{"gzip, deflate, br", map[string]bool{"gzip": true, "deflate": true, "br": true}},
+		{"br;q=0, gzip;q=0.5", map[string]bool{"br": false, "gzip": true}},
+		{"*", map[string]bool{"br": true, "gzip": true}},
+		{"br;q=0, *;q=0.1", map[string]bool{"br": false, "gzip": true}},
+		{"GZIP ; q=1.0", map[string]bool{"gzip": true}},
+	}
+	for _, tt := range tests {
+		got := parseAcceptEncoding(tt.header)
+		if len(got) != len(tt.want) {
+			t.Errorf("parseAcceptEncoding(%q) = %v, want %v", tt.header, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("parseAcceptEncoding(%q)[%s] = %v, want %v", tt.header, k, got[k], v)
+			}
+		}
+	}
+}
@@ -32,6 +32,11 @@ type refreshFilePayload struct {
 	ChangeType   changeType `json:"changeType"`
 	CriticalCSS  Base64     `json:"criticalCSS"`
 	NormalCSSURL string     `json:"normalCSSURL"`
+
+	// Only set for changeTypeCSSBundle
+	CSSBundleName  string `json:"cssBundleName,omitempty"`
+	CSSBundleURL   string `json:"cssBundleURL,omitempty"`
+	CSSBundleStyle Base64 `json:"cssBundleStyle,omitempty"`
 }
 
 type changeType string
@@ -39,6 +44,7 @@ type changeType string
 const (
 	changeTypeNormalCSS   changeType = "normal"
 	changeTypeCriticalCSS changeType = "critical"
+	changeTypeCSSBundle   changeType = "bundle"
 	changeTypeOther       changeType = "other"
 	changeTypeRebuilding  changeType = "rebuilding"
 	changeTypeRevalidate  changeType = "revalidate"
@@ -100,8 +106,9 @@ func GetRefreshScriptInner(port int) string {
 	return fmt.Sprintf(refreshScriptFmt, port)
 }
 
-// changeTypes: "rebuilding", "other", "normal", "critical", "revalidate"
-// Element IDs: "kiruna-refreshscript-rebuilding", "kiruna-normal-css", "kiruna-critical-css"
+// changeTypes: "rebuilding", "other", "normal", "critical", "bundle", "revalidate"
+// Element IDs: "kiruna-refreshscript-rebuilding", "kiruna-normal-css", "kiruna-critical-css",
+// "kiruna-css-bundle-{name}"
 const refreshScriptFmt = `
 	function base64ToUTF8(base64) {
 		const bytes = Uint8Array.from(atob(base64), (m) => m.codePointAt(0) || 0);
@@ -125,7 +132,14 @@ const refreshScriptFmt = `
 	const ws = new WebSocket("ws://localhost:%d/events");
 
 	ws.onmessage = (e) => {
-		const { changeType, criticalCSS, normalCSSURL } = JSON.parse(e.data);
+		const {
+			changeType,
+			criticalCSS,
+			normalCSSURL,
+			cssBundleName,
+			cssBundleURL,
+			cssBundleStyle,
+		} = JSON.parse(e.data);
 
 		if (changeType == "rebuilding") {
 			console.log("KIRUNA DEV: Rebuilding server...");
@@ -182,6 +196,26 @@ const refreshScriptFmt = `
 			newStyle.innerHTML = base64ToUTF8(criticalCSS);
 			document.head.replaceChild(newStyle, oldStyle);
 		}
+
+		if (changeType == "bundle") {
+			const oldStyle = document.getElementById("kiruna-css-bundle-" + cssBundleName);
+			if (oldStyle && oldStyle.tagName == "STYLE" && cssBundleStyle) {
+				const newStyle = document.createElement("style");
+				newStyle.id = oldStyle.id;
+				newStyle.innerHTML = base64ToUTF8(cssBundleStyle);
+				oldStyle.parentNode.replaceChild(newStyle, oldStyle);
+			}
+			const selector = 'link[href^="/public/kiruna_css_' + cssBundleName + '_"]';
+			document.querySelectorAll(selector).forEach((oldLink) => {
+				const newLink = oldLink.cloneNode();
+				newLink.href = cssBundleURL;
+				if (newLink.hasAttribute("data-river-css-bundle")) {
+					newLink.setAttribute("data-river-css-bundle", cssBundleURL.split("/").pop());
+				}
+				newLink.onload = () => oldLink.remove();
+				oldLink.parentNode.insertBefore(newLink, oldLink.nextSibling);
+			});
+		}
 			
 		if (changeType == "revalidate") {
 			console.log("KIRUNA DEV: Revalidating...");
@@ -11,10 +11,15 @@ import (
 type fileVal struct {
 	Val         string
 	IsPrehashed bool
+	HasGzip     bool   // a "{Val}.gz" sibling exists
+	HasBrotli   bool   // a "{Val}.br" sibling exists
+	Integrity   string // SRI value (e.g., "sha256-..."), public files only
 }
 
 type FileMap map[string]fileVal
 
+// Serves the public static dir. Precompressed siblings of files (see
+// Core.PrecompressPublicFiles) are served to clients that accept them.
 func (c *Config) GetServeStaticHandler(addImmutableCacheHeaders bool) (http.Handler, error) {
 	publicFS, err := c.GetPublicFS()
 	if err != nil {
@@ -22,13 +27,16 @@ func (c *Config) GetServeStaticHandler(addImmutableCacheHeaders bool) (http.Hand
 		c.Logger.Error(errMsg)
 		return nil, errors.New(errMsg)
 	}
-	if addImmutableCacheHeaders {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fileServer := http.FileServer(http.FS(publicFS))
+	return http.StripPrefix(c.GetPublicPathPrefix(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addImmutableCacheHeaders {
 			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
-			http.StripPrefix(c.GetPublicPathPrefix(), http.FileServer(http.FS(publicFS))).ServeHTTP(w, r)
-		}), nil
-	}
-	return http.StripPrefix(c.GetPublicPathPrefix(), http.FileServer(http.FS(publicFS))), nil
+		}
+		if c.servePrecompressed(w, r, publicFS) {
+			return
+		}
+		fileServer.ServeHTTP(w, r)
+	})), nil
 }
 
 func (c *Config) getInitialPublicFileMapFromGobBuildtime() (FileMap, error) {
@@ -7,6 +7,7 @@ import (
 
 	"github.com/sjc5/river/kiruna/internal/ki"
 	"github.com/sjc5/river/kit/middleware"
+	"github.com/sjc5/river/kit/tsgen"
 )
 
 type (
@@ -15,6 +16,8 @@ type (
 	FileMap     = ki.FileMap
 	WatchedFile = ki.WatchedFile
 	OnChangeCmd = ki.OnChangeHook
+	ImageAttrs  = ki.ImageAttrs
+	ImageMap    = ki.ImageMap
 )
 
 const (
@@ -106,6 +109,51 @@ func (k Kiruna) GetCriticalCSSStyleElementSha256Hash() string {
 func (k Kiruna) GetStyleSheetLinkElement() template.HTML {
 	return k.c.GetStyleSheetLinkElement()
 }
+func (k Kiruna) GetCSSBundleNames() []string {
+	return k.c.GetCSSBundleNames()
+}
+func (k Kiruna) GetCSSBundleExists(name string) bool {
+	return k.c.GetCSSBundleExists(name)
+}
+func (k Kiruna) GetCSSBundleIsCritical(name string) bool {
+	return k.c.GetCSSBundleIsCritical(name)
+}
+func (k Kiruna) GetCSSBundleFilename(name string) string {
+	return k.c.GetCSSBundleFilename(name)
+}
+func (k Kiruna) GetCSSBundleURL(name string) string {
+	return k.c.GetCSSBundleURL(name)
+}
+func (k Kiruna) GetCSSBundleCSS(name string) template.CSS {
+	return template.CSS(k.c.GetCSSBundleCSS(name))
+}
+func (k Kiruna) GetCSSBundleElementID(name string) string {
+	return ki.ToCSSBundleElementID(name)
+}
+func (k Kiruna) GetCSSBundleLinkElement(name string) template.HTML {
+	return k.c.GetCSSBundleLinkElement(name)
+}
+func (k Kiruna) GetCSSBundleStyleElement(name string) template.HTML {
+	return k.c.GetCSSBundleStyleElement(name)
+}
+func (k Kiruna) GetCSSBundleStyleElementSha256Hash(name string) string {
+	return k.c.GetCSSBundleStyleElementSha256Hash(name)
+}
+func (k Kiruna) GetCSSBundleElement(name string) template.HTML {
+	return k.c.GetCSSBundleElement(name)
+}
+func (k Kiruna) GetImageAttrs(originalPublicURL string, sizes string) *ImageAttrs {
+	return k.c.GetImageAttrs(originalPublicURL, sizes)
+}
+func (k Kiruna) GetPublicImageMap() (ImageMap, error) {
+	return k.c.GetPublicImageMap()
+}
+func (k Kiruna) AddImageAttrs(statements *tsgen.Statements) (*tsgen.Statements, error) {
+	return k.c.AddImageAttrs(statements)
+}
+func (k Kiruna) GetPublicFileIntegrity(hashedFilename string) string {
+	return k.c.GetPublicFileIntegrity(hashedFilename)
+}
 func (k Kiruna) GetServeStaticHandler(addImmutableCacheHeaders bool) (http.Handler, error) {
 	return k.c.GetServeStaticHandler(addImmutableCacheHeaders)
 }
@@ -0,0 +1,602 @@
+// Package brotli is a pure-Go brotli (RFC 7932) compressor. It pairs LZ77
+// matching (with hash chains and lazy evaluation) over a 4 MB window with
+// per-meta-block Huffman coding. Its output is about the size of gzip's at
+// its best level for small files, and smaller for larger ones. It doesn't
+// use context modeling or the static dictionary, so dedicated brotli
+// encoders do better still.
+package brotli
+
+import (
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"slices"
+)
+
+const (
+	windowBits  = 22
+	maxDistance = 1<<windowBits - 16
+	blockSize   = 1 << 16 // bytes per meta-block
+	minMatch    = 4
+	niceMatch   = 258
+	maxChain    = 1024
+	hashBits    = 16
+)
+
+// Compress writes the brotli-compressed form of src to dst.
+func Compress(dst io.Writer, src []byte) error {
+	w := &bitWriter{buf: make([]byte, 0, len(src)/3+16)}
+
+	// WBITS
+	w.writeBits(1, 1)
+	w.writeBits(3, windowBits-17)
+
+	m := newMatcher(src)
+	lastDistance := uint32(4) // the decoder's initial last distance
+	for start := 0; start < len(src); start += blockSize {
+		end := min(start+blockSize, len(src))
+		commands := m.findCommands(start, end)
+
+		// Stores incompressible blocks as-is instead
+		saved, savedLastDistance := *w, lastDistance
+		w.writeMetaBlock(src[start:end], commands, &lastDistance)
+		if compressedBits := 8*(len(w.buf)-len(saved.buf)) + int(w.nbits) - int(saved.nbits); compressedBits > 8*(end-start)+32 {
+			*w, lastDistance = saved, savedLastDistance
+			w.buf = w.buf[:len(saved.buf)]
+			w.writeUncompressedMetaBlock(src[start:end])
+		}
+	}
+
+	// ISLAST, ISLASTEMPTY
+	w.writeBits(2, 3)
+	w.flush()
+
+	_, err := dst.Write(w.buf)
+	return err
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// LZ77
+/////////////////////////////////////////////////////////////////////
+
+// Inserts insertLen literals, then copies copyLen bytes from distance bytes
+// back. Only a meta-block's last command may have a copyLen of zero.
+type command struct {
+	insertLen uint32
+	copyLen   uint32
+	distance  uint32
+}
+
+type matcher struct {
+	src          []byte
+	head         []int32
+	prev         []int32
+	lastDistance int
+}
+
+func newMatcher(src []byte) *matcher {
+	head := make([]int32, 1<<hashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	return &matcher{src: src, head: head, prev: make([]int32, len(src)), lastDistance: 4}
+}
+
+func (m *matcher) hash(pos int) uint32 {
+	return (binary.LittleEndian.Uint32(m.src[pos:]) * 0x1e35a7bd) >> (32 - hashBits)
+}
+
+func (m *matcher) insert(pos int) {
+	if pos+4 > len(m.src) {
+		return
+	}
+	h := m.hash(pos)
+	m.prev[pos] = m.head[h]
+	m.head[h] = int32(pos)
+}
+
+// Returns the longest match for pos that ends at or before end, preferring
+// the last distance (which is the cheapest to encode).
+func (m *matcher) find(pos, end int) (length, distance int) {
+	limit := end - pos
+	if limit < minMatch || pos+4 > len(m.src) {
+		return 0, 0
+	}
+	cur := m.src[pos:end]
+
+	if d := m.lastDistance; d <= pos {
+		if l := matchLen(m.src[pos-d:], cur); l >= minMatch {
+			length, distance = l, d
+		}
+	}
+
+	cand := m.head[m.hash(pos)]
+	for chain := maxChain; cand >= 0 && chain > 0 && length < min(limit, niceMatch); chain-- {
+		d := pos - int(cand)
+		if d > maxDistance {
+			break
+		}
+		if m.src[int(cand)+length] == cur[length] {
+			if l := matchLen(m.src[cand:], cur); l > length {
+				length, distance = l, d
+			}
+		}
+		cand = m.prev[cand]
+	}
+
+	if length < minMatch {
+		return 0, 0
+	}
+	return length, distance
+}
+
+func matchLen(a, b []byte) int {
+	n := min(len(a), len(b))
+	i := 0
+	for i+8 <= n {
+		if x := binary.LittleEndian.Uint64(a[i:]) ^ binary.LittleEndian.Uint64(b[i:]); x != 0 {
+			return i + bits.TrailingZeros64(x)/8
+		}
+		i += 8
+	}
+	for i < n && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// Splits src[start:end] into commands. Matches may reach back into earlier
+// meta-blocks, but never past end.
+func (m *matcher) findCommands(start, end int) []command {
+	var commands []command
+	pos, literalStart := start, start
+
+	for pos < end {
+		length, distance := m.find(pos, end)
+		m.insert(pos)
+		if length == 0 {
+			pos++
+			continue
+		}
+
+		// Lazy evaluation: emit a literal instead if the next position
+		// has a longer match
+		for pos+1 < end && length < niceMatch {
+			nextLength, nextDistance := m.find(pos+1, end)
+			if nextLength <= length {
+				break
+			}
+			pos++
+			m.insert(pos)
+			length, distance = nextLength, nextDistance
+		}
+
+		commands = append(commands, command{
+			insertLen: uint32(pos - literalStart),
+			copyLen:   uint32(length),
+			distance:  uint32(distance),
+		})
+		m.lastDistance = distance
+
+		for i := pos + 1; i < pos+length; i++ {
+			m.insert(i)
+		}
+		pos += length
+		literalStart = pos
+	}
+
+	if literalStart < end {
+		commands = append(commands, command{insertLen: uint32(end - literalStart)})
+	}
+	return commands
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// META-BLOCKS
+/////////////////////////////////////////////////////////////////////
+
+var (
+	insertBase  = [24]uint32{0, 1, 2, 3, 4, 5, 6, 8, 10, 14, 18, 26, 34, 50, 66, 98, 130, 194, 322, 578, 1090, 2114, 6210, 22594}
+	insertExtra = [24]uint8{0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 12, 14, 24}
+	copyBase    = [24]uint32{2, 3, 4, 5, 6, 7, 8, 9, 10, 12, 14, 18, 22, 30, 38, 54, 70, 102, 134, 198, 326, 582, 1094, 2118}
+	copyExtra   = [24]uint8{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 7, 8, 9, 10, 24}
+)
+
+const (
+	literalAlphabetSize  = 256
+	commandAlphabetSize  = 704
+	distanceAlphabetSize = 64 // 16 + NDIRECT (0) + 48 << NPOSTFIX (0)
+)
+
+// Returns the length code for n, per the given base table.
+func lengthCode(base *[24]uint32, n uint32) uint32 {
+	code, _ := slices.BinarySearch(base[:], n+1)
+	return uint32(code - 1)
+}
+
+// Returns the insert-and-copy length symbol. Symbols below 128 imply that
+// the distance is the last distance.
+func commandSymbol(insertCode, copyCode uint32, useLastDistance bool) uint32 {
+	var base uint32
+	switch {
+	case useLastDistance && insertCode < 8 && copyCode < 8:
+		base = 0
+	case useLastDistance && insertCode < 8 && copyCode < 16:
+		base = 64
+	case insertCode < 8 && copyCode < 8:
+		base = 128
+	case insertCode < 8 && copyCode < 16:
+		base = 192
+	case insertCode < 8:
+		base = 384
+	case insertCode < 16 && copyCode < 8:
+		base = 256
+	case insertCode < 16 && copyCode < 16:
+		base = 320
+	case insertCode < 16:
+		base = 512
+	case copyCode < 8:
+		base = 448
+	case copyCode < 16:
+		base = 576
+	default:
+		base = 640
+	}
+	return base + (insertCode&7)<<3 + copyCode&7
+}
+
+// Returns the distance symbol and its extra bits, for NPOSTFIX = 0 and
+// NDIRECT = 0.
+func distanceSymbol(distance uint32) (symbol uint32, nbits uint, extra uint32) {
+	x := distance + 3
+	nbits = uint(bits.Len32(x) - 2)
+	hbit := (x >> nbits) & 1
+	return 16 + 2*uint32(nbits-1) + hbit, nbits, x - (2+hbit)<<nbits
+}
+
+type encodedCommand struct {
+	command
+	symbol      uint32
+	insertCode  uint32
+	copyCode    uint32
+	hasDistance bool
+	distSymbol  uint32
+	distBits    uint
+	distExtra   uint32
+}
+
+func (w *bitWriter) writeMetaBlock(block []byte, commands []command, lastDistance *uint32) {
+	literalHisto := make([]uint32, literalAlphabetSize)
+	commandHisto := make([]uint32, commandAlphabetSize)
+	distanceHisto := make([]uint32, distanceAlphabetSize)
+
+	encoded := make([]encodedCommand, len(commands))
+	pos := 0
+	for i, cmd := range commands {
+		e := encodedCommand{command: cmd}
+		for _, b := range block[pos : pos+int(cmd.insertLen)] {
+			literalHisto[b]++
+		}
+		pos += int(cmd.insertLen) + int(cmd.copyLen)
+
+		copyLen := cmd.copyLen
+		if copyLen == 0 {
+			// The meta-block ends after the insert, so the copy is ignored
+			copyLen = minMatch
+		}
+		e.insertCode = lengthCode(&insertBase, cmd.insertLen)
+		e.copyCode = lengthCode(&copyBase, copyLen)
+
+		isLastDistance := cmd.copyLen == 0 || cmd.distance == *lastDistance
+		e.symbol = commandSymbol(e.insertCode, e.copyCode, isLastDistance)
+		commandHisto[e.symbol]++
+
+		if cmd.copyLen > 0 && e.symbol >= 128 {
+			e.hasDistance = true
+			if cmd.distance == *lastDistance {
+				e.distSymbol = 0
+			} else {
+				e.distSymbol, e.distBits, e.distExtra = distanceSymbol(cmd.distance)
+			}
+			distanceHisto[e.distSymbol]++
+		}
+		if cmd.copyLen > 0 {
+			*lastDistance = cmd.distance
+		}
+		encoded[i] = e
+	}
+
+	w.writeMetaBlockHeader(len(block))
+
+	literalCode := w.writePrefixCode(literalHisto, 8)
+	commandCode := w.writePrefixCode(commandHisto, 10)
+	distanceCode := w.writePrefixCode(distanceHisto, 6)
+
+	pos = 0
+	for _, e := range encoded {
+		commandCode.write(w, e.symbol)
+		w.writeBits(uint(insertExtra[e.insertCode]), uint64(e.insertLen-insertBase[e.insertCode]))
+		copyLen := max(e.copyLen, minMatch)
+		w.writeBits(uint(copyExtra[e.copyCode]), uint64(copyLen-copyBase[e.copyCode]))
+		for _, b := range block[pos : pos+int(e.insertLen)] {
+			literalCode.write(w, uint32(b))
+		}
+		if e.hasDistance {
+			distanceCode.write(w, e.distSymbol)
+			w.writeBits(e.distBits, uint64(e.distExtra))
+		}
+		pos += int(e.insertLen) + int(e.copyLen)
+	}
+}
+
+func (w *bitWriter) writeUncompressedMetaBlock(block []byte) {
+	w.writeMetaBlockLength(len(block))
+	w.writeBits(1, 1) // ISUNCOMPRESSED
+	w.flush()
+	w.buf = append(w.buf, block...)
+}
+
+func (w *bitWriter) writeMetaBlockLength(length int) {
+	w.writeBits(1, 0) // ISLAST
+	nibbles := max((bits.Len32(uint32(length-1))+3)/4, 4)
+	w.writeBits(2, uint64(nibbles-4))
+	w.writeBits(uint(nibbles*4), uint64(length-1))
+}
+
+func (w *bitWriter) writeMetaBlockHeader(length int) {
+	w.writeMetaBlockLength(length)
+	w.writeBits(1, 0) // ISUNCOMPRESSED
+	w.writeBits(3, 0) // NBLTYPESL, NBLTYPESI and NBLTYPESD are each 1
+	w.writeBits(6, 0) // NPOSTFIX and NDIRECT are 0
+	w.writeBits(2, 0) // literal context mode LSB6 (unused with one tree)
+	w.writeBits(2, 0) // NTREESL and NTREESD are 1
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// PREFIX CODES
+/////////////////////////////////////////////////////////////////////
+
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint16
+}
+
+func (p *prefixCode) write(w *bitWriter, symbol uint32) {
+	w.writeBits(uint(p.lengths[symbol]), uint64(p.codes[symbol]))
+}
+
+const maxCodeLength = 15
+
+// The order in which code length code lengths are stored, and the static
+// code they are stored with (codes are bit-reversed, ready to write)
+var (
+	codeLengthOrder       = [18]uint8{1, 2, 3, 4, 0, 5, 17, 6, 16, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	codeLengthLengthCodes = [6]uint8{0, 7, 3, 2, 1, 15}
+	codeLengthLengthBits  = [6]uint8{2, 4, 3, 2, 2, 4}
+)
+
+// Writes a prefix code fit to histo, and returns it for writing symbols.
+func (w *bitWriter) writePrefixCode(histo []uint32, alphabetBits uint) *prefixCode {
+	lengths := huffmanLengths(histo, maxCodeLength)
+
+	used := 0
+	var lastUsed int
+	for s, l := range lengths {
+		if l > 0 {
+			used++
+			lastUsed = s
+		}
+	}
+	if used <= 1 {
+		// Simple prefix code with one symbol, which takes zero bits
+		w.writeBits(2, 1) // HSKIP
+		w.writeBits(2, 0) // NSYM - 1
+		w.writeBits(alphabetBits, uint64(lastUsed))
+		return &prefixCode{lengths: make([]uint8, len(histo)), codes: make([]uint16, len(histo))}
+	}
+
+	// Run-length encode zeros with code 17 (never twice in a row, since
+	// consecutive repeat codes combine). Trailing zeros are implied.
+	type codeLengthSymbol struct{ symbol, extra uint8 }
+	var symbols []codeLengthSymbol
+	codeLengthHisto := make([]uint32, 18)
+	wasRepeat := false
+	for i := 0; i <= lastUsed; {
+		run := 0
+		for i+run <= lastUsed && lengths[i+run] == 0 {
+			run++
+		}
+		if run >= 3 && !wasRepeat {
+			n := min(run, 10)
+			symbols = append(symbols, codeLengthSymbol{17, uint8(n - 3)})
+			codeLengthHisto[17]++
+			i += n
+			wasRepeat = true
+			continue
+		}
+		symbols = append(symbols, codeLengthSymbol{lengths[i], 0})
+		codeLengthHisto[lengths[i]]++
+		i++
+		wasRepeat = false
+	}
+
+	codeLengthLengths := huffmanLengths(codeLengthHisto, 5)
+	codeLengthCode := &prefixCode{lengths: slices.Clone(codeLengthLengths), codes: canonicalCodes(codeLengthLengths)}
+	numCodes := 0
+	for _, l := range codeLengthLengths {
+		if l > 0 {
+			numCodes++
+		}
+	}
+
+	// The decoder stops reading once the code is complete, so trailing
+	// zeros are omitted. A single code is never complete, so all are
+	// written, and its symbol then takes zero bits.
+	toStore := len(codeLengthOrder)
+	if numCodes > 1 {
+		for codeLengthLengths[codeLengthOrder[toStore-1]] == 0 {
+			toStore--
+		}
+	} else {
+		clear(codeLengthCode.lengths)
+	}
+	skip := 0
+	if codeLengthLengths[codeLengthOrder[0]] == 0 && codeLengthLengths[codeLengthOrder[1]] == 0 {
+		skip = 2
+		if codeLengthLengths[codeLengthOrder[2]] == 0 {
+			skip = 3
+		}
+	}
+	w.writeBits(2, uint64(skip)) // HSKIP
+	for _, symbol := range codeLengthOrder[skip:toStore] {
+		l := codeLengthLengths[symbol]
+		w.writeBits(uint(codeLengthLengthBits[l]), uint64(codeLengthLengthCodes[l]))
+	}
+
+	for _, s := range symbols {
+		codeLengthCode.write(w, uint32(s.symbol))
+		if s.symbol == 17 {
+			w.writeBits(3, uint64(s.extra))
+		}
+	}
+
+	return &prefixCode{lengths: lengths, codes: canonicalCodes(lengths)}
+}
+
+// Returns Huffman code lengths for histo, limited to maxBits. Lone symbols
+// get a length of 1.
+func huffmanLengths(histo []uint32, maxBits int) []uint8 {
+	lengths := make([]uint8, len(histo))
+
+	var symbols []int
+	for s, count := range histo {
+		if count > 0 {
+			symbols = append(symbols, s)
+		}
+	}
+	switch len(symbols) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[symbols[0]] = 1
+		return lengths
+	}
+
+	// Raising the smallest counts flattens the tree until it fits
+	for minCount := uint32(1); ; minCount *= 2 {
+		if buildHuffmanTree(histo, symbols, minCount, lengths) <= maxBits {
+			return lengths
+		}
+	}
+}
+
+// Sets the depth of each symbol in a Huffman tree for histo (with counts
+// raised to at least minCount), and returns the maximum depth.
+func buildHuffmanTree(histo []uint32, symbols []int, minCount uint32, lengths []uint8) int {
+	type node struct {
+		weight      uint64
+		left, right int
+		symbol      int
+	}
+
+	n := len(symbols)
+	nodes := make([]node, 0, 2*n-1)
+	for _, s := range symbols {
+		nodes = append(nodes, node{weight: uint64(max(histo[s], minCount)), left: -1, right: -1, symbol: s})
+	}
+	slices.SortStableFunc(nodes, func(a, b node) int {
+		switch {
+		case a.weight < b.weight:
+			return -1
+		case a.weight > b.weight:
+			return 1
+		}
+		return 0
+	})
+
+	// Two queues: the sorted leaves, and the internal nodes (which are
+	// created in order of weight)
+	leaf, internal := 0, n
+	pop := func() int {
+		if leaf < n && (internal >= len(nodes) || nodes[leaf].weight <= nodes[internal].weight) {
+			leaf++
+			return leaf - 1
+		}
+		internal++
+		return internal - 1
+	}
+	for range n - 1 {
+		a, b := pop(), pop()
+		nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, left: a, right: b, symbol: -1})
+	}
+
+	maxDepth := 0
+	depths := make([]int, len(nodes))
+	for i := len(nodes) - 1; i >= 0; i-- {
+		nd := nodes[i]
+		if nd.symbol >= 0 {
+			lengths[nd.symbol] = uint8(min(depths[i], 255))
+			maxDepth = max(maxDepth, depths[i])
+			continue
+		}
+		depths[nd.left] = depths[i] + 1
+		depths[nd.right] = depths[i] + 1
+	}
+	return maxDepth
+}
+
+// Returns the canonical codes for the given lengths, bit-reversed so that
+// they can be written LSB first.
+func canonicalCodes(lengths []uint8) []uint16 {
+	var lengthCounts [maxCodeLength + 1]int
+	for _, l := range lengths {
+		if l > 0 {
+			lengthCounts[l]++
+		}
+	}
+	var nextCode [maxCodeLength + 1]int
+	code := 0
+	for l := 1; l <= maxCodeLength; l++ {
+		code = (code + lengthCounts[l-1]) << 1
+		nextCode[l] = code
+	}
+
+	codes := make([]uint16, len(lengths))
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		codes[s] = uint16(bits.Reverse16(uint16(nextCode[l])) >> (16 - l))
+		nextCode[l]++
+	}
+	return codes
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// BIT WRITER
+/////////////////////////////////////////////////////////////////////
+
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+// Writes the low n (at most 56) bits of v, LSB first.
+func (w *bitWriter) writeBits(n uint, v uint64) {
+	w.acc |= v << w.nbits
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc >>= 8
+		w.nbits -= 8
+	}
+}
+
+// Pads the output to a byte boundary with zeros.
+func (w *bitWriter) flush() {
+	if w.nbits > 0 {
+		w.buf = append(w.buf, byte(w.acc))
+		w.acc, w.nbits = 0, 0
+	}
+}
@@ -0,0 +1,166 @@
+package brotli
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math/rand/v2"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func testInputs() map[string][]byte {
+	r := rand.New(rand.NewPCG(1, 2))
+	random := make([]byte, 100_000)
+	for i := range random {
+		random[i] = byte(r.Uint32())
+	}
+
+	var js strings.Builder
+	for i := range 3000 {
+		js.WriteString("export function handler")
+		js.WriteByte(byte('a' + i%26))
+		js.WriteString("(req, res) { return res.json({ ok: true, id: ")
+		js.WriteByte(byte('0' + i%10))
+		js.WriteString(" }); }\n")
+	}
+
+	return map[string][]byte{
+		"empty":       {},
+		"one byte":    []byte("a"),
+		"short":       []byte("hello, hello, hello world"),
+		"repetitive":  bytes.Repeat([]byte("abc"), 100_000),
+		"js":          []byte(js.String()),
+		"random":      random,
+		"mixed":       append(append([]byte(js.String()), random[:70_000]...), js.String()...),
+		"zeros":       make([]byte, blockSize*2+1),
+		"block sized": bytes.Repeat([]byte("0123456789abcdef"), blockSize/16),
+	}
+}
+
+func compress(t *testing.T, src []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Compress(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// Go's standard library has no brotli decoder, so round trips go through
+// Node's (if available).
+func TestRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node not found")
+	}
+	for name, src := range testInputs() {
+		t.Run(name, func(t *testing.T) {
+			cmd := exec.Command("node", "-e", `process.stdout.write(require("zlib").brotliDecompressSync(require("fs").readFileSync(0)))`)
+			cmd.Stdin = bytes.NewReader(compress(t, src))
+			var stderr bytes.Buffer
+			cmd.Stderr = &stderr
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("decompression failed: %v\n%s", err, stderr.String())
+			}
+			if !bytes.Equal(out, src) {
+				t.Errorf("round trip mismatch: got %d bytes, want: %d", len(out), len(src))
+			}
+		})
+	}
+}
+
+func TestCompressionRatio(t *testing.T) {
+	inputs := testInputs()
+	for _, name := range []string{"repetitive", "js", "mixed"} {
+		var gz bytes.Buffer
+		zw, _ := gzip.NewWriterLevel(&gz, gzip.BestCompression)
+		zw.Write(inputs[name])
+		zw.Close()
+		if br := compress(t, inputs[name]); len(br) > gz.Len() {
+			t.Errorf("%s: brotli output (%d bytes) is larger than gzip's (%d bytes)", name, len(br), gz.Len())
+		}
+	}
+
+	// Incompressible blocks are stored, with only a few bytes of overhead
+	if random := inputs["random"]; len(compress(t, random)) > len(random)+16 {
+		t.Errorf("expected random data to be stored as-is")
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	// WBITS (22), then ISLAST and ISLASTEMPTY
+	if got := compress(t, nil); !bytes.Equal(got, []byte{0x3b}) {
+		t.Errorf("Compress(nil) = %x, want: 3b", got)
+	}
+}
+
+func TestHuffmanLengths(t *testing.T) {
+	histo := make([]uint32, 300)
+	for i := range histo {
+		// Skewed enough to need length limiting
+		histo[i] = 1 << (i % 28)
+	}
+	histo[7] = 0
+
+	for _, maxBits := range []int{5, 15} {
+		small := histo[:18]
+		if maxBits == 15 {
+			small = histo
+		}
+		lengths := huffmanLengths(small, maxBits)
+		kraft := 0
+		for s, l := range lengths {
+			if (l == 0) != (small[s] == 0) {
+				t.Errorf("symbol %d: length %d for count %d", s, l, small[s])
+			}
+			if int(l) > maxBits {
+				t.Errorf("symbol %d: length %d exceeds %d", s, l, maxBits)
+			}
+			if l > 0 {
+				kraft += 1 << (maxBits - int(l))
+			}
+		}
+		if kraft != 1<<maxBits {
+			t.Errorf("maxBits %d: expected a complete code, got Kraft sum %d/%d", maxBits, kraft, 1<<maxBits)
+		}
+	}
+
+	if lengths := huffmanLengths([]uint32{0, 5, 0}, 15); lengths[1] != 1 || lengths[0] != 0 || lengths[2] != 0 {
+		t.Errorf("expected a lone symbol to get length 1, got %v", lengths)
+	}
+}
+
+func TestCanonicalCodes(t *testing.T) {
+	// From RFC 7932 section 3.2 (lengths 3, 3, 3, 3, 3, 2, 4, 4 give codes
+	// 010, 011, 100, 101, 110, 00, 1110, 1111), bit-reversed
+	codes := canonicalCodes([]uint8{3, 3, 3, 3, 3, 2, 4, 4})
+	want := []uint16{0b010, 0b110, 0b001, 0b101, 0b011, 0b00, 0b0111, 0b1111}
+	for i := range want {
+		if codes[i] != want[i] {
+			t.Errorf("code %d = %b, want: %b", i, codes[i], want[i])
+		}
+	}
+}
+
+func TestDistanceSymbol(t *testing.T) {
+	tests := []struct {
+		distance uint32
+		symbol   uint32
+		nbits    uint
+		extra    uint32
+	}{
+		{1, 16, 1, 0},
+		{2, 16, 1, 1},
+		{3, 17, 1, 0},
+		{4, 17, 1, 1},
+		{5, 18, 2, 0},
+		{maxDistance, 16 + 2*(windowBits-3) + 1, windowBits - 2, 1<<(windowBits-2) - 13},
+	}
+	for _, tt := range tests {
+		symbol, nbits, extra := distanceSymbol(tt.distance)
+		if symbol != tt.symbol || nbits != tt.nbits || extra != tt.extra {
+			t.Errorf("distanceSymbol(%d) = %d, %d, %d, want: %d, %d, %d", tt.distance, symbol, nbits, extra, tt.symbol, tt.nbits, tt.extra)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Package cachestore defines a minimal, pluggable key-value store for cached
+// response payloads, along with an in-memory implementation (backed by
+// kit/lru) and a filesystem implementation. Stores must be safe for
+// concurrent use.
+package cachestore
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sjc5/river/kit/colorlog"
+	"github.com/sjc5/river/kit/lru"
+)
+
+var Log = colorlog.New("cachestore")
+
+type Store interface {
+	// Get returns the entry for key, if present and not yet expired.
+	Get(key string) (*Entry, bool)
+	// Set stores the entry for key, replacing any existing entry.
+	Set(key string, entry *Entry)
+	// Delete removes the entry for key, if present.
+	Delete(key string)
+	// DeletePrefix removes all entries whose keys start with prefix.
+	DeletePrefix(prefix string)
+}
+
+type Entry struct {
+	Value    []byte    `json:"value"`
+	StoredAt time.Time `json:"storedAt"`
+	// Optional. Zero means the entry never expires. Stores must not return
+	// entries after this time.
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+}
+
+// IsExpired reports whether the entry has a set expiry that is not after now.
+func (e *Entry) IsExpired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// IN-MEMORY (LRU) STORE
+/////////////////////////////////////////////////////////////////////
+
+type LRU struct {
+	cache *lru.Cache[string, *Entry]
+}
+
+// NewLRU creates an in-memory store that holds at most maxItems entries,
+// evicting the least recently used entry when full.
+func NewLRU(maxItems int) *LRU {
+	return &LRU{cache: lru.NewCache[string, *Entry](maxItems)}
+}
+
+func (s *LRU) Get(key string) (*Entry, bool) {
+	entry, found := s.cache.Get(key)
+	if !found || entry.IsExpired(time.Now()) {
+		return nil, false
+	}
+	return entry, true
+}
+
+func (s *LRU) Set(key string, entry *Entry) {
+	var ttl time.Duration
+	if !entry.ExpiresAt.IsZero() {
+		ttl = time.Until(entry.ExpiresAt)
+		if ttl <= 0 {
+			s.cache.Delete(key)
+			return
+		}
+	}
+	s.cache.SetWithTTL(key, entry, false, ttl)
+}
+
+func (s *LRU) Delete(key string) {
+	s.cache.Delete(key)
+}
+
+func (s *LRU) DeletePrefix(prefix string) {
+	s.cache.DeleteFunc(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
@@ -0,0 +1,103 @@
+package cachestore
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func newStores(t *testing.T) map[string]Store {
+	fsStore, err := NewFS(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFS: %v", err)
+	}
+	return map[string]Store{"lru": NewLRU(10), "fs": fsStore}
+}
+
+func TestStores(t *testing.T) {
+	for name, store := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, found := store.Get("missing"); found {
+				t.Error("expected miss for unknown key")
+			}
+
+			store.Set("a", &Entry{Value: []byte("1"), StoredAt: time.Now()})
+			entry, found := store.Get("a")
+			if !found || string(entry.Value) != "1" {
+				t.Fatalf("expected hit with value 1, got %v %v", entry, found)
+			}
+
+			store.Set("a", &Entry{Value: []byte("2"), StoredAt: time.Now()})
+			if entry, _ := store.Get("a"); entry == nil || string(entry.Value) != "2" {
+				t.Errorf("expected overwritten value 2, got %v", entry)
+			}
+
+			store.Delete("a")
+			if _, found := store.Get("a"); found {
+				t.Error("expected miss after Delete")
+			}
+		})
+	}
+}
+
+func TestStoresExpiry(t *testing.T) {
+	for name, store := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store.Set("expired", &Entry{Value: []byte("x"), ExpiresAt: time.Now().Add(-time.Second)})
+			if _, found := store.Get("expired"); found {
+				t.Error("expected expired entry to be a miss")
+			}
+
+			store.Set("short", &Entry{Value: []byte("x"), ExpiresAt: time.Now().Add(20 * time.Millisecond)})
+			if _, found := store.Get("short"); !found {
+				t.Error("expected unexpired entry to be a hit")
+			}
+			time.Sleep(40 * time.Millisecond)
+			if _, found := store.Get("short"); found {
+				t.Error("expected entry to expire")
+			}
+		})
+	}
+}
+
+func TestStoresDeletePrefix(t *testing.T) {
+	for name, store := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			store.Set("GET /products/:id\x00id=1\x01", &Entry{Value: []byte("1")})
+			store.Set("GET /products/:id\x00id=10\x01", &Entry{Value: []byte("10")})
+			store.Set("GET /users/:id\x00id=1\x01", &Entry{Value: []byte("u1")})
+
+			store.DeletePrefix("GET /products/:id\x00id=1\x01")
+			if _, found := store.Get("GET /products/:id\x00id=1\x01"); found {
+				t.Error("expected exact prefix match to be deleted")
+			}
+			if _, found := store.Get("GET /products/:id\x00id=10\x01"); !found {
+				t.Error("expected id=10 to survive")
+			}
+
+			store.DeletePrefix("GET /products/:id\x00")
+			if _, found := store.Get("GET /products/:id\x00id=10\x01"); found {
+				t.Error("expected all product entries to be deleted")
+			}
+			if _, found := store.Get("GET /users/:id\x00id=1\x01"); !found {
+				t.Error("expected unrelated entry to survive")
+			}
+		})
+	}
+}
+
+func TestFSIgnoresForeignFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFS(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/garbage.json", []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	store.Set("a", &Entry{Value: []byte("1")})
+	store.DeletePrefix("")
+	if _, found := store.Get("a"); found {
+		t.Error("expected entry to be deleted")
+	}
+}
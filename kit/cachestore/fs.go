@@ -0,0 +1,127 @@
+package cachestore
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sjc5/river/kit/cryptoutil"
+	"github.com/sjc5/river/kit/fsutil"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// FILESYSTEM STORE
+/////////////////////////////////////////////////////////////////////
+
+// FS stores each entry as a JSON file (named by the SHA-256 hash of its key)
+// in a single directory. Because the Store interface does not return errors,
+// filesystem failures are logged and treated as cache misses. Expired
+// entries are removed lazily when read. DeletePrefix reads every file in
+// the directory, so it is O(n) in the number of stored entries.
+type FS struct {
+	dir string
+}
+
+type fsFile struct {
+	Key   string `json:"key"`
+	Entry *Entry `json:"entry"`
+}
+
+// NewFS creates a filesystem store rooted at dir, creating the directory
+// if it does not already exist.
+func NewFS(dir string) (*FS, error) {
+	if err := fsutil.EnsureDir(dir); err != nil {
+		return nil, fmt.Errorf("could not create cache dir: %w", err)
+	}
+	return &FS{dir: dir}, nil
+}
+
+func (s *FS) Get(key string) (*Entry, bool) {
+	path := s.pathFor(key)
+	f, ok := s.readFile(path)
+	if !ok || f.Key != key {
+		return nil, false
+	}
+	if f.Entry.IsExpired(time.Now()) {
+		s.remove(path)
+		return nil, false
+	}
+	return f.Entry, true
+}
+
+func (s *FS) Set(key string, entry *Entry) {
+	b, err := json.Marshal(fsFile{Key: key, Entry: entry})
+	if err != nil {
+		Log.Warn(fmt.Sprintf("could not marshal cache entry: %s", err))
+		return
+	}
+
+	// Write to a temp file and rename so that readers never see partial writes
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		Log.Warn(fmt.Sprintf("could not create temp cache file: %s", err))
+		return
+	}
+	_, err = tmp.Write(b)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.pathFor(key))
+	}
+	if err != nil {
+		Log.Warn(fmt.Sprintf("could not write cache file: %s", err))
+		s.remove(tmp.Name())
+	}
+}
+
+func (s *FS) Delete(key string) {
+	s.remove(s.pathFor(key))
+}
+
+func (s *FS) DeletePrefix(prefix string) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		Log.Warn(fmt.Sprintf("could not read cache dir: %s", err))
+		return
+	}
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		path := filepath.Join(s.dir, name)
+		if f, ok := s.readFile(path); ok && strings.HasPrefix(f.Key, prefix) {
+			s.remove(path)
+		}
+	}
+}
+
+func (s *FS) pathFor(key string) string {
+	return filepath.Join(s.dir, hex.EncodeToString(cryptoutil.Sha256Hash([]byte(key)))+".json")
+}
+
+func (s *FS) readFile(path string) (*fsFile, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			Log.Warn(fmt.Sprintf("could not read cache file: %s", err))
+		}
+		return nil, false
+	}
+	var f fsFile
+	if err := json.Unmarshal(b, &f); err != nil || f.Entry == nil {
+		return nil, false
+	}
+	return &f, true
+}
+
+func (s *FS) remove(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		Log.Warn(fmt.Sprintf("could not remove cache file: %s", err))
+	}
+}
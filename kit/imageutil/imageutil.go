@@ -0,0 +1,167 @@
+// Package imageutil provides pure-Go image resizing and encoding helpers.
+package imageutil
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+)
+
+// Resize scales src to width x height by averaging the source pixels that
+// each destination pixel covers (an area filter). This gives good results
+// for downscaling, which is what it is meant for.
+func Resize(src image.Image, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	b := src.Bounds()
+	srcW, srcH := b.Dx(), b.Dy()
+	if width <= 0 || height <= 0 || srcW == 0 || srcH == 0 {
+		return dst
+	}
+
+	// Work in premultiplied RGBA, so that transparent pixels don't bleed
+	// their color into their neighbors
+	rgba, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, srcW, srcH))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+
+	xWeights := toWeights(srcW, width)
+	yWeights := toWeights(srcH, height)
+
+	// Horizontal pass, into a float buffer of height srcH
+	tmp := make([]float64, srcH*width*4)
+	for y := range srcH {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x, ws := range xWeights {
+			var r, g, bl, a float64
+			for _, w := range ws {
+				i := w.index * 4
+				r += float64(row[i]) * w.weight
+				g += float64(row[i+1]) * w.weight
+				bl += float64(row[i+2]) * w.weight
+				a += float64(row[i+3]) * w.weight
+			}
+			j := (y*width + x) * 4
+			tmp[j], tmp[j+1], tmp[j+2], tmp[j+3] = r, g, bl, a
+		}
+	}
+
+	// Vertical pass
+	for y, ws := range yWeights {
+		for x := range width {
+			var r, g, bl, a float64
+			for _, w := range ws {
+				j := (w.index*width + x) * 4
+				r += tmp[j] * w.weight
+				g += tmp[j+1] * w.weight
+				bl += tmp[j+2] * w.weight
+				a += tmp[j+3] * w.weight
+			}
+			i := y*dst.Stride + x*4
+			dst.Pix[i] = clampToUint8(r)
+			dst.Pix[i+1] = clampToUint8(g)
+			dst.Pix[i+2] = clampToUint8(bl)
+			dst.Pix[i+3] = clampToUint8(a)
+		}
+	}
+
+	return dst
+}
+
+// ResizeToWidth scales src to the given width, preserving its aspect ratio.
+func ResizeToWidth(src image.Image, width int) *image.RGBA {
+	b := src.Bounds()
+	return Resize(src, width, HeightForWidth(b.Dx(), b.Dy(), width))
+}
+
+// HeightForWidth returns the height an image of srcW x srcH has when scaled
+// to width, preserving its aspect ratio (never less than 1).
+func HeightForWidth(srcW, srcH, width int) int {
+	if srcW == 0 {
+		return 0
+	}
+	return max(1, int(math.Round(float64(srcH)*float64(width)/float64(srcW))))
+}
+
+type weight struct {
+	index  int
+	weight float64
+}
+
+// For each destination pixel, the source pixels it covers and how much of
+// each, normalized to sum to 1.
+func toWeights(srcLen, dstLen int) [][]weight {
+	scale := float64(srcLen) / float64(dstLen)
+	result := make([][]weight, dstLen)
+	for d := range dstLen {
+		start, end := float64(d)*scale, float64(d+1)*scale
+		var ws []weight
+		var total float64
+		for s := int(start); s < srcLen && float64(s) < end; s++ {
+			overlap := math.Min(end, float64(s+1)) - math.Max(start, float64(s))
+			if overlap <= 0 {
+				continue
+			}
+			ws = append(ws, weight{index: s, weight: overlap})
+			total += overlap
+		}
+		for i := range ws {
+			ws[i].weight /= total
+		}
+		result[d] = ws
+	}
+	return result
+}
+
+func clampToUint8(v float64) uint8 {
+	v = math.Round(v)
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// ENCODERS
+/////////////////////////////////////////////////////////////////////
+
+// Encoder writes img to w in some format. Quality ranges from 1 to 100, and
+// may be ignored by lossless formats.
+type Encoder func(w io.Writer, img image.Image, quality int) error
+
+// EncodeJPEG is an Encoder for JPEG. Transparent areas are flattened onto
+// white, since JPEG has no alpha channel.
+func EncodeJPEG(w io.Writer, img image.Image, quality int) error {
+	b := img.Bounds()
+	flat := image.NewRGBA(b)
+	draw.Draw(flat, b, image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, b, img, b.Min, draw.Over)
+	return jpeg.Encode(w, flat, &jpeg.Options{Quality: quality})
+}
+
+// EncodePNG is an Encoder for PNG. Quality is ignored.
+func EncodePNG(w io.Writer, img image.Image, quality int) error {
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	return enc.Encode(w, img)
+}
+
+// Returns the built-in Encoder for format ("jpeg", "png" or "webp").
+func GetBuiltInEncoder(format string) (Encoder, error) {
+	switch format {
+	case "jpeg":
+		return EncodeJPEG, nil
+	case "png":
+		return EncodePNG, nil
+	case "webp":
+		return EncodeWebP, nil
+	}
+	return nil, fmt.Errorf("imageutil: no built-in encoder for format %q", format)
+}
@@ -0,0 +1,95 @@
+package imageutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestResize(t *testing.T) {
+	// Left half red, right half blue
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := range 2 {
+		for x := range 4 {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 2 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	dst := Resize(src, 2, 1)
+	if dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 1 {
+		t.Fatalf("unexpected bounds %v", dst.Bounds())
+	}
+	if got := dst.RGBAAt(0, 0); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("expected red, got %v", got)
+	}
+	if got := dst.RGBAAt(1, 0); got != (color.RGBA{0, 0, 255, 255}) {
+		t.Errorf("expected blue, got %v", got)
+	}
+
+	// Fractional coverage blends neighbors
+	blended := Resize(src, 1, 1).RGBAAt(0, 0)
+	if blended.R < 126 || blended.R > 128 || blended.B < 126 || blended.B > 128 {
+		t.Errorf("expected an even blend, got %v", blended)
+	}
+}
+
+func TestResizeNonZeroOrigin(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(10, 10, 14, 12))
+	for y := 10; y < 12; y++ {
+		for x := 10; x < 14; x++ {
+			src.Set(x, y, color.NRGBA{0, 255, 0, 255})
+		}
+	}
+	dst := ResizeToWidth(src, 2)
+	if dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 1 {
+		t.Fatalf("unexpected bounds %v", dst.Bounds())
+	}
+	if got := dst.RGBAAt(1, 0); got != (color.RGBA{0, 255, 0, 255}) {
+		t.Errorf("expected green, got %v", got)
+	}
+}
+
+func TestHeightForWidth(t *testing.T) {
+	if h := HeightForWidth(1600, 900, 640); h != 360 {
+		t.Errorf("expected 360, got %d", h)
+	}
+	if h := HeightForWidth(1000, 1, 10); h != 1 {
+		t.Errorf("expected a minimum of 1, got %d", h)
+	}
+}
+
+func TestEncoders(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 8, 8))
+
+	var buf bytes.Buffer
+	if err := EncodeJPEG(&buf, src, 80); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jpeg.Decode(&buf); err != nil {
+		t.Errorf("expected a valid JPEG: %v", err)
+	}
+
+	buf.Reset()
+	if err := EncodePNG(&buf, src, 80); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(&buf); err != nil {
+		t.Errorf("expected a valid PNG: %v", err)
+	}
+
+	for _, format := range []string{"jpeg", "png", "webp"} {
+		if _, err := GetBuiltInEncoder(format); err != nil {
+			t.Errorf("expected a built-in %s encoder: %v", format, err)
+		}
+	}
+	if _, err := GetBuiltInEncoder("avif"); err == nil {
+		t.Error("expected no built-in AVIF encoder")
+	}
+}
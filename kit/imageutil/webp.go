@@ -0,0 +1,685 @@
+package imageutil
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math/bits"
+	"slices"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// LOSSLESS WEBP
+/////////////////////////////////////////////////////////////////////
+
+// EncodeWebP is an Encoder for lossless WebP (VP8L). Quality is ignored.
+// Lossless WebP is usually smaller than PNG, but usually larger than a
+// lossy JPEG of a photo.
+func EncodeWebP(w io.Writer, img image.Image, quality int) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > 1<<14 || height > 1<<14 {
+		return fmt.Errorf("imageutil: cannot encode a %dx%d image as WebP", width, height)
+	}
+
+	nrgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+	argb := make([]uint32, width*height)
+	hasAlpha := false
+	for y := range height {
+		row := nrgba.Pix[y*nrgba.Stride:]
+		for x := range width {
+			p := row[x*4 : x*4+4]
+			if p[3] != 0xff {
+				hasAlpha = true
+			}
+			argb[y*width+x] = uint32(p[3])<<24 | uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+		}
+	}
+
+	bw := &webpBitWriter{}
+	bw.writeBits(0x2f, 8)
+	bw.writeBits(uint64(width-1), 14)
+	bw.writeBits(uint64(height-1), 14)
+	bw.writeBits(boolBit(hasAlpha), 1)
+	bw.writeBits(0, 3)
+
+	// Transforms, in the order they are applied
+	bw.writeBits(1, 1)
+	bw.writeBits(webpSubtractGreenTransform, 2)
+	subtractGreen(argb)
+
+	bw.writeBits(1, 1)
+	bw.writeBits(webpPredictorTransform, 2)
+	bw.writeBits(webpPredictorBits-2, 3)
+	modes, tilesW := applyPredictors(argb, width, height)
+	bw.writeBits(0, 1) // No color cache
+	bw.writeImageData(modes, tilesW)
+
+	bw.writeBits(0, 1) // No more transforms
+
+	bw.writeBits(0, 1) // No color cache
+	bw.writeBits(0, 1) // No meta prefix codes
+	bw.writeImageData(argb, width)
+
+	data := bw.bytes()
+	chunkSize := len(data)
+	padded := chunkSize + chunkSize&1
+	header := make([]byte, 20)
+	copy(header, "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(4+8+padded))
+	copy(header[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(header[16:], uint32(chunkSize))
+	if padded > chunkSize {
+		data = append(data, 0)
+	}
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+const (
+	webpPredictorTransform     = 0
+	webpSubtractGreenTransform = 2
+
+	// Predictor modes are chosen per 16x16 tile
+	webpPredictorBits = 4
+
+	webpNumLengthCodes   = 24
+	webpNumDistanceCodes = 40
+	webpMinMatch         = 3
+	webpMaxMatch         = 4096
+	// The largest distance the 40 distance prefix codes can express, less
+	// the 120 short distance codes
+	webpMaxDistance = 1<<20 - 120
+)
+
+func subtractGreen(argb []uint32) {
+	for i, p := range argb {
+		g := (p >> 8) & 0xff
+		r := ((p >> 16) - g) & 0xff
+		b := (p - g) & 0xff
+		argb[i] = p&0xff00ff00 | r<<16 | b
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// PREDICTORS
+/////////////////////////////////////////////////////////////////////
+
+// Replaces each pixel with its residual from the predictor that suits its
+// tile best, and returns the tile modes (as the green channel of an image
+// tilesW wide).
+func applyPredictors(argb []uint32, width, height int) (modes []uint32, tilesW int) {
+	tileSize := 1 << webpPredictorBits
+	tilesW = (width + tileSize - 1) / tileSize
+	tilesH := (height + tileSize - 1) / tileSize
+	modes = make([]uint32, tilesW*tilesH)
+
+	// Residuals are taken against the original neighbors, which is what the
+	// decoder will have reconstructed
+	residuals := make([]uint32, len(argb))
+	for ty := range tilesH {
+		for tx := range tilesW {
+			x0, y0 := tx*tileSize, ty*tileSize
+			x1, y1 := min(x0+tileSize, width), min(y0+tileSize, height)
+
+			bestMode, bestCost := 0, -1
+			for mode := range 14 {
+				cost := 0
+				for y := max(y0, 1); y < y1; y++ {
+					for x := max(x0, 1); x < x1; x++ {
+						i := y*width + x
+						cost += residualCost(subPixels(argb[i], predict(mode, argb, i, width)))
+					}
+				}
+				if bestCost < 0 || cost < bestCost {
+					bestMode, bestCost = mode, cost
+				}
+			}
+			modes[ty*tilesW+tx] = 0xff000000 | uint32(bestMode)<<8
+
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					i := y*width + x
+					var pred uint32
+					switch {
+					case x == 0 && y == 0:
+						pred = 0xff000000
+					case y == 0:
+						pred = argb[i-1]
+					case x == 0:
+						pred = argb[i-width]
+					default:
+						pred = predict(bestMode, argb, i, width)
+					}
+					residuals[i] = subPixels(argb[i], pred)
+				}
+			}
+		}
+	}
+	copy(argb, residuals)
+	return modes, tilesW
+}
+
+// The prediction of a pixel that is neither in the top row nor the left
+// column. For the rightmost column, the top-right pixel is the leftmost
+// pixel of the current row, which is simply the next one in argb.
+func predict(mode int, argb []uint32, i, width int) uint32 {
+	l, t, tl, tr := argb[i-1], argb[i-width], argb[i-width-1], argb[i-width+1]
+	switch mode {
+	case 0:
+		return 0xff000000
+	case 1:
+		return l
+	case 2:
+		return t
+	case 3:
+		return tr
+	case 4:
+		return tl
+	case 5:
+		return average2(average2(l, tr), t)
+	case 6:
+		return average2(l, tl)
+	case 7:
+		return average2(l, t)
+	case 8:
+		return average2(tl, t)
+	case 9:
+		return average2(t, tr)
+	case 10:
+		return average2(average2(l, tl), average2(t, tr))
+	case 11:
+		return selectPredictor(l, t, tl)
+	case 12:
+		return clampAddSubtractFull(l, t, tl)
+	default:
+		return clampAddSubtractHalf(average2(l, t), tl)
+	}
+}
+
+func channel(p uint32, shift uint) int { return int(p>>shift) & 0xff }
+
+func average2(a, b uint32) uint32 {
+	var out uint32
+	for shift := uint(0); shift < 32; shift += 8 {
+		out |= uint32((channel(a, shift)+channel(b, shift))/2) << shift
+	}
+	return out
+}
+
+func selectPredictor(l, t, tl uint32) uint32 {
+	var pl, pt int
+	for shift := uint(0); shift < 32; shift += 8 {
+		pl += abs(channel(t, shift) - channel(tl, shift))
+		pt += abs(channel(l, shift) - channel(tl, shift))
+	}
+	if pl < pt {
+		return l
+	}
+	return t
+}
+
+func clampAddSubtractFull(a, b, c uint32) uint32 {
+	var out uint32
+	for shift := uint(0); shift < 32; shift += 8 {
+		out |= uint32(clampChannel(channel(a, shift)+channel(b, shift)-channel(c, shift))) << shift
+	}
+	return out
+}
+
+func clampAddSubtractHalf(a, b uint32) uint32 {
+	var out uint32
+	for shift := uint(0); shift < 32; shift += 8 {
+		ca := channel(a, shift)
+		out |= uint32(clampChannel(ca+(ca-channel(b, shift))/2)) << shift
+	}
+	return out
+}
+
+func subPixels(a, b uint32) uint32 {
+	var out uint32
+	for shift := uint(0); shift < 32; shift += 8 {
+		out |= uint32((channel(a, shift)-channel(b, shift))&0xff) << shift
+	}
+	return out
+}
+
+// Small residuals (in either direction) are cheap
+func residualCost(p uint32) int {
+	var cost int
+	for shift := uint(0); shift < 32; shift += 8 {
+		cost += abs(int(int8(p >> shift)))
+	}
+	return cost
+}
+
+func clampChannel(v int) int { return min(max(v, 0), 255) }
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func boolBit(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// ENTROPY CODING
+/////////////////////////////////////////////////////////////////////
+
+// A literal pixel (length 0) or a backward reference
+type webpToken struct {
+	pixel        uint32
+	length       int
+	distanceCode int
+}
+
+// Writes the prefix codes and entropy-coded pixels of an image (without a
+// color cache or meta prefix codes).
+func (bw *webpBitWriter) writeImageData(argb []uint32, width int) {
+	tokens := findBackwardReferences(argb, width)
+
+	green := make([]uint32, 256+webpNumLengthCodes)
+	red := make([]uint32, 256)
+	blue := make([]uint32, 256)
+	alpha := make([]uint32, 256)
+	dist := make([]uint32, webpNumDistanceCodes)
+	for _, tok := range tokens {
+		if tok.length == 0 {
+			green[channel(tok.pixel, 8)]++
+			red[channel(tok.pixel, 16)]++
+			blue[channel(tok.pixel, 0)]++
+			alpha[channel(tok.pixel, 24)]++
+			continue
+		}
+		lengthCode, _, _ := webpPrefixEncode(tok.length)
+		green[256+lengthCode]++
+		distCode, _, _ := webpPrefixEncode(tok.distanceCode)
+		dist[distCode]++
+	}
+
+	greenCode := bw.writePrefixCode(green)
+	redCode := bw.writePrefixCode(red)
+	blueCode := bw.writePrefixCode(blue)
+	alphaCode := bw.writePrefixCode(alpha)
+	distCode := bw.writePrefixCode(dist)
+
+	for _, tok := range tokens {
+		if tok.length == 0 {
+			greenCode.write(bw, channel(tok.pixel, 8))
+			redCode.write(bw, channel(tok.pixel, 16))
+			blueCode.write(bw, channel(tok.pixel, 0))
+			alphaCode.write(bw, channel(tok.pixel, 24))
+			continue
+		}
+		code, nbits, extra := webpPrefixEncode(tok.length)
+		greenCode.write(bw, 256+code)
+		bw.writeBits(uint64(extra), nbits)
+		code, nbits, extra = webpPrefixEncode(tok.distanceCode)
+		distCode.write(bw, code)
+		bw.writeBits(uint64(extra), nbits)
+	}
+}
+
+// Greedy LZ77 over pixels, with hash chains keyed on pixel pairs.
+func findBackwardReferences(argb []uint32, width int) []webpToken {
+	const hashBits = 16
+	const maxChainLength = 32
+
+	n := len(argb)
+	head := make([]int32, 1<<hashBits)
+	for i := range head {
+		head[i] = -1
+	}
+	prev := make([]int32, n)
+	hash := func(i int) uint32 {
+		return (argb[i]*0x1e35a7bd + argb[i+1]*0x9e3779b1) >> (32 - hashBits)
+	}
+	insert := func(i int) {
+		if i+1 < n {
+			h := hash(i)
+			prev[i] = head[h]
+			head[h] = int32(i)
+		}
+	}
+
+	var tokens []webpToken
+	for i := 0; i < n; {
+		bestLen, bestDist := 0, 0
+		if i+webpMinMatch <= n {
+			maxLen := min(webpMaxMatch, n-i)
+			chain := 0
+			for c := head[hash(i)]; c >= 0 && chain < maxChainLength && i-int(c) <= webpMaxDistance; c = prev[c] {
+				chain++
+				l := 0
+				for l < maxLen && argb[int(c)+l] == argb[i+l] {
+					l++
+				}
+				if l > bestLen {
+					bestLen, bestDist = l, i-int(c)
+					if l == maxLen {
+						break
+					}
+				}
+			}
+		}
+
+		if bestLen < webpMinMatch {
+			tokens = append(tokens, webpToken{pixel: argb[i]})
+			insert(i)
+			i++
+			continue
+		}
+		tokens = append(tokens, webpToken{length: bestLen, distanceCode: webpDistanceCode(bestDist, width)})
+		for j := i; j < i+bestLen; j++ {
+			insert(j)
+		}
+		i += bestLen
+	}
+	return tokens
+}
+
+// Distances are either one of 120 short codes relative to the pixel's 2D
+// neighborhood, or the linear distance plus 120. Only the two most common
+// short codes (the pixel above, and the pixel to the left) are used.
+func webpDistanceCode(distance, width int) int {
+	switch distance {
+	case width:
+		return 1
+	case 1:
+		return 2
+	}
+	return distance + 120
+}
+
+// Splits a length or distance code into its prefix symbol and extra bits.
+func webpPrefixEncode(v int) (code int, nbits uint, extra int) {
+	x := v - 1
+	if x < 4 {
+		return x, 0, 0
+	}
+	h := bits.Len(uint(x)) - 1
+	nbits = uint(h - 1)
+	return 2*h + (x>>nbits)&1, nbits, x & (1<<nbits - 1)
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// PREFIX CODES
+/////////////////////////////////////////////////////////////////////
+
+type webpPrefixCode struct {
+	lengths []uint8
+	codes   []uint16
+}
+
+func (p *webpPrefixCode) write(bw *webpBitWriter, symbol int) {
+	bw.writeBits(uint64(p.codes[symbol]), uint(p.lengths[symbol]))
+}
+
+const webpMaxCodeLength = 15
+
+// The order in which code length code lengths are written
+var webpCodeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// Writes a prefix code for histo, and returns it for writing symbols.
+func (bw *webpBitWriter) writePrefixCode(histo []uint32) *webpPrefixCode {
+	var used []int
+	for s, count := range histo {
+		if count > 0 {
+			used = append(used, s)
+		}
+	}
+
+	// A code with a single symbol takes no bits per symbol, which is what
+	// the zero lengths below give
+	p := &webpPrefixCode{lengths: make([]uint8, len(histo)), codes: make([]uint16, len(histo))}
+
+	if len(used) <= 2 && (len(used) == 0 || used[len(used)-1] < 256) {
+		bw.writeBits(1, 1) // Simple code
+		if len(used) == 0 {
+			used = []int{0}
+		}
+		bw.writeBits(uint64(len(used)-1), 1)
+		bw.writeBits(1, 1) // 8-bit first symbol
+		bw.writeBits(uint64(used[0]), 8)
+		if len(used) == 2 {
+			bw.writeBits(uint64(used[1]), 8)
+			p.lengths[used[0]], p.lengths[used[1]] = 1, 1
+			p.codes[used[1]] = 1
+		}
+		return p
+	}
+
+	lengths := huffmanLengths(histo, webpMaxCodeLength)
+	bw.writeBits(0, 1) // Normal code
+	bw.writeCodeLengths(lengths)
+	if len(used) > 1 {
+		p.lengths = lengths
+		p.codes = canonicalCodes(lengths)
+	}
+	return p
+}
+
+// Writes the code lengths of a normal prefix code, themselves run-length
+// coded and prefix coded.
+func (bw *webpBitWriter) writeCodeLengths(lengths []uint8) {
+	type token struct {
+		symbol int
+		extra  int
+		nbits  uint
+	}
+
+	var tokens []token
+	for i := 0; i < len(lengths); {
+		v := lengths[i]
+		run := 1
+		for i+run < len(lengths) && lengths[i+run] == v {
+			run++
+		}
+		i += run
+
+		if v == 0 {
+			for run >= 11 {
+				n := min(run, 138)
+				tokens = append(tokens, token{18, n - 11, 7})
+				run -= n
+			}
+			if run >= 3 {
+				tokens = append(tokens, token{17, run - 3, 3})
+				run = 0
+			}
+			for range run {
+				tokens = append(tokens, token{symbol: 0})
+			}
+			continue
+		}
+
+		tokens = append(tokens, token{symbol: int(v)})
+		run--
+		for run >= 3 {
+			n := min(run, 6)
+			tokens = append(tokens, token{16, n - 3, 2})
+			run -= n
+		}
+		for range run {
+			tokens = append(tokens, token{symbol: int(v)})
+		}
+	}
+
+	histo := make([]uint32, 19)
+	for _, tok := range tokens {
+		histo[tok.symbol]++
+	}
+	codeLengthLengths := huffmanLengths(histo, 7)
+
+	numCodes := 4
+	for i, s := range webpCodeLengthOrder {
+		if codeLengthLengths[s] > 0 {
+			numCodes = max(numCodes, i+1)
+		}
+	}
+	bw.writeBits(uint64(numCodes-4), 4)
+	for _, s := range webpCodeLengthOrder[:numCodes] {
+		bw.writeBits(uint64(codeLengthLengths[s]), 3)
+	}
+	bw.writeBits(0, 1) // Lengths are given for the whole alphabet
+
+	p := &webpPrefixCode{lengths: make([]uint8, 19), codes: make([]uint16, 19)}
+	if slices.ContainsFunc(tokens, func(tok token) bool { return tok.symbol != tokens[0].symbol }) {
+		p.lengths = codeLengthLengths
+		p.codes = canonicalCodes(codeLengthLengths)
+	}
+	for _, tok := range tokens {
+		p.write(bw, tok.symbol)
+		bw.writeBits(uint64(tok.extra), tok.nbits)
+	}
+}
+
+// Returns length-limited Huffman code lengths for histo.
+func huffmanLengths(histo []uint32, maxBits int) []uint8 {
+	lengths := make([]uint8, len(histo))
+
+	var symbols []int
+	for s, count := range histo {
+		if count > 0 {
+			symbols = append(symbols, s)
+		}
+	}
+	switch len(symbols) {
+	case 0:
+		return lengths
+	case 1:
+		lengths[symbols[0]] = 1
+		return lengths
+	}
+
+	// Raising the smallest counts flattens the tree until it fits
+	for minCount := uint32(1); ; minCount *= 2 {
+		if buildHuffmanTree(histo, symbols, minCount, lengths) <= maxBits {
+			return lengths
+		}
+	}
+}
+
+// Sets the depth of each symbol in a Huffman tree for histo (with counts
+// raised to at least minCount), and returns the maximum depth.
+func buildHuffmanTree(histo []uint32, symbols []int, minCount uint32, lengths []uint8) int {
+	type node struct {
+		weight      uint64
+		left, right int
+		symbol      int
+	}
+
+	n := len(symbols)
+	nodes := make([]node, 0, 2*n-1)
+	for _, s := range symbols {
+		nodes = append(nodes, node{weight: uint64(max(histo[s], minCount)), left: -1, right: -1, symbol: s})
+	}
+	slices.SortStableFunc(nodes, func(a, b node) int {
+		switch {
+		case a.weight < b.weight:
+			return -1
+		case a.weight > b.weight:
+			return 1
+		}
+		return 0
+	})
+
+	// Two queues: the sorted leaves, and the internal nodes (which are
+	// created in order of weight)
+	leaf, internal := 0, n
+	pop := func() int {
+		if leaf < n && (internal >= len(nodes) || nodes[leaf].weight <= nodes[internal].weight) {
+			leaf++
+			return leaf - 1
+		}
+		internal++
+		return internal - 1
+	}
+	for range n - 1 {
+		a, b := pop(), pop()
+		nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, left: a, right: b, symbol: -1})
+	}
+
+	maxDepth := 0
+	depths := make([]int, len(nodes))
+	for i := len(nodes) - 1; i >= 0; i-- {
+		nd := nodes[i]
+		if nd.symbol >= 0 {
+			lengths[nd.symbol] = uint8(min(depths[i], 255))
+			maxDepth = max(maxDepth, depths[i])
+			continue
+		}
+		depths[nd.left] = depths[i] + 1
+		depths[nd.right] = depths[i] + 1
+	}
+	return maxDepth
+}
+
+// Returns the canonical codes for the given lengths, bit-reversed so that
+// they can be written LSB first.
+func canonicalCodes(lengths []uint8) []uint16 {
+	var lengthCounts [webpMaxCodeLength + 1]int
+	for _, l := range lengths {
+		if l > 0 {
+			lengthCounts[l]++
+		}
+	}
+	var nextCode [webpMaxCodeLength + 1]int
+	code := 0
+	for l := 1; l <= webpMaxCodeLength; l++ {
+		code = (code + lengthCounts[l-1]) << 1
+		nextCode[l] = code
+	}
+
+	codes := make([]uint16, len(lengths))
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		codes[s] = bits.Reverse16(uint16(nextCode[l])) >> (16 - l)
+		nextCode[l]++
+	}
+	return codes
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// BIT WRITER
+/////////////////////////////////////////////////////////////////////
+
+type webpBitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+// Writes the low n bits of v, LSB first.
+func (bw *webpBitWriter) writeBits(v uint64, n uint) {
+	bw.acc |= v << bw.nbits
+	bw.nbits += n
+	for bw.nbits >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nbits -= 8
+	}
+}
+
+func (bw *webpBitWriter) bytes() []byte {
+	if bw.nbits > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nbits = 0, 0
+	}
+	return bw.buf
+}
@@ -0,0 +1,390 @@
+package imageutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	fills := map[string]func(x, y int) color.NRGBA{
+		"flat":     func(x, y int) color.NRGBA { return color.NRGBA{10, 200, 30, 255} },
+		"gradient": func(x, y int) color.NRGBA { return color.NRGBA{uint8(x), uint8(y), uint8(x + y), 255} },
+		"alpha":    func(x, y int) color.NRGBA { return color.NRGBA{uint8(x * 3), uint8(y * 5), 77, uint8(x ^ y)} },
+		"noise": func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256))}
+		},
+	}
+	sizes := [][2]int{{1, 1}, {2, 1}, {1, 7}, {17, 13}, {100, 61}}
+
+	for name, fill := range fills {
+		for _, size := range sizes {
+			src := image.NewNRGBA(image.Rect(0, 0, size[0], size[1]))
+			for y := range size[1] {
+				for x := range size[0] {
+					src.SetNRGBA(x, y, fill(x, y))
+				}
+			}
+
+			var buf bytes.Buffer
+			if err := EncodeWebP(&buf, src, 80); err != nil {
+				t.Fatal(err)
+			}
+			got, err := decodeTestWebP(buf.Bytes())
+			if err != nil {
+				t.Fatalf("%s %v: %v", name, size, err)
+			}
+			if !bytes.Equal(got.Pix, src.Pix) || got.Rect != src.Rect {
+				t.Errorf("%s %v: decoded image differs from the source", name, size)
+			}
+		}
+	}
+}
+
+func TestEncodeWebPHeader(t *testing.T) {
+	// A non-zero origin, and no transparency
+	src := image.NewRGBA(image.Rect(5, 5, 12, 8))
+	for i := range src.Pix {
+		src.Pix[i] = 0xff
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeWebP(&buf, src, 80); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if string(b[:4]) != "RIFF" || string(b[8:16]) != "WEBPVP8L" {
+		t.Fatalf("unexpected header %q", b[:16])
+	}
+	if size := binary.LittleEndian.Uint32(b[4:]); int(size) != len(b)-8 {
+		t.Errorf("RIFF size = %d, want: %d", size, len(b)-8)
+	}
+	if len(b)%2 != 0 {
+		t.Errorf("expected the file to be padded to an even length")
+	}
+
+	got, err := decodeTestWebP(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Rect != image.Rect(0, 0, 7, 3) {
+		t.Errorf("unexpected bounds %v", got.Rect)
+	}
+	if b[20+4]&0x10 != 0 {
+		t.Errorf("expected the alpha hint to be unset for an opaque image")
+	}
+
+	if err := EncodeWebP(&buf, image.NewRGBA(image.Rect(0, 0, 1<<14+1, 1)), 80); err == nil {
+		t.Error("expected an error for an image wider than WebP allows")
+	}
+}
+
+func TestWebPPrefixEncode(t *testing.T) {
+	for v := 1; v <= 1<<20; v++ {
+		code, nbits, extra := webpPrefixEncode(v)
+		if got := decodeTestWebPPrefix(code, func(n int) int {
+			if n != int(nbits) {
+				t.Fatalf("%d: read %d extra bits, want: %d", v, n, nbits)
+			}
+			return extra
+		}); got != v {
+			t.Fatalf("%d: decoded as %d", v, got)
+		}
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// TEST DECODER
+/////////////////////////////////////////////////////////////////////
+
+// Decodes the subset of lossless WebP that EncodeWebP writes.
+func decodeTestWebP(b []byte) (*image.NRGBA, error) {
+	if len(b) < 21 || string(b[:4]) != "RIFF" || string(b[8:16]) != "WEBPVP8L" {
+		return nil, errors.New("not a VP8L file")
+	}
+	br := &testBitReader{buf: b[20:]}
+	if br.read(8) != 0x2f {
+		return nil, errors.New("bad signature")
+	}
+	width, height := br.read(14)+1, br.read(14)+1
+	br.read(1)
+	if br.read(3) != 0 {
+		return nil, errors.New("bad version")
+	}
+
+	var transforms []func([]uint32)
+	for br.read(1) == 1 {
+		switch br.read(2) {
+		case webpSubtractGreenTransform:
+			transforms = append(transforms, addGreen)
+		case webpPredictorTransform:
+			sizeBits := br.read(3) + 2
+			tileSize := 1 << sizeBits
+			tilesW := (width + tileSize - 1) / tileSize
+			tilesH := (height + tileSize - 1) / tileSize
+			if br.read(1) != 0 {
+				return nil, errors.New("unexpected color cache")
+			}
+			modes, err := br.readImageData(tilesW, tilesH)
+			if err != nil {
+				return nil, err
+			}
+			transforms = append(transforms, func(argb []uint32) {
+				unpredict(argb, width, height, sizeBits, modes, tilesW)
+			})
+		default:
+			return nil, errors.New("unexpected transform")
+		}
+	}
+	if br.read(1) != 0 || br.read(1) != 0 {
+		return nil, errors.New("unexpected color cache or meta prefix codes")
+	}
+	argb, err := br.readImageData(width, height)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(transforms) - 1; i >= 0; i-- {
+		transforms[i](argb)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i, p := range argb {
+		img.Pix[i*4] = uint8(p >> 16)
+		img.Pix[i*4+1] = uint8(p >> 8)
+		img.Pix[i*4+2] = uint8(p)
+		img.Pix[i*4+3] = uint8(p >> 24)
+	}
+	return img, br.err
+}
+
+func addGreen(argb []uint32) {
+	for i, p := range argb {
+		g := (p >> 8) & 0xff
+		argb[i] = p&0xff00ff00 | ((p>>16+g)&0xff)<<16 | (p+g)&0xff
+	}
+}
+
+func unpredict(argb []uint32, width, height, sizeBits int, modes []uint32, tilesW int) {
+	for y := range height {
+		for x := range width {
+			i := y*width + x
+			var pred uint32
+			switch {
+			case x == 0 && y == 0:
+				pred = 0xff000000
+			case y == 0:
+				pred = argb[i-1]
+			case x == 0:
+				pred = argb[i-width]
+			default:
+				mode := int(modes[(y>>sizeBits)*tilesW+x>>sizeBits]>>8) & 0xff
+				pred = predict(mode, argb, i, width)
+			}
+			var out uint32
+			for shift := uint(0); shift < 32; shift += 8 {
+				out |= uint32((channel(argb[i], shift)+channel(pred, shift))&0xff) << shift
+			}
+			argb[i] = out
+		}
+	}
+}
+
+func decodeTestWebPPrefix(code int, readExtra func(int) int) int {
+	if code < 4 {
+		return code + 1
+	}
+	nbits := uint(code-2) >> 1
+	offset := (2 + code&1) << nbits
+	return offset + readExtra(int(nbits)) + 1
+}
+
+type testBitReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (br *testBitReader) read(n int) int {
+	v := 0
+	for i := range n {
+		if br.pos>>3 >= len(br.buf) {
+			br.err = errors.New("unexpected end of data")
+			return 0
+		}
+		v |= int(br.buf[br.pos>>3]>>(br.pos&7)&1) << i
+		br.pos++
+	}
+	return v
+}
+
+type testPrefixCode struct {
+	single  int
+	symbols map[[2]int]int // {length, code} -> symbol
+}
+
+func (br *testBitReader) readSymbol(p *testPrefixCode) (int, error) {
+	if p.symbols == nil {
+		return p.single, nil
+	}
+	code := 0
+	for length := 1; length <= webpMaxCodeLength; length++ {
+		code = code<<1 | br.read(1)
+		if s, ok := p.symbols[[2]int{length, code}]; ok {
+			return s, nil
+		}
+	}
+	return 0, errors.New("invalid prefix code")
+}
+
+func newTestPrefixCode(lengths []int) (*testPrefixCode, error) {
+	var used []int
+	for s, l := range lengths {
+		if l > 0 {
+			used = append(used, s)
+		}
+	}
+	switch len(used) {
+	case 0:
+		return nil, errors.New("empty prefix code")
+	case 1:
+		return &testPrefixCode{single: used[0]}, nil
+	}
+
+	p := &testPrefixCode{symbols: map[[2]int]int{}}
+	code := 0
+	for length := 1; length <= webpMaxCodeLength; length++ {
+		for s, l := range lengths {
+			if l == length {
+				p.symbols[[2]int{length, code}] = s
+				code++
+			}
+		}
+		code <<= 1
+	}
+	if code != 1<<(webpMaxCodeLength+1) {
+		return nil, errors.New("incomplete prefix code")
+	}
+	return p, nil
+}
+
+func (br *testBitReader) readPrefixCode(alphabetSize int) (*testPrefixCode, error) {
+	lengths := make([]int, alphabetSize)
+
+	if br.read(1) == 1 {
+		numSymbols := br.read(1) + 1
+		first := br.read(br.read(1)*7 + 1)
+		if numSymbols == 1 {
+			return &testPrefixCode{single: first}, nil
+		}
+		lengths[first] = 1
+		lengths[br.read(8)] = 1
+		return newTestPrefixCode(lengths)
+	}
+
+	codeLengthLengths := make([]int, 19)
+	numCodes := br.read(4) + 4
+	for _, s := range webpCodeLengthOrder[:numCodes] {
+		codeLengthLengths[s] = br.read(3)
+	}
+	codeLengthCode, err := newTestPrefixCode(codeLengthLengths)
+	if err != nil {
+		return nil, err
+	}
+	if br.read(1) != 0 {
+		return nil, errors.New("unexpected max_symbol")
+	}
+
+	prev := 8
+	for i := 0; i < alphabetSize; {
+		s, err := br.readSymbol(codeLengthCode)
+		if err != nil {
+			return nil, err
+		}
+		if s < 16 {
+			lengths[i] = s
+			if s != 0 {
+				prev = s
+			}
+			i++
+			continue
+		}
+		value, repeat := 0, 0
+		switch s {
+		case 16:
+			value, repeat = prev, 3+br.read(2)
+		case 17:
+			repeat = 3 + br.read(3)
+		case 18:
+			repeat = 11 + br.read(7)
+		}
+		if i+repeat > alphabetSize {
+			return nil, errors.New("code lengths overflow the alphabet")
+		}
+		for range repeat {
+			lengths[i] = value
+			i++
+		}
+	}
+	return newTestPrefixCode(lengths)
+}
+
+func (br *testBitReader) readImageData(width, height int) ([]uint32, error) {
+	var codes [5]*testPrefixCode
+	for i, size := range []int{256 + webpNumLengthCodes, 256, 256, 256, webpNumDistanceCodes} {
+		code, err := br.readPrefixCode(size)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+
+	argb := make([]uint32, width*height)
+	for i := 0; i < len(argb); {
+		green, err := br.readSymbol(codes[0])
+		if err != nil {
+			return nil, err
+		}
+		if green < 256 {
+			var rba [3]int
+			for j := range rba {
+				if rba[j], err = br.readSymbol(codes[j+1]); err != nil {
+					return nil, err
+				}
+			}
+			argb[i] = uint32(rba[2])<<24 | uint32(rba[0])<<16 | uint32(green)<<8 | uint32(rba[1])
+			i++
+			continue
+		}
+
+		length := decodeTestWebPPrefix(green-256, br.read)
+		distSymbol, err := br.readSymbol(codes[4])
+		if err != nil {
+			return nil, err
+		}
+		distance := decodeTestWebPPrefix(distSymbol, br.read)
+		switch {
+		case distance > 120:
+			distance -= 120
+		case distance == 1:
+			distance = width
+		case distance == 2:
+			distance = 1
+		default:
+			return nil, fmt.Errorf("unexpected short distance code %d", distance)
+		}
+		if distance > i || i+length > len(argb) {
+			return nil, errors.New("backward reference out of range")
+		}
+		for range length {
+			argb[i] = argb[i-distance]
+			i++
+		}
+	}
+	return argb, br.err
+}
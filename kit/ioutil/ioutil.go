@@ -34,3 +34,25 @@ func ReadLimited(r io.Reader, limit uint64) ([]byte, error) {
 
 	return data, nil
 }
+
+// CopyLimited copies data from src to dst up to the given limit. It returns
+// ErrReadLimitExceeded if src holds more than limit bytes, in which case
+// exactly limit bytes will have been written to dst. Like ReadLimited, it
+// reads a single extra byte (which is discarded) to check if the limit is
+// exceeded.
+func CopyLimited(dst io.Writer, src io.Reader, limit uint64) (int64, error) {
+	n, err := io.Copy(dst, io.LimitReader(src, int64(limit)))
+	if err != nil {
+		return n, err
+	}
+
+	// Check if the limit was exceeded
+	if uint64(n) == limit {
+		var extra [1]byte
+		if m, _ := io.ReadFull(src, extra[:]); m > 0 {
+			return n, ErrReadLimitExceeded
+		}
+	}
+
+	return n, nil
+}
@@ -186,3 +186,35 @@ func (r *failingReader) Read(p []byte) (n int, err error) {
 
 	return toRead, nil
 }
+
+func TestCopyLimited(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		limit   uint64
+		wantN   int64
+		wantErr error
+	}{
+		{name: "Empty reader", input: "", limit: 10, wantN: 0},
+		{name: "Under limit", input: "hello", limit: 10, wantN: 5},
+		{name: "Exactly at limit", input: "hello", limit: 5, wantN: 5},
+		{name: "Over limit", input: "hello world", limit: 5, wantN: 5, wantErr: ErrReadLimitExceeded},
+		{name: "Zero limit with data", input: "x", limit: 0, wantN: 0, wantErr: ErrReadLimitExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dst bytes.Buffer
+			n, err := CopyLimited(&dst, strings.NewReader(tt.input), tt.limit)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CopyLimited() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if n != tt.wantN || int64(dst.Len()) != tt.wantN {
+				t.Errorf("CopyLimited() n = %d, written = %d, want %d", n, dst.Len(), tt.wantN)
+			}
+			if dst.String() != tt.input[:tt.wantN] {
+				t.Errorf("CopyLimited() written = %q, want %q", dst.String(), tt.input[:tt.wantN])
+			}
+		})
+	}
+}
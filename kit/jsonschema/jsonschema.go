@@ -39,19 +39,23 @@ type Def struct {
 	AllOf               []any
 	Items               Entry
 	Enum                []string
+
+	// Schema for the values of an object's arbitrary keys (i.e., a map)
+	AdditionalProperties Entry
 }
 
 type Entry struct {
-	Schema      string   `json:"$schema,omitempty"`
-	Type        string   `json:"type"`
-	Description string   `json:"description,omitempty"`
-	Default     any      `json:"default,omitempty"`
-	Required    []string `json:"required,omitempty"`
-	AllOf       []any    `json:"allOf,omitempty"`
-	Properties  any      `json:"properties,omitempty"`
-	Items       any      `json:"items,omitempty"`
-	Enum        []string `json:"enum,omitempty"`
-	Examples    []string `json:"examples,omitempty"`
+	Schema               string   `json:"$schema,omitempty"`
+	Type                 string   `json:"type"`
+	Description          string   `json:"description,omitempty"`
+	Default              any      `json:"default,omitempty"`
+	Required             []string `json:"required,omitempty"`
+	AllOf                []any    `json:"allOf,omitempty"`
+	Properties           any      `json:"properties,omitempty"`
+	Items                any      `json:"items,omitempty"`
+	AdditionalProperties any      `json:"additionalProperties,omitempty"`
+	Enum                 []string `json:"enum,omitempty"`
+	Examples             []string `json:"examples,omitempty"`
 }
 
 type IfThen struct {
@@ -73,6 +77,9 @@ func ToJSONSchema(sd Def) Entry {
 	if sd.Items.Type != "" {
 		x.Items = sd.Items
 	}
+	if sd.AdditionalProperties.Type != "" {
+		x.AdditionalProperties = sd.AdditionalProperties
+	}
 	return x
 }
 
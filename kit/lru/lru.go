@@ -71,26 +71,28 @@ func NewCacheWithTTL[K comparable, V any](maxItems int, defaultTTL time.Duration
 func (c *Cache[K, V]) Get(key K) (v V, found bool) {
 	c.mu.RLock()
 	itm, found := c.items[key]
-	c.mu.RUnlock()
-
 	if !found {
+		c.mu.RUnlock()
 		return
 	}
+	// Copy fields while holding the lock, as SetWithTTL mutates items in place
+	value, isSpam, expiresAt := itm.value, itm.isSpam, itm.expiresAt
+	c.mu.RUnlock()
 
 	// Check if the item has expired
-	if !itm.expiresAt.IsZero() && time.Now().After(itm.expiresAt) {
+	if !expiresAt.IsZero() && time.Now().After(expiresAt) {
 		c.Delete(key)
 		var zero V
 		return zero, false
 	}
 
-	if !itm.isSpam {
+	if !isSpam {
 		c.mu.Lock()
 		c.order.MoveToFront(itm.element)
 		c.mu.Unlock()
 	}
 
-	return itm.value, true
+	return value, true
 }
 
 // Set adds or updates an item in the cache, evicting the LRU item if necessary.
@@ -155,6 +157,19 @@ func (c *Cache[K, V]) Delete(key K) {
 	c.order.Remove(itm.element)
 }
 
+// DeleteFunc removes all items whose keys satisfy the provided predicate.
+func (c *Cache[K, V]) DeleteFunc(del func(key K) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, itm := range c.items {
+		if del(key) {
+			delete(c.items, key)
+			c.order.Remove(itm.element)
+		}
+	}
+}
+
 // evict removes the least recently used item from the cache.
 func (c *Cache[K, V]) evict() {
 	back := c.order.Back()
@@ -2,6 +2,7 @@ package lru
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -113,6 +114,28 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDeleteFunc(t *testing.T) {
+	cache := NewCache[string, int](5)
+	cache.Set("a:1", 1, false)
+	cache.Set("a:2", 2, false)
+	cache.Set("b:1", 3, true)
+
+	cache.DeleteFunc(func(key string) bool { return strings.HasPrefix(key, "a:") })
+
+	if _, found := cache.Get("a:1"); found {
+		t.Errorf("Expected 'a:1' to be deleted")
+	}
+	if _, found := cache.Get("a:2"); found {
+		t.Errorf("Expected 'a:2' to be deleted")
+	}
+	if _, found := cache.Get("b:1"); !found {
+		t.Errorf("Expected 'b:1' to still be in cache")
+	}
+	if cache.order.Len() != 1 {
+		t.Errorf("Expected cache to have 1 item, got %d", cache.order.Len())
+	}
+}
+
 func TestEdgeCases(t *testing.T) {
 	// Test cache with size 0
 	cache := NewCache[string, int](0)
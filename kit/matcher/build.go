@@ -0,0 +1,55 @@
+package matcher
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrMissingParam = errors.New("missing param")
+	ErrInvalidParam = errors.New("param does not satisfy constraint")
+)
+
+// Build generates a path matching the pattern by filling in its dynamic
+// params and splat (if any). Values are path-escaped. Missing required
+// params, and params violating their constraint, cause an error. Index
+// patterns produce a trailing slash (e.g., "/dashboard/"), which matches the
+// index route whether or not an explicit index segment is used.
+func (rp *RegisteredPattern) Build(params Params, splatValues []string) (string, error) {
+	var sb strings.Builder
+
+	for _, seg := range rp.normalizedSegments {
+		switch seg.segType {
+		case segTypes.static:
+			sb.WriteString("/")
+			sb.WriteString(seg.normalizedVal)
+		case segTypes.index:
+			sb.WriteString("/")
+		case segTypes.dynamic:
+			val, ok := params[seg.paramName]
+			if !ok || val == "" {
+				if seg.optional {
+					continue
+				}
+				return "", fmt.Errorf("%w %q for pattern '%s'", ErrMissingParam, seg.paramName, rp.originalPattern)
+			}
+			if !seg.accepts(val) {
+				return "", fmt.Errorf("%w: %q=%q for pattern '%s'", ErrInvalidParam, seg.paramName, val, rp.originalPattern)
+			}
+			sb.WriteString("/")
+			sb.WriteString(url.PathEscape(val))
+		case segTypes.splat:
+			for _, val := range splatValues {
+				sb.WriteString("/")
+				sb.WriteString(url.PathEscape(val))
+			}
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "/", nil
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,27 @@
+package matcher
+
+import "testing"
+
+func TestBuild(t *testing.T) {
+	m := New(&Options{Quiet: true})
+	cases := []struct {
+		pattern string
+		params  Params
+		splat   []string
+		want    string
+	}{
+		{"", nil, nil, "/"},
+		{"/", nil, nil, "/"},
+		{"/about", nil, nil, "/about"},
+		{"/dashboard/", nil, nil, "/dashboard/"},
+		{"/users/:id", Params{"id": "a/b"}, nil, "/users/a%2Fb"},
+		{"/users/:id?", nil, nil, "/users"},
+		{"/*", nil, []string{"x", "y"}, "/x/y"},
+	}
+	for _, c := range cases {
+		got, err := m.NormalizePattern(c.pattern).Build(c.params, c.splat)
+		if err != nil || got != c.want {
+			t.Errorf("Build(%q) = %q, %v -- want %q", c.pattern, got, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package matcher
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// PARAM CONSTRAINTS
+/////////////////////////////////////////////////////////////////////
+
+// Dynamic segments may carry a constraint in angle brackets, and the final
+// segment of a pattern may be marked optional with a trailing "?":
+//
+//	/users/:id<int>          -- only matches integer segments
+//	/items/:id<uuid>         -- only matches canonical UUIDs
+//	/posts/:slug<[a-z0-9-]+> -- any other constraint is a regular expression,
+//	                            anchored to the whole segment
+//	/users/:id?              -- also matches "/users"
+//
+// Constrained segments score higher than unconstrained ones (but lower than
+// static segments), so "/users/:id<int>" and "/users/:name" can coexist. A
+// constraint may not contain a slash.
+
+const (
+	ConstraintInt  = "int"
+	ConstraintUUID = "uuid"
+)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type constraint struct {
+	raw   string
+	match func(string) bool
+}
+
+// Returns the raw constraint, or an empty string if c is nil.
+func (c *constraint) String() string {
+	if c == nil {
+		return ""
+	}
+	return c.raw
+}
+
+func newConstraint(raw string) (*constraint, error) {
+	switch raw {
+	case ConstraintInt:
+		return &constraint{raw: raw, match: func(s string) bool {
+			_, err := strconv.Atoi(s)
+			return err == nil
+		}}, nil
+	case ConstraintUUID:
+		return &constraint{raw: raw, match: uuidRegex.MatchString}, nil
+	}
+	re, err := regexp.Compile("^(?:" + raw + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return &constraint{raw: raw, match: re.MatchString}, nil
+}
+
+// Describes a dynamic param segment of a registered pattern.
+type ParamSpec struct {
+	Name string
+	// Empty if unconstrained, otherwise ConstraintInt, ConstraintUUID or a
+	// regular expression.
+	Constraint string
+	Optional   bool
+}
+
+// IsInt reports whether the param only ever matches integers.
+func (ps ParamSpec) IsInt() bool { return ps.Constraint == ConstraintInt }
+
+// Returns the specs of the pattern's dynamic params, in order.
+func (rp *RegisteredPattern) ParamSpecs() []ParamSpec {
+	var specs []ParamSpec
+	for _, seg := range rp.normalizedSegments {
+		if seg.segType != segTypes.dynamic {
+			continue
+		}
+		spec := ParamSpec{Name: seg.paramName, Optional: seg.optional}
+		if seg.constraint != nil {
+			spec.Constraint = seg.constraint.raw
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// Splits a dynamic segment body (without its prefix rune), such as
+// "id<int>?", into its name, constraint and optional marker.
+func parseDynamicSegment(body string) (name string, c *constraint, optional bool) {
+	if strings.HasSuffix(body, "?") {
+		optional = true
+		body = body[:len(body)-1]
+	}
+	name = body
+	if open := strings.IndexByte(body, '<'); open != -1 && strings.HasSuffix(body, ">") {
+		name = body[:open]
+		var err error
+		c, err = newConstraint(body[open+1 : len(body)-1])
+		if err != nil {
+			panic(fmt.Sprintf("matcher: invalid constraint in param %q: %v", name, err))
+		}
+	}
+	return name, c, optional
+}
+
+// Returns the normalized form of a dynamic segment, e.g., ":id<int>?".
+func normalizeDynamicSegment(name string, c *constraint, optional bool) string {
+	var sb strings.Builder
+	sb.WriteString(":")
+	sb.WriteString(name)
+	if c != nil {
+		sb.WriteString("<" + c.raw + ">")
+	}
+	if optional {
+		sb.WriteString("?")
+	}
+	return sb.String()
+}
+
+func (seg *segment) accepts(realSegment string) bool {
+	return seg.constraint == nil || seg.constraint.match(realSegment)
+}
+
+// Returns a key that is identical for patterns matching exactly the same
+// paths, i.e., patterns that differ only in param names.
+func (rp *RegisteredPattern) shapeKey() string {
+	var sb strings.Builder
+	for _, seg := range rp.normalizedSegments {
+		sb.WriteString("/")
+		if seg.segType != segTypes.dynamic {
+			sb.WriteString(seg.normalizedVal)
+			continue
+		}
+		sb.WriteString(":")
+		if seg.constraint != nil {
+			sb.WriteString("<" + seg.constraint.raw + ">")
+		}
+		if seg.optional {
+			sb.WriteString("?")
+		}
+	}
+	return sb.String()
+}
+
+// Reports whether a and b have the same structure, except that a has a
+// constrained dynamic segment wherever it differs from b, and b has an
+// unconstrained one. In that case, b is shadowed by a for paths both match.
+func isMoreSpecific(a, b *RegisteredPattern) bool {
+	if len(a.normalizedSegments) != len(b.normalizedSegments) {
+		return false
+	}
+	moreSpecific := false
+	for i, segA := range a.normalizedSegments {
+		segB := b.normalizedSegments[i]
+		if segA.segType != segB.segType {
+			return false
+		}
+		if segA.segType != segTypes.dynamic {
+			if segA.normalizedVal != segB.normalizedVal {
+				return false
+			}
+			continue
+		}
+		switch {
+		case segA.constraint == nil && segB.constraint == nil:
+		case segA.constraint != nil && segB.constraint == nil:
+			moreSpecific = true
+		case segA.constraint != nil && segB.constraint != nil && segA.constraint.raw == segB.constraint.raw:
+		default:
+			return false
+		}
+	}
+	return moreSpecific
+}
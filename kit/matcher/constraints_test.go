@@ -0,0 +1,82 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNestedMatchesWithConstraints(t *testing.T) {
+	m := New(&Options{Quiet: true})
+	for _, p := range []string{"/users", "/users/:name", "/users/:id<int>", "/users/:id<int>/posts"} {
+		m.RegisterPattern(p)
+	}
+
+	results, ok := m.FindNestedMatches("/users/123")
+	if !ok {
+		t.Fatal("expected matches")
+	}
+	var got []string
+	for _, match := range results.Matches {
+		got = append(got, match.normalizedPattern)
+	}
+	if want := []string{"/users", "/users/:id<int>"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if results.Params["id"] != "123" {
+		t.Errorf("expected id param, got %v", results.Params)
+	}
+
+	results, _ = m.FindNestedMatches("/users/bob")
+	if last := results.Matches[len(results.Matches)-1]; last.normalizedPattern != "/users/:name" {
+		t.Errorf("expected unconstrained match, got %s", last.normalizedPattern)
+	}
+}
+
+func TestParamSpecs(t *testing.T) {
+	m := New(&Options{DynamicParamPrefixRune: '$'})
+	rp := m.NormalizePattern("/orgs/$org/users/$id<int>?")
+
+	if rp.NormalizedPattern() != "/orgs/:org/users/:id<int>?" {
+		t.Errorf("unexpected normalized pattern %q", rp.NormalizedPattern())
+	}
+	want := []ParamSpec{{Name: "org"}, {Name: "id", Constraint: ConstraintInt, Optional: true}}
+	if got := rp.ParamSpecs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestInvalidPatternsPanic(t *testing.T) {
+	for _, p := range []string{"/users/:id?/posts", "/users/:id<[>"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for %q", p)
+				}
+			}()
+			New(nil).NormalizePattern(p)
+		}()
+	}
+}
+
+func TestBestMatchScore(t *testing.T) {
+	m := New(&Options{Quiet: true})
+	for _, p := range []string{"/users/new", "/users/:name", "/users/:id<int>", "/files/*"} {
+		m.RegisterPattern(p)
+	}
+
+	for path, want := range map[string]int{
+		"/users/new":  8,
+		"/users/123":  7,
+		"/users/bob":  6,
+		"/files/a/b":  4,
+		"/users/new/": 8,
+	} {
+		match, ok := m.FindBestMatch(path)
+		if !ok {
+			t.Fatalf("expected a match for %s", path)
+		}
+		if got := match.Score(); got != want {
+			t.Errorf("%s (%s): got score %d, want %d", path, match.normalizedPattern, got, want)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package matcher
 
 func (m *Matcher) FindBestMatch(realPath string) (*BestMatch, bool) {
 	if rr, ok := m.staticPatterns[realPath]; ok {
-		return &BestMatch{RegisteredPattern: rr}, true
+		return &BestMatch{RegisteredPattern: rr, score: staticScore(rr)}, true
 	}
 
 	segments := ParseSegments(realPath)
@@ -11,7 +11,7 @@ func (m *Matcher) FindBestMatch(realPath string) (*BestMatch, bool) {
 	if hasTrailingSlash {
 		pathWithoutTrailingSlash := realPath[:len(realPath)-1]
 		if rr, ok := m.staticPatterns[pathWithoutTrailingSlash]; ok {
-			return &BestMatch{RegisteredPattern: rr}, true
+			return &BestMatch{RegisteredPattern: rr, score: staticScore(rr)}, true
 		}
 	}
 
@@ -28,8 +28,9 @@ func (m *Matcher) FindBestMatch(realPath string) (*BestMatch, bool) {
 	if best.numberOfDynamicParamSegs > 0 {
 		params := make(Params, best.numberOfDynamicParamSegs)
 		for i, seg := range best.normalizedSegments {
-			if seg.segType == segTypes.dynamic {
-				params[seg.normalizedVal[1:]] = segments[i]
+			// An omitted optional segment is left out of the params
+			if seg.segType == segTypes.dynamic && i < len(segments) && segments[i] != "" {
+				params[seg.paramName] = segments[i]
 			}
 		}
 		best.Params = params
@@ -42,6 +43,16 @@ func (m *Matcher) FindBestMatch(realPath string) (*BestMatch, bool) {
 	return best, true
 }
 
+// Score reports how specifically the match's pattern matched the path: each
+// static segment adds 4, each constrained dynamic segment 3, and each
+// unconstrained dynamic segment 2. Splat segments add nothing. When several
+// patterns match a path, the highest score wins.
+func (bm *BestMatch) Score() int { return int(bm.score) }
+
+func staticScore(rp *RegisteredPattern) uint16 {
+	return uint16(len(rp.normalizedSegments) * scoreStaticMatch)
+}
+
 func (m *Matcher) dfsBest(
 	node *segmentNode,
 	segments []string,
@@ -85,8 +96,12 @@ func (m *Matcher) dfsBest(
 		switch child.nodeType {
 		case nodeDynamic:
 			// Don't match empty segments to dynamic parameters
-			if segments[depth] != "" {
-				m.dfsBest(child, segments, depth+1, score+scoreDynamic, best, bestScore, foundMatch, checkTrailingSlash)
+			if segments[depth] != "" && child.segment.accepts(segments[depth]) {
+				childScore := uint16(scoreDynamic)
+				if child.segment.constraint != nil {
+					childScore = scoreConstrained
+				}
+				m.dfsBest(child, segments, depth+1, score+childScore, best, bestScore, foundMatch, checkTrailingSlash)
 			}
 
 		case nodeSplat:
@@ -94,6 +109,7 @@ func (m *Matcher) dfsBest(
 				if rp := m.dynamicPatterns[child.pattern]; rp != nil {
 					if !*foundMatch {
 						best.RegisteredPattern = rp
+						best.score = score
 						*foundMatch = true
 					}
 				}
@@ -283,6 +283,68 @@ func getTestCases() []testCase {
 			wantParams:        nil,
 			wantSplatSegments: nil,
 		},
+
+		// constraints and optional segments
+		{
+			name:        "int constraint beats unconstrained",
+			patterns:    []string{"/users/:name", "/users/:id<int>"},
+			path:        "/users/123",
+			wantPattern: "/users/:id<int>",
+			wantParams:  Params{"id": "123"},
+		},
+		{
+			name:        "unconstrained used when constraint fails",
+			patterns:    []string{"/users/:name", "/users/:id<int>"},
+			path:        "/users/bob",
+			wantPattern: "/users/:name",
+			wantParams:  Params{"name": "bob"},
+		},
+		{
+			name:        "failed constraint does not match",
+			patterns:    []string{"/users/:id<int>"},
+			path:        "/users/bob",
+			wantPattern: NOT_FOUND,
+		},
+		{
+			name:        "static beats constrained",
+			patterns:    []string{"/users/:id<int>", "/users/123"},
+			path:        "/users/123",
+			wantPattern: "/users/123",
+		},
+		{
+			name:        "uuid constraint",
+			patterns:    []string{"/items/:id<uuid>", "/items/:slug"},
+			path:        "/items/123e4567-e89b-12d3-a456-426614174000",
+			wantPattern: "/items/:id<uuid>",
+			wantParams:  Params{"id": "123e4567-e89b-12d3-a456-426614174000"},
+		},
+		{
+			name:        "regex constraint",
+			patterns:    []string{"/posts/:slug<[a-z-]+>"},
+			path:        "/posts/hello-world",
+			wantPattern: "/posts/:slug<[a-z-]+>",
+			wantParams:  Params{"slug": "hello-world"},
+		},
+		{
+			name:        "regex constraint is anchored",
+			patterns:    []string{"/posts/:slug<[a-z-]+>"},
+			path:        "/posts/Hello-world",
+			wantPattern: NOT_FOUND,
+		},
+		{
+			name:        "optional segment present",
+			patterns:    []string{"/users/:id<int>?"},
+			path:        "/users/5",
+			wantPattern: "/users/:id<int>?",
+			wantParams:  Params{"id": "5"},
+		},
+		{
+			name:        "optional segment omitted",
+			patterns:    []string{"/users/:id?"},
+			path:        "/users",
+			wantPattern: "/users/:id?",
+			wantParams:  nil,
+		},
 	}
 }
 
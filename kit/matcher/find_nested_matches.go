@@ -79,6 +79,16 @@ func (m *Matcher) FindNestedMatches(realPath string) (*FindNestedMatchesResults,
 		return flattenAndSortMatches(matches)
 	}
 
+	// if a match is shadowed by a more specific (constrained) sibling, remove it
+	for pattern, match := range matches {
+		for _, other := range matches {
+			if isMoreSpecific(other.RegisteredPattern, match.RegisteredPattern) {
+				delete(matches, pattern)
+				break
+			}
+		}
+	}
+
 	var longestSegmentLen int
 	longestSegmentMatches := make(matchesMap)
 	for _, match := range matches {
@@ -192,6 +202,15 @@ func (m *Matcher) dfsNestedMatches(
 	for _, child := range node.dynChildren {
 		switch child.nodeType {
 		case nodeDynamic:
+			if !child.segment.accepts(seg) {
+				continue
+			}
+			// A trailing slash omits an optional segment, just as if the
+			// path ended before it, so it is left out of the params
+			if seg == "" && child.segment.optional {
+				m.dfsNestedMatches(child, segments, depth+1, params, matches)
+				continue
+			}
 			// Backtracking pattern for dynamic
 			oldVal, hadVal := params[child.paramName]
 			params[child.paramName] = seg
@@ -529,3 +529,26 @@ func BenchmarkFindNestedMatches(b *testing.B) {
 		})
 	}
 }
+
+func TestFindNestedMatchesOptionalSegment(t *testing.T) {
+	m := New(nil)
+	m.RegisterPattern("/files/:id?")
+
+	for _, path := range []string{"/files", "/files/"} {
+		results, ok := m.FindNestedMatches(path)
+		if !ok {
+			t.Fatalf("%s: expected a match", path)
+		}
+		if id, has := results.Params["id"]; has {
+			t.Errorf("%s: expected no id param, got %q", path, id)
+		}
+		if len(results.Matches) != 1 || results.Matches[0].normalizedPattern != "/files/:id?" {
+			t.Errorf("%s: expected only /files/:id? to match, got %v", path, results.Matches)
+		}
+	}
+
+	results, _ := m.FindNestedMatches("/files/5")
+	if results.Params["id"] != "5" {
+		t.Errorf("/files/5: expected id 5, got %v", results.Params)
+	}
+}
@@ -19,6 +19,7 @@ type Matcher struct {
 	staticPatterns  patternsMap
 	dynamicPatterns patternsMap
 	rootNode        *segmentNode
+	shapes          map[string]pattern
 
 	explicitIndexSegment   string
 	dynamicParamPrefixRune rune
@@ -61,6 +62,7 @@ func New(opts *Options) *Matcher {
 	instance.staticPatterns = make(patternsMap)
 	instance.dynamicPatterns = make(patternsMap)
 	instance.rootNode = new(segmentNode)
+	instance.shapes = make(map[string]pattern)
 
 	mungedOpts := mungeOptsToDefaults(opts)
 
@@ -3,6 +3,7 @@ package matcher
 import (
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 )
 
@@ -10,8 +11,9 @@ const (
 	nodeStatic       uint8 = 0
 	nodeDynamic      uint8 = 1
 	nodeSplat        uint8 = 2
-	scoreStaticMatch       = 2
-	scoreDynamic           = 1
+	scoreStaticMatch       = 4
+	scoreConstrained       = 3
+	scoreDynamic           = 2
 )
 
 type RegisteredPattern struct {
@@ -77,6 +79,11 @@ func JoinPatterns(rp *RegisteredPattern, pattern string) string {
 type segment struct {
 	normalizedVal string
 	segType       segType
+
+	// Dynamic segments only
+	paramName  string
+	constraint *constraint
+	optional   bool
 }
 
 var segTypes = struct {
@@ -131,22 +138,22 @@ func (m *Matcher) NormalizePattern(originalPattern string) *RegisteredPattern {
 
 	var numberOfDynamicParamSegs uint8
 
-	for _, seg := range rawSegments {
-		normalizedVal := seg
+	for i, seg := range rawSegments {
+		normalized := &segment{normalizedVal: seg, segType: m.getSegmentTypeAssumeNormalized(seg)}
 
-		segType := m.getSegmentTypeAssumeNormalized(seg)
-		if segType == segTypes.dynamic {
+		if normalized.segType == segTypes.dynamic {
 			numberOfDynamicParamSegs++
-			normalizedVal = ":" + seg[1:]
+			normalized.paramName, normalized.constraint, normalized.optional = parseDynamicSegment(seg[1:])
+			if normalized.optional && i < len(rawSegments)-1 {
+				panic(fmt.Sprintf("matcher: only the last segment may be optional (pattern '%s')", originalPattern))
+			}
+			normalized.normalizedVal = normalizeDynamicSegment(normalized.paramName, normalized.constraint, normalized.optional)
 		}
-		if segType == segTypes.splat {
-			normalizedVal = "*"
+		if normalized.segType == segTypes.splat {
+			normalized.normalizedVal = "*"
 		}
 
-		segments = append(segments, &segment{
-			normalizedVal: normalizedVal,
-			segType:       segType,
-		})
+		segments = append(segments, normalized)
 	}
 
 	segLen := len(segments)
@@ -198,12 +205,21 @@ func (m *Matcher) RegisterPattern(originalPattern string) *RegisteredPattern {
 
 	m.dynamicPatterns[n.normalizedPattern] = n
 
+	shapeKey := n.shapeKey()
+	if existing, ok := m.shapes[shapeKey]; ok && existing != n.normalizedPattern {
+		m.Log(fmt.Sprintf("WARN: Pattern '%s' matches exactly the same paths as '%s', which will shadow it.", originalPattern, existing))
+	} else {
+		m.shapes[shapeKey] = n.normalizedPattern
+	}
+
 	current := m.rootNode
 	var nodeScore int
 
 	for i, segment := range n.normalizedSegments {
-		child := current.findOrCreateChild(segment.normalizedVal)
+		child := current.findOrCreateChild(segment)
 		switch {
+		case segment.segType == segTypes.dynamic && segment.constraint != nil:
+			nodeScore += scoreConstrained
 		case segment.segType == segTypes.dynamic:
 			nodeScore += scoreDynamic
 		case segment.segType != segTypes.splat:
@@ -213,6 +229,10 @@ func (m *Matcher) RegisterPattern(originalPattern string) *RegisteredPattern {
 		if i == len(n.normalizedSegments)-1 {
 			child.finalScore = nodeScore
 			child.pattern = n.normalizedPattern
+			// An optional last segment also lets the pattern end at the parent
+			if segment.optional && current.pattern == "" {
+				current.pattern = n.normalizedPattern
+			}
 		}
 
 		current = child
@@ -254,40 +274,55 @@ type segmentNode struct {
 	children    map[string]*segmentNode
 	dynChildren []*segmentNode
 	paramName   string
+	segment     *segment
 	finalScore  int
 }
 
 // findOrCreateChild finds or creates a child node for a segment
-func (n *segmentNode) findOrCreateChild(segment string) *segmentNode {
-	if segment == "*" || (len(segment) > 0 && rune(segment[0]) == ':') {
+func (n *segmentNode) findOrCreateChild(seg *segment) *segmentNode {
+	if seg.segType == segTypes.splat || seg.segType == segTypes.dynamic {
 		for _, child := range n.dynChildren {
-			if child.paramName == segment[1:] {
+			if child.nodeType == nodeSplat && seg.segType == segTypes.splat {
+				return child
+			}
+			if child.nodeType == nodeDynamic && child.paramName == seg.paramName &&
+				child.segment.constraint.String() == seg.constraint.String() {
 				return child
 			}
 		}
-		return n.addDynamicChild(segment)
+		return n.addDynamicChild(seg)
 	}
 
 	if n.children == nil {
 		n.children = make(map[string]*segmentNode)
 	}
-	if child, exists := n.children[segment]; exists {
+	if child, exists := n.children[seg.normalizedVal]; exists {
 		return child
 	}
 	child := &segmentNode{nodeType: nodeStatic}
-	n.children[segment] = child
+	n.children[seg.normalizedVal] = child
 	return child
 }
 
-// addDynamicChild creates a new dynamic or splat child node
-func (n *segmentNode) addDynamicChild(segment string) *segmentNode {
-	child := &segmentNode{}
-	if segment == "*" {
+// addDynamicChild creates a new dynamic or splat child node. Constrained
+// children are kept ahead of unconstrained ones, so that they are tried first.
+func (n *segmentNode) addDynamicChild(seg *segment) *segmentNode {
+	child := &segmentNode{segment: seg}
+	if seg.segType == segTypes.splat {
 		child.nodeType = nodeSplat
-	} else {
-		child.nodeType = nodeDynamic
-		child.paramName = segment[1:]
+		n.dynChildren = append(n.dynChildren, child)
+		return child
+	}
+	child.nodeType = nodeDynamic
+	child.paramName = seg.paramName
+	if seg.constraint == nil {
+		n.dynChildren = append(n.dynChildren, child)
+		return child
+	}
+	insertAt := 0
+	for insertAt < len(n.dynChildren) && n.dynChildren[insertAt].nodeType == nodeDynamic && n.dynChildren[insertAt].segment.constraint != nil {
+		insertAt++
 	}
-	n.dynChildren = append(n.dynChildren, child)
+	n.dynChildren = slices.Insert(n.dynChildren, insertAt, child)
 	return child
 }
@@ -0,0 +1,250 @@
+package csp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sjc5/river/kit/bytesutil"
+	"github.com/sjc5/river/kit/contextutil"
+	"github.com/sjc5/river/kit/response"
+)
+
+// Directives maps directive names to their sources, e.g.,
+// {"default-src": {"'self'"}, "object-src": {"'none'"}}.
+type Directives map[string][]string
+
+type Opts struct {
+	// The base policy of every response. Hashes (and the nonce, if any) are
+	// added to script-src and style-src. If either is missing, it starts
+	// out with the sources of default-src, as browsers would fall back to.
+	Directives Directives
+
+	// If true, a fresh nonce is generated for each request and added to
+	// script-src and style-src. Get it with GetNonce.
+	UseNonce bool
+
+	// If true, sets Content-Security-Policy-Report-Only instead of
+	// Content-Security-Policy.
+	ReportOnly bool
+}
+
+const (
+	scriptSrc  = "script-src"
+	styleSrc   = "style-src"
+	defaultSrc = "default-src"
+	nonceLen   = 16
+)
+
+var builderStore = contextutil.NewStore[*Builder]("csp_builder")
+
+// Attaches a Builder to each request, and sets the resulting policy header
+// right before the response headers are written. Handlers add the hashes of
+// the inline scripts and styles they render with GetBuilder (River does
+// this for its own inline elements).
+func NewMiddleware(opts Opts) func(http.Handler) http.Handler {
+	headerName := "Content-Security-Policy"
+	if opts.ReportOnly {
+		headerName = "Content-Security-Policy-Report-Only"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b := NewBuilder(opts.Directives)
+
+			if opts.UseNonce {
+				nonceBytes, err := bytesutil.Random(nonceLen)
+				if err != nil {
+					res := response.New(w)
+					res.InternalServerError("")
+					return
+				}
+				b.setNonce(bytesutil.ToBase64(nonceBytes))
+			}
+
+			cw := &cspWriter{ResponseWriter: w, builder: b, headerName: headerName}
+			next.ServeHTTP(cw, builderStore.GetRequestWithContext(r, b))
+		})
+	}
+}
+
+// Returns the request's Builder, or nil if the request did not go through
+// the middleware. All Builder methods are no-ops on a nil Builder.
+func GetBuilder(r *http.Request) *Builder {
+	return builderStore.GetValueFromContext(r.Context())
+}
+
+// Returns the request's nonce, for rendering into the nonce attribute of
+// inline elements, or an empty string if there is none.
+func GetNonce(r *http.Request) string {
+	return GetBuilder(r).Nonce()
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// BUILDER
+/////////////////////////////////////////////////////////////////////
+
+// Builder collects the sources of a single response's policy. It is safe
+// for concurrent use.
+type Builder struct {
+	mu         sync.Mutex
+	directives Directives
+	order      []string
+	nonce      string
+}
+
+func NewBuilder(directives Directives) *Builder {
+	b := &Builder{directives: make(Directives, len(directives))}
+	names := make([]string, 0, len(directives))
+	for name := range directives {
+		names = append(names, name)
+	}
+	// default-src first, then alphabetical, for a deterministic header
+	slices.SortFunc(names, func(x, y string) int {
+		switch {
+		case x == defaultSrc:
+			return -1
+		case y == defaultSrc:
+			return 1
+		}
+		return strings.Compare(x, y)
+	})
+	for _, name := range names {
+		b.order = append(b.order, name)
+		b.directives[name] = slices.Clone(directives[name])
+	}
+	return b
+}
+
+// Adds sources to a directive, skipping any it already has.
+func (b *Builder) Add(directive string, sources ...string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.add(directive, sources...)
+}
+
+// Adds the hash of an inline script. Accepts the base64-encoded SHA-256
+// hash of the script's contents, with or without the "sha256-" prefix
+// (e.g., as returned by htmlutil.AddSha256HashInline).
+func (b *Builder) AddScriptHash(sha256Base64 string) {
+	b.addHash(scriptSrc, sha256Base64)
+}
+
+// Adds the hash of an inline style. See AddScriptHash.
+func (b *Builder) AddStyleHash(sha256Base64 string) {
+	b.addHash(styleSrc, sha256Base64)
+}
+
+func (b *Builder) Nonce() string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nonce
+}
+
+// Returns the policy, e.g., "default-src 'self'; script-src 'self' 'sha256-...'".
+func (b *Builder) String() string {
+	if b == nil {
+		return ""
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var sb strings.Builder
+	for i, name := range b.order {
+		if i > 0 {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(name)
+		for _, source := range b.directives[name] {
+			sb.WriteString(" ")
+			sb.WriteString(source)
+		}
+	}
+	return sb.String()
+}
+
+func (b *Builder) addHash(directive, sha256Base64 string) {
+	if sha256Base64 == "" {
+		return
+	}
+	b.Add(directive, "'sha256-"+strings.TrimPrefix(sha256Base64, "sha256-")+"'")
+}
+
+func (b *Builder) setNonce(nonce string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nonce = nonce
+	b.add(scriptSrc, "'nonce-"+nonce+"'")
+	b.add(styleSrc, "'nonce-"+nonce+"'")
+}
+
+func (b *Builder) add(directive string, sources ...string) {
+	existing, ok := b.directives[directive]
+	if !ok {
+		b.order = append(b.order, directive)
+		if directive == scriptSrc || directive == styleSrc {
+			existing = slices.Clone(b.directives[defaultSrc])
+		}
+	}
+	for _, source := range sources {
+		if !slices.Contains(existing, source) {
+			existing = append(existing, source)
+		}
+	}
+	b.directives[directive] = existing
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// RESPONSE WRITER
+/////////////////////////////////////////////////////////////////////
+
+type cspWriter struct {
+	http.ResponseWriter
+	builder    *Builder
+	headerName string
+	once       sync.Once
+}
+
+func (w *cspWriter) setHeader() {
+	w.once.Do(func() {
+		if policy := w.builder.String(); policy != "" {
+			w.ResponseWriter.Header().Set(w.headerName, policy)
+		}
+	})
+}
+
+func (w *cspWriter) WriteHeader(statusCode int) {
+	w.setHeader()
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *cspWriter) Write(b []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cspWriter) Flush() {
+	w.setHeader()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Allows http.ResponseController to reach the underlying writer
+func (w *cspWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Implemented directly (not only through Unwrap), since WebSocket libraries
+// check for http.Hijacker with a type assertion.
+func (w *cspWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
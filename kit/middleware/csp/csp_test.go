@@ -0,0 +1,162 @@
+package csp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder(Directives{
+		"object-src":  {"'none'"},
+		"default-src": {"'self'"},
+		"img-src":     {"'self'", "data:"},
+	})
+	b.AddScriptHash("abc=")
+	b.AddScriptHash("sha256-abc=")
+	b.AddStyleHash("def=")
+	b.AddStyleHash("")
+	b.Add("img-src", "data:", "https://example.com")
+
+	want := "default-src 'self'; img-src 'self' data: https://example.com; object-src 'none'; " +
+		"script-src 'self' 'sha256-abc='; style-src 'self' 'sha256-def='"
+	if got := b.String(); got != want {
+		t.Errorf("String() = %q, want: %q", got, want)
+	}
+}
+
+func TestBuilder_ExplicitScriptSrc(t *testing.T) {
+	b := NewBuilder(Directives{
+		"default-src": {"'self'"},
+		"script-src":  {"'strict-dynamic'"},
+	})
+	b.AddScriptHash("abc=")
+	want := "default-src 'self'; script-src 'strict-dynamic' 'sha256-abc='"
+	if got := b.String(); got != want {
+		t.Errorf("String() = %q, want: %q", got, want)
+	}
+}
+
+func TestBuilder_Nil(t *testing.T) {
+	var b *Builder
+	b.AddScriptHash("abc=")
+	b.Add("img-src", "data:")
+	if b.String() != "" || b.Nonce() != "" {
+		t.Error("expected a nil builder to be a no-op")
+	}
+	if GetNonce(httptest.NewRequest(http.MethodGet, "/", nil)) != "" {
+		t.Error("expected no nonce without the middleware")
+	}
+}
+
+func TestBuilder_Concurrent(t *testing.T) {
+	b := NewBuilder(Directives{"default-src": {"'self'"}})
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.AddScriptHash("abc=")
+		}()
+	}
+	wg.Wait()
+	if got := strings.Count(b.String(), "'sha256-abc='"); got != 1 {
+		t.Errorf("expected the hash once, got %d", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var nonce string
+	handler := NewMiddleware(Opts{
+		Directives: Directives{"default-src": {"'self'"}},
+		UseNonce:   true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce = GetNonce(r)
+		GetBuilder(r).AddScriptHash("abc=")
+		w.Write([]byte("ok"))
+		// Too late to make it into the header
+		GetBuilder(r).AddStyleHash("def=")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if nonce == "" {
+		t.Fatal("expected a nonce")
+	}
+	want := "default-src 'self'; script-src 'self' 'nonce-" + nonce + "' 'sha256-abc='; style-src 'self' 'nonce-" + nonce + "'"
+	if got := rr.Header().Get("Content-Security-Policy"); got != want {
+		t.Errorf("Content-Security-Policy = %q, want: %q", got, want)
+	}
+
+	firstNonce := nonce
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if nonce == firstNonce {
+		t.Error("expected a fresh nonce per request")
+	}
+}
+
+func TestMiddleware_ReportOnly(t *testing.T) {
+	handler := NewMiddleware(Opts{
+		Directives: Directives{"default-src": {"'self'"}},
+		ReportOnly: true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rr.Header().Get("Content-Security-Policy") != "" {
+		t.Error("expected no enforced policy")
+	}
+	if got := rr.Header().Get("Content-Security-Policy-Report-Only"); got != "default-src 'self'" {
+		t.Errorf("Content-Security-Policy-Report-Only = %q", got)
+	}
+}
+
+func TestMiddleware_Flush(t *testing.T) {
+	handler := NewMiddleware(Opts{
+		Directives: Directives{"default-src": {"'self'"}},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush() error = %v", err)
+		}
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !rr.Flushed || rr.Header().Get("Content-Security-Policy") != "default-src 'self'" {
+		t.Error("expected the policy to be set on flush")
+	}
+}
+
+func TestMiddleware_WebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(NewMiddleware(Opts{
+		Directives: Directives{"default-src": {"'self'"}},
+		UseNonce:   true,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte("hello"))
+	})))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("expected the upgrade to succeed through the middleware: %v", err)
+	}
+	defer conn.Close()
+	if _, msg, err := conn.ReadMessage(); err != nil || string(msg) != "hello" {
+		t.Errorf("unexpected message %q, %v", msg, err)
+	}
+}
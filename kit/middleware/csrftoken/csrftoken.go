@@ -1,11 +1,13 @@
 package csrftoken
 
 import (
+	"crypto/subtle"
 	"errors"
 	"net/http"
 	"net/url"
 	"strings"
 
+	"github.com/sjc5/river/kit/mux"
 	"github.com/sjc5/river/kit/response"
 )
 
@@ -14,20 +16,43 @@ type (
 	GetSubmittedCSRFToken = func(r *http.Request) string
 )
 
+// Exemptions from the CSRF token check (but not the host check) are best
+// declared per route with mux.SetRouteCSRFExempt, which the middleware
+// honors when attached to a mux.Router.
 type Opts struct {
-	GetExpectedCSRFToken  GetExpectedCSRFToken
-	GetSubmittedCSRFToken GetSubmittedCSRFToken
+	GetExpectedCSRFToken  GetExpectedCSRFToken       // Ignored if Issue is set
+	GetSubmittedCSRFToken GetSubmittedCSRFToken      // Optional if Issue is set
 	GetIsExempt           func(r *http.Request) bool // Exempts from CSRF token check (not host check)
 	PermittedHosts        []string                   // If len == 0, all hosts are permitted
+
+	// If set, the middleware issues and verifies its own tokens. See
+	// IssueOpts.
+	Issue *IssueOpts
 }
 
 func NewMiddleware(opts Opts) func(http.Handler) http.Handler {
+	var iss *issuer
+	if opts.Issue != nil {
+		iss = newIssuer(opts.Issue)
+	} else if opts.GetExpectedCSRFToken == nil || opts.GetSubmittedCSRFToken == nil {
+		panic("csrftoken: GetExpectedCSRFToken and GetSubmittedCSRFToken are required unless Issue is set")
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			res := response.New(w)
 
-			switch r.Method {
-			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			var issuedToken string
+			if iss != nil {
+				var err error
+				r, issuedToken, err = iss.prepare(w, r)
+				if err != nil {
+					res.InternalServerError("")
+					return
+				}
+			}
+
+			if isSafeMethod(r.Method) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -55,24 +80,38 @@ func NewMiddleware(opts Opts) func(http.Handler) http.Handler {
 				}
 			}
 
-			if opts.GetIsExempt != nil && opts.GetIsExempt(r) {
+			if mux.IsCSRFExempt(r) || (opts.GetIsExempt != nil && opts.GetIsExempt(r)) {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			expectedToken := opts.GetExpectedCSRFToken(r)
-			if expectedToken == "" {
-				res.InternalServerError("")
-				return
+			var expectedToken string
+			if iss != nil {
+				expectedToken = issuedToken
+				if expectedToken == "" {
+					res.Forbidden("CSRF cookie missing or invalid")
+					return
+				}
+			} else {
+				expectedToken = opts.GetExpectedCSRFToken(r)
+				if expectedToken == "" {
+					res.InternalServerError("")
+					return
+				}
 			}
 
-			submittedToken := opts.GetSubmittedCSRFToken(r)
+			var submittedToken string
+			if opts.GetSubmittedCSRFToken != nil {
+				submittedToken = opts.GetSubmittedCSRFToken(r)
+			} else {
+				submittedToken = iss.getSubmittedToken(r)
+			}
 			if submittedToken == "" {
 				res.BadRequest("CSRF token missing")
 				return
 			}
 
-			if submittedToken != expectedToken {
+			if subtle.ConstantTimeCompare([]byte(submittedToken), []byte(expectedToken)) != 1 {
 				res.Forbidden("CSRF token mismatch")
 				return
 			}
@@ -3,7 +3,14 @@ package csrftoken
 import (
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/sjc5/river/kit/bytesutil"
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/signedcookie"
 )
 
 // Mock functions
@@ -209,3 +216,151 @@ func TestGetLowercaseHost(t *testing.T) {
 		})
 	}
 }
+
+func newTestCookieManager(t *testing.T) *signedcookie.Manager {
+	t.Helper()
+	secret, _ := bytesutil.Random(signedcookie.SecretSize)
+	manager, err := signedcookie.NewManager(signedcookie.Secrets{bytesutil.ToBase64(secret)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manager
+}
+
+func TestIssue(t *testing.T) {
+	sessionID := "s1"
+	opts := Opts{Issue: &IssueOpts{
+		CookieManager:    newTestCookieManager(t),
+		RotationInterval: time.Hour,
+		GetSessionID:     func(r *http.Request) string { return sessionID },
+	}}
+
+	var seenToken string
+	handler := NewMiddleware(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenToken = GetToken(r)
+	}))
+
+	serve := func(method string, cookie *http.Cookie, header string, form url.Values) *httptest.ResponseRecorder {
+		var req *http.Request
+		if form != nil {
+			req = httptest.NewRequest(method, "http://example.com", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(method, "http://example.com", nil)
+		}
+		req.Header.Set("Origin", "http://example.com")
+		if header != "" {
+			req.Header.Set("X-CSRF-Token", header)
+		}
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	cookieFrom := func(rr *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range rr.Result().Cookies() {
+			if c.Name == "csrf_token" {
+				return c
+			}
+		}
+		return nil
+	}
+
+	rr := serve("GET", nil, "", nil)
+	cookie := cookieFrom(rr)
+	if cookie == nil || !cookie.HttpOnly || seenToken == "" || strings.Contains(cookie.Value, seenToken) {
+		t.Fatalf("expected a signed token cookie on first visit, got %v (token %q)", cookie, seenToken)
+	}
+	token := seenToken
+
+	if rr := serve("GET", cookie, "", nil); cookieFrom(rr) != nil || seenToken != token {
+		t.Error("expected the existing token to be reused")
+	}
+
+	if rr := serve("POST", cookie, token, nil); rr.Code != http.StatusOK {
+		t.Errorf("expected header token to be accepted, got %d", rr.Code)
+	}
+	if rr := serve("POST", cookie, "", url.Values{"csrf_token": {token}}); rr.Code != http.StatusOK {
+		t.Errorf("expected form token to be accepted, got %d", rr.Code)
+	}
+	if rr := serve("POST", cookie, token+"x", nil); rr.Code != http.StatusForbidden {
+		t.Errorf("expected mismatched token to be rejected, got %d", rr.Code)
+	}
+	if rr := serve("POST", cookie, "", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected missing token to be rejected, got %d", rr.Code)
+	}
+	rr = serve("POST", nil, token, nil)
+	if rr.Code != http.StatusForbidden || cookieFrom(rr) == nil {
+		t.Errorf("expected missing cookie to be rejected with a fresh cookie, got %d", rr.Code)
+	}
+
+	// Session change
+	sessionID = "s2"
+	if rr := serve("POST", cookie, token, nil); rr.Code != http.StatusForbidden {
+		t.Errorf("expected token from another session to be rejected, got %d", rr.Code)
+	}
+	rr = serve("GET", cookie, "", nil)
+	if cookieFrom(rr) == nil || seenToken == token {
+		t.Fatal("expected a new token after a session change")
+	}
+	cookie, token = cookieFrom(rr), seenToken
+
+	// Scheduled rotation only happens on safe requests
+	iss := newIssuer(opts.Issue)
+	iss.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	rotating := func(method, header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "http://example.com", nil)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		r, existing, _ := iss.prepare(rr, req)
+		if existing != token {
+			t.Errorf("expected the existing token to remain valid for verification")
+		}
+		seenToken = GetToken(r)
+		return rr
+	}
+	if rr := rotating("POST", token); cookieFrom(rr) != nil || seenToken != token {
+		t.Error("expected no rotation on unsafe requests")
+	}
+	if rr := rotating("GET", ""); cookieFrom(rr) == nil || seenToken == token {
+		t.Error("expected rotation on safe requests once due")
+	}
+}
+
+func TestIssueWithMuxExemption(t *testing.T) {
+	router := mux.NewRouter(nil)
+	mux.SetGlobalHTTPMiddleware(router, NewMiddleware(Opts{Issue: &IssueOpts{CookieManager: newTestCookieManager(t)}}))
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux.RegisterHandler(router, "POST", "/form", ok)
+	webhook := mux.RegisterHandler(router, "POST", "/webhook", ok)
+	mux.SetRouteCSRFExempt(webhook)
+
+	serve := func(path string) int {
+		req := httptest.NewRequest("POST", "http://example.com"+path, nil)
+		req.Header.Set("Origin", "http://example.com")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := serve("/form"); code != http.StatusForbidden {
+		t.Errorf("expected unexempt route to require a token, got %d", code)
+	}
+	if code := serve("/webhook"); code != http.StatusOK {
+		t.Errorf("expected exempt route to pass, got %d", code)
+	}
+}
+
+func TestHeadElement(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	if HeadElement(req) != nil {
+		t.Error("expected no element without a token")
+	}
+	req = tokenStore.GetRequestWithContext(req, "abc")
+	if el := HeadElement(req); el == nil || el.Tag != "meta" || el.Attributes["content"] != "abc" {
+		t.Errorf("unexpected element %+v", el)
+	}
+}
@@ -0,0 +1,170 @@
+package csrftoken
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sjc5/river/kit/contextutil"
+	"github.com/sjc5/river/kit/htmlutil"
+	"github.com/sjc5/river/kit/id"
+	"github.com/sjc5/river/kit/signedcookie"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// TOKEN ISSUANCE
+/////////////////////////////////////////////////////////////////////
+
+// IssueOpts configures the middleware's batteries-included mode, a signed
+// double-submit scheme: each client gets a random token in a signed (and
+// optionally encrypted) HttpOnly cookie, pages expose the same token to
+// client code (see GetToken and HeadElement), and unsafe requests must
+// submit it back in a header or form field.
+type IssueOpts struct {
+	// Required.
+	CookieManager *signedcookie.Manager
+
+	// Optional. Defaults to "csrf_token". The Name, HttpOnly, Secure and
+	// Expires fields are ignored. Path defaults to "/" and SameSite to Lax.
+	Cookie signedcookie.BaseCookie
+
+	// Optional. Encrypts the cookie value, in addition to signing it.
+	Encrypt bool
+
+	// Optional. Tokens older than this are replaced on the client's next
+	// safe (e.g., GET) request. Old tokens are still accepted until then,
+	// so that forms rendered before the rotation keep working. Zero means
+	// never.
+	RotationInterval time.Duration
+
+	// Optional. Binds tokens to the client's session, so that a token is
+	// only accepted with the session it was issued for, and a new one is
+	// issued whenever the session ID changes (e.g., on login).
+	GetSessionID func(r *http.Request) string
+
+	// Optional. Defaults to "X-CSRF-Token".
+	HeaderName string
+
+	// Optional. Checked when the header is absent. Defaults to
+	// "csrf_token".
+	FormFieldName string
+}
+
+const tokenLen = 32
+
+type tokenRecord struct {
+	Token     string
+	IssuedAt  time.Time
+	SessionID string
+}
+
+type issuer struct {
+	opts   *IssueOpts
+	cookie *signedcookie.SignedCookie[tokenRecord]
+	now    func() time.Time
+}
+
+func newIssuer(opts *IssueOpts) *issuer {
+	if opts.CookieManager == nil {
+		panic("csrftoken: IssueOpts.CookieManager is required")
+	}
+	o := *opts
+	if o.Cookie.Name == "" {
+		o.Cookie.Name = "csrf_token"
+	}
+	if o.Cookie.Path == "" {
+		o.Cookie.Path = "/"
+	}
+	if o.Cookie.SameSite == 0 {
+		o.Cookie.SameSite = http.SameSiteLaxMode
+	}
+	if o.HeaderName == "" {
+		o.HeaderName = "X-CSRF-Token"
+	}
+	if o.FormFieldName == "" {
+		o.FormFieldName = "csrf_token"
+	}
+	return &issuer{
+		opts: &o,
+		cookie: &signedcookie.SignedCookie[tokenRecord]{
+			Manager:    o.CookieManager,
+			BaseCookie: o.Cookie,
+			Encrypt:    o.Encrypt,
+		},
+		now: time.Now,
+	}
+}
+
+var tokenStore = contextutil.NewStore[string]("csrf_token")
+
+// Issues a new token if the client has no valid one (or, on safe requests,
+// if it is due for rotation), and makes the client's current token
+// available to GetToken. Returns the token previously issued to the client,
+// if valid, for verification.
+func (iss *issuer) prepare(w http.ResponseWriter, r *http.Request) (*http.Request, string, error) {
+	var sessionID string
+	if iss.opts.GetSessionID != nil {
+		sessionID = iss.opts.GetSessionID(r)
+	}
+
+	var existing string
+	rec, err := iss.cookie.VerifyAndReadCookieValue(r)
+	if err == nil && rec.Token != "" && rec.SessionID == sessionID {
+		existing = rec.Token
+	}
+
+	token := existing
+	if existing == "" || (isSafeMethod(r.Method) && iss.isDueForRotation(rec)) {
+		token, err = id.New(tokenLen)
+		if err != nil {
+			return r, "", err
+		}
+		cookie, err := iss.cookie.NewSignedCookie(tokenRecord{Token: token, IssuedAt: iss.now(), SessionID: sessionID}, nil)
+		if err != nil {
+			return r, "", err
+		}
+		http.SetCookie(w, cookie)
+	}
+
+	return tokenStore.GetRequestWithContext(r, token), existing, nil
+}
+
+func (iss *issuer) isDueForRotation(rec tokenRecord) bool {
+	return iss.opts.RotationInterval > 0 && iss.now().Sub(rec.IssuedAt) >= iss.opts.RotationInterval
+}
+
+func (iss *issuer) getSubmittedToken(r *http.Request) string {
+	if token := r.Header.Get(iss.opts.HeaderName); token != "" {
+		return token
+	}
+	return r.PostFormValue(iss.opts.FormFieldName)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// Returns the client's current CSRF token, for rendering into pages (e.g.,
+// into River core data, or as a hidden form field). Only available
+// downstream of a middleware with Issue set; returns an empty string
+// otherwise.
+func GetToken(r *http.Request) string {
+	return tokenStore.GetValueFromContext(r.Context())
+}
+
+// Returns a <meta name="csrf-token"> element holding the client's current
+// CSRF token, for including in head blocks (e.g., from River's
+// GetDefaultHeadBlocks), or nil if there is none.
+func HeadElement(r *http.Request) *htmlutil.Element {
+	token := GetToken(r)
+	if token == "" {
+		return nil
+	}
+	return &htmlutil.Element{
+		Tag:        "meta",
+		Attributes: map[string]string{"name": "csrf-token", "content": token},
+	}
+}
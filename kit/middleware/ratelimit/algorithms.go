@@ -0,0 +1,103 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// TOKEN BUCKET
+/////////////////////////////////////////////////////////////////////
+
+// State: Stamp is the time of the last take, and A is the tokens left
+// after it.
+
+func (l *Limiter) takeTokenBucket(old State, now time.Time) (Result, State, time.Duration) {
+	capacity := float64(l.opts.Burst)
+	perToken := l.opts.Window / time.Duration(l.opts.Limit)
+
+	tokens := capacity
+	if old.Stamp != 0 {
+		elapsed := now.Sub(time.Unix(0, old.Stamp))
+		tokens = min(capacity, old.A+float64(max(elapsed, 0))/float64(perToken))
+	}
+
+	result := Result{Limit: l.opts.Burst}
+	state := old
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+		state = State{Stamp: now.UnixNano(), A: tokens}
+	} else {
+		result.RetryAfter = time.Duration((1 - tokens) * float64(perToken))
+	}
+
+	result.Remaining = int(math.Floor(tokens))
+	result.Reset = time.Duration((capacity - tokens) * float64(perToken))
+
+	// Once full, a bucket is indistinguishable from a missing one
+	return result, state, max(result.Reset, time.Second)
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// SLIDING WINDOW
+/////////////////////////////////////////////////////////////////////
+
+// State: Stamp is the start of the current fixed window, A is its count, and
+// B is the previous fixed window's count.
+
+func (l *Limiter) takeSlidingWindow(old State, now time.Time) (Result, State, time.Duration) {
+	window := l.opts.Window
+	limit := float64(l.opts.Limit)
+
+	nowNano := now.UnixNano()
+	start := nowNano - nowNano%int64(window)
+
+	var current, previous float64
+	switch old.Stamp {
+	case start:
+		current, previous = old.A, old.B
+	case start - int64(window):
+		previous = old.A
+	}
+
+	untilNext := time.Duration(start + int64(window) - nowNano)
+	elapsedFraction := 1 - float64(untilNext)/float64(window)
+	estimate := previous*(1-elapsedFraction) + current
+
+	result := Result{Limit: l.opts.Limit}
+	state := old
+	if estimate+1 <= limit {
+		current++
+		estimate++
+		result.Allowed = true
+		state = State{Stamp: start, A: current, B: previous}
+	} else {
+		result.RetryAfter = slidingWindowRetryAfter(window, untilNext, elapsedFraction, limit, current, previous)
+	}
+
+	result.Remaining = max(int(math.Floor(limit-estimate)), 0)
+	// The estimate only drops to zero once the current window has fully
+	// slid out
+	result.Reset = untilNext + window
+
+	return result, state, untilNext + window
+}
+
+// Returns the time until previous*(1-fraction) + current <= limit-1 again.
+func slidingWindowRetryAfter(window, untilNext time.Duration, elapsedFraction, limit, current, previous float64) time.Duration {
+	target := limit - 1
+
+	// Within the current window, as the previous window's weight decays
+	if current <= target && previous > 0 {
+		fraction := 1 - (target-current)/previous
+		return time.Duration((fraction - elapsedFraction) * float64(window))
+	}
+
+	// Within the next window, as the current window's weight decays
+	fraction := 0.0
+	if current > 0 {
+		fraction = max(1-target/current, 0)
+	}
+	return untilNext + time.Duration(fraction*float64(window))
+}
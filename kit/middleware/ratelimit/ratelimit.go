@@ -0,0 +1,305 @@
+// Package ratelimit limits how often clients may hit a handler, using either
+// a token bucket or a sliding window, with limiter state kept in a pluggable
+// Store (in memory by default). Limiters work as plain HTTP middleware, so
+// they can be attached at any level of a mux.Router:
+//
+//	limiter := ratelimit.New(ratelimit.Opts{Limit: 10, Window: time.Minute})
+//	mux.SetGlobalHTTPMiddleware(router, limiter.Middleware)
+//	mux.SetPatternLevelHTTPMiddleware(loginRoute, strictLimiter.Middleware)
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjc5/river/kit/colorlog"
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/response"
+)
+
+var Log = colorlog.New("ratelimit")
+
+type Algorithm int
+
+const (
+	// Allows bursts of up to Burst requests, refilling at Limit requests per
+	// Window.
+	TokenBucket Algorithm = iota
+
+	// Allows up to Limit requests in any rolling Window, approximated by
+	// weighting the previous fixed window's count by its overlap with the
+	// rolling one.
+	SlidingWindow
+)
+
+// Returns the key to limit the request by. Requests with an empty key are
+// not limited.
+type KeyFunc = func(r *http.Request) string
+
+type Opts struct {
+	// Required. Requests permitted per Window.
+	Limit int
+
+	// Required.
+	Window time.Duration
+
+	// Optional. Defaults to TokenBucket.
+	Algorithm Algorithm
+
+	// Optional. Token bucket capacity. Defaults to Limit.
+	Burst int
+
+	// Optional. Defaults to ByIP.
+	Key KeyFunc
+
+	// Optional. When true, each route (as identified by its mux pattern) gets
+	// its own limit per key, even when the limiter is attached globally.
+	PerRoute bool
+
+	// Optional. Prefixes all store keys, so that several limiters can share
+	// a store. Required when they do.
+	Name string
+
+	// Optional. Defaults to a MemoryStore holding up to 10,000 keys, owned
+	// by the limiter (see Limiter.Close). To avoid one cleanup goroutine per
+	// limiter, pass a single store to several limiters (with distinct Names).
+	Store Store
+
+	// Optional. Exempts requests from limiting (and from rate limit headers).
+	GetIsExempt func(r *http.Request) bool
+
+	// Optional. Responds to limited requests, after the rate limit headers
+	// are set. Defaults to a plain-text 429.
+	OnLimited http.Handler
+}
+
+type Limiter struct {
+	opts Opts
+	now  func() time.Time
+
+	// Set when the limiter created its own store, which Close then closes
+	ownStore *MemoryStore
+}
+
+func New(opts Opts) *Limiter {
+	if opts.Limit <= 0 || opts.Window <= 0 {
+		panic("ratelimit: Limit and Window must be positive")
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = opts.Limit
+	}
+	if opts.Key == nil {
+		opts.Key = ByIP()
+	}
+	l := &Limiter{opts: opts, now: time.Now}
+	if opts.Store == nil {
+		l.ownStore = NewMemoryStore(10_000)
+		l.opts.Store = l.ownStore
+	}
+	return l
+}
+
+// Closes the limiter's default MemoryStore, stopping its expired-key
+// cleanup. Stores passed in via Opts.Store are left alone, since they may
+// be shared. Only needed for limiters that don't live as long as the
+// process (e.g., ones created per tenant or in tests). The limiter must not
+// be used after Close.
+func (l *Limiter) Close() {
+	if l.ownStore != nil {
+		l.ownStore.Close()
+	}
+}
+
+// Convenience wrapper around New(opts).Middleware. The limiter can't be
+// closed, so this is meant for limiters that live as long as the process.
+func NewMiddleware(opts Opts) func(http.Handler) http.Handler {
+	return New(opts).Middleware
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+
+	// Time until the limit fully resets.
+	Reset time.Duration
+
+	// Time until the next request would be allowed (zero if allowed).
+	RetryAfter time.Duration
+}
+
+// Maximum compare-and-swap attempts per request before giving up on a
+// contended key.
+const maxAttempts = 8
+
+var ErrContended = errors.New("ratelimit: too much contention on key")
+
+// Takes one request's worth of the limit for key. Useful for limiting things
+// other than HTTP requests (e.g., login attempts per username, from within a
+// handler).
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	storeKey := key
+	if l.opts.Name != "" {
+		storeKey = l.opts.Name + ":" + key
+	}
+
+	for range maxAttempts {
+		old, found, err := l.opts.Store.Get(ctx, storeKey)
+		if err != nil {
+			return Result{}, err
+		}
+		if !found {
+			old = State{}
+		}
+
+		result, state, ttl := l.take(old, l.now())
+		if !result.Allowed && found {
+			// Denials don't change the state, so there's nothing to write
+			return result, nil
+		}
+
+		swapped, err := l.opts.Store.CompareAndSwap(ctx, storeKey, old, state, ttl)
+		if err != nil {
+			return Result{}, err
+		}
+		if swapped {
+			return result, nil
+		}
+	}
+
+	return Result{}, ErrContended
+}
+
+func (l *Limiter) take(old State, now time.Time) (Result, State, time.Duration) {
+	if l.opts.Algorithm == SlidingWindow {
+		return l.takeSlidingWindow(old, now)
+	}
+	return l.takeTokenBucket(old, now)
+}
+
+// Limits requests per the limiter's options, setting RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers (per the
+// IETF RateLimit header fields draft) on every response, and Retry-After on
+// limited ones. If the store fails, requests are let through and the error
+// is logged.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.opts.GetIsExempt != nil && l.opts.GetIsExempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := l.opts.Key(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if l.opts.PerRoute {
+			key = r.Method + " " + mux.GetPattern(r) + "|" + key
+		}
+
+		result, err := l.Allow(r.Context(), key)
+		if err != nil {
+			Log.Error(fmt.Sprintf("error checking rate limit: %v", err))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		l.setHeaders(w.Header(), result)
+
+		if !result.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			if l.opts.OnLimited != nil {
+				l.opts.OnLimited.ServeHTTP(w, r)
+				return
+			}
+			res := response.New(w)
+			res.TooManyRequests()
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (l *Limiter) setHeaders(h http.Header, result Result) {
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", l.opts.Limit, ceilSeconds(l.opts.Window)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// KEYS
+/////////////////////////////////////////////////////////////////////
+
+// Keys requests by the client IP of the connection. Behind a proxy, use
+// ByForwardedIP instead.
+func ByIP() KeyFunc {
+	return func(r *http.Request) string {
+		return remoteIP(r)
+	}
+}
+
+// Keys requests by the client IP in the given header, as set by your
+// trusted proxies (e.g., "X-Forwarded-For" or "CF-Connecting-IP").
+// Clients can send the header themselves, so only the entries appended by
+// your own proxies can be trusted: trustedHops is the number of proxies
+// in front of the app that append to the header, and the entry that many
+// places from the right is used. For single-value headers set by one
+// proxy, pass 1. Values below 1 are treated as 1. Falls back to the
+// connection's IP if the header is missing or has fewer entries than
+// trustedHops.
+func ByForwardedIP(header string, trustedHops int) KeyFunc {
+	trustedHops = max(trustedHops, 1)
+	return func(r *http.Request) string {
+		// Proxies may append to an existing header or add another one
+		var entries []string
+		for _, value := range r.Header.Values(header) {
+			entries = append(entries, strings.Split(value, ",")...)
+		}
+		if len(entries) >= trustedHops {
+			if ip := strings.TrimSpace(entries[len(entries)-trustedHops]); ip != "" {
+				return ip
+			}
+		}
+		return remoteIP(r)
+	}
+}
+
+// Keys requests by the value of the given header (e.g., an API key).
+func ByHeader(header string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(header)
+	}
+}
+
+// Keys requests by the value of the given cookie (e.g., a session ID).
+func ByCookie(name string) KeyFunc {
+	return func(r *http.Request) string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
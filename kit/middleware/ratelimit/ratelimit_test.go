@@ -0,0 +1,260 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sjc5/river/kit/mux"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestLimiter(opts Opts) (*Limiter, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	l := New(opts)
+	l.now = clock.Now
+	return l, clock
+}
+
+func TestTokenBucket(t *testing.T) {
+	l, clock := newTestLimiter(Opts{Limit: 2, Window: 2 * time.Second, Burst: 3})
+	ctx := context.Background()
+
+	for i, wantRemaining := range []int{2, 1, 0} {
+		result, err := l.Allow(ctx, "k")
+		if err != nil || !result.Allowed || result.Remaining != wantRemaining {
+			t.Fatalf("request %d: expected allowed with %d remaining, got %+v, %v", i, wantRemaining, result, err)
+		}
+	}
+
+	result, _ := l.Allow(ctx, "k")
+	if result.Allowed || result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Errorf("expected denial with 1s retry and 3s reset, got %+v", result)
+	}
+
+	clock.Advance(time.Second)
+	if result, _ := l.Allow(ctx, "k"); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("expected one token to have refilled, got %+v", result)
+	}
+
+	if result, _ := l.Allow(ctx, "other"); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("expected keys to be limited separately, got %+v", result)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	l, clock := newTestLimiter(Opts{Limit: 4, Window: 10 * time.Second, Algorithm: SlidingWindow})
+	ctx := context.Background()
+
+	for range 4 {
+		if result, _ := l.Allow(ctx, "k"); !result.Allowed {
+			t.Fatalf("expected allowed, got %+v", result)
+		}
+	}
+	result, _ := l.Allow(ctx, "k")
+	if result.Allowed || result.Remaining != 0 {
+		t.Fatalf("expected denial, got %+v", result)
+	}
+
+	// Halfway into the next window, half of the previous window's 4 count
+	clock.Advance(10*time.Second + 5*time.Second)
+	for range 2 {
+		if result, _ := l.Allow(ctx, "k"); !result.Allowed {
+			t.Fatalf("expected allowed after the window slid, got %+v", result)
+		}
+	}
+	result, _ = l.Allow(ctx, "k")
+	if result.Allowed {
+		t.Fatalf("expected denial, got %+v", result)
+	}
+
+	// Waiting out the reported retry time is enough
+	clock.Advance(result.RetryAfter)
+	if result, _ := l.Allow(ctx, "k"); !result.Allowed {
+		t.Errorf("expected allowed after retry time, got %+v", result)
+	}
+
+	// Two whole windows later, everything is forgotten
+	clock.Advance(20 * time.Second)
+	if result, _ := l.Allow(ctx, "k"); !result.Allowed || result.Remaining != 3 {
+		t.Errorf("expected a fresh limit, got %+v", result)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l, _ := newTestLimiter(Opts{
+		Limit:       1,
+		Window:      time.Minute,
+		Key:         ByHeader("X-API-Key"),
+		GetIsExempt: func(r *http.Request) bool { return r.URL.Path == "/exempt" },
+	})
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(path, apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("/", "a")
+	if rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Remaining") != "0" || rr.Header().Get("RateLimit-Policy") != "1;w=60" {
+		t.Errorf("unexpected first response %d %v", rr.Code, rr.Header())
+	}
+
+	rr = serve("/", "a")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" || rr.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("unexpected limited response %d %v", rr.Code, rr.Header())
+	}
+
+	if rr := serve("/", ""); rr.Code != http.StatusOK || rr.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("expected requests without a key not to be limited, got %d %v", rr.Code, rr.Header())
+	}
+	if rr := serve("/exempt", "a"); rr.Code != http.StatusOK {
+		t.Errorf("expected exempt request to pass, got %d", rr.Code)
+	}
+}
+
+func TestPerRouteWithMux(t *testing.T) {
+	router := mux.NewRouter(nil)
+	l, _ := newTestLimiter(Opts{Limit: 1, Window: time.Minute, PerRoute: true})
+	mux.SetGlobalHTTPMiddleware(router, l.Middleware)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mux.RegisterHandler(router, "GET", "/a/:id", ok)
+	mux.RegisterHandler(router, "GET", "/b", ok)
+
+	serve := func(path string) int {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr.Code
+	}
+
+	if serve("/a/1") != http.StatusOK || serve("/b") != http.StatusOK {
+		t.Fatal("expected each route's first request to pass")
+	}
+	if serve("/a/2") != http.StatusTooManyRequests {
+		t.Error("expected the second request to the same route pattern to be limited")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", " 203.0.113.7 , 10.0.0.1")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "abc"})
+
+	if got := ByIP()(req); got != "10.0.0.1" {
+		t.Errorf("ByIP: got %q", got)
+	}
+	if got := ByForwardedIP("CF-Connecting-IP", 1)(req); got != "10.0.0.1" {
+		t.Errorf("ByForwardedIP fallback: got %q", got)
+	}
+	if got := ByCookie("sid")(req); got != "abc" {
+		t.Errorf("ByCookie: got %q", got)
+	}
+	if got := ByCookie("missing")(req); got != "" {
+		t.Errorf("ByCookie missing: got %q", got)
+	}
+}
+
+func TestByForwardedIP(t *testing.T) {
+	tests := []struct {
+		name        string
+		headers     []string
+		trustedHops int
+		want        string
+	}{
+		{"single proxy", []string{"203.0.113.7"}, 1, "203.0.113.7"},
+		{"spoofed entries are ignored", []string{"1.2.3.4, 203.0.113.7"}, 1, "203.0.113.7"},
+		{"two proxies", []string{"1.2.3.4, 203.0.113.7 , 10.0.0.2"}, 2, "203.0.113.7"},
+		{"multiple headers", []string{"1.2.3.4", "203.0.113.7", "10.0.0.2"}, 2, "203.0.113.7"},
+		{"zero hops means one", []string{"1.2.3.4, 203.0.113.7"}, 0, "203.0.113.7"},
+		{"too few entries", []string{"203.0.113.7"}, 2, "10.0.0.1"},
+		{"empty entry", []string{"1.2.3.4, "}, 1, "10.0.0.1"},
+		{"missing header", nil, 1, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			for _, h := range tt.headers {
+				req.Header.Add("X-Forwarded-For", h)
+			}
+			if got := ByForwardedIP("X-Forwarded-For", tt.trustedHops)(req); got != tt.want {
+				t.Errorf("got %q, want: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	l := New(Opts{Limit: 1, Window: time.Minute})
+	if l.ownStore == nil || l.opts.Store != l.ownStore {
+		t.Fatal("expected the limiter to own its default store")
+	}
+	l.Close()
+
+	shared := NewMemoryStore(10)
+	defer shared.Close()
+	l = New(Opts{Limit: 1, Window: time.Minute, Store: shared, Name: "a"})
+	l.Close()
+	// The shared store is still usable after the limiter is closed
+	if result, err := New(Opts{Limit: 1, Window: time.Minute, Store: shared, Name: "b"}).Allow(context.Background(), "k"); err != nil || !result.Allowed {
+		t.Errorf("expected the shared store to keep working, got %+v, %v", result, err)
+	}
+}
+
+func TestConcurrentAllow(t *testing.T) {
+	store := NewMemoryStore(100)
+	defer store.Close()
+	l := New(Opts{Limit: 50, Window: time.Hour, Store: store, Name: "concurrent"})
+
+	var allowed atomic.Int64
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := l.Allow(context.Background(), "k")
+			if err != nil && !errors.Is(err, ErrContended) {
+				t.Error(err)
+			}
+			if result.Allowed {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed.Load() > 50 {
+		t.Errorf("expected at most 50 allowed, got %d", allowed.Load())
+	}
+	if _, found, _ := store.Get(context.Background(), "concurrent:k"); !found {
+		t.Error("expected state under the limiter's name prefix")
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sjc5/river/kit/lru"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// STORES
+/////////////////////////////////////////////////////////////////////
+
+// Per-key limiter state. Its meaning depends on the algorithm, so stores
+// should treat it as opaque, persisting all three fields as-is.
+type State struct {
+	Stamp int64 // Unix nanoseconds
+	A     float64
+	B     float64
+}
+
+// Store holds limiter state by key. Implementations for shared backends
+// (e.g., Redis) must make CompareAndSwap atomic across processes, for
+// instance with a Lua script or WATCH/MULTI.
+type Store interface {
+	// Returns the state for key, with found set to false if it is missing
+	// or expired.
+	Get(ctx context.Context, key string) (state State, found bool, err error)
+
+	// Sets key to new (expiring after ttl) if its current state is old, or
+	// if it is missing and old is the zero State. Reports whether it did.
+	CompareAndSwap(ctx context.Context, key string, old, new State, ttl time.Duration) (swapped bool, err error)
+}
+
+// MemoryStore is a Store for single-process deployments, backed by an LRU
+// cache. When full, the least recently limited keys are evicted (i.e.,
+// forgiven) first.
+type MemoryStore struct {
+	mu    sync.Mutex
+	cache *lru.Cache[string, State]
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// Creates a MemoryStore holding up to maxKeys keys. Call Close when done
+// with it to stop its expired-key cleanup.
+func NewMemoryStore(maxKeys int) *MemoryStore {
+	return &MemoryStore{cache: lru.NewCacheWithTTL[string, State](maxKeys, time.Minute)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (State, bool, error) {
+	state, found := s.cache.Get(key)
+	return state, found, nil
+}
+
+func (s *MemoryStore) CompareAndSwap(ctx context.Context, key string, old, new State, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, found := s.cache.Get(key)
+	if !found {
+		current = State{}
+	}
+	if current != old {
+		return false, nil
+	}
+	s.cache.SetWithTTL(key, new, false, ttl)
+	return true, nil
+}
+
+func (s *MemoryStore) Close() {
+	s.cache.Close()
+}
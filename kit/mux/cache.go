@@ -0,0 +1,356 @@
+package mux
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sjc5/river/kit/cachestore"
+	"github.com/sjc5/river/kit/cryptoutil"
+	"github.com/sjc5/river/kit/htmlutil"
+	"github.com/sjc5/river/kit/matcher"
+	"github.com/sjc5/river/kit/response"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// CACHE POLICY
+/////////////////////////////////////////////////////////////////////
+
+type CacheStore = cachestore.Store
+
+// A CachePolicy caches the output of a task handler (or nested route task
+// handler) so that it does not need to run on every request. Entries are
+// always keyed by route, and additionally by the request attributes listed
+// in the Vary fields.
+//
+// Only successful outputs are cached, and only if the task handler did not
+// set a status, cookie or redirect on its response proxy. Headers and head
+// elements set by the task handler are cached along with the output and
+// re-applied on cache hits. Task middlewares still run on every request.
+//
+// Cached outputs are round-tripped through JSON, so nested route output
+// types must unmarshal back into an equivalent value.
+type CachePolicy struct {
+	// Required.
+	Store CacheStore
+	// Required. How long an entry is considered fresh.
+	TTL time.Duration
+	// Optional. How long after TTL a stale entry may still be served while
+	// it is refreshed in the background.
+	StaleWhileRevalidate time.Duration
+
+	// Optional. If nil, entries vary by all params and splat values. If
+	// non-nil, entries vary only by the listed params (and never by splat
+	// values).
+	VaryByParams []string
+	// Optional. If nil, entries vary by the full query string (with params
+	// sorted, so that order doesn't matter). If non-nil, entries vary only
+	// by the listed query params (and never by others).
+	VaryByQuery []string
+	// Optional. Request header names to vary by.
+	VaryByHeaders []string
+	// Optional. Request cookie names to vary by.
+	VaryByCookies []string
+}
+
+// Caches successful outputs of a GET task handler according to policy.
+// Panics if the route is not a GET task handler or if policy is invalid.
+func SetRouteCachePolicy[I any, O any](route *Route[I, O], policy *CachePolicy) {
+	if route._handler_type != _handler_types._task || route._method != http.MethodGet {
+		panic("cache policies are only supported for GET task handlers: " + route._method + " " + route._pattern)
+	}
+	_must_validate_cache_policy(policy)
+	route._cache_policy = policy
+}
+
+// Caches successful outputs of a nested route task handler according to
+// policy. Panics if the route has no task handler or if policy is invalid.
+func SetNestedRouteCachePolicy[O any](route *NestedRoute[O], policy *CachePolicy) {
+	if route._task_handler == nil {
+		panic("cache policies are only supported for nested routes with task handlers: " + route._pattern)
+	}
+	_must_validate_cache_policy(policy)
+	route._cache_policy = policy
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// INVALIDATION
+/////////////////////////////////////////////////////////////////////
+
+// Removes cached entries for the task handler registered at method and
+// pattern. If params is nil, all entries for the route are removed.
+// Otherwise, only entries for the provided params are removed, in which
+// case params must include every param the route's policy varies by.
+// Does nothing if the route does not exist or has no cache policy.
+func InvalidateRouteCache(router *Router, method, pattern string, params Params) {
+	_method_matcher, ok := router._method_to_matcher_map[method]
+	if !ok {
+		return
+	}
+	_route, ok := _method_matcher._routes[pattern]
+	if !ok {
+		return
+	}
+	_invalidate(_route._get_cache_policy(), _cache_kind_route(method), pattern, params)
+}
+
+// Same as InvalidateRouteCache, but for nested routes.
+func InvalidateNestedRouteCache(router *NestedRouter, pattern string, params Params) {
+	_route, ok := router._routes[pattern]
+	if !ok {
+		return
+	}
+	_invalidate(_route._get_cache_policy(), _cache_kind_nested, pattern, params)
+}
+
+func _invalidate(_policy *CachePolicy, _kind, _pattern string, _params Params) {
+	if _policy == nil {
+		return
+	}
+	_prefix := _cache_key_prefix(_kind, _pattern)
+	if _params != nil {
+		_prefix += _policy._params_part(_params)
+	}
+	_policy.Store.DeletePrefix(_prefix)
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// INTERNAL HELPERS
+/////////////////////////////////////////////////////////////////////
+
+const _cache_kind_nested = "NESTED"
+
+func _cache_kind_route(_method string) string { return _method }
+
+type _cache_payload struct {
+	Data    json.RawMessage     `json:"data"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	HeadEls []*htmlutil.Element `json:"headEls,omitempty"`
+}
+
+func _must_validate_cache_policy(_policy *CachePolicy) {
+	if _policy == nil || _policy.Store == nil {
+		panic("cache policy must have a store")
+	}
+	if _policy.TTL <= 0 {
+		panic("cache policy must have a positive TTL")
+	}
+}
+
+// Keys look like: "<kind> <pattern>\x00<params part>\x00<vary hash>", so
+// that invalidations can delete by route, or by route and params, by prefix.
+func _cache_key_prefix(_kind, _pattern string) string {
+	return _kind + " " + _pattern + "\x00"
+}
+
+func (p *CachePolicy) _key(_kind, _pattern string, _params Params, _splat_vals []string, r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(_cache_key_prefix(_kind, _pattern))
+	sb.WriteString(p._params_part(_params))
+	if p.VaryByParams == nil {
+		for _, v := range _splat_vals {
+			sb.WriteString("*=" + url.QueryEscape(v) + "\x01")
+		}
+	}
+	sb.WriteString("\x00")
+	sb.WriteString(p._vary_hash(r))
+	return sb.String()
+}
+
+func (p *CachePolicy) _params_part(_params Params) string {
+	_keys := p.VaryByParams
+	if _keys == nil {
+		_keys = make([]string, 0, len(_params))
+		for k := range _params {
+			_keys = append(_keys, k)
+		}
+	}
+	_keys = slices.Clone(_keys)
+	slices.Sort(_keys)
+
+	var sb strings.Builder
+	for _, k := range _keys {
+		sb.WriteString(url.QueryEscape(k) + "=" + url.QueryEscape(_params[k]) + "\x01")
+	}
+	return sb.String()
+}
+
+func (p *CachePolicy) _vary_hash(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	_query := r.URL.Query()
+	_vary_by_full_query := p.VaryByQuery == nil && len(_query) > 0
+	if !_vary_by_full_query && len(p.VaryByQuery) == 0 && len(p.VaryByHeaders) == 0 && len(p.VaryByCookies) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	if _vary_by_full_query {
+		// Encode sorts by key
+		sb.WriteString("Q\x01" + _query.Encode() + "\x00")
+	}
+	for _, k := range p.VaryByQuery {
+		sb.WriteString("q\x01" + k + "\x01" + strings.Join(_query[k], "\x02") + "\x00")
+	}
+	for _, k := range p.VaryByHeaders {
+		sb.WriteString("h\x01" + k + "\x01" + strings.Join(r.Header.Values(k), "\x02") + "\x00")
+	}
+	for _, k := range p.VaryByCookies {
+		var v string
+		if c, err := r.Cookie(k); err == nil {
+			v = c.Value
+		}
+		sb.WriteString("c\x01" + k + "\x01" + v + "\x00")
+	}
+	return hex.EncodeToString(cryptoutil.Sha256Hash([]byte(sb.String())))
+}
+
+// Second return value indicates freshness, third indicates presence.
+func (p *CachePolicy) _get(_key string) (*_cache_payload, bool, bool) {
+	_entry, found := p.Store.Get(_key)
+	if !found {
+		return nil, false, false
+	}
+	var _payload _cache_payload
+	if err := json.Unmarshal(_entry.Value, &_payload); err != nil {
+		return nil, false, false
+	}
+	_fresh := time.Now().Before(_entry.StoredAt.Add(p.TTL))
+	return &_payload, _fresh, true
+}
+
+// Stores the already-marshalled output if the response proxy allows it.
+func (p *CachePolicy) _set(_key string, _json_bytes []byte, _proxy *response.Proxy) {
+	if !_is_cacheable_proxy(_proxy) {
+		return
+	}
+	_value, err := json.Marshal(_cache_payload{
+		Data:    _json_bytes,
+		Headers: _proxy.GetAllHeaders(),
+		HeadEls: _proxy.GetHeadElements(),
+	})
+	if err != nil {
+		return
+	}
+	_now := time.Now()
+	p.Store.Set(_key, &cachestore.Entry{
+		Value:     _value,
+		StoredAt:  _now,
+		ExpiresAt: _now.Add(p.TTL + p.StaleWhileRevalidate),
+	})
+}
+
+func (p *CachePolicy) _set_any(_key string, _data any, _proxy *response.Proxy) {
+	if !_is_cacheable_proxy(_proxy) {
+		return
+	}
+	_json_bytes, err := json.Marshal(_data)
+	if err != nil {
+		return
+	}
+	p._set(_key, _json_bytes, _proxy)
+}
+
+func _is_cacheable_proxy(_proxy *response.Proxy) bool {
+	_status, _ := _proxy.GetStatus()
+	return (_status == 0 || _proxy.IsSuccess()) && !_proxy.IsRedirect() && len(_proxy.GetCookies()) == 0
+}
+
+func (_payload *_cache_payload) _apply_to_proxy(_proxy *response.Proxy) {
+	for k, vs := range _payload.Headers {
+		for _, v := range vs {
+			_proxy.AddHeader(k, v)
+		}
+	}
+	_proxy.AddHeadElements(_payload.HeadEls...)
+}
+
+// Keys are scoped to their policy, because separate routers (or stores)
+// can produce the same key.
+type _revalidation_key struct {
+	_policy *CachePolicy
+	_key    string
+}
+
+var _revalidating sync.Map
+
+// Runs fn in the background, unless a revalidation for the same policy and
+// key is already in flight.
+func (p *CachePolicy) _revalidate_in_background(_key string, fn func()) {
+	_rk := _revalidation_key{p, _key}
+	if _, _already := _revalidating.LoadOrStore(_rk, struct{}{}); _already {
+		return
+	}
+	go func() {
+		defer _revalidating.Delete(_rk)
+		fn()
+	}()
+}
+
+// The original request's context is canceled once the response is written,
+// so background revalidations use a detached copy of it.
+func _detached_request(r *http.Request) *http.Request {
+	return r.WithContext(context.WithoutCancel(r.Context()))
+}
+
+func _unmarshal_nested_output(_route AnyNestedRoute, _json_bytes []byte) (any, error) {
+	_ptr := _route.OPtr()
+	if err := json.Unmarshal(_json_bytes, _ptr); err != nil {
+		return nil, err
+	}
+	return reflect.ValueOf(_ptr).Elem().Interface(), nil
+}
+
+func _revalidate_route(
+	_policy *CachePolicy,
+	_key string,
+	_req_data_getter _Req_Data_Getter,
+	_route AnyRoute,
+	_match *matcher.BestMatch,
+	r *http.Request,
+) {
+	_rd, err := _req_data_getter._get_req_data(_detached_request(r), _match)
+	if err != nil {
+		return
+	}
+	_prepared_task := tasks.PrepAny(_rd.TasksCtx(), _route._get_task_handler(), _rd._get_underlying_req_data_instance())
+	_data, err := _prepared_task.GetAny()
+	if err == nil {
+		_policy._set_any(_key, _data, _rd.ResponseProxy())
+	}
+}
+
+func _revalidate_nested(
+	_policy *CachePolicy,
+	_key string,
+	_tasks_registry *tasks.Registry,
+	_task tasks.AnyRegisteredTask,
+	_params Params,
+	_splat_vals []string,
+	r *http.Request,
+) {
+	_policy._revalidate_in_background(_key, func() {
+		_tasks_ctx := _tasks_registry.NewCtxFromRequest(_detached_request(r))
+		_proxy := response.NewProxy()
+		_rd := &ReqData[None]{
+			_params:         _params,
+			_splat_vals:     _splat_vals,
+			_tasks_ctx:      _tasks_ctx,
+			_input:          None{},
+			_response_proxy: _proxy,
+		}
+		_data, err := tasks.PrepAny(_tasks_ctx, _task, _rd).GetAny()
+		if err == nil {
+			_policy._set_any(_key, _data, _proxy)
+		}
+	})
+}
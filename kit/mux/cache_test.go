@@ -0,0 +1,251 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sjc5/river/kit/cachestore"
+	"github.com/sjc5/river/kit/htmlutil"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+func TestRouteCachePolicy(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry})
+
+	var calls atomic.Int32
+	handler := TaskHandlerFromFunc(tasksRegistry, func(rd *ReqData[None]) (map[string]any, error) {
+		calls.Add(1)
+		rd.ResponseProxy().SetHeader("X-Test", "1")
+		return map[string]any{"id": rd.Params()["id"], "lang": rd.Request().URL.Query().Get("lang")}, nil
+	})
+	route := RegisterTaskHandler(router, "GET", "/products/:id", handler)
+	SetRouteCachePolicy(route, &CachePolicy{
+		Store:       cachestore.NewLRU(100),
+		TTL:         time.Minute,
+		VaryByQuery: []string{"lang"},
+	})
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w
+	}
+
+	first := get("/products/1?lang=en")
+	second := get("/products/1?lang=en")
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 handler call, got %d", calls.Load())
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Errorf("expected identical bodies, got %q and %q", first.Body.String(), second.Body.String())
+	}
+	if second.Header().Get("X-Test") != "1" {
+		t.Errorf("expected cached headers to be re-applied")
+	}
+
+	get("/products/1?lang=fr")
+	get("/products/2?lang=en")
+	if calls.Load() != 3 {
+		t.Fatalf("expected vary-by params and query to miss, got %d calls", calls.Load())
+	}
+
+	InvalidateRouteCache(router, "GET", "/products/:id", Params{"id": "1"})
+	get("/products/1?lang=en")
+	get("/products/1?lang=fr")
+	get("/products/2?lang=en")
+	if calls.Load() != 5 {
+		t.Fatalf("expected only id=1 entries to be invalidated, got %d calls", calls.Load())
+	}
+
+	InvalidateRouteCache(router, "GET", "/products/:id", nil)
+	get("/products/2?lang=en")
+	if calls.Load() != 6 {
+		t.Fatalf("expected all entries to be invalidated, got %d calls", calls.Load())
+	}
+}
+
+func TestRouteCachePolicyVariesByQueryByDefault(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry})
+
+	var calls atomic.Int32
+	handler := TaskHandlerFromFunc(tasksRegistry, func(rd *ReqData[None]) (string, error) {
+		calls.Add(1)
+		return rd.Request().URL.Query().Get("q"), nil
+	})
+	search := RegisterTaskHandler(router, "GET", "/search", handler)
+	SetRouteCachePolicy(search, &CachePolicy{Store: cachestore.NewLRU(100), TTL: time.Minute})
+	narrowed := RegisterTaskHandler(router, "GET", "/narrowed", handler)
+	SetRouteCachePolicy(narrowed, &CachePolicy{Store: cachestore.NewLRU(100), TTL: time.Minute, VaryByQuery: []string{}})
+
+	get := func(url string) string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		return w.Body.String()
+	}
+
+	if a, b := get("/search?q=a"), get("/search?q=b"); a == b {
+		t.Errorf("expected different queries not to collide, got %q for both", a)
+	}
+	get("/search?q=a&page=2")
+	get("/search?page=2&q=a")
+	if calls.Load() != 3 {
+		t.Fatalf("expected only reordered query params to hit, got %d calls", calls.Load())
+	}
+
+	get("/narrowed?q=a")
+	if body := get("/narrowed?q=b"); body != `"a"` || calls.Load() != 4 {
+		t.Errorf("expected an empty VaryByQuery to ignore the query, got %q after %d calls", body, calls.Load())
+	}
+}
+
+func TestRouteCachePolicySkipsUncacheable(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry})
+
+	var calls atomic.Int32
+	handler := TaskHandlerFromFunc(tasksRegistry, func(rd *ReqData[None]) (string, error) {
+		calls.Add(1)
+		rd.ResponseProxy().SetCookie(&http.Cookie{Name: "session", Value: "x"})
+		return "ok", nil
+	})
+	route := RegisterTaskHandler(router, "GET", "/me", handler)
+	SetRouteCachePolicy(route, &CachePolicy{Store: cachestore.NewLRU(100), TTL: time.Minute})
+
+	for range 2 {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/me", nil))
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected responses that set cookies not to be cached, got %d calls", calls.Load())
+	}
+}
+
+func TestRouteCachePolicyStaleWhileRevalidate(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry})
+
+	var calls atomic.Int32
+	handler := TaskHandlerFromFunc(tasksRegistry, func(rd *ReqData[None]) (int32, error) {
+		return calls.Add(1), nil
+	})
+	route := RegisterTaskHandler(router, "GET", "/count", handler)
+	SetRouteCachePolicy(route, &CachePolicy{
+		Store:                cachestore.NewLRU(100),
+		TTL:                  10 * time.Millisecond,
+		StaleWhileRevalidate: time.Minute,
+	})
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/count", nil))
+		return w.Body.String()
+	}
+
+	if body := get(); body != "1" {
+		t.Fatalf("expected 1, got %q", body)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if body := get(); body != "1" {
+		t.Fatalf("expected stale value 1, got %q", body)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for get() != "2" {
+		if time.Now().After(deadline) {
+			t.Fatal("expected background revalidation to refresh the entry")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSetRouteCachePolicyPanicsForNonGET(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry})
+	handler := TaskHandlerFromFunc(tasksRegistry, func(rd *ReqData[None]) (string, error) {
+		return "", nil
+	})
+	route := RegisterTaskHandler(router, "POST", "/x", handler)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	SetRouteCachePolicy(route, &CachePolicy{Store: cachestore.NewLRU(1), TTL: time.Minute})
+}
+
+type cachedOutput struct {
+	Name string `json:"name"`
+}
+
+func TestNestedRouteCachePolicy(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewNestedRouter(&NestedOptions{TasksRegistry: tasksRegistry})
+
+	var calls atomic.Int32
+	handler := TaskHandlerFromFunc(tasksRegistry, func(rd *NestedReqData) (cachedOutput, error) {
+		calls.Add(1)
+		rd.ResponseProxy().AddHeadElement(&htmlutil.Element{Tag: "title", InnerHTML: "Catalog"})
+		return cachedOutput{Name: rd.Params()["id"]}, nil
+	})
+	route := RegisterNestedTaskHandler(router, "/catalog/:id", handler)
+	SetNestedRouteCachePolicy(route, &CachePolicy{Store: cachestore.NewLRU(100), TTL: time.Minute})
+
+	run := func() *NestedTasksResults {
+		r := httptest.NewRequest("GET", "/catalog/7", nil)
+		results, ok := FindNestedMatchesAndRunTasks(router, tasksRegistry.NewCtxFromRequest(r), r)
+		if !ok {
+			t.Fatal("expected matches")
+		}
+		return results
+	}
+
+	run()
+	results := run()
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 handler call, got %d", calls.Load())
+	}
+	if data, ok := results.Slice[0].Data().(cachedOutput); !ok || data.Name != "7" {
+		t.Errorf("expected cached output to keep its type, got %#v", results.Slice[0].Data())
+	}
+	if els := results.ResponseProxies[0].GetHeadElements(); len(els) != 1 || els[0].InnerHTML != "Catalog" {
+		t.Errorf("expected cached head elements to be re-applied, got %v", els)
+	}
+
+	InvalidateNestedRouteCache(router, "/catalog/:id", Params{"id": "7"})
+	run()
+	if calls.Load() != 2 {
+		t.Fatalf("expected invalidation to force a re-run, got %d calls", calls.Load())
+	}
+}
+
+func TestRevalidationIsScopedToPolicy(t *testing.T) {
+	store := cachestore.NewLRU(10)
+	p1 := &CachePolicy{Store: store, TTL: time.Minute}
+	p2 := &CachePolicy{Store: store, TTL: time.Minute}
+
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	var runs atomic.Int32
+	revalidate := func() {
+		runs.Add(1)
+		started.Done()
+		<-release
+	}
+
+	started.Add(2)
+	p1._revalidate_in_background("same-key", revalidate)
+	p1._revalidate_in_background("same-key", revalidate) // in flight, so skipped
+	p2._revalidate_in_background("same-key", revalidate)
+	started.Wait()
+	close(release)
+
+	if runs.Load() != 2 {
+		t.Errorf("expected one revalidation per policy, got %d", runs.Load())
+	}
+}
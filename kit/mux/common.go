@@ -1,12 +1,15 @@
 package mux
 
 import (
+	"github.com/sjc5/river/kit/colorlog"
 	"github.com/sjc5/river/kit/genericsutil"
 	"github.com/sjc5/river/kit/matcher"
 	"github.com/sjc5/river/kit/response"
 	"github.com/sjc5/river/kit/tasks"
 )
 
+var Log = colorlog.New("mux")
+
 type (
 	None                      = genericsutil.None
 	TaskHandler[I any, O any] = tasks.RegisteredTask[*ReqData[I], O]
@@ -14,9 +17,11 @@ type (
 )
 
 type ReqData[I any] struct {
+	_pattern        string
 	_params         Params
 	_splat_vals     []string
 	_tasks_ctx      *tasks.TasksCtx
 	_input          I
 	_response_proxy *response.Proxy
+	_csrf_exempt    bool
 }
@@ -0,0 +1,164 @@
+package mux
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sjc5/river/kit/contextutil"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// HOST ROUTING
+/////////////////////////////////////////////////////////////////////
+
+// Host patterns match a request's host (without port, case-insensitively)
+// label by label. Labels starting with the router's DynamicParamPrefixRune
+// capture a param, and a leading "*" label matches one or more labels
+// without capturing. For example, ":tenant.example.com" matches
+// "acme.example.com" with the param tenant=acme, and "*.example.com" matches
+// any subdomain of example.com.
+//
+// Host params are stored on the request context, and are merged into
+// ReqData.Params() (and nested route params) for any router that handles
+// the request downstream. Path params take precedence over host params with
+// the same name.
+
+type _Host_Pattern struct {
+	_original string
+	_wildcard bool
+	_labels   []_Host_Label
+}
+
+type _Host_Label struct {
+	_value      string
+	_param_name string
+}
+
+type _Host_Route struct {
+	_pattern *_Host_Pattern
+	_handler http.Handler
+}
+
+var _host_params_store = contextutil.NewStore[Params]("_host_params")
+
+// MountHost routes requests whose host matches hostPattern to handler
+// (typically another Router, or a handler wrapping a NestedRouter) instead
+// of to this router's own routes. Host routes are tried in the order they
+// were mounted, and requests matching none of them fall through to this
+// router's own routes.
+func (rt *Router) MountHost(hostPattern string, handler http.Handler) {
+	rt._host_routes = append(rt._host_routes, &_Host_Route{
+		_pattern: _parse_host_pattern(hostPattern, rt._matcher_opts.DynamicParamPrefixRune),
+		_handler: handler,
+	})
+}
+
+// Returns the params captured from the request's host by MountHost or a
+// router's Host option, or nil if there are none.
+func GetHostParams(r *http.Request) Params {
+	return _host_params_store.GetValueFromContext(r.Context())
+}
+
+func _parse_host_pattern(_pattern string, _param_prefix rune) *_Host_Pattern {
+	_normalized := strings.TrimSuffix(strings.ToLower(_pattern), ".")
+	if _normalized == "" {
+		panic("mux: host pattern must not be empty")
+	}
+
+	_host_pattern := &_Host_Pattern{_original: _pattern}
+	_labels := strings.Split(_normalized, ".")
+
+	if _labels[0] == "*" {
+		_host_pattern._wildcard = true
+		_labels = _labels[1:]
+	}
+
+	for _, _label := range _labels {
+		switch {
+		case _label == "":
+			panic(fmt.Sprintf("mux: host pattern %q has an empty label", _pattern))
+		case _label == "*":
+			panic(fmt.Sprintf("mux: host pattern %q may only have a wildcard as its first label", _pattern))
+		case strings.HasPrefix(_label, string(_param_prefix)):
+			_host_pattern._labels = append(_host_pattern._labels, _Host_Label{
+				_param_name: _label[len(string(_param_prefix)):],
+			})
+		default:
+			_host_pattern._labels = append(_host_pattern._labels, _Host_Label{_value: _label})
+		}
+	}
+
+	return _host_pattern
+}
+
+// Returns the captured params (nil if none) and whether the host matched.
+func (hp *_Host_Pattern) _match(_host string) (Params, bool) {
+	_labels := strings.Split(_host, ".")
+
+	if hp._wildcard {
+		if len(_labels) <= len(hp._labels) {
+			return nil, false
+		}
+		_labels = _labels[len(_labels)-len(hp._labels):]
+	} else if len(_labels) != len(hp._labels) {
+		return nil, false
+	}
+
+	var _params Params
+	for i, _label := range hp._labels {
+		if _label._param_name == "" {
+			if _labels[i] != _label._value {
+				return nil, false
+			}
+			continue
+		}
+		if _labels[i] == "" {
+			return nil, false
+		}
+		if _params == nil {
+			_params = make(Params, len(hp._labels))
+		}
+		_params[_label._param_name] = _labels[i]
+	}
+	return _params, true
+}
+
+// Returns the request's host, lowercased and without port or trailing dot.
+func _get_request_host(r *http.Request) string {
+	_host := r.Host
+	if h, _, err := net.SplitHostPort(_host); err == nil {
+		_host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(_host), ".")
+}
+
+// Matches the request's host against _host_pattern. On a match, returns the
+// request with any captured params added to its context.
+func _match_host(_host_pattern *_Host_Pattern, r *http.Request) (*http.Request, bool) {
+	_params, ok := _host_pattern._match(_get_request_host(r))
+	if !ok {
+		return r, false
+	}
+	if len(_params) == 0 {
+		return r, true
+	}
+	return _host_params_store.GetRequestWithContext(r, _merge_params(GetHostParams(r), _params)), true
+}
+
+// Returns a new map with the params of b overriding those of a, or whichever
+// is non-empty if the other is empty.
+func _merge_params(a, b Params) Params {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	_merged := make(Params, len(a)+len(b))
+	maps.Copy(_merged, a)
+	maps.Copy(_merged, b)
+	return _merged
+}
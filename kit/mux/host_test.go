@@ -0,0 +1,86 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sjc5/river/kit/tasks"
+)
+
+func TestMountHost(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+
+	tenantRouter := NewRouter(&Options{TasksRegistry: tasksRegistry, MountRoot: "/api/"})
+	RegisterTaskHandler(tenantRouter, "GET", "/users/:id", TaskHandlerFromFunc(tasksRegistry, func(rd *ReqData[None]) (string, error) {
+		return rd.Params()["tenant"] + ":" + rd.Params()["id"], nil
+	}))
+
+	root := NewRouter(&Options{TasksRegistry: tasksRegistry})
+	root.MountHost("www.example.com", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("www"))
+	}))
+	root.MountHost(":tenant.example.com", tenantRouter)
+	RegisterHandlerFunc(root, "GET", "/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("apex"))
+	})
+
+	cases := []struct {
+		host string
+		path string
+		code int
+		body string
+	}{
+		{"acme.example.com", "/api/users/5", 200, `"acme:5"`},
+		{"ACME.example.com:8080", "/api/users/5", 200, `"acme:5"`},
+		{"www.example.com", "/api/users/5", 200, "www"},
+		{"example.com", "/", 200, "apex"},
+		{"a.b.example.com", "/api/users/5", 404, ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", c.path, nil)
+		r.Host = c.host
+		rec := httptest.NewRecorder()
+		root.ServeHTTP(rec, r)
+		if rec.Code != c.code || (c.body != "" && rec.Body.String() != c.body) {
+			t.Errorf("%s%s: got %d %q, want %d %q", c.host, c.path, rec.Code, rec.Body.String(), c.code, c.body)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/api/users/5", nil)
+	r.Host = "acme.example.com"
+	if !root.HasMatch(r) {
+		t.Error("expected HasMatch to delegate to the host's router")
+	}
+}
+
+func TestHostOption(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry, Host: "*.example.com"})
+	RegisterHandlerFunc(router, "GET", "/", func(w http.ResponseWriter, r *http.Request) {})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Host = "other.com"
+	if router.HasMatch(r) {
+		t.Error("expected no match for other host")
+	}
+	r.Host = "a.b.example.com"
+	if !router.HasMatch(r) {
+		t.Error("expected wildcard host to match")
+	}
+
+	nested := NewNestedRouter(&NestedOptions{TasksRegistry: tasksRegistry, Host: ":tenant.example.com"})
+	RegisterNestedPatternWithoutHandler(nested, "/posts/:id")
+
+	r = httptest.NewRequest("GET", "/posts/1", nil)
+	r.Host = "acme.example.com"
+	results, ok := FindNestedMatches(nested, r)
+	if !ok || results.Params["tenant"] != "acme" || results.Params["id"] != "1" {
+		t.Errorf("expected host and path params, got %v", results)
+	}
+	r.Host = "example.com"
+	if _, ok := FindNestedMatches(nested, r); ok {
+		t.Error("expected no nested match for non-matching host")
+	}
+}
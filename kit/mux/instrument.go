@@ -0,0 +1,100 @@
+package mux
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/sjc5/river/kit/tasks"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// INSTRUMENTATION
+/////////////////////////////////////////////////////////////////////
+
+// RouteEvent describes the route a request was matched to. Method is the
+// route's method, which is GET for HEAD requests served by a GET route.
+type RouteEvent struct {
+	Method      string
+	Pattern     string
+	HandlerType string
+	Params      Params
+}
+
+// MiddlewareEvent reports how long a middleware took for a request. For
+// HTTP middlewares, Duration excludes time spent downstream (in later
+// middlewares and the handler). For task middlewares, which run in
+// parallel, it is the task's own run time, and Err is the task's error.
+type MiddlewareEvent struct {
+	MiddlewareInfo
+	Method   string
+	Pattern  string
+	Duration time.Duration
+	Err      error
+}
+
+// Instrumentation receives hooks for requests served by a router. Hooks
+// are called synchronously while serving the request, so implementations
+// must be safe for concurrent use and should return quickly. For task
+// start/end, dedupe and cancellation hooks, see tasks.Instrumentation.
+type Instrumentation interface {
+	RouteMatched(r *http.Request, event RouteEvent)
+	MiddlewareEnded(r *http.Request, event MiddlewareEvent)
+}
+
+// Adds an instrumentation to the router. Should be called at init time,
+// before the router serves any requests. Measuring middleware timings adds
+// a little per-request overhead, so routers without instrumentations skip
+// it entirely.
+func AddInstrumentation(router *Router, instrumentation Instrumentation) {
+	router._instrumentations = append(router._instrumentations, instrumentation)
+}
+
+// Wraps an HTTP middleware so that its own run time (excluding downstream)
+// is reported to the router's instrumentations.
+func (rt *Router) _timed_http_mw(_route AnyRoute, _level string, _mw HTTPMiddleware, _next http.Handler) http.Handler {
+	var _downstream time.Duration
+	_wrapped := _mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_start := time.Now()
+		_next.ServeHTTP(w, r)
+		_downstream += time.Since(_start)
+	}))
+	_info := MiddlewareInfo{Level: _level, Kind: _handler_types._http, Name: _func_name(_mw)}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_start := time.Now()
+		_wrapped.ServeHTTP(w, r)
+		_event := MiddlewareEvent{
+			MiddlewareInfo: _info,
+			Method:         _route.Method(),
+			Pattern:        _route.Pattern(),
+			Duration:       time.Since(_start) - _downstream,
+		}
+		for _, _instrumentation := range rt._instrumentations {
+			_instrumentation.MiddlewareEnded(r, _event)
+		}
+	})
+}
+
+func (rt *Router) _report_task_mws(r *http.Request, _route AnyRoute, _method_matcher *_Method_Matcher, _tasks_ctx *tasks.TasksCtx) {
+	for _, _level := range []struct {
+		_name  string
+		_tasks []tasks.AnyRegisteredTask
+	}{
+		{MiddlewareLevelGlobal, rt._task_mws},
+		{MiddlewareLevelMethod, _method_matcher._task_mws},
+		{MiddlewareLevelPattern, _route._get_task_mws()},
+	} {
+		for _, _task := range _level._tasks {
+			_result, _ := _tasks_ctx.GetResult(_task)
+			_event := MiddlewareEvent{
+				MiddlewareInfo: MiddlewareInfo{Level: _level._name, Kind: _handler_types._task, Name: _task.Name()},
+				Method:         _route.Method(),
+				Pattern:        _route.Pattern(),
+				Duration:       _result.Duration,
+				Err:            _result.Err,
+			}
+			for _, _instrumentation := range rt._instrumentations {
+				_instrumentation.MiddlewareEnded(r, _event)
+			}
+		}
+	}
+}
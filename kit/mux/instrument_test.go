@@ -0,0 +1,74 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sjc5/river/kit/tasks"
+)
+
+type recordingInstrumentation struct {
+	mu          sync.Mutex
+	routes      []RouteEvent
+	middlewares []MiddlewareEvent
+}
+
+func (ri *recordingInstrumentation) RouteMatched(r *http.Request, event RouteEvent) {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	ri.routes = append(ri.routes, event)
+}
+
+func (ri *recordingInstrumentation) MiddlewareEnded(r *http.Request, event MiddlewareEvent) {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	ri.middlewares = append(ri.middlewares, event)
+}
+
+func slowMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestInstrumentation(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry})
+	ri := &recordingInstrumentation{}
+	AddInstrumentation(router, ri)
+
+	SetGlobalHTTPMiddleware(router, slowMiddleware)
+	SetGlobalTaskMiddleware(router, TaskMiddlewareFromFunc(tasksRegistry, introspectAuth))
+	RegisterHandlerFunc(router, "GET", "/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	})
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/users/5", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	if len(ri.routes) != 1 || ri.routes[0].Method != "GET" || ri.routes[0].Pattern != "/users/:id" || ri.routes[0].Params["id"] != "5" {
+		t.Fatalf("unexpected route events %+v", ri.routes)
+	}
+	if len(ri.middlewares) != 2 {
+		t.Fatalf("expected 2 middleware events, got %+v", ri.middlewares)
+	}
+
+	taskMw, httpMw := ri.middlewares[0], ri.middlewares[1]
+	if taskMw.Kind != "task" || taskMw.Name != "mux.introspectAuth" || taskMw.Level != "global" || taskMw.Err != nil {
+		t.Errorf("unexpected task middleware event %+v", taskMw)
+	}
+	if httpMw.Kind != "http" || httpMw.Name != "mux.slowMiddleware" || httpMw.Pattern != "/users/:id" {
+		t.Errorf("unexpected http middleware event %+v", httpMw)
+	}
+	// Excludes the 20ms spent in the handler
+	if httpMw.Duration < 10*time.Millisecond || httpMw.Duration >= 25*time.Millisecond {
+		t.Errorf("expected the middleware's own duration of about 10ms, got %v", httpMw.Duration)
+	}
+}
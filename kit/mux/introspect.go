@@ -0,0 +1,428 @@
+package mux
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sjc5/river/kit/genericsutil"
+	"github.com/sjc5/river/kit/matcher"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// INTROSPECTION
+/////////////////////////////////////////////////////////////////////
+
+// Middleware levels, in the order they run for a request. Task middlewares
+// at every level run (in parallel) before any HTTP middleware, and HTTP
+// middlewares run outermost (global) to innermost (pattern).
+const (
+	MiddlewareLevelGlobal  = "global"
+	MiddlewareLevelMethod  = "method"
+	MiddlewareLevelPattern = "pattern"
+)
+
+type MiddlewareInfo struct {
+	Level string `json:"level"` // "global", "method" or "pattern"
+	Kind  string `json:"kind"`  // "task" or "http"
+	Name  string `json:"name"`  // function name, e.g., "auth.RequireUser"
+}
+
+// A RouteInfo describes a single registered route, for debugging and
+// tooling. Type names are empty for HTTP handlers and for None.
+type RouteInfo struct {
+	Host        string           `json:"host,omitempty"`
+	Method      string           `json:"method"`
+	Pattern     string           `json:"pattern"`
+	Template    string           `json:"template"`
+	HandlerType string           `json:"handlerType"`
+	Handler     string           `json:"handler,omitempty"`
+	InputType   string           `json:"inputType,omitempty"`
+	OutputType  string           `json:"outputType,omitempty"`
+	Deferrable  bool             `json:"deferrable,omitempty"`
+	CSRFExempt  bool             `json:"csrfExempt,omitempty"`
+	Middleware  []MiddlewareInfo `json:"middleware,omitempty"`
+}
+
+// Describe returns every route registered on the router (including routes
+// on routers mounted via MountHost), sorted by host, template and method.
+// Each route's middleware chain is listed in execution order.
+func (rt *Router) Describe() []RouteInfo {
+	var _infos []RouteInfo
+
+	for _method, _method_matcher := range rt._method_to_matcher_map {
+		for _, _route := range _method_matcher._routes {
+			_info := RouteInfo{
+				Method:      _method,
+				Pattern:     _route.Pattern(),
+				Template:    _route.URLBuilder().Template(),
+				HandlerType: _route._get_handler_type(),
+				InputType:   _type_name(_route.I()),
+				OutputType:  _type_name(_route.O()),
+				CSRFExempt:  _route.IsCSRFExempt(),
+			}
+			if rt._host_pattern != nil {
+				_info.Host = rt._host_pattern._original
+			}
+			if _task := _route._get_task_handler(); _task != nil {
+				_info.Handler = _task.Name()
+			} else if _handler := _route._get_http_handler(); _handler != nil {
+				_info.Handler = _handler_name(_handler)
+			}
+			_info.Middleware = _describe_mws(rt, _method_matcher, _route)
+			_infos = append(_infos, _info)
+		}
+	}
+
+	for _, _host_route := range rt._host_routes {
+		_describer, ok := _host_route._handler.(interface{ Describe() []RouteInfo })
+		if !ok {
+			_infos = append(_infos, RouteInfo{
+				Host:        _host_route._pattern._original,
+				Method:      "*",
+				Pattern:     "*",
+				Template:    "*",
+				HandlerType: _handler_types._http,
+				Handler:     _handler_name(_host_route._handler),
+			})
+			continue
+		}
+		for _, _info := range _describer.Describe() {
+			if _info.Host == "" {
+				_info.Host = _host_route._pattern._original
+			}
+			_infos = append(_infos, _info)
+		}
+	}
+
+	slices.SortStableFunc(_infos, func(a, b RouteInfo) int {
+		return strings.Compare(a.Host+" "+a.Template+" "+a.Method, b.Host+" "+b.Template+" "+b.Method)
+	})
+
+	return _infos
+}
+
+// Describe returns every route registered on the nested router, sorted by
+// template. Nested routes are always GETs, and have no middleware.
+func (nr *NestedRouter) Describe() []RouteInfo {
+	_infos := make([]RouteInfo, 0, len(nr._routes))
+
+	for _pattern, _route := range nr._routes {
+		_info := RouteInfo{
+			Method:      http.MethodGet,
+			Pattern:     _pattern,
+			Template:    nr.NormalizedPattern(_pattern),
+			HandlerType: "none",
+			Deferrable:  _route.IsDeferrable(),
+		}
+		if nr._host_pattern != nil {
+			_info.Host = nr._host_pattern._original
+		}
+		if _task := _route._get_task_handler(); _task != nil {
+			_info.HandlerType = _handler_types._task
+			_info.Handler = _task.Name()
+			_info.OutputType = _type_name(_route.O())
+		}
+		_infos = append(_infos, _info)
+	}
+
+	slices.SortStableFunc(_infos, func(a, b RouteInfo) int {
+		return strings.Compare(a.Template, b.Template)
+	})
+
+	return _infos
+}
+
+// A MatchCandidate is a pattern that matches a path on its own, along with
+// the score it matched with (see matcher.BestMatch.Score).
+type MatchCandidate struct {
+	Method   string `json:"method"`
+	Pattern  string `json:"pattern"`
+	Template string `json:"template"`
+	Score    int    `json:"score"`
+	Selected bool   `json:"selected"`
+}
+
+// A MatchExplanation reports which route a request would be routed to, and
+// why: every registered pattern that matches the path on its own is listed
+// as a candidate, highest score first.
+type MatchExplanation struct {
+	Host    string `json:"host,omitempty"`
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Matched bool   `json:"matched"`
+
+	// The winning route, if any
+	MatchedMethod  string   `json:"matchedMethod,omitempty"`
+	MatchedPattern string   `json:"matchedPattern,omitempty"`
+	Params         Params   `json:"params,omitempty"`
+	SplatValues    []string `json:"splatValues,omitempty"`
+
+	Candidates []MatchCandidate `json:"candidates"`
+}
+
+// Explain reports which route the router would serve the request with, and
+// why, without running anything. Host routes (see MountHost) are honored.
+func (rt *Router) Explain(r *http.Request) *MatchExplanation {
+	if _host_route, _r := rt._find_host_route(r); _host_route != nil {
+		if _explainer, ok := _host_route._handler.(interface {
+			Explain(*http.Request) *MatchExplanation
+		}); ok {
+			_explanation := _explainer.Explain(_r)
+			if _explanation.Host == "" {
+				_explanation.Host = _host_route._pattern._original
+			}
+			return _explanation
+		}
+		return &MatchExplanation{Host: _host_route._pattern._original, Method: r.Method, Path: r.URL.Path, Matched: true}
+	}
+
+	_explanation := &MatchExplanation{Method: r.Method, Path: r.URL.Path, Candidates: []MatchCandidate{}}
+
+	if rt._host_pattern != nil {
+		_explanation.Host = rt._host_pattern._original
+		var ok bool
+		if r, ok = _match_host(rt._host_pattern, r); !ok {
+			return _explanation
+		}
+	}
+
+	_real_path := rt._strip_mount_root(r.URL.Path)
+
+	_methods := []string{r.Method}
+	if r.Method == http.MethodHead {
+		_methods = append(_methods, http.MethodGet)
+	}
+
+	_best := rt._find_best_matcher_and_match(r.Method, _real_path)
+	if _best._did_match {
+		_explanation.Matched = true
+		_explanation.MatchedMethod = r.Method
+		if _best._head_fell_back_to_get {
+			_explanation.MatchedMethod = http.MethodGet
+		}
+		_explanation.MatchedPattern = _best._match.OriginalPattern()
+		_explanation.Params = _merge_params(GetHostParams(r), _best._match.Params)
+		_explanation.SplatValues = _best._match.SplatValues
+	}
+
+	_quiet_opts := *rt._matcher_opts
+	_quiet_opts.Quiet = true
+
+	for _, _method := range _methods {
+		_method_matcher, ok := rt._method_to_matcher_map[_method]
+		if !ok {
+			continue
+		}
+		for _pattern, _route := range _method_matcher._routes {
+			_single := matcher.New(&_quiet_opts)
+			_single.RegisterPattern(_pattern)
+			_match, ok := _single.FindBestMatch(_real_path)
+			if !ok {
+				continue
+			}
+			_explanation.Candidates = append(_explanation.Candidates, MatchCandidate{
+				Method:   _method,
+				Pattern:  _pattern,
+				Template: _route.URLBuilder().Template(),
+				Score:    _match.Score(),
+				Selected: _method == _explanation.MatchedMethod && _pattern == _explanation.MatchedPattern,
+			})
+		}
+	}
+
+	_sort_candidates(_explanation.Candidates)
+
+	return _explanation
+}
+
+// Explain reports which nested routes would match the request, in order,
+// and why. Candidates are patterns that match the path on their own (as a
+// full match or as a parent layout); those not selected were dropped in
+// favor of more specific matches.
+func (nr *NestedRouter) Explain(r *http.Request) *MatchExplanation {
+	_explanation := &MatchExplanation{Method: http.MethodGet, Path: r.URL.Path, Candidates: []MatchCandidate{}}
+	if nr._host_pattern != nil {
+		_explanation.Host = nr._host_pattern._original
+	}
+
+	_selected := map[string]struct{}{}
+	if _results, ok := FindNestedMatches(nr, r); ok && len(_results.Matches) > 0 {
+		_explanation.Matched = true
+		_last := _results.Matches[len(_results.Matches)-1]
+		_explanation.MatchedMethod = http.MethodGet
+		_explanation.MatchedPattern = _last.OriginalPattern()
+		_explanation.Params = _results.Params
+		_explanation.SplatValues = _results.SplatValues
+		for _, _match := range _results.Matches {
+			_selected[_match.OriginalPattern()] = struct{}{}
+		}
+	} else if nr._host_pattern != nil {
+		if _, ok := _match_host(nr._host_pattern, r); !ok {
+			return _explanation
+		}
+	}
+
+	_quiet_opts := *nr._matcher_opts
+	_quiet_opts.Quiet = true
+
+	for _pattern := range nr._routes {
+		_single := matcher.New(&_quiet_opts)
+		_single.RegisterPattern(_pattern)
+		_results, ok := _single.FindNestedMatches(r.URL.Path)
+		if !ok || len(_results.Matches) == 0 {
+			continue
+		}
+		_candidate := MatchCandidate{
+			Method:   http.MethodGet,
+			Pattern:  _pattern,
+			Template: nr.NormalizedPattern(_pattern),
+		}
+		// Only full matches get a score, as parent layouts don't compete
+		if _match, ok := _single.FindBestMatch(r.URL.Path); ok {
+			_candidate.Score = _match.Score()
+		}
+		_, _candidate.Selected = _selected[_pattern]
+		_explanation.Candidates = append(_explanation.Candidates, _candidate)
+	}
+
+	_sort_candidates(_explanation.Candidates)
+
+	return _explanation
+}
+
+// String renders the explanation for terminal output.
+func (e *MatchExplanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s", e.Method, e.Path)
+	if e.Host != "" {
+		fmt.Fprintf(&sb, " (host %s)", e.Host)
+	}
+	if !e.Matched {
+		sb.WriteString(" -> no match\n")
+	} else {
+		fmt.Fprintf(&sb, " -> %s %s\n", e.MatchedMethod, e.MatchedPattern)
+	}
+	if len(e.Params) > 0 {
+		_keys := make([]string, 0, len(e.Params))
+		for k := range e.Params {
+			_keys = append(_keys, k)
+		}
+		slices.Sort(_keys)
+		sb.WriteString("  params:")
+		for _, k := range _keys {
+			fmt.Fprintf(&sb, " %s=%s", k, e.Params[k])
+		}
+		sb.WriteString("\n")
+	}
+	if len(e.SplatValues) > 0 {
+		fmt.Fprintf(&sb, "  splat: %s\n", strings.Join(e.SplatValues, "/"))
+	}
+	if len(e.Candidates) > 0 {
+		sb.WriteString("  candidates:\n")
+		for _, c := range e.Candidates {
+			_marker := " "
+			if c.Selected {
+				_marker = "*"
+			}
+			fmt.Fprintf(&sb, "  %s %-6s %s (score %d)\n", _marker, c.Method, c.Template, c.Score)
+		}
+	}
+	return sb.String()
+}
+
+// WriteRoutesTable writes route infos to w as an aligned, human-readable
+// table, one route per line, with middleware listed in execution order.
+func WriteRoutesTable(w io.Writer, infos []RouteInfo) error {
+	_tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(_tw, "HOST\tMETHOD\tPATTERN\tTYPE\tHANDLER\tINPUT\tOUTPUT\tMIDDLEWARE")
+	for _, _info := range infos {
+		_mws := make([]string, 0, len(_info.Middleware))
+		for _, _mw := range _info.Middleware {
+			_mws = append(_mws, fmt.Sprintf("%s/%s:%s", _mw.Level, _mw.Kind, _mw.Name))
+		}
+		_type := _info.HandlerType
+		if _info.Deferrable {
+			_type += " (deferrable)"
+		}
+		fmt.Fprintf(_tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			_or_dash(_info.Host), _info.Method, _info.Template, _type, _or_dash(_info.Handler),
+			_or_dash(_info.InputType), _or_dash(_info.OutputType), _or_dash(strings.Join(_mws, " > ")),
+		)
+	}
+	return _tw.Flush()
+}
+
+func _describe_mws(_router *Router, _method_matcher *_Method_Matcher, _route AnyRoute) []MiddlewareInfo {
+	var _mws []MiddlewareInfo
+	_add_tasks := func(_level string, _tasks []tasks.AnyRegisteredTask) {
+		for _, _task := range _tasks {
+			_mws = append(_mws, MiddlewareInfo{Level: _level, Kind: _handler_types._task, Name: _task.Name()})
+		}
+	}
+	_add_http := func(_level string, _http_mws []HTTPMiddleware) {
+		for _, _mw := range _http_mws {
+			_mws = append(_mws, MiddlewareInfo{Level: _level, Kind: _handler_types._http, Name: _func_name(_mw)})
+		}
+	}
+	// Mirrors the order in run_appropriate_mws
+	_add_tasks(MiddlewareLevelGlobal, _router._task_mws)
+	_add_tasks(MiddlewareLevelMethod, _method_matcher._task_mws)
+	_add_tasks(MiddlewareLevelPattern, _route._get_task_mws())
+	_add_http(MiddlewareLevelGlobal, _router._http_mws)
+	_add_http(MiddlewareLevelMethod, _method_matcher._http_mws)
+	_add_http(MiddlewareLevelPattern, _route._get_http_mws())
+	return _mws
+}
+
+func _sort_candidates(_candidates []MatchCandidate) {
+	slices.SortStableFunc(_candidates, func(a, b MatchCandidate) int {
+		if a.Selected != b.Selected {
+			if a.Selected {
+				return -1
+			}
+			return 1
+		}
+		if a.Score != b.Score {
+			return b.Score - a.Score
+		}
+		return strings.Compare(a.Template, b.Template)
+	})
+}
+
+func _type_name(_v any) string {
+	if _v == nil || genericsutil.IsNone(_v) {
+		return ""
+	}
+	return reflect.TypeOf(_v).String()
+}
+
+func _handler_name(_handler http.Handler) string {
+	if _func, ok := _handler.(http.HandlerFunc); ok {
+		return _func_name(_func)
+	}
+	return reflect.TypeOf(_handler).String()
+}
+
+// Returns the function's name without its package path.
+func _func_name(_f any) string {
+	_fn := runtime.FuncForPC(reflect.ValueOf(_f).Pointer())
+	if _fn == nil {
+		return ""
+	}
+	_name := _fn.Name()
+	return _name[strings.LastIndex(_name, "/")+1:]
+}
+
+func _or_dash(_s string) string {
+	if _s == "" {
+		return "-"
+	}
+	return _s
+}
@@ -0,0 +1,134 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sjc5/river/kit/tasks"
+)
+
+type introspectUser struct{ ID int }
+
+func getIntrospectUser(rd *ReqData[None]) (introspectUser, error) { return introspectUser{}, nil }
+func introspectAuth(rd *ReqData[None]) (string, error)            { return "", nil }
+func introspectLogger(next http.Handler) http.Handler             { return next }
+func introspectCORS(next http.Handler) http.Handler               { return next }
+
+func TestDescribe(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry, MountRoot: "/api/"})
+
+	SetGlobalHTTPMiddleware(router, introspectLogger)
+	SetGlobalTaskMiddleware(router, TaskMiddlewareFromFunc(tasksRegistry, introspectAuth))
+	SetMethodLevelHTTPMiddleware(router, "GET", introspectCORS)
+
+	route := RegisterTaskHandler(router, "GET", "/users/:id<int>", TaskHandlerFromFunc(tasksRegistry, getIntrospectUser))
+	SetPatternLevelHTTPMiddleware(route, introspectLogger)
+	RegisterHandlerFunc(router, "POST", "/webhook", func(w http.ResponseWriter, r *http.Request) {})
+
+	infos := router.Describe()
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 routes, got %d", len(infos))
+	}
+
+	users := infos[0]
+	if users.Method != "GET" || users.Pattern != "/users/:id<int>" || users.Template != "/api/users/:id<int>" {
+		t.Errorf("unexpected route info %+v", users)
+	}
+	if users.HandlerType != "task" || users.Handler != "mux.getIntrospectUser" {
+		t.Errorf("unexpected handler %q (%s)", users.Handler, users.HandlerType)
+	}
+	if users.InputType != "" || users.OutputType != "mux.introspectUser" {
+		t.Errorf("unexpected types %q -> %q", users.InputType, users.OutputType)
+	}
+	wantMws := []MiddlewareInfo{
+		{Level: "global", Kind: "task", Name: "mux.introspectAuth"},
+		{Level: "global", Kind: "http", Name: "mux.introspectLogger"},
+		{Level: "method", Kind: "http", Name: "mux.introspectCORS"},
+		{Level: "pattern", Kind: "http", Name: "mux.introspectLogger"},
+	}
+	if !reflect.DeepEqual(users.Middleware, wantMws) {
+		t.Errorf("got middleware %+v, want %+v", users.Middleware, wantMws)
+	}
+
+	if webhook := infos[1]; webhook.HandlerType != "http" || !strings.HasPrefix(webhook.Handler, "mux.TestDescribe.func") {
+		t.Errorf("unexpected webhook info %+v", webhook)
+	}
+
+	var sb strings.Builder
+	if err := WriteRoutesTable(&sb, infos); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sb.String(), "global/task:mux.introspectAuth > global/http:mux.introspectLogger") {
+		t.Errorf("unexpected table:\n%s", sb.String())
+	}
+}
+
+func TestExplain(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry, MountRoot: "/api/"})
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	RegisterHandlerFunc(router, "GET", "/users/new", noop)
+	RegisterHandlerFunc(router, "GET", "/users/:name", noop)
+	RegisterHandlerFunc(router, "GET", "/users/:id<int>", noop)
+	RegisterHandlerFunc(router, "GET", "/*", noop)
+
+	e := router.Explain(httptest.NewRequest("HEAD", "/api/users/42", nil))
+	if !e.Matched || e.MatchedMethod != "GET" || e.MatchedPattern != "/users/:id<int>" || e.Params["id"] != "42" {
+		t.Fatalf("unexpected explanation %+v", e)
+	}
+	var got []string
+	for _, c := range e.Candidates {
+		got = append(got, c.Template)
+	}
+	if want := []string{"/api/users/:id<int>", "/api/users/:name", "/api/*"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got candidates %v, want %v", got, want)
+	}
+	if !e.Candidates[0].Selected || e.Candidates[0].Score <= e.Candidates[1].Score {
+		t.Errorf("expected the selected candidate to have the highest score: %+v", e.Candidates)
+	}
+	if s := e.String(); !strings.Contains(s, "-> GET /users/:id<int>") || !strings.Contains(s, "* GET    /api/users/:id<int> (score 7)") {
+		t.Errorf("unexpected rendering:\n%s", s)
+	}
+
+	if e := router.Explain(httptest.NewRequest("POST", "/api/users/42", nil)); e.Matched || len(e.Candidates) != 0 {
+		t.Errorf("expected no match for POST, got %+v", e)
+	}
+}
+
+func TestNestedDescribeAndExplain(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewNestedRouter(&NestedOptions{TasksRegistry: tasksRegistry})
+	RegisterNestedPatternWithoutHandler(router, "")
+	RegisterNestedPatternWithoutHandler(router, "/users")
+	RegisterNestedPatternWithoutHandler(router, "/users/:name")
+	user := RegisterNestedTaskHandler(router, "/users/:id<int>", TaskHandlerFromFunc(tasksRegistry, getIntrospectUser))
+	SetNestedRouteDeferrable(user)
+
+	infos := router.Describe()
+	if len(infos) != 4 {
+		t.Fatalf("expected 4 routes, got %d", len(infos))
+	}
+	if last := infos[len(infos)-1]; last.Template != "/users/:name" || last.HandlerType != "none" {
+		t.Errorf("unexpected route info %+v", last)
+	}
+	if info := infos[2]; info.Template != "/users/:id<int>" || info.HandlerType != "task" || !info.Deferrable || info.OutputType != "mux.introspectUser" {
+		t.Errorf("unexpected route info %+v", info)
+	}
+
+	e := router.Explain(httptest.NewRequest("GET", "/users/42", nil))
+	if !e.Matched || e.MatchedPattern != "/users/:id<int>" {
+		t.Fatalf("unexpected explanation %+v", e)
+	}
+	selected := map[string]bool{}
+	for _, c := range e.Candidates {
+		selected[c.Pattern] = c.Selected
+	}
+	want := map[string]bool{"": true, "/users": true, "/users/:id<int>": true, "/users/:name": false}
+	if !reflect.DeepEqual(selected, want) {
+		t.Errorf("got %v, want %v", selected, want)
+	}
+}
@@ -3,8 +3,11 @@ package mux
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"path/filepath"
+	"strconv"
+	"time"
 
 	"github.com/sjc5/river/kit/contextutil"
 	"github.com/sjc5/river/kit/genericsutil"
@@ -12,6 +15,7 @@ import (
 	"github.com/sjc5/river/kit/opt"
 	"github.com/sjc5/river/kit/response"
 	"github.com/sjc5/river/kit/tasks"
+	"github.com/sjc5/river/kit/upload"
 	"github.com/sjc5/river/kit/validate"
 )
 
@@ -31,15 +35,21 @@ type (
 /////////////////////////////////////////////////////////////////////
 
 type Router struct {
-	_marshal_input           func(r *http.Request, iPtr any) error
-	_tasks_registry          *tasks.Registry
-	_http_mws                []HTTPMiddleware
-	_task_mws                []tasks.AnyRegisteredTask
-	_method_to_matcher_map   map[string]*_Method_Matcher
-	_matcher_opts            *matcher.Options
-	_not_found_handler       http.Handler
-	_mount_root              string
-	_auto_task_handler_etags bool
+	_marshal_input              func(r *http.Request, iPtr any) error
+	_tasks_registry             *tasks.Registry
+	_http_mws                   []HTTPMiddleware
+	_task_mws                   []tasks.AnyRegisteredTask
+	_method_to_matcher_map      map[string]*_Method_Matcher
+	_matcher_opts               *matcher.Options
+	_not_found_handler          http.Handler
+	_mount_root                 string
+	_auto_task_handler_etags    bool
+	_stream_heartbeat_interval  time.Duration
+	_websocket_max_message_size int64
+	_websocket_check_origin     func(r *http.Request) bool
+	_host_pattern               *_Host_Pattern
+	_host_routes                []*_Host_Route
+	_instrumentations           []Instrumentation
 }
 
 func (rt *Router) AllRoutes() []AnyRoute {
@@ -92,12 +102,37 @@ type Options struct {
 	DynamicParamPrefixRune rune // Optional. Defaults to ':'.
 	SplatSegmentRune       rune // Optional. Defaults to '*'.
 
+	// Optional. If set, the router only serves requests whose host matches
+	// this host pattern (e.g., ":tenant.example.com"), and any params it
+	// captures are added to ReqData.Params(). Other requests are treated as
+	// not found. See Router.MountHost for the pattern syntax.
+	Host string
+
+	// Optional. Parses and validates task handler inputs. For file uploads,
+	// use validate.MultipartInto, e.g.:
+	//	func(r *http.Request, iPtr any) error { return validate.MultipartInto(r, iPtr, uploadOpts) }
+	// Upload limit errors are returned to clients as 413s, and disallowed or
+	// non-multipart content types as 415s.
 	MarshalInput func(r *http.Request, inputPtr any) error
 
 	// If set to true, task handlers will automatically add a strong ETag
 	// (SHA-256 hash) to successful JSON responses. Has no effect on HTTP handlers.
 	// Defaults to false.
 	AutoTaskHandlerETags bool
+
+	// Interval at which stream handlers send heartbeats, and websocket
+	// handlers send pings, to keep idle connections open (websocket
+	// connections that miss two consecutive pongs are closed). Defaults to
+	// 15 seconds.
+	StreamHeartbeatInterval time.Duration
+
+	// Optional. Maximum size, in bytes, of inbound websocket messages.
+	// Defaults to 1 MB.
+	WebSocketMaxMessageSize int64
+	// Optional. Decides whether to accept a websocket upgrade request. If
+	// nil, only same-origin requests (or requests without an Origin
+	// header) are accepted.
+	WebSocketCheckOrigin func(r *http.Request) bool
 }
 
 func NewRouter(opts *Options) *Router {
@@ -127,13 +162,22 @@ func NewRouter(opts *Options) *Router {
 		}
 	}
 
+	var _host_pattern *_Host_Pattern
+	if opts.Host != "" {
+		_host_pattern = _parse_host_pattern(opts.Host, _matcher_opts.DynamicParamPrefixRune)
+	}
+
 	return &Router{
-		_marshal_input:           opts.MarshalInput,
-		_tasks_registry:          opts.TasksRegistry,
-		_method_to_matcher_map:   make(map[string]*_Method_Matcher),
-		_matcher_opts:            _matcher_opts,
-		_mount_root:              mountRootToUse,
-		_auto_task_handler_etags: opts.AutoTaskHandlerETags,
+		_host_pattern:               _host_pattern,
+		_marshal_input:              opts.MarshalInput,
+		_tasks_registry:             opts.TasksRegistry,
+		_method_to_matcher_map:      make(map[string]*_Method_Matcher),
+		_matcher_opts:               _matcher_opts,
+		_mount_root:                 mountRootToUse,
+		_auto_task_handler_etags:    opts.AutoTaskHandlerETags,
+		_stream_heartbeat_interval:  opt.Resolve(opts, opts.StreamHeartbeatInterval, DefaultStreamHeartbeatInterval),
+		_websocket_max_message_size: opt.Resolve(opts, opts.WebSocketMaxMessageSize, DefaultWebSocketMaxMessageSize),
+		_websocket_check_origin:     opts.WebSocketCheckOrigin,
 	}
 }
 
@@ -145,15 +189,30 @@ func NewRouter(opts *Options) *Router {
 // be particularly convenient for sending JSON. If you need to send a different
 // content type, use a traditional http.Handler instead.
 func TaskHandlerFromFunc[I any, O any](tasksRegistry *tasks.Registry, taskHandlerFunc TaskHandlerFunc[I, O]) *TaskHandler[I, O] {
-	return tasks.Register(tasksRegistry, func(tasksCtx *tasks.Arg[*ReqData[I]]) (O, error) {
-		return taskHandlerFunc(tasksCtx.Input)
+	_task := tasks.Register(tasksRegistry, func(tasksCtx *tasks.Arg[*ReqData[I]]) (O, error) {
+		return taskHandlerFunc(_with_tasks_ctx(tasksCtx.Input, tasksCtx.TasksCtx))
 	})
+	tasks.SetName(_task, _func_name(taskHandlerFunc))
+	return _task
 }
 
 func TaskMiddlewareFromFunc[O any](tasksRegistry *tasks.Registry, taskMwFunc TaskMiddlewareFunc[O]) *TaskMiddleware[O] {
-	return tasks.Register(tasksRegistry, func(tasksCtx *tasks.Arg[*ReqData[None]]) (O, error) {
-		return taskMwFunc(tasksCtx.Input)
+	_task := tasks.Register(tasksRegistry, func(tasksCtx *tasks.Arg[*ReqData[None]]) (O, error) {
+		return taskMwFunc(_with_tasks_ctx(tasksCtx.Input, tasksCtx.TasksCtx))
 	})
+	tasks.SetName(_task, _func_name(taskMwFunc))
+	return _task
+}
+
+// Returns a shallow copy of rd whose TasksCtx is the task run's own view,
+// so that per-task providers resolve once per handler or middleware run.
+func _with_tasks_ctx[I any](rd *ReqData[I], _tasks_ctx *tasks.TasksCtx) *ReqData[I] {
+	if rd == nil || _tasks_ctx == nil {
+		return rd
+	}
+	_copy := *rd
+	_copy._tasks_ctx = _tasks_ctx
+	return &_copy
 }
 
 /////////////////////////////////////////////////////////////////////
@@ -197,6 +256,12 @@ func SetPatternLevelHTTPMiddleware[I any, O any](route *Route[I, O], httpMw HTTP
 	route._http_mws = append(route._http_mws, httpMw)
 }
 
+// Exempts the route from CSRF token checks (e.g., for webhooks
+// authenticated by other means). See IsCSRFExempt.
+func SetRouteCSRFExempt[I any, O any](route *Route[I, O]) {
+	route._csrf_exempt = true
+}
+
 /////////////////////////////////////////////////////////////////////
 /////// NOT FOUND HANDLER
 /////////////////////////////////////////////////////////////////////
@@ -210,11 +275,15 @@ func SetGlobalNotFoundHTTPHandler(router *Router, httpHandler http.Handler) {
 /////////////////////////////////////////////////////////////////////
 
 var _handler_types = struct {
-	_http string
-	_task string
+	_http      string
+	_task      string
+	_stream    string
+	_websocket string
 }{
-	_http: "http",
-	_task: "task",
+	_http:      "http",
+	_task:      "task",
+	_stream:    "stream",
+	_websocket: "websocket",
 }
 
 /////////////////////////////////////////////////////////////////////
@@ -232,9 +301,12 @@ type Route[I any, O any] struct {
 	_http_mws []HTTPMiddleware
 	_task_mws []tasks.AnyRegisteredTask
 
-	_handler_type string
-	_http_handler http.Handler
-	_task_handler tasks.AnyRegisteredTask
+	_handler_type   string
+	_http_handler   http.Handler
+	_task_handler   tasks.AnyRegisteredTask
+	_stream_handler func(http.ResponseWriter, *http.Request, _Req_Data_Marker)
+	_cache_policy   *CachePolicy
+	_csrf_exempt    bool
 }
 
 /////////////////////////////////////////////////////////////////////
@@ -247,23 +319,55 @@ type AnyRoute interface {
 	_get_handler_type() string
 	_get_http_handler() http.Handler
 	_get_task_handler() tasks.AnyRegisteredTask
+	_get_stream_handler() func(http.ResponseWriter, *http.Request, _Req_Data_Marker)
 	_get_http_mws() []HTTPMiddleware
 	_get_task_mws() []tasks.AnyRegisteredTask
+	_get_cache_policy() *CachePolicy
 	Pattern() string
 	Method() string
+	NormalizedPattern() string
+	ParamSpecs() []matcher.ParamSpec
+	URLBuilder() *URLBuilder
+	IsStream() bool
+	IsWebSocket() bool
+	IsCSRFExempt() bool
 }
 
 // Implementing the routeMarker interface on the Route struct.
 func (route *Route[I, O]) _get_handler_type() string                  { return route._handler_type }
 func (route *Route[I, O]) _get_http_handler() http.Handler            { return route._http_handler }
 func (route *Route[I, O]) _get_task_handler() tasks.AnyRegisteredTask { return route._task_handler }
+func (route *Route[I, O]) _get_stream_handler() func(http.ResponseWriter, *http.Request, _Req_Data_Marker) {
+	return route._stream_handler
+}
 func (route *Route[I, O]) _get_http_mws() []HTTPMiddleware {
 	return route._http_mws
 }
 func (route *Route[I, O]) _get_task_mws() []tasks.AnyRegisteredTask { return route._task_mws }
+func (route *Route[I, O]) _get_cache_policy() *CachePolicy          { return route._cache_policy }
 func (route *Route[I, O]) Pattern() string                          { return route._pattern }
 func (route *Route[I, O]) Method() string                           { return route._method }
 
+// Returns the route's pattern with dynamic params prefixed by ':' and splat
+// segments written as '*', regardless of the router's configured runes.
+func (route *Route[I, O]) NormalizedPattern() string {
+	return route._registered_pattern().NormalizedPattern()
+}
+
+// Returns the names, constraints and optionality of the route's params.
+func (route *Route[I, O]) ParamSpecs() []matcher.ParamSpec {
+	return route._registered_pattern().ParamSpecs()
+}
+
+func (route *Route[I, O]) _registered_pattern() *matcher.RegisteredPattern {
+	_matcher_opts := *route._router._matcher_opts
+	_matcher_opts.Quiet = true
+	return matcher.New(&_matcher_opts).NormalizePattern(route._pattern)
+}
+func (route *Route[I, O]) IsStream() bool     { return route._handler_type == _handler_types._stream }
+func (route *Route[I, O]) IsWebSocket() bool  { return route._handler_type == _handler_types._websocket }
+func (route *Route[I, O]) IsCSRFExempt() bool { return route._csrf_exempt }
+
 /////////////////////////////////////////////////////////////////////
 /////// CORE PATTERN REGISTRATION FUNCTIONS
 /////////////////////////////////////////////////////////////////////
@@ -301,7 +405,9 @@ func RegisterHandler(router *Router, method, pattern string, httpHandler http.Ha
 type _Req_Data_Marker interface {
 	_get_input() any
 	_get_underlying_req_data_instance() any
+	_is_csrf_exempt() bool
 
+	Pattern() string
 	Params() Params
 	SplatValues() []string
 	TasksCtx() *tasks.TasksCtx
@@ -312,13 +418,16 @@ type _Req_Data_Marker interface {
 // Implementing the reqDataMarker interface on the ReqData struct.
 func (rd *ReqData[I]) _get_input() any                        { return rd._input }
 func (rd *ReqData[I]) _get_underlying_req_data_instance() any { return rd }
+func (rd *ReqData[I]) _is_csrf_exempt() bool                  { return rd._csrf_exempt }
 
-func (rd *ReqData[I]) Input() I                       { return rd._input }
-func (rd *ReqData[I]) Params() Params                 { return rd._params }
-func (rd *ReqData[I]) SplatValues() []string          { return rd._splat_vals }
-func (rd *ReqData[I]) TasksCtx() *tasks.TasksCtx      { return rd._tasks_ctx }
-func (rd *ReqData[I]) Request() *http.Request         { return rd._tasks_ctx.Request() }
-func (rd *ReqData[I]) ResponseProxy() *response.Proxy { return rd._response_proxy }
+func (rd *ReqData[I]) Input() I                         { return rd._input }
+func (rd *ReqData[I]) Pattern() string                  { return rd._pattern }
+func (rd *ReqData[I]) Params() Params                   { return rd._params }
+func (rd *ReqData[I]) SplatValues() []string            { return rd._splat_vals }
+func (rd *ReqData[I]) TasksCtx() *tasks.TasksCtx        { return rd._tasks_ctx }
+func (rd *ReqData[I]) ParamInt(key string) (int, error) { return _param_int(rd._params, key) }
+func (rd *ReqData[I]) Request() *http.Request           { return rd._tasks_ctx.Request() }
+func (rd *ReqData[I]) ResponseProxy() *response.Proxy   { return rd._response_proxy }
 
 type _Req_Data_Getter interface {
 	_get_req_data(r *http.Request, match *matcher.BestMatch) (_Req_Data_Marker, error)
@@ -345,10 +454,46 @@ func GetReqData[I any](r *http.Request) *ReqData[I] {
 	return genericsutil.AssertOrZero[*ReqData[I]](_req_data_marker)
 }
 
+// Returns the pattern of the route the request matched (e.g.,
+// "/users/:id"), or an empty string outside of a matched route. Unlike the
+// other getters, it doesn't need the route's input type, so it's handy in
+// HTTP middlewares shared across routes.
+func GetPattern(r *http.Request) string {
+	if _req_data_marker := _context_store.GetValueFromContext(r.Context()); _req_data_marker != nil {
+		return _req_data_marker.Pattern()
+	}
+	return ""
+}
+
+// Returns the request's TasksCtx (shared with the route's task middlewares
+// and handler), or nil outside of a matched route.
+func GetTasksCtx(r *http.Request) *tasks.TasksCtx {
+	if _req_data_marker := _context_store.GetValueFromContext(r.Context()); _req_data_marker != nil {
+		return _req_data_marker.TasksCtx()
+	}
+	return nil
+}
+
+// Reports whether the request matched a route marked with
+// SetRouteCSRFExempt. For use by CSRF middlewares attached to the router.
+func IsCSRFExempt(r *http.Request) bool {
+	if _req_data_marker := _context_store.GetValueFromContext(r.Context()); _req_data_marker != nil {
+		return _req_data_marker._is_csrf_exempt()
+	}
+	return false
+}
+
 func GetParam[I any](r *http.Request, key string) string {
 	return GetParams[I](r)[key]
 }
 
+// Returns the param parsed as an int. Pair with an "<int>" constraint in the
+// pattern (e.g., "/users/:id<int>") so that non-integers never reach the
+// handler, in which case the error only occurs for omitted optional params.
+func GetParamInt[I any](r *http.Request, key string) (int, error) {
+	return _param_int(GetParams[I](r), key)
+}
+
 func GetParams[I any](r *http.Request) Params {
 	if _req_data := GetReqData[I](r); _req_data != nil {
 		return _req_data._params
@@ -433,16 +578,58 @@ func (hw *headResponseWriter) Header() http.Header            { return hw.header
 func (hw *headResponseWriter) WriteHeader(statusCode int)     { hw.statusCode = statusCode }
 func (hw *headResponseWriter) Write(data []byte) (int, error) { return len(data), nil }
 
-func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	pathToUse := r.URL.Path
+func (rt *Router) _strip_mount_root(_path string) string {
 	if rt._mount_root != "" {
-		// strip the mount root from the path
-		if len(pathToUse) >= len(rt._mount_root) && pathToUse[:len(rt._mount_root)] == rt._mount_root {
-			pathToUse = "/" + pathToUse[len(rt._mount_root):]
+		if len(_path) >= len(rt._mount_root) && _path[:len(rt._mount_root)] == rt._mount_root {
+			_path = "/" + _path[len(rt._mount_root):]
+		}
+	}
+	return _path
+}
+
+// Reports whether a route is registered for the request's method and path
+// (after stripping the mount root), without running anything.
+func (rt *Router) HasMatch(r *http.Request) bool {
+	if _host_route, _r := rt._find_host_route(r); _host_route != nil {
+		if _matcher, ok := _host_route._handler.(interface{ HasMatch(*http.Request) bool }); ok {
+			return _matcher.HasMatch(_r)
+		}
+		return true
+	}
+	if rt._host_pattern != nil {
+		if _, ok := _match_host(rt._host_pattern, r); !ok {
+			return false
 		}
 	}
+	return rt._find_best_matcher_and_match(r.Method, rt._strip_mount_root(r.URL.Path))._did_match
+}
+
+func (rt *Router) _find_host_route(r *http.Request) (*_Host_Route, *http.Request) {
+	for _, _host_route := range rt._host_routes {
+		if _r, ok := _match_host(_host_route._pattern, r); ok {
+			return _host_route, _r
+		}
+	}
+	return nil, r
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _host_route, _r := rt._find_host_route(r); _host_route != nil {
+		_host_route._handler.ServeHTTP(w, _r)
+		return
+	}
+
+	_host_matched := true
+	if rt._host_pattern != nil {
+		r, _host_matched = _match_host(rt._host_pattern, r)
+	}
 
-	_find_best_match_output := rt._find_best_matcher_and_match(r.Method, pathToUse)
+	pathToUse := rt._strip_mount_root(r.URL.Path)
+
+	_find_best_match_output := &findBestOutput{}
+	if _host_matched {
+		_find_best_match_output = rt._find_best_matcher_and_match(r.Method, pathToUse)
+	}
 
 	if !_find_best_match_output._did_match {
 		if rt._not_found_handler != nil {
@@ -461,6 +648,15 @@ func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	_orig_pattern := _match.OriginalPattern()
 	_route := _method_matcher._routes[_orig_pattern]
 
+	for _, _instrumentation := range rt._instrumentations {
+		_instrumentation.RouteMatched(r, RouteEvent{
+			Method:      _route.Method(),
+			Pattern:     _orig_pattern,
+			HandlerType: _route._get_handler_type(),
+			Params:      _match.Params,
+		})
+	}
+
 	_req_data_getter, ok := _method_matcher._req_data_getters[_orig_pattern]
 	if !ok {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -473,6 +669,14 @@ func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if errors.Is(err, upload.ErrTooLarge) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if errors.Is(err, upload.ErrContentTypeNotAllowed) || errors.Is(err, upload.ErrNotMultipart) {
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
 
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -493,9 +697,40 @@ func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _handler_type := _route._get_handler_type(); _handler_type == _handler_types._stream || _handler_type == _handler_types._websocket {
+		_handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_route._get_stream_handler()(w, r, _handler_req_data_marker)
+		}))
+		_handler = run_appropriate_mws(rt, _handler_req_data_marker, _method_matcher, _route, _handler)
+		_handler.ServeHTTP(w, r)
+		return
+	}
+
 	_handler_func := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		res := response.New(w)
 
+		_response_proxy := _handler_req_data_marker.ResponseProxy()
+
+		_cache_policy := _route._get_cache_policy()
+		var _cache_key string
+		if _cache_policy != nil {
+			_cache_key = _cache_policy._key(
+				_cache_kind_route(_route.Method()), _orig_pattern,
+				_handler_req_data_marker.Params(), _handler_req_data_marker.SplatValues(), r,
+			)
+			if _payload, _fresh, _found := _cache_policy._get(_cache_key); _found {
+				if !_fresh {
+					_cache_policy._revalidate_in_background(_cache_key, func() {
+						_revalidate_route(_cache_policy, _cache_key, _req_data_getter, _route, _match, r)
+					})
+				}
+				_payload._apply_to_proxy(_response_proxy)
+				_response_proxy.ApplyToResponseWriter(w, r)
+				rt._write_task_handler_json(&res, r, _payload.Data)
+				return
+			}
+		}
+
 		_tasks_ctx := _handler_req_data_marker.TasksCtx()
 
 		_prepared_task := tasks.PrepAny(_tasks_ctx, _route._get_task_handler(), _handler_req_data_marker._get_underlying_req_data_instance())
@@ -510,7 +745,6 @@ func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		_response_proxy := _handler_req_data_marker.ResponseProxy()
 		_response_proxy.ApplyToResponseWriter(w, r)
 
 		if _response_proxy.IsError() || _response_proxy.IsRedirect() {
@@ -523,16 +757,11 @@ func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if rt._auto_task_handler_etags {
-			etag := response.ToQuotedSha256Etag(_json_bytes)
-			res.SetETag(etag)
-			if response.ShouldReturn304Conservative(r, etag) {
-				res.NotModified()
-				return
-			}
+		if _cache_policy != nil {
+			_cache_policy._set(_cache_key, _json_bytes, _response_proxy)
 		}
 
-		res.JSONBytes(_json_bytes)
+		rt._write_task_handler_json(&res, r, _json_bytes)
 	})
 
 	_handler := http.Handler(_handler_func)
@@ -552,6 +781,19 @@ func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	_handler.ServeHTTP(w, r)
 }
 
+func (rt *Router) _write_task_handler_json(res *response.Response, r *http.Request, _json_bytes []byte) {
+	if rt._auto_task_handler_etags {
+		etag := response.ToQuotedSha256Etag(_json_bytes)
+		res.SetETag(etag)
+		if response.ShouldReturn304Conservative(r, etag) {
+			res.NotModified()
+			return
+		}
+	}
+
+	res.JSONBytes(_json_bytes)
+}
+
 func run_appropriate_mws(
 	_router *Router,
 	_req_data_marker _Req_Data_Marker,
@@ -559,15 +801,29 @@ func run_appropriate_mws(
 	_route_marker AnyRoute,
 	_handler http.Handler,
 ) http.Handler {
+	_instrumented := len(_router._instrumentations) > 0
+
 	/////// HTTP MIDDLEWARES - Chain in reverse order
 	_http_mws := _route_marker._get_http_mws()
 	for i := len(_http_mws) - 1; i >= 0; i-- { // pattern
+		if _instrumented {
+			_handler = _router._timed_http_mw(_route_marker, MiddlewareLevelPattern, _http_mws[i], _handler)
+			continue
+		}
 		_handler = _http_mws[i](_handler)
 	}
 	for i := len(_method_matcher._http_mws) - 1; i >= 0; i-- { // method
+		if _instrumented {
+			_handler = _router._timed_http_mw(_route_marker, MiddlewareLevelMethod, _method_matcher._http_mws[i], _handler)
+			continue
+		}
 		_handler = _method_matcher._http_mws[i](_handler)
 	}
 	for i := len(_router._http_mws) - 1; i >= 0; i-- { // global
+		if _instrumented {
+			_handler = _router._timed_http_mw(_route_marker, MiddlewareLevelGlobal, _router._http_mws[i], _handler)
+			continue
+		}
 		_handler = _router._http_mws[i](_handler)
 	}
 
@@ -590,6 +846,7 @@ func run_appropriate_mws(
 			_response_proxies = append(_response_proxies, _response_proxy)
 
 			_new_rd := &ReqData[None]{
+				_pattern:        _req_data_marker.Pattern(),
 				_params:         _req_data_marker.Params(),
 				_splat_vals:     _req_data_marker.SplatValues(),
 				_tasks_ctx:      _tasks_ctx,
@@ -604,6 +861,10 @@ func run_appropriate_mws(
 		// Run all task middlewares in parallel
 		_tasks_ctx.ParallelPreload(_tasks_with_input...)
 
+		if _instrumented {
+			_router._report_task_mws(r, _route_marker, _method_matcher, _tasks_ctx)
+		}
+
 		// Merge all response proxies from task middlewares
 		_merged_response_proxy := response.MergeProxyResponses(_response_proxies...)
 		// _is_client_redirect := _merged_response_proxy.IsClientRedirect()
@@ -622,6 +883,16 @@ func run_appropriate_mws(
 /////// INTERNAL HELPERS
 /////////////////////////////////////////////////////////////////////
 
+var ErrParamNotFound = errors.New("param not found")
+
+func _param_int(_params Params, key string) (int, error) {
+	_val, ok := _params[key]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrParamNotFound, key)
+	}
+	return strconv.Atoi(_val)
+}
+
 func _new_route_struct[I any, O any](_router *Router, _method, _pattern string) *Route[I, O] {
 	return &Route[I, O]{_router: _router, _method: _method, _pattern: _pattern}
 }
@@ -630,13 +901,18 @@ func _must_register_route[I any, O any](_route *Route[I, O]) {
 	_method_matcher := _must_get_matcher(_route._router, _route._method)
 	_method_matcher._matcher.RegisterPattern(_route._pattern)
 	_method_matcher._routes[_route._pattern] = _route
-	_method_matcher._req_data_getters[_route._pattern] = _to_req_data_getter_impl(_route)
+	if _route._handler_type == _handler_types._websocket {
+		_method_matcher._req_data_getters[_route._pattern] = _websocket_req_data_getter(_route._router)
+	} else {
+		_method_matcher._req_data_getters[_route._pattern] = _to_req_data_getter_impl(_route)
+	}
 }
 
 func _req_data_starter[I any](_match *matcher.BestMatch, _tasks_registry *tasks.Registry, r *http.Request) *ReqData[I] {
 	_req_data := new(ReqData[I])
-	if len(_match.Params) > 0 {
-		_req_data._params = _match.Params
+	_req_data._pattern = _match.OriginalPattern()
+	if _params := _merge_params(GetHostParams(r), _match.Params); len(_params) > 0 {
+		_req_data._params = _params
 	}
 	if len(_match.SplatValues) > 0 {
 		_req_data._splat_vals = _match.SplatValues
@@ -650,6 +926,7 @@ func _to_req_data_getter_impl[I any, O any](_route *Route[I, O]) _Req_Data_Gette
 	return _Req_Data_Getter_Impl[I](
 		func(r *http.Request, _match *matcher.BestMatch) (*ReqData[I], error) {
 			_req_data := _req_data_starter[I](_match, _route._router._tasks_registry, r)
+			_req_data._csrf_exempt = _route._csrf_exempt
 			_input_ptr := _route.IPtr()
 			if _route._router._marshal_input != nil {
 				if err := _route._router._marshal_input(_req_data.Request(), _input_ptr); err != nil {
@@ -0,0 +1,115 @@
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sjc5/river/kit/tasks"
+	"github.com/sjc5/river/kit/upload"
+	"github.com/sjc5/river/kit/validate"
+)
+
+func TestHasMatch(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry, MountRoot: "/api/"})
+	handler := TaskHandlerFromFunc(tasksRegistry, func(rd *ReqData[None]) (string, error) {
+		return "", nil
+	})
+	RegisterTaskHandler(router, "POST", "/contact/:id", handler)
+
+	if !router.HasMatch(httptest.NewRequest("POST", "/api/contact/1", nil)) {
+		t.Error("expected match")
+	}
+	if router.HasMatch(httptest.NewRequest("GET", "/api/contact/1", nil)) {
+		t.Error("expected no match for unregistered method")
+	}
+	if router.HasMatch(httptest.NewRequest("POST", "/api/other", nil)) {
+		t.Error("expected no match for unregistered path")
+	}
+}
+
+type uploadInput struct {
+	Name   string       `json:"name"`
+	Avatar *upload.File `json:"avatar"`
+}
+
+func TestMultipartTaskHandler(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	uploadOpts := &upload.Options{MaxFileSize: 16}
+	router := NewRouter(&Options{
+		TasksRegistry: tasksRegistry,
+		MarshalInput: func(r *http.Request, iPtr any) error {
+			return validate.MultipartInto(r, iPtr, uploadOpts)
+		},
+	})
+	handler := TaskHandlerFromFunc(tasksRegistry, func(rd *ReqData[uploadInput]) (string, error) {
+		f, err := rd.Input().Avatar.Open()
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return "", err
+		}
+		return rd.Input().Name + ":" + string(b), nil
+	})
+	RegisterTaskHandler(router, "POST", "/avatar", handler)
+
+	post := func(content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("name", "bob")
+		w, _ := mw.CreateFormFile("avatar", "a.txt")
+		w.Write([]byte(content))
+		mw.Close()
+		r := httptest.NewRequest("POST", "/avatar", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := post("hi"); rec.Code != http.StatusOK || rec.Body.String() != `"bob:hi"` {
+		t.Errorf("expected 200 with bound input, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := post("this is far too long"); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413, got %d", rec.Code)
+	}
+
+	r := httptest.NewRequest("POST", "/avatar", bytes.NewReader([]byte("{}")))
+	r.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, r)
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415, got %d", rec.Code)
+	}
+}
+
+func TestConstrainedParams(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry})
+	RegisterTaskHandler(router, "GET", "/users/:id<int>", TaskHandlerFromFunc(tasksRegistry, func(rd *ReqData[None]) (int, error) {
+		return rd.ParamInt("id")
+	}))
+	RegisterTaskHandler(router, "GET", "/users/:name", TaskHandlerFromFunc(tasksRegistry, func(rd *ReqData[None]) (string, error) {
+		return rd.Params()["name"], nil
+	}))
+
+	for path, want := range map[string]string{"/users/42": "42", "/users/bob": `"bob"`} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("%s: expected 200 %s, got %d %s", path, want, rec.Code, rec.Body.String())
+		}
+	}
+
+	if _, err := (&ReqData[None]{}).ParamInt("id"); !errors.Is(err, ErrParamNotFound) {
+		t.Errorf("expected ErrParamNotFound, got %v", err)
+	}
+}
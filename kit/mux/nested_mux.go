@@ -22,7 +22,9 @@ type NestedReqData = ReqData[None]
 type NestedRouter struct {
 	_tasks_registry *tasks.Registry
 	_matcher        *matcher.Matcher
+	_matcher_opts   *matcher.Options
 	_routes         map[string]AnyNestedRoute
+	_host_pattern   *_Host_Pattern
 }
 
 func (nr *NestedRouter) AllRoutes() map[string]AnyNestedRoute {
@@ -33,11 +35,29 @@ func (nr *NestedRouter) TasksRegistry() *tasks.Registry {
 	return nr._tasks_registry
 }
 
+// Returns the pattern with dynamic params prefixed by ':', splat segments
+// written as '*' and index segments written as a trailing slash.
+func (nr *NestedRouter) NormalizedPattern(pattern string) string {
+	return nr._matcher.NormalizePattern(pattern).NormalizedPattern()
+}
+
+// Returns the names, constraints and optionality of the pattern's params.
+func (nr *NestedRouter) ParamSpecs(pattern string) []matcher.ParamSpec {
+	return nr._matcher.NormalizePattern(pattern).ParamSpecs()
+}
+
 func (nr *NestedRouter) IsRegistered(pattern string) bool {
 	_, exists := nr._routes[pattern]
 	return exists
 }
 
+// Returns true if the router was created with a Host option, in which case
+// whether (and with which params) a path matches depends on the request's
+// host too.
+func (nr *NestedRouter) HasHostPattern() bool {
+	return nr._host_pattern != nil
+}
+
 /////////////////////////////////////////////////////////////////////
 /////// NEW ROUTER
 /////////////////////////////////////////////////////////////////////
@@ -50,6 +70,11 @@ type NestedOptions struct {
 	// Optional. Defaults to empty string (trailing slash in your patterns).
 	// You can set it to something like "_index" to make it explicit.
 	ExplicitIndexSegment string
+
+	// Optional. If set, FindNestedMatches only finds matches for requests
+	// whose host matches this host pattern (e.g., ":tenant.example.com").
+	// See Router.MountHost for the pattern syntax.
+	Host string
 }
 
 func NewNestedRouter(opts *NestedOptions) *NestedRouter {
@@ -67,11 +92,16 @@ func NewNestedRouter(opts *NestedOptions) *NestedRouter {
 	_matcher_opts.SplatSegmentRune = opt.Resolve(opts, opts.SplatSegmentRune, '*')
 	_matcher_opts.ExplicitIndexSegment = opt.Resolve(opts, opts.ExplicitIndexSegment, "")
 
-	return &NestedRouter{
+	_nested_router := &NestedRouter{
 		_tasks_registry: opts.TasksRegistry,
 		_matcher:        matcher.New(_matcher_opts),
+		_matcher_opts:   _matcher_opts,
 		_routes:         make(map[string]AnyNestedRoute),
 	}
+	if opts.Host != "" {
+		_nested_router._host_pattern = _parse_host_pattern(opts.Host, _matcher_opts.DynamicParamPrefixRune)
+	}
+	return _nested_router
 }
 
 /////////////////////////////////////////////////////////////////////
@@ -85,6 +115,8 @@ type NestedRoute[O any] struct {
 	_pattern string
 
 	_task_handler tasks.AnyRegisteredTask
+	_deferrable   bool
+	_cache_policy *CachePolicy
 }
 
 /////////////////////////////////////////////////////////////////////
@@ -94,11 +126,16 @@ type NestedRoute[O any] struct {
 type AnyNestedRoute interface {
 	genericsutil.AnyZeroHelper
 	_get_task_handler() tasks.AnyRegisteredTask
+	_get_cache_policy() *CachePolicy
 	Pattern() string
+	URLBuilder() *URLBuilder
+	IsDeferrable() bool
 }
 
 func (route *NestedRoute[O]) _get_task_handler() tasks.AnyRegisteredTask { return route._task_handler }
+func (route *NestedRoute[O]) _get_cache_policy() *CachePolicy            { return route._cache_policy }
 func (route *NestedRoute[O]) Pattern() string                            { return route._pattern }
+func (route *NestedRoute[O]) IsDeferrable() bool                         { return route._deferrable }
 
 /////////////////////////////////////////////////////////////////////
 /////// CORE PATTERN REGISTRATION FUNCTIONS
@@ -118,14 +155,27 @@ func RegisterNestedPatternWithoutHandler(router *NestedRouter, pattern string) {
 	_must_register_nested_route(_route)
 }
 
+// Marks a nested route's task handler output as deferrable. When nested
+// tasks are run via RunNestedTasksWithDeferrals, deferrable tasks are
+// started alongside everything else, but the caller does not wait on
+// them before returning. Because the caller may have already committed
+// the response by the time a deferrable task finishes, response proxy
+// mutations (status, headers, cookies, head elements, redirects) made
+// by deferrable tasks are ignored.
+func SetNestedRouteDeferrable[O any](route *NestedRoute[O]) {
+	route._deferrable = true
+}
+
 /////////////////////////////////////////////////////////////////////
 /////// RUN NESTED TASKS
 /////////////////////////////////////////////////////////////////////
 
 type NestedTasksResult struct {
-	_pattern string
-	_data    any
-	_err     error
+	_pattern  string
+	_data     any
+	_err      error
+	_deferred bool
+	_done     chan struct{}
 }
 
 func (ntr *NestedTasksResult) Pattern() string { return ntr._pattern }
@@ -133,6 +183,15 @@ func (ntr *NestedTasksResult) OK() bool        { return ntr._err == nil }
 func (ntr *NestedTasksResult) Data() any       { return ntr._data }
 func (ntr *NestedTasksResult) Err() error      { return ntr._err }
 
+// True if the result belongs to a deferrable route and was not waited on
+// before the results were returned. Data and Err must not be read until
+// the channel returned by Done is closed.
+func (ntr *NestedTasksResult) IsDeferred() bool { return ntr._deferred }
+
+// Returns a channel that is closed once Data and Err are safe to read.
+// For non-deferred results, the channel is already closed.
+func (ntr *NestedTasksResult) Done() <-chan struct{} { return ntr._done }
+
 type NestedTasksResults struct {
 	Params          Params
 	SplatValues     []string
@@ -141,9 +200,20 @@ type NestedTasksResults struct {
 	ResponseProxies []*response.Proxy
 }
 
-// Second return value (bool) indicates matches found
+// Second return value (bool) indicates matches found. Host params (see
+// Router.MountHost) are merged into the results' params.
 func FindNestedMatches(nestedRouter *NestedRouter, r *http.Request) (*matcher.FindNestedMatchesResults, bool) {
-	return nestedRouter._matcher.FindNestedMatches(r.URL.Path)
+	if nestedRouter._host_pattern != nil {
+		var ok bool
+		if r, ok = _match_host(nestedRouter._host_pattern, r); !ok {
+			return nil, false
+		}
+	}
+	_results, ok := nestedRouter._matcher.FindNestedMatches(r.URL.Path)
+	if ok {
+		_results.Params = _merge_params(GetHostParams(r), _results.Params)
+	}
+	return _results, ok
 }
 
 // Second return value (bool) indicates matches found, not success of tasks run
@@ -161,6 +231,28 @@ func RunNestedTasks(
 	tasksCtx *tasks.TasksCtx,
 	r *http.Request,
 	findNestedMatchesResults *matcher.FindNestedMatchesResults,
+) *NestedTasksResults {
+	return _run_nested_tasks(nestedRouter, tasksCtx, findNestedMatchesResults, false)
+}
+
+// Same as RunNestedTasks, except that tasks belonging to routes marked via
+// SetNestedRouteDeferrable are not waited on. Their results are returned
+// immediately with IsDeferred() == true, and will be populated once their
+// Done() channel closes.
+func RunNestedTasksWithDeferrals(
+	nestedRouter *NestedRouter,
+	tasksCtx *tasks.TasksCtx,
+	r *http.Request,
+	findNestedMatchesResults *matcher.FindNestedMatchesResults,
+) *NestedTasksResults {
+	return _run_nested_tasks(nestedRouter, tasksCtx, findNestedMatchesResults, true)
+}
+
+func _run_nested_tasks(
+	nestedRouter *NestedRouter,
+	tasksCtx *tasks.TasksCtx,
+	findNestedMatchesResults *matcher.FindNestedMatchesResults,
+	_allow_deferrals bool,
 ) *NestedTasksResults {
 	matches := findNestedMatchesResults.Matches
 
@@ -180,6 +272,8 @@ func RunNestedTasks(
 	_tasks_with_input := make([]tasks.AnyPreparedTask, 0, len(matches))
 	_results.ResponseProxies = make([]*response.Proxy, 0, len(matches))
 	_task_indices := make(map[int]int) // Maps match index to task index
+	_deferred_tasks := make(map[int]_deferred_task)
+	_cache_keys := make(map[int]string) // Maps match index to cache key (misses only)
 
 	for i, _match := range matches {
 		_response_proxy := response.NewProxy()
@@ -188,7 +282,7 @@ func RunNestedTasks(
 		_nested_route_marker, routeExists := nestedRouter._routes[_match.OriginalPattern()]
 
 		// Create result object regardless of whether a task exists
-		_res := &NestedTasksResult{_pattern: _match.OriginalPattern()}
+		_res := &NestedTasksResult{_pattern: _match.OriginalPattern(), _done: _closed_chan}
 		_results.Map[_match.OriginalPattern()] = _res
 		_results.Slice[i] = _res
 
@@ -204,6 +298,29 @@ func RunNestedTasks(
 			continue
 		}
 
+		// Serve from cache if possible, and otherwise remember the key so
+		// that the result can be stored once the task has run
+		if _cache_policy := _nested_route_marker._get_cache_policy(); _cache_policy != nil {
+			_key := _cache_policy._key(
+				_cache_kind_nested, _match.OriginalPattern(),
+				findNestedMatchesResults.Params, findNestedMatchesResults.SplatValues, tasksCtx.Request(),
+			)
+			if _payload, _fresh, _found := _cache_policy._get(_key); _found {
+				if _data, err := _unmarshal_nested_output(_nested_route_marker, _payload.Data); err == nil {
+					_res._data = _data
+					_payload._apply_to_proxy(_response_proxy)
+					if !_fresh && tasksCtx.Request() != nil {
+						_revalidate_nested(
+							_cache_policy, _key, nestedRouter._tasks_registry, _task,
+							findNestedMatchesResults.Params, findNestedMatchesResults.SplatValues, tasksCtx.Request(),
+						)
+					}
+					continue
+				}
+			}
+			_cache_keys[i] = _key
+		}
+
 		_rd := &ReqData[None]{
 			_params:         findNestedMatchesResults.Params,
 			_splat_vals:     findNestedMatchesResults.SplatValues,
@@ -212,10 +329,38 @@ func RunNestedTasks(
 			_response_proxy: _response_proxy,
 		}
 
-		_tasks_with_input = append(_tasks_with_input, tasks.PrepAny(tasksCtx, _task, _rd))
+		_prepared_task := tasks.PrepAny(tasksCtx, _task, _rd)
+
+		if _allow_deferrals && _nested_route_marker.IsDeferrable() {
+			_res._deferred = true
+			_res._done = make(chan struct{})
+			_deferred_tasks[i] = _deferred_task{_prepared_task, _response_proxy}
+			// The deferred task keeps writing to its own proxy, so expose an
+			// untouched one to callers to keep indices aligned without races.
+			_results.ResponseProxies[i] = response.NewProxy()
+			continue
+		}
+
+		_tasks_with_input = append(_tasks_with_input, _prepared_task)
 		_task_indices[i] = len(_tasks_with_input) - 1 // Store the mapping between match index and task index
 	}
 
+	// Kick off deferred tasks without waiting on them
+	for matchIdx, _deferred := range _deferred_tasks {
+		_res := _results.Slice[matchIdx]
+		_cache_key, _should_cache := _cache_keys[matchIdx]
+		_cache_policy := _nested_cache_policy(nestedRouter, _res._pattern)
+		go func() {
+			_data, err := _deferred._prepared_task.GetAny()
+			if err == nil && _should_cache {
+				_cache_policy._set_any(_cache_key, _data, _deferred._response_proxy)
+			}
+			_res._data = _data
+			_res._err = err
+			close(_res._done)
+		}()
+	}
+
 	// Only run parallelPreload if we have tasks to run
 	if len(_tasks_with_input) > 0 {
 		tasksCtx.ParallelPreload(_tasks_with_input...)
@@ -227,15 +372,33 @@ func RunNestedTasks(
 		_data, err := _tasks_with_input[taskIdx].GetAny()
 		_res._data = _data
 		_res._err = err
+		if _cache_key, ok := _cache_keys[matchIdx]; ok && err == nil {
+			_nested_cache_policy(nestedRouter, _res._pattern)._set_any(_cache_key, _data, _results.ResponseProxies[matchIdx])
+		}
 	}
 
 	return _results
 }
 
+type _deferred_task struct {
+	_prepared_task  tasks.AnyPreparedTask
+	_response_proxy *response.Proxy
+}
+
+func _nested_cache_policy(_router *NestedRouter, _pattern string) *CachePolicy {
+	return _router._routes[_pattern]._get_cache_policy()
+}
+
 /////////////////////////////////////////////////////////////////////
 /////// INTERNAL HELPERS
 /////////////////////////////////////////////////////////////////////
 
+var _closed_chan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
 func _new_nested_route_struct[O any](_router *NestedRouter, _pattern string) *NestedRoute[O] {
 	return &NestedRoute[O]{_router: _router, _pattern: _pattern}
 }
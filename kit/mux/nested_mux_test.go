@@ -0,0 +1,106 @@
+package mux
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sjc5/river/kit/tasks"
+)
+
+func TestRunNestedTasksWithDeferrals(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewNestedRouter(&NestedOptions{TasksRegistry: tasksRegistry})
+
+	release := make(chan struct{})
+
+	fast := TaskHandlerFromFunc(tasksRegistry, func(rd *NestedReqData) (string, error) {
+		return "fast", nil
+	})
+	slow := TaskHandlerFromFunc(tasksRegistry, func(rd *NestedReqData) (string, error) {
+		<-release
+		rd.ResponseProxy().SetStatus(500) // must be ignored
+		return "slow", nil
+	})
+
+	RegisterNestedTaskHandler(router, "/dashboard", fast)
+	slowRoute := RegisterNestedTaskHandler(router, "/dashboard/stats", slow)
+	SetNestedRouteDeferrable(slowRoute)
+
+	if !slowRoute.IsDeferrable() {
+		t.Fatal("expected route to be deferrable")
+	}
+
+	r := httptest.NewRequest("GET", "/dashboard/stats", nil)
+	matches, ok := FindNestedMatches(router, r)
+	if !ok {
+		t.Fatal("expected matches")
+	}
+
+	done := make(chan *NestedTasksResults)
+	go func() {
+		done <- RunNestedTasksWithDeferrals(router, tasksRegistry.NewCtxFromRequest(r), r, matches)
+	}()
+
+	var results *NestedTasksResults
+	select {
+	case results = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("RunNestedTasksWithDeferrals waited on a deferrable task")
+	}
+
+	if len(results.Slice) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results.Slice))
+	}
+
+	fastResult, slowResult := results.Slice[0], results.Slice[1]
+
+	if fastResult.IsDeferred() || fastResult.Data() != "fast" {
+		t.Errorf("expected resolved fast result, got deferred=%v data=%v", fastResult.IsDeferred(), fastResult.Data())
+	}
+	select {
+	case <-fastResult.Done():
+	default:
+		t.Error("expected non-deferred result to already be done")
+	}
+
+	if !slowResult.IsDeferred() {
+		t.Fatal("expected slow result to be deferred")
+	}
+	select {
+	case <-slowResult.Done():
+		t.Fatal("expected slow result to still be pending")
+	default:
+	}
+
+	close(release)
+	<-slowResult.Done()
+
+	if slowResult.Data() != "slow" || slowResult.Err() != nil {
+		t.Errorf("expected slow data, got %v (err: %v)", slowResult.Data(), slowResult.Err())
+	}
+	if status, _ := results.ResponseProxies[1].GetStatus(); status != 0 {
+		t.Errorf("expected deferred response proxy mutations to be ignored, got status %d", status)
+	}
+}
+
+func TestRunNestedTasksWaitsOnDeferrable(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewNestedRouter(&NestedOptions{TasksRegistry: tasksRegistry})
+
+	slow := TaskHandlerFromFunc(tasksRegistry, func(rd *NestedReqData) (string, error) {
+		time.Sleep(10 * time.Millisecond)
+		return "slow", nil
+	})
+	SetNestedRouteDeferrable(RegisterNestedTaskHandler(router, "/slow", slow))
+
+	r := httptest.NewRequest("GET", "/slow", nil)
+	results, ok := FindNestedMatchesAndRunTasks(router, tasksRegistry.NewCtxFromRequest(r), r)
+	if !ok {
+		t.Fatal("expected matches")
+	}
+
+	if results.Slice[0].IsDeferred() || results.Slice[0].Data() != "slow" {
+		t.Errorf("expected RunNestedTasks to wait on deferrable routes")
+	}
+}
@@ -0,0 +1,233 @@
+package mux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// STREAM HANDLERS
+/////////////////////////////////////////////////////////////////////
+
+const DefaultStreamHeartbeatInterval = 15 * time.Second
+
+// Sent as the final SSE event after a stream handler returns without error,
+// so that clients know not to reconnect.
+const StreamDoneEvent = "done"
+
+// Sent as the final SSE event (with a generic message) after a stream
+// handler returns an error.
+const StreamErrorEvent = "error"
+
+const (
+	_stream_format_sse    = "sse"
+	_stream_format_ndjson = "ndjson"
+)
+
+type StreamHandlerFunc[I any, O any] = func(rd *ReqData[I], emitter *StreamEmitter[O]) error
+
+// A StreamHandler pushes a sequence of O values to the client, either as
+// Server-Sent Events (the default) or, if the request's Accept header
+// prefers "application/x-ndjson", as newline-delimited JSON. Unlike task
+// handlers, stream handlers are not tasks: they run once per request and
+// their output is never cached. Task middlewares still run first, and a
+// stream only starts if none of them error or redirect.
+type StreamHandler[I any, O any] struct {
+	_fn StreamHandlerFunc[I, O]
+}
+
+func StreamHandlerFromFunc[I any, O any](streamHandlerFunc StreamHandlerFunc[I, O]) *StreamHandler[I, O] {
+	return &StreamHandler[I, O]{_fn: streamHandlerFunc}
+}
+
+func RegisterStreamHandler[I any, O any](
+	router *Router, method, pattern string, streamHandler *StreamHandler[I, O],
+) *Route[I, O] {
+	_route := _new_route_struct[I, O](router, method, pattern)
+	_route._handler_type = _handler_types._stream
+	_route._stream_handler = func(w http.ResponseWriter, r *http.Request, _rd _Req_Data_Marker) {
+		_serve_stream(router, w, r, _rd.(*ReqData[I]), streamHandler._fn)
+	}
+	_must_register_route(_route)
+	return _route
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// EMITTER
+/////////////////////////////////////////////////////////////////////
+
+// ErrStreamClosed is returned by StreamEmitter methods once the client has
+// disconnected or the handler's context is otherwise done.
+var ErrStreamClosed = errors.New("stream closed")
+
+type StreamEmitter[O any] struct {
+	_mu            sync.Mutex
+	_w             http.ResponseWriter
+	_rc            *http.ResponseController
+	_ctx           context.Context
+	_format        string
+	_last_event_id string
+	_closed        bool
+}
+
+// Context is done when the client disconnects. Long-running work should
+// select on it.
+func (e *StreamEmitter[O]) Context() context.Context { return e._ctx }
+
+// LastEventID is the ID of the last event the client received before
+// reconnecting, taken from the "Last-Event-ID" request header (sent
+// automatically by EventSource) or the "lastEventID" query param. Empty
+// on first connect. Use it to resume a stream where it left off.
+func (e *StreamEmitter[O]) LastEventID() string { return e._last_event_id }
+
+// Emit sends a value without an event ID.
+func (e *StreamEmitter[O]) Emit(v O) error {
+	return e.EmitWithID("", v)
+}
+
+// EmitWithID sends a value tagged with id, which the client will send back
+// as the last event ID if it reconnects. For NDJSON streams, the value is
+// sent as-is and id is ignored.
+func (e *StreamEmitter[O]) EmitWithID(id string, v O) error {
+	if strings.ContainsAny(id, "\r\n") {
+		return fmt.Errorf("mux.StreamEmitter: event ID must not contain newlines: %q", id)
+	}
+	_json_bytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	if e._format == _stream_format_ndjson {
+		sb.Write(_json_bytes)
+		sb.WriteString("\n")
+	} else {
+		if id != "" {
+			sb.WriteString("id: " + id + "\n")
+		}
+		sb.WriteString("data: ")
+		sb.Write(_json_bytes)
+		sb.WriteString("\n\n")
+	}
+	return e._write(sb.String())
+}
+
+func (e *StreamEmitter[O]) _heartbeat() error {
+	if e._format == _stream_format_ndjson {
+		// Blank lines are ignored by NDJSON readers
+		return e._write("\n")
+	}
+	return e._write(": heartbeat\n\n")
+}
+
+func (e *StreamEmitter[O]) _event(_name, _data string) error {
+	if e._format == _stream_format_ndjson {
+		return nil
+	}
+	return e._write("event: " + _name + "\ndata: " + _data + "\n\n")
+}
+
+func (e *StreamEmitter[O]) _write(s string) error {
+	e._mu.Lock()
+	defer e._mu.Unlock()
+	if e._closed || e._ctx.Err() != nil {
+		return ErrStreamClosed
+	}
+	if _, err := e._w.Write([]byte(s)); err != nil {
+		return err
+	}
+	return e._rc.Flush()
+}
+
+// Once closed, the emitter never touches the response writer again, even if
+// the handler leaked it to a goroutine that outlives the request.
+func (e *StreamEmitter[O]) _close() {
+	e._mu.Lock()
+	defer e._mu.Unlock()
+	e._closed = true
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// INTERNAL HELPERS
+/////////////////////////////////////////////////////////////////////
+
+func _get_stream_format(r *http.Request) string {
+	_accept := r.Header.Get("Accept")
+	if strings.Contains(_accept, "application/x-ndjson") && !strings.Contains(_accept, "text/event-stream") {
+		return _stream_format_ndjson
+	}
+	return _stream_format_sse
+}
+
+func _serve_stream[I any, O any](
+	_router *Router, w http.ResponseWriter, r *http.Request, _rd *ReqData[I], _fn StreamHandlerFunc[I, O],
+) {
+	_format := _get_stream_format(r)
+
+	_last_event_id := r.Header.Get("Last-Event-ID")
+	if _last_event_id == "" {
+		_last_event_id = r.URL.Query().Get("lastEventID")
+	}
+
+	_emitter := &StreamEmitter[O]{
+		_w:             w,
+		_rc:            http.NewResponseController(w),
+		_ctx:           _rd.TasksCtx().NativeContext(),
+		_format:        _format,
+		_last_event_id: _last_event_id,
+	}
+
+	if _format == _stream_format_ndjson {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := _emitter._rc.Flush(); err != nil {
+		Log.Error(fmt.Sprintf("streaming not supported by response writer: %v", err))
+		return
+	}
+
+	// The response writer must not be used after the handler returns, so
+	// wait for the heartbeat goroutine to exit before returning
+	_done := make(chan struct{})
+	_heartbeat_exited := make(chan struct{})
+	defer func() {
+		close(_done)
+		<-_heartbeat_exited
+		_emitter._close()
+	}()
+	go func() {
+		defer close(_heartbeat_exited)
+		_ticker := time.NewTicker(_router._stream_heartbeat_interval)
+		defer _ticker.Stop()
+		for {
+			select {
+			case <-_done:
+				return
+			case <-_emitter._ctx.Done():
+				return
+			case <-_ticker.C:
+				_emitter._heartbeat()
+			}
+		}
+	}()
+
+	if err := _fn(_rd, _emitter); err != nil {
+		if !errors.Is(err, ErrStreamClosed) && _emitter._ctx.Err() == nil {
+			Log.Error(fmt.Sprintf("stream handler error: %v", err))
+			_emitter._event(StreamErrorEvent, `"Internal Server Error"`)
+		}
+		return
+	}
+
+	_emitter._event(StreamDoneEvent, "null")
+}
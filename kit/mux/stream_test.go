@@ -0,0 +1,190 @@
+package mux
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sjc5/river/kit/tasks"
+)
+
+type progress struct {
+	Done int `json:"done"`
+}
+
+func newStreamTestServer(t *testing.T, fn StreamHandlerFunc[None, progress]) *httptest.Server {
+	t.Helper()
+	router := NewRouter(&Options{
+		TasksRegistry:           tasks.NewRegistry(),
+		StreamHeartbeatInterval: 10 * time.Millisecond,
+	})
+	RegisterStreamHandler(router, "GET", "/progress", StreamHandlerFromFunc(fn))
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStreamHandlerSSE(t *testing.T) {
+	server := newStreamTestServer(t, func(rd *ReqData[None], e *StreamEmitter[progress]) error {
+		start := 0
+		if id := e.LastEventID(); id != "" {
+			start, _ = strconv.Atoi(id)
+		}
+		for i := start + 1; i <= 3; i++ {
+			if err := e.EmitWithID(strconv.Itoa(i), progress{Done: i}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	req, _ := http.NewRequest("GET", server.URL+"/progress", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("expected text/event-stream, got %q", ct)
+	}
+
+	var body strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		body.WriteString(scanner.Text() + "\n")
+	}
+
+	expected := "id: 2\ndata: {\"done\":2}\n\nid: 3\ndata: {\"done\":3}\n\nevent: done\ndata: null\n\n"
+	if got := body.String(); got != expected {
+		t.Errorf("unexpected stream body:\n%q\nwant:\n%q", got, expected)
+	}
+}
+
+func TestStreamHandlerNDJSONAndHeartbeats(t *testing.T) {
+	server := newStreamTestServer(t, func(rd *ReqData[None], e *StreamEmitter[progress]) error {
+		e.Emit(progress{Done: 1})
+		time.Sleep(50 * time.Millisecond)
+		return e.Emit(progress{Done: 2})
+	})
+
+	req, _ := http.NewRequest("GET", server.URL+"/progress", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("expected application/x-ndjson, got %q", ct)
+	}
+
+	var values []string
+	blankLines := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			blankLines++
+			continue
+		}
+		values = append(values, scanner.Text())
+	}
+
+	if strings.Join(values, ",") != `{"done":1},{"done":2}` {
+		t.Errorf("unexpected values: %v", values)
+	}
+	if blankLines == 0 {
+		t.Error("expected heartbeats while idle")
+	}
+}
+
+func TestStreamHandlerClientDisconnect(t *testing.T) {
+	stopped := make(chan error, 1)
+	server := newStreamTestServer(t, func(rd *ReqData[None], e *StreamEmitter[progress]) error {
+		for i := 0; ; i++ {
+			if err := e.Emit(progress{Done: i}); err != nil {
+				stopped <- err
+				return err
+			}
+			select {
+			case <-rd.TasksCtx().NativeContext().Done():
+				stopped <- ErrStreamClosed
+				return nil
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	})
+
+	resp, err := http.Get(server.URL + "/progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bufio.NewReader(resp.Body).ReadString('\n')
+	resp.Body.Close()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected stream handler to stop after client disconnect")
+	}
+}
+
+// Fails the test if anything is written after the handler has returned
+type afterReturnWriter struct {
+	*httptest.ResponseRecorder
+	t        *testing.T
+	returned atomic.Bool
+}
+
+func (w *afterReturnWriter) Write(b []byte) (int, error) {
+	if w.returned.Load() {
+		w.t.Errorf("write after the handler returned: %q", b)
+	}
+	return w.ResponseRecorder.Write(b)
+}
+
+func (w *afterReturnWriter) Flush() {
+	if w.returned.Load() {
+		w.t.Error("flush after the handler returned")
+	}
+	w.ResponseRecorder.Flush()
+}
+
+func TestStreamHandlerNoWritesAfterReturn(t *testing.T) {
+	router := NewRouter(&Options{
+		TasksRegistry:           tasks.NewRegistry(),
+		StreamHeartbeatInterval: time.Millisecond,
+	})
+	var leaked atomic.Pointer[StreamEmitter[progress]]
+	RegisterStreamHandler(router, "GET", "/progress", StreamHandlerFromFunc(
+		func(rd *ReqData[None], e *StreamEmitter[progress]) error {
+			// Return right as a heartbeat is due
+			time.Sleep(time.Millisecond)
+			leaked.Store(e)
+			return e.Emit(progress{Done: 1})
+		},
+	))
+
+	for range 50 {
+		w := &afterReturnWriter{ResponseRecorder: httptest.NewRecorder(), t: t}
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/progress", nil))
+		w.returned.Store(true)
+
+		// Reading the body races with any heartbeat still being written
+		if !strings.Contains(w.Body.String(), "event: done") {
+			t.Fatalf("expected a done event, got %q", w.Body.String())
+		}
+		if err := leaked.Load().Emit(progress{Done: 2}); !errors.Is(err, ErrStreamClosed) {
+			t.Fatalf("expected ErrStreamClosed from a leaked emitter, got %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+}
@@ -0,0 +1,100 @@
+package mux
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sjc5/river/kit/matcher"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// URL BUILDING
+/////////////////////////////////////////////////////////////////////
+
+var ErrRouteNotFound = errors.New("route not found")
+
+// A URLBuilder generates URLs for a single registered pattern. Get one for a
+// route handle (Route.URLBuilder) or, by pattern, from the router it was
+// registered on (Router.MustURLBuilder, NestedRouter.MustURLBuilder). The
+// Must variants panic if the pattern is not registered, so calling them at
+// init time (after registration) surfaces broken links at startup.
+type URLBuilder struct {
+	_mount_root         string
+	_registered_pattern *matcher.RegisteredPattern
+}
+
+// Template returns the mount root joined with the normalized pattern, e.g.,
+// "/api/users/:id<int>".
+func (b *URLBuilder) Template() string {
+	return strings.TrimSuffix(b._mount_root, "/") + b._registered_pattern.NormalizedPattern()
+}
+
+// Build returns the URL path for the given params and splat values,
+// including the router's mount root. See matcher.RegisteredPattern.Build.
+func (b *URLBuilder) Build(params Params, splatValues ...string) (string, error) {
+	_path, err := b._registered_pattern.Build(params, splatValues)
+	if err != nil {
+		return "", err
+	}
+	if b._mount_root == "" {
+		return _path, nil
+	}
+	return strings.TrimSuffix(b._mount_root, "/") + _path, nil
+}
+
+// MustBuild is like Build, but panics on error.
+func (b *URLBuilder) MustBuild(params Params, splatValues ...string) string {
+	_url, err := b.Build(params, splatValues...)
+	if err != nil {
+		panic(err)
+	}
+	return _url
+}
+
+func (route *Route[I, O]) URLBuilder() *URLBuilder {
+	return &URLBuilder{_mount_root: route._router._mount_root, _registered_pattern: route._registered_pattern()}
+}
+
+// Returns a URLBuilder for the route registered with the given method and
+// pattern (exactly as passed at registration).
+func (rt *Router) URLBuilder(method, pattern string) (*URLBuilder, error) {
+	_method_matcher, ok := rt._method_to_matcher_map[method]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s", ErrRouteNotFound, method, pattern)
+	}
+	_route, ok := _method_matcher._routes[pattern]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s", ErrRouteNotFound, method, pattern)
+	}
+	return &URLBuilder{_mount_root: rt._mount_root, _registered_pattern: _method_matcher._matcher.NormalizePattern(_route.Pattern())}, nil
+}
+
+func (rt *Router) MustURLBuilder(method, pattern string) *URLBuilder {
+	_builder, err := rt.URLBuilder(method, pattern)
+	if err != nil {
+		panic(err)
+	}
+	return _builder
+}
+
+func (route *NestedRoute[O]) URLBuilder() *URLBuilder {
+	return &URLBuilder{_registered_pattern: route._router._matcher.NormalizePattern(route._pattern)}
+}
+
+// Returns a URLBuilder for the registered nested pattern (exactly as passed
+// at registration, including any explicit index segment).
+func (nr *NestedRouter) URLBuilder(pattern string) (*URLBuilder, error) {
+	if !nr.IsRegistered(pattern) {
+		return nil, fmt.Errorf("%w: %s", ErrRouteNotFound, pattern)
+	}
+	return &URLBuilder{_registered_pattern: nr._matcher.NormalizePattern(pattern)}, nil
+}
+
+func (nr *NestedRouter) MustURLBuilder(pattern string) *URLBuilder {
+	_builder, err := nr.URLBuilder(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return _builder
+}
@@ -0,0 +1,59 @@
+package mux
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sjc5/river/kit/matcher"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+func TestURLBuilder(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewRouter(&Options{TasksRegistry: tasksRegistry, MountRoot: "/api/", DynamicParamPrefixRune: '$'})
+	handler := TaskHandlerFromFunc(tasksRegistry, func(rd *ReqData[None]) (string, error) { return "", nil })
+	route := RegisterTaskHandler(router, "GET", "/users/$id<int>/files/*", handler)
+
+	got, err := route.URLBuilder().Build(Params{"id": "5"}, "a b", "c.txt")
+	if err != nil || got != "/api/users/5/files/a%20b/c.txt" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if tmpl := route.URLBuilder().Template(); tmpl != "/api/users/:id<int>/files/*" {
+		t.Errorf("unexpected template %q", tmpl)
+	}
+
+	builder := router.MustURLBuilder("GET", "/users/$id<int>/files/*")
+	if _, err := builder.Build(Params{"id": "abc"}); !errors.Is(err, matcher.ErrInvalidParam) {
+		t.Errorf("expected ErrInvalidParam, got %v", err)
+	}
+	if _, err := builder.Build(nil); !errors.Is(err, matcher.ErrMissingParam) {
+		t.Errorf("expected ErrMissingParam, got %v", err)
+	}
+	if _, err := router.URLBuilder("POST", "/users/$id<int>/files/*"); !errors.Is(err, ErrRouteNotFound) {
+		t.Errorf("expected ErrRouteNotFound, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected MustURLBuilder to panic for unregistered pattern")
+		}
+	}()
+	router.MustURLBuilder("GET", "/nope")
+}
+
+func TestNestedURLBuilder(t *testing.T) {
+	tasksRegistry := tasks.NewRegistry()
+	router := NewNestedRouter(&NestedOptions{TasksRegistry: tasksRegistry, ExplicitIndexSegment: "_index"})
+	RegisterNestedPatternWithoutHandler(router, "/dashboard/_index")
+	RegisterNestedPatternWithoutHandler(router, "/posts/:slug?")
+
+	if got := router.MustURLBuilder("/dashboard/_index").MustBuild(nil); got != "/dashboard/" {
+		t.Errorf("got %q", got)
+	}
+	if got := router.MustURLBuilder("/posts/:slug?").MustBuild(nil); got != "/posts" {
+		t.Errorf("got %q", got)
+	}
+	if got := router.MustURLBuilder("/posts/:slug?").MustBuild(Params{"slug": "hi"}); got != "/posts/hi" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,220 @@
+package mux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sjc5/river/kit/matcher"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// WEBSOCKET HANDLERS
+/////////////////////////////////////////////////////////////////////
+
+const DefaultWebSocketMaxMessageSize = 1 << 20
+
+// How many inbound messages are held for Receive. Once the buffer is full,
+// further inbound messages are dropped until the handler catches up.
+const WebSocketInboundBufferSize = 64
+
+type WebSocketHandlerFunc[I any, O any] = func(rd *ReqData[None], conn *WebSocketConn[I, O]) error
+
+// A WebSocketHandler exchanges JSON text messages with a client: I is the
+// type of inbound (client-to-server) messages, and O is the type of
+// outbound (server-to-client) messages. WebSocket routes are always GET
+// routes, and they go through the same HTTP and task middlewares, method
+// matching and param extraction as any other route before the connection
+// is upgraded. Router.MarshalInput is not used. When the handler returns,
+// the connection is closed (normally if it returned nil, or with an
+// internal error status otherwise).
+type WebSocketHandler[I any, O any] struct {
+	_fn WebSocketHandlerFunc[I, O]
+}
+
+func WebSocketHandlerFromFunc[I any, O any](webSocketHandlerFunc WebSocketHandlerFunc[I, O]) *WebSocketHandler[I, O] {
+	return &WebSocketHandler[I, O]{_fn: webSocketHandlerFunc}
+}
+
+func RegisterWebSocketHandler[I any, O any](
+	router *Router, pattern string, webSocketHandler *WebSocketHandler[I, O],
+) *Route[I, O] {
+	_route := _new_route_struct[I, O](router, http.MethodGet, pattern)
+	_route._handler_type = _handler_types._websocket
+	_route._stream_handler = func(w http.ResponseWriter, r *http.Request, _rd _Req_Data_Marker) {
+		_serve_websocket(router, w, r, _rd.(*ReqData[None]), webSocketHandler._fn)
+	}
+	_must_register_route(_route)
+	return _route
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// CONNECTION
+/////////////////////////////////////////////////////////////////////
+
+// ErrWebSocketClosed is returned by WebSocketConn methods once the
+// connection has been closed by either side.
+var ErrWebSocketClosed = errors.New("websocket closed")
+
+type WebSocketConn[I any, O any] struct {
+	_conn     *websocket.Conn
+	_write_mu sync.Mutex
+	_ctx      context.Context
+	_cancel   context.CancelFunc
+	_inbound  chan []byte
+
+	_read_err_mu sync.Mutex
+	_read_err    error
+}
+
+// Context is done when the connection is closed by either side.
+func (c *WebSocketConn[I, O]) Context() context.Context { return c._ctx }
+
+// Receive blocks until the next inbound message arrives and decodes it.
+// It returns ErrWebSocketClosed (possibly wrapped) once the connection is
+// closed.
+func (c *WebSocketConn[I, O]) Receive() (I, error) {
+	var _msg I
+	select {
+	case _data, ok := <-c._inbound:
+		if !ok {
+			return _msg, c._closed_err()
+		}
+		if err := json.Unmarshal(_data, &_msg); err != nil {
+			return _msg, fmt.Errorf("error decoding websocket message: %w", err)
+		}
+		return _msg, nil
+	case <-c._ctx.Done():
+		return _msg, c._closed_err()
+	}
+}
+
+// Send encodes and sends an outbound message. It is safe for concurrent use.
+func (c *WebSocketConn[I, O]) Send(msg O) error {
+	_json_bytes, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c._write(websocket.TextMessage, _json_bytes)
+}
+
+func (c *WebSocketConn[I, O]) _write(_message_type int, _data []byte) error {
+	c._write_mu.Lock()
+	defer c._write_mu.Unlock()
+	if c._ctx.Err() != nil {
+		return c._closed_err()
+	}
+	return c._conn.WriteMessage(_message_type, _data)
+}
+
+func (c *WebSocketConn[I, O]) _closed_err() error {
+	c._read_err_mu.Lock()
+	_read_err := c._read_err
+	c._read_err_mu.Unlock()
+	if _read_err != nil && !websocket.IsCloseError(_read_err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+		return fmt.Errorf("%w: %w", ErrWebSocketClosed, _read_err)
+	}
+	return ErrWebSocketClosed
+}
+
+// Reads until the connection fails, then cancels the context. Reading
+// continuously (rather than only inside Receive) is what lets handlers
+// notice disconnects while they are only sending, so a full inbound buffer
+// drops messages rather than blocking the loop.
+func (c *WebSocketConn[I, O]) _read_loop() {
+	defer c._cancel()
+	defer close(c._inbound)
+	for {
+		_message_type, _data, err := c._conn.ReadMessage()
+		if err != nil {
+			c._read_err_mu.Lock()
+			c._read_err = err
+			c._read_err_mu.Unlock()
+			return
+		}
+		if _message_type != websocket.TextMessage {
+			continue
+		}
+		select {
+		case c._inbound <- _data:
+		default:
+		}
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// INTERNAL HELPERS
+/////////////////////////////////////////////////////////////////////
+
+func _serve_websocket[I any, O any](
+	_router *Router, w http.ResponseWriter, r *http.Request, _rd *ReqData[None], _fn WebSocketHandlerFunc[I, O],
+) {
+	_upgrader := websocket.Upgrader{CheckOrigin: _router._websocket_check_origin}
+	_ws_conn, err := _upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		return
+	}
+	defer _ws_conn.Close()
+
+	_ping_interval := _router._stream_heartbeat_interval
+	_ws_conn.SetReadLimit(_router._websocket_max_message_size)
+	_ws_conn.SetReadDeadline(time.Now().Add(2 * _ping_interval))
+	_ws_conn.SetPongHandler(func(string) error {
+		return _ws_conn.SetReadDeadline(time.Now().Add(2 * _ping_interval))
+	})
+
+	_ctx, _cancel := context.WithCancel(_rd.TasksCtx().NativeContext())
+	defer _cancel()
+
+	_conn := &WebSocketConn[I, O]{
+		_conn:    _ws_conn,
+		_ctx:     _ctx,
+		_cancel:  _cancel,
+		_inbound: make(chan []byte, WebSocketInboundBufferSize),
+	}
+	go _conn._read_loop()
+
+	go func() {
+		_ticker := time.NewTicker(_ping_interval)
+		defer _ticker.Stop()
+		for {
+			select {
+			case <-_ctx.Done():
+				return
+			case <-_ticker.C:
+				if err := _conn._write(websocket.PingMessage, nil); err != nil {
+					_cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	_close_code, _close_text := websocket.CloseNormalClosure, ""
+	if err := _fn(_rd, _conn); err != nil && !errors.Is(err, ErrWebSocketClosed) {
+		Log.Error(fmt.Sprintf("websocket handler error: %v", err))
+		_close_code, _close_text = websocket.CloseInternalServerErr, "Internal Server Error"
+	}
+
+	_conn._write_mu.Lock()
+	_ws_conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(_close_code, _close_text),
+		time.Now().Add(time.Second),
+	)
+	_conn._write_mu.Unlock()
+}
+
+// WebSocket routes' type params describe messages, not request input, so
+// request data is built without running Router.MarshalInput.
+func _websocket_req_data_getter(_router *Router) _Req_Data_Getter_Impl[None] {
+	return func(r *http.Request, _match *matcher.BestMatch) (*ReqData[None], error) {
+		return _req_data_starter[None](_match, _router._tasks_registry, r), nil
+	}
+}
@@ -0,0 +1,171 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+type wsInbound struct {
+	Text string `json:"text"`
+}
+
+type wsOutbound struct {
+	Room string `json:"room"`
+	Echo string `json:"echo"`
+}
+
+func newWebSocketTestServer(t *testing.T, fn WebSocketHandlerFunc[wsInbound, wsOutbound]) string {
+	t.Helper()
+	router := NewRouter(&Options{
+		TasksRegistry:           tasks.NewRegistry(),
+		StreamHeartbeatInterval: 20 * time.Millisecond,
+	})
+	route := RegisterWebSocketHandler(router, "/rooms/:room", WebSocketHandlerFromFunc(fn))
+	SetPatternLevelHTTPMiddleware(route, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("token") != "secret" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	url := newWebSocketTestServer(t, func(rd *ReqData[None], conn *WebSocketConn[wsInbound, wsOutbound]) error {
+		for {
+			msg, err := conn.Receive()
+			if err != nil {
+				return err
+			}
+			if err := conn.Send(wsOutbound{Room: rd.Params()["room"], Echo: msg.Text}); err != nil {
+				return err
+			}
+		}
+	})
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"/rooms/a", nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected middleware to reject the upgrade, got %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"/rooms/a?token=secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for _, text := range []string{"hello", "world"} {
+		if err := conn.WriteJSON(wsInbound{Text: text}); err != nil {
+			t.Fatal(err)
+		}
+		var out wsOutbound
+		if err := conn.ReadJSON(&out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Room != "a" || out.Echo != text {
+			t.Errorf("unexpected message: %+v", out)
+		}
+	}
+}
+
+func TestWebSocketHandlerClose(t *testing.T) {
+	handlerDone := make(chan error, 1)
+	url := newWebSocketTestServer(t, func(rd *ReqData[None], conn *WebSocketConn[wsInbound, wsOutbound]) error {
+		<-conn.Context().Done()
+		_, err := conn.Receive()
+		handlerDone <- err
+		return err
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"/rooms/a?token=secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.Close()
+
+	select {
+	case err := <-handlerDone:
+		if err != ErrWebSocketClosed {
+			t.Errorf("expected ErrWebSocketClosed, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected handler context to be done after client closed")
+	}
+}
+
+func TestWebSocketHandlerSendOnly(t *testing.T) {
+	handlerDone := make(chan struct{})
+	url := newWebSocketTestServer(t, func(rd *ReqData[None], conn *WebSocketConn[wsInbound, wsOutbound]) error {
+		defer close(handlerDone)
+		<-conn.Context().Done()
+		return nil
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"/rooms/a?token=secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// The client answers pings while it reads
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// Never received, so these must not stop the server from reading
+	for range WebSocketInboundBufferSize + 10 {
+		if err := conn.WriteJSON(wsInbound{Text: "ignored"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Pongs keep being read, so the connection outlives the read deadline
+	// (two heartbeat intervals)
+	time.Sleep(100 * time.Millisecond)
+	select {
+	case <-handlerDone:
+		t.Fatal("expected the connection to stay open")
+	default:
+	}
+
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	select {
+	case <-handlerDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a send-only handler to notice the client closing")
+	}
+}
+
+func TestWebSocketHandlerServerClose(t *testing.T) {
+	url := newWebSocketTestServer(t, func(rd *ReqData[None], conn *WebSocketConn[wsInbound, wsOutbound]) error {
+		return conn.Send(wsOutbound{Echo: "bye"})
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"/rooms/a?token=secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var out wsOutbound
+	if err := conn.ReadJSON(&out); err != nil || out.Echo != "bye" {
+		t.Fatalf("expected message before close, got %+v, %v", out, err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("expected normal closure, got %v", err)
+	}
+}
@@ -0,0 +1,425 @@
+// Package openapi generates OpenAPI 3.1 documents from the routes registered
+// on a mux.Router. Paths come from route patterns, request schemas from each
+// route's input type (as query params for GET routes, and as a JSON or
+// multipart body otherwise), and response schemas from each route's output
+// type. Named Go struct types are emitted once under components/schemas and
+// referenced everywhere else.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/sjc5/river/kit/genericsutil"
+	"github.com/sjc5/river/kit/matcher"
+	"github.com/sjc5/river/kit/mux"
+)
+
+const Version = "3.1.0"
+
+/////////////////////////////////////////////////////////////////////
+/////// DOCUMENT
+/////////////////////////////////////////////////////////////////////
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components *Components          `json:"components,omitempty"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+type PathItem struct {
+	Get     *Operation `json:"get,omitempty"`
+	Put     *Operation `json:"put,omitempty"`
+	Post    *Operation `json:"post,omitempty"`
+	Delete  *Operation `json:"delete,omitempty"`
+	Options *Operation `json:"options,omitempty"`
+	Head    *Operation `json:"head,omitempty"`
+	Patch   *Operation `json:"patch,omitempty"`
+	Trace   *Operation `json:"trace,omitempty"`
+}
+
+type Operation struct {
+	OperationID string               `json:"operationId,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// A JSON Schema (2020-12, as used by OpenAPI 3.1). Only the keywords needed
+// to describe Go types are supported. The zero value is the empty schema,
+// which accepts any value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"` // string or []string
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// JSON returns the document as indented JSON.
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// YAML returns the document as YAML.
+func (d *Document) YAML() ([]byte, error) {
+	jsonBytes, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToYAML(jsonBytes)
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// GENERATION
+/////////////////////////////////////////////////////////////////////
+
+type Options struct {
+	Title       string // Optional. Defaults to "API".
+	Version     string // Optional. Defaults to "0.0.0".
+	Description string
+	Servers     []Server
+}
+
+// Generate builds a document from all routes currently registered on router.
+// Plain HTTP handler routes are included without request or response schemas
+// (their types are unknown). WebSocket routes are omitted, as OpenAPI cannot
+// describe them.
+func Generate(router *mux.Router, opts *Options) *Document {
+	if opts == nil {
+		opts = new(Options)
+	}
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       opts.Title,
+			Version:     opts.Version,
+			Description: opts.Description,
+		},
+		Servers: opts.Servers,
+		Paths:   make(map[string]*PathItem),
+	}
+	if doc.Info.Title == "" {
+		doc.Info.Title = "API"
+	}
+	if doc.Info.Version == "" {
+		doc.Info.Version = "0.0.0"
+	}
+
+	routes := router.AllRoutes()
+	// Sorted so that component names are assigned deterministically
+	slices.SortFunc(routes, func(a, b mux.AnyRoute) int {
+		if c := strings.Compare(a.NormalizedPattern(), b.NormalizedPattern()); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Method(), b.Method())
+	})
+
+	g := newSchemaGen()
+
+	for _, route := range routes {
+		if route.IsWebSocket() {
+			continue
+		}
+
+		paths, pathParams := toOpenAPIPaths(router.MountRoot(), route.NormalizedPattern(), route.ParamSpecs())
+
+		for i, path := range paths {
+			item := doc.Paths[path]
+			if item == nil {
+				item = new(PathItem)
+				doc.Paths[path] = item
+			}
+
+			op := &Operation{
+				OperationID: toOperationID(route.Method(), path),
+				Parameters:  slices.Clone(pathParams[i]),
+				Responses:   make(map[string]*Response),
+			}
+			addInput(g, op, route)
+			addOutput(g, op, route)
+
+			setOperation(item, route.Method(), op)
+		}
+	}
+
+	if len(g.components) > 0 {
+		doc.Components = &Components{Schemas: g.components}
+	}
+
+	return doc
+}
+
+func addInput(g *schemaGen, op *Operation, route mux.AnyRoute) {
+	inputType := typeOf(route.IPtr())
+	if inputType == nil || isNone(inputType) {
+		return
+	}
+
+	if route.Method() == http.MethodGet || route.Method() == http.MethodHead {
+		for _, f := range g.fields(derefType(inputType)) {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:     f.name,
+				In:       "query",
+				Required: f.required,
+				Schema:   g.schemaFor(f.typ),
+			})
+		}
+		return
+	}
+
+	contentType := "application/json"
+	if hasUploadFields(inputType) {
+		contentType = "multipart/form-data"
+	}
+	op.RequestBody = &RequestBody{
+		Required: true,
+		Content:  map[string]*MediaType{contentType: {Schema: g.schemaFor(inputType)}},
+	}
+}
+
+func addOutput(g *schemaGen, op *Operation, route mux.AnyRoute) {
+	outputType := typeOf(route.OPtr())
+	if outputType == nil {
+		op.Responses["default"] = &Response{Description: "Response"}
+		return
+	}
+
+	schema := g.schemaFor(outputType)
+
+	if route.IsStream() {
+		op.Responses["200"] = &Response{
+			Description: "Stream of events, each carrying one value matching the schema",
+			Content: map[string]*MediaType{
+				"text/event-stream":    {Schema: schema},
+				"application/x-ndjson": {Schema: schema},
+			},
+		}
+		return
+	}
+
+	op.Responses["200"] = &Response{
+		Description: "OK",
+		Content:     map[string]*MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+func setOperation(item *PathItem, method string, op *Operation) {
+	switch method {
+	case http.MethodGet:
+		item.Get = op
+	case http.MethodPut:
+		item.Put = op
+	case http.MethodPost:
+		item.Post = op
+	case http.MethodDelete:
+		item.Delete = op
+	case http.MethodOptions:
+		item.Options = op
+	case http.MethodHead:
+		item.Head = op
+	case http.MethodPatch:
+		item.Patch = op
+	case http.MethodTrace:
+		item.Trace = op
+	}
+}
+
+// Converts a normalized mux pattern (e.g., "/users/:id<int>/*") into an
+// OpenAPI path (e.g., "/users/{id}/{splat}"), along with the path params it
+// contains. A splat is described as a single "splat" param, though at runtime
+// it may span multiple segments. OpenAPI path params are always required, so
+// a pattern ending in an optional param yields a second path without it.
+func toOpenAPIPaths(mountRoot, normalizedPattern string, specs []matcher.ParamSpec) ([]string, [][]*Parameter) {
+	var params []*Parameter
+	segments := strings.Split(normalizedPattern, "/")
+	lastIsOptional := false
+	specIdx := 0
+
+	for i, seg := range segments {
+		switch {
+		case seg == "*":
+			segments[i] = "{splat}"
+			params = append(params, &Parameter{
+				Name:        "splat",
+				In:          "path",
+				Description: "Remaining path segments",
+				Required:    true,
+				Schema:      &Schema{Type: "string"},
+			})
+		case strings.HasPrefix(seg, ":") && specIdx < len(specs):
+			spec := specs[specIdx]
+			specIdx++
+			segments[i] = "{" + spec.Name + "}"
+			params = append(params, &Parameter{
+				Name:     spec.Name,
+				In:       "path",
+				Required: true,
+				Schema:   paramSchema(spec),
+			})
+			lastIsOptional = spec.Optional && i == len(segments)-1
+		}
+	}
+
+	prefix := strings.TrimSuffix(mountRoot, "/")
+	paths := []string{joinPath(prefix, segments)}
+	paramSets := [][]*Parameter{params}
+	if lastIsOptional {
+		paths = append(paths, joinPath(prefix, segments[:len(segments)-1]))
+		paramSets = append(paramSets, params[:len(params)-1])
+	}
+	return paths, paramSets
+}
+
+func joinPath(prefix string, segments []string) string {
+	path := prefix + strings.Join(segments, "/")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+func paramSchema(spec matcher.ParamSpec) *Schema {
+	switch spec.Constraint {
+	case "":
+		return &Schema{Type: "string"}
+	case matcher.ConstraintInt:
+		return &Schema{Type: "integer"}
+	case matcher.ConstraintUUID:
+		return &Schema{Type: "string", Format: "uuid"}
+	default:
+		return &Schema{Type: "string", Pattern: "^(?:" + spec.Constraint + ")$"}
+	}
+}
+
+// e.g., ("GET", "/users/{id}") -> "getUsersById"
+func toOperationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, "{") {
+			sb.WriteString("By")
+			seg = strings.Trim(seg, "{}")
+		}
+		upperNext := true
+		for _, r := range seg {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				upperNext = true
+				continue
+			}
+			if upperNext {
+				r = unicode.ToUpper(r)
+				upperNext = false
+			}
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func typeOf(ptr any) reflect.Type {
+	t := reflect.TypeOf(ptr)
+	if t == nil {
+		return nil
+	}
+	t = t.Elem()
+	if t.Kind() == reflect.Interface {
+		return nil
+	}
+	return t
+}
+
+func isNone(t reflect.Type) bool {
+	t = derefType(t)
+	return t == reflect.TypeFor[genericsutil.None]() || (t.Kind() == reflect.Struct && t.NumField() == 0)
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// HANDLER
+/////////////////////////////////////////////////////////////////////
+
+// Handler serves the document generated from router. The document is
+// generated on first request, so routes must all be registered by then. It is
+// served as YAML if the request path ends in ".yaml" or ".yml", or if the
+// "format" query param is "yaml", and as JSON otherwise.
+func Handler(router *mux.Router, opts *Options) http.Handler {
+	var once sync.Once
+	var jsonBytes, yamlBytes []byte
+	var err error
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			doc := Generate(router, opts)
+			if jsonBytes, err = doc.JSON(); err != nil {
+				return
+			}
+			yamlBytes, err = doc.YAML()
+		})
+		if err != nil {
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+
+		wantsYAML := strings.HasSuffix(r.URL.Path, ".yaml") ||
+			strings.HasSuffix(r.URL.Path, ".yml") ||
+			r.URL.Query().Get("format") == "yaml"
+
+		if wantsYAML {
+			w.Header().Set("Content-Type", "application/yaml")
+			w.Write(yamlBytes)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(bytes.TrimSpace(jsonBytes))
+	})
+}
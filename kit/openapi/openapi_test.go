@@ -0,0 +1,194 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/tasks"
+	"github.com/sjc5/river/kit/upload"
+)
+
+type User struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Email     *string   `json:"email"`
+	Tags      []string  `json:"tags,omitempty"`
+	Friends   []*User   `json:"friends,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	internal  string
+}
+
+type ListUsersInput struct {
+	Query string `json:"query"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+type CreateUserInput struct {
+	Name string `json:"name"`
+}
+
+type AvatarInput struct {
+	Avatar *upload.File `json:"avatar"`
+}
+
+func newTestRouter() *mux.Router {
+	reg := tasks.NewRegistry()
+	r := mux.NewRouter(&mux.Options{TasksRegistry: reg, MountRoot: "/api/", DynamicParamPrefixRune: '$'})
+
+	mux.RegisterTaskHandler(r, "GET", "/users", mux.TaskHandlerFromFunc(reg, func(rd *mux.ReqData[ListUsersInput]) ([]User, error) {
+		return nil, nil
+	}))
+	mux.RegisterTaskHandler(r, "GET", "/users/$id<int>", mux.TaskHandlerFromFunc(reg, func(rd *mux.ReqData[mux.None]) (*User, error) {
+		return nil, nil
+	}))
+	mux.RegisterTaskHandler(r, "POST", "/users", mux.TaskHandlerFromFunc(reg, func(rd *mux.ReqData[CreateUserInput]) (User, error) {
+		return User{}, nil
+	}))
+	mux.RegisterTaskHandler(r, "POST", "/users/$id/avatar", mux.TaskHandlerFromFunc(reg, func(rd *mux.ReqData[AvatarInput]) (bool, error) {
+		return true, nil
+	}))
+	mux.RegisterStreamHandler(r, "GET", "/events", mux.StreamHandlerFromFunc(func(rd *mux.ReqData[mux.None], e *mux.StreamEmitter[User]) error {
+		return nil
+	}))
+	mux.RegisterHandlerFunc(r, "GET", "/posts/$slug<[a-z-]+>?", func(w http.ResponseWriter, r *http.Request) {})
+	mux.RegisterHandlerFunc(r, "GET", "/files/*", func(w http.ResponseWriter, r *http.Request) {})
+	mux.RegisterWebSocketHandler(r, "/ws", mux.WebSocketHandlerFromFunc(func(rd *mux.ReqData[mux.None], c *mux.WebSocketConn[string, string]) error {
+		return nil
+	}))
+
+	return r
+}
+
+func TestGenerate(t *testing.T) {
+	doc := Generate(newTestRouter(), &Options{Title: "Test"})
+
+	if doc.OpenAPI != "3.1.0" || doc.Info.Title != "Test" || doc.Info.Version != "0.0.0" {
+		t.Errorf("unexpected header fields: %+v %+v", doc.OpenAPI, doc.Info)
+	}
+
+	for _, p := range []string{"/api/users", "/api/users/{id}", "/api/users/{id}/avatar", "/api/events", "/api/files/{splat}"} {
+		if doc.Paths[p] == nil {
+			t.Errorf("expected path %q", p)
+		}
+	}
+	if doc.Paths["/api/ws"] != nil {
+		t.Error("expected websocket route to be omitted")
+	}
+
+	list := doc.Paths["/api/users"].Get
+	if list.RequestBody != nil || len(list.Parameters) != 2 {
+		t.Fatalf("expected GET input as 2 query params, got %+v", list.Parameters)
+	}
+	if p := list.Parameters[0]; p.Name != "query" || p.In != "query" || !p.Required {
+		t.Errorf("unexpected query param: %+v", p)
+	}
+	if p := list.Parameters[1]; p.Name != "limit" || p.Required {
+		t.Errorf("expected omitempty field to be optional: %+v", p)
+	}
+	if s := list.Responses["200"].Content["application/json"].Schema; s.Type != "array" || s.Items.Ref != "#/components/schemas/User" {
+		t.Errorf("unexpected list response schema: %+v", s)
+	}
+
+	get := doc.Paths["/api/users/{id}"].Get
+	if get.OperationID != "getApiUsersById" {
+		t.Errorf("unexpected operation ID %q", get.OperationID)
+	}
+	if len(get.Parameters) != 1 || get.Parameters[0].In != "path" || get.Parameters[0].Name != "id" {
+		t.Errorf("expected single path param, got %+v", get.Parameters)
+	}
+	if get.Parameters[0].Schema.Type != "integer" {
+		t.Errorf("expected int constraint as integer schema, got %+v", get.Parameters[0].Schema)
+	}
+
+	if posts := doc.Paths["/api/posts/{slug}"]; posts == nil || posts.Get.Parameters[0].Schema.Pattern != "^(?:[a-z-]+)$" {
+		t.Errorf("expected regex constraint as pattern, got %+v", posts)
+	}
+	if posts := doc.Paths["/api/posts"]; posts == nil || len(posts.Get.Parameters) != 0 {
+		t.Errorf("expected optional param to yield a path without it, got %+v", posts)
+	}
+
+	create := doc.Paths["/api/users"].Post
+	if s := create.RequestBody.Content["application/json"].Schema; s.Ref != "#/components/schemas/CreateUserInput" {
+		t.Errorf("unexpected request body schema: %+v", s)
+	}
+
+	avatar := doc.Paths["/api/users/{id}/avatar"].Post
+	s := avatar.RequestBody.Content["multipart/form-data"]
+	if s == nil {
+		t.Fatal("expected multipart request body")
+	}
+	if f := doc.Components.Schemas["AvatarInput"].Properties["avatar"]; f.Format != "binary" {
+		t.Errorf("expected upload field as binary string, got %+v", f)
+	}
+
+	events := doc.Paths["/api/events"].Get
+	if events.Responses["200"].Content["text/event-stream"] == nil {
+		t.Error("expected event stream response")
+	}
+
+	files := doc.Paths["/api/files/{splat}"].Get
+	if files.Responses["default"] == nil || files.Parameters[0].Name != "splat" {
+		t.Errorf("unexpected http handler operation: %+v", files)
+	}
+
+	user := doc.Components.Schemas["User"]
+	if user == nil {
+		t.Fatal("expected User component")
+	}
+	if strings.Join(user.Required, ",") != "id,name,createdAt" {
+		t.Errorf("unexpected required fields: %v", user.Required)
+	}
+	if user.Properties["friends"].Items.Ref != "#/components/schemas/User" {
+		t.Error("expected self-reference via $ref")
+	}
+	if user.Properties["createdAt"].Format != "date-time" {
+		t.Error("expected time.Time as date-time")
+	}
+	if _, ok := user.Properties["internal"]; ok {
+		t.Error("expected unexported field to be skipped")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	h := Handler(newTestRouter(), nil)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.json", nil))
+	var doc map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil || doc["openapi"] != "3.1.0" {
+		t.Fatalf("expected JSON document, got %v %q", err, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/openapi.yaml", nil))
+	if rec.Header().Get("Content-Type") != "application/yaml" {
+		t.Errorf("unexpected content type %q", rec.Header().Get("Content-Type"))
+	}
+	if !strings.HasPrefix(rec.Body.String(), "openapi: \"3.1.0\"\n") {
+		t.Errorf("unexpected YAML:\n%s", rec.Body.String())
+	}
+}
+
+func TestJSONToYAML(t *testing.T) {
+	got, err := jsonToYAML([]byte(`{"b":1,"a":[{"x":"y","z":[]},"s"],"200":{},"/p/{id}":null,"e":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `b: 1
+a:
+  - x: "y"
+    z: []
+  - "s"
+"200": {}
+/p/{id}: null
+e: true
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
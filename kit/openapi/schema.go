@@ -0,0 +1,208 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/sjc5/river/kit/upload"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// SCHEMA GENERATION
+/////////////////////////////////////////////////////////////////////
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+	uploadFileType = reflect.TypeFor[upload.File]()
+)
+
+type schemaGen struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaGen() *schemaGen {
+	return &schemaGen{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// Returns the schema for t. Named struct types are added to components (once)
+// and referenced by $ref.
+func (g *schemaGen) schemaFor(t reflect.Type) *Schema {
+	t = derefType(t)
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	case uploadFileType:
+		return &Schema{Type: "string", Format: "binary"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return g.ref(t)
+	default:
+		// Interfaces (and anything else JSON can't describe) accept any value
+		return &Schema{}
+	}
+}
+
+func (g *schemaGen) ref(t reflect.Type) *Schema {
+	name, ok := g.names[t]
+	if !ok {
+		name = g.uniqueName(t)
+		g.names[t] = name
+		// Reserve the name before recursing, so self-referential types terminate
+		g.components[name] = nil
+		g.components[name] = g.structSchema(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (g *schemaGen) uniqueName(t reflect.Type) string {
+	name := sanitizeName(t.Name())
+	if _, taken := g.components[name]; !taken {
+		return name
+	}
+	name = sanitizeName(path.Base(t.PkgPath())) + "_" + name
+	if _, taken := g.components[name]; !taken {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s_%d", name, i)
+		if _, taken := g.components[candidate]; !taken {
+			return candidate
+		}
+	}
+}
+
+func (g *schemaGen) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range g.fields(t) {
+		s.Properties[f.name] = g.schemaFor(f.typ)
+		if f.required {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+	return s
+}
+
+type field struct {
+	name     string
+	typ      reflect.Type
+	required bool
+}
+
+// Returns the fields of struct type t as encoding/json would see them:
+// unexported and "-" fields are skipped, and untagged embedded structs are
+// flattened. Fields are required unless they are pointers or tagged with
+// omitempty or omitzero.
+func (g *schemaGen) fields(t reflect.Type) []field {
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []field
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if sf.Anonymous && name == "" && derefType(sf.Type).Kind() == reflect.Struct {
+			fields = append(fields, g.fields(derefType(sf.Type))...)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		optional := sf.Type.Kind() == reflect.Pointer
+		for o := range strings.SplitSeq(opts, ",") {
+			if o == "omitempty" || o == "omitzero" {
+				optional = true
+			}
+		}
+
+		fields = append(fields, field{name: name, typ: sf.Type, required: !optional})
+	}
+	return fields
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Reports whether t (a struct or pointer to one) has any upload.File fields,
+// directly or in slices.
+func hasUploadFields(t reflect.Type) bool {
+	t = derefType(t)
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := range t.NumField() {
+		ft := derefType(t.Field(i).Type)
+		if ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array {
+			ft = derefType(ft.Elem())
+		}
+		if ft == uploadFileType {
+			return true
+		}
+		if t.Field(i).Anonymous && hasUploadFields(ft) {
+			return true
+		}
+	}
+	return false
+}
+
+// Generic type names (e.g., "Page[example.com/pkg.User]") are reduced to
+// characters that are valid in component names.
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,158 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// YAML
+/////////////////////////////////////////////////////////////////////
+
+// A minimal JSON-to-YAML converter, sufficient for OpenAPI documents. Object
+// key order is preserved, and all string values are double-quoted (using JSON
+// escapes, which are valid YAML) so that no value is misread as another type.
+
+type yamlKV struct {
+	key   string
+	value any
+}
+
+type yamlObject []yamlKV
+
+func jsonToYAML(jsonBytes []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(jsonBytes))
+	dec.UseNumber()
+	v, err := decodeOrdered(dec)
+	if err != nil {
+		return nil, fmt.Errorf("openapi: error converting JSON to YAML: %w", err)
+	}
+	var buf bytes.Buffer
+	writeYAML(&buf, v, 0)
+	return buf.Bytes(), nil
+}
+
+func decodeOrdered(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := yamlObject{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj = append(obj, yamlKV{key: keyTok.(string), value: value})
+		}
+		_, err := dec.Token() // '}'
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+		_, err := dec.Token() // ']'
+		return arr, err
+	default:
+		return tok, nil
+	}
+}
+
+func writeYAML(buf *bytes.Buffer, v any, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case yamlObject:
+		for _, kv := range v {
+			buf.WriteString(pad + yamlKey(kv.key) + ":")
+			writeYAMLChild(buf, kv.value, indent)
+		}
+	case []any:
+		for _, item := range v {
+			buf.WriteString(pad + "-")
+			if obj, ok := item.(yamlObject); ok && len(obj) > 0 {
+				// Inline the first key after the dash
+				buf.WriteString(" " + yamlKey(obj[0].key) + ":")
+				writeYAMLChild(buf, obj[0].value, indent+1)
+				writeYAML(buf, obj[1:], indent+1)
+				continue
+			}
+			writeYAMLChild(buf, item, indent)
+		}
+	}
+}
+
+// Writes the value following a "key:" or "-", either inline (scalars and
+// empty collections) or on the following lines.
+func writeYAMLChild(buf *bytes.Buffer, v any, indent int) {
+	switch v := v.(type) {
+	case yamlObject:
+		if len(v) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, v, indent+1)
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, v, indent+1)
+	default:
+		buf.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// Keys are left unquoted when they can't be misread (e.g., "paths" or
+// "/users/{id}"), and quoted otherwise (e.g., "200").
+func yamlKey(key string) string {
+	if key == "" || !(isASCIILetter(key[0]) || key[0] == '/' || key[0] == '_' || key[0] == '$') {
+		return yamlScalar(key)
+	}
+	for i := range len(key) {
+		c := key[i]
+		if !isASCIILetter(c) && !(c >= '0' && c <= '9') && !strings.ContainsRune("_-./${}", rune(c)) {
+			return yamlScalar(key)
+		}
+	}
+	switch strings.ToLower(key) {
+	case "true", "false", "null", "yes", "no", "on", "off", "y", "n":
+		return yamlScalar(key)
+	}
+	return key
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
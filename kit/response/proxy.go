@@ -62,6 +62,11 @@ func (p *Proxy) GetHeaders(key string) []string {
 	return p._headers[key]
 }
 
+// Returns a copy of all headers set on the proxy.
+func (p *Proxy) GetAllHeaders() http.Header {
+	return http.Header(p._headers).Clone()
+}
+
 /////// COOKIES
 
 func (p *Proxy) SetCookie(cookie *http.Cookie) {
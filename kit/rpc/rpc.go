@@ -108,8 +108,11 @@ type CategorySpecificOptions struct {
 	KeyUnionTypeName     string
 	InputUnionTypeName   string
 	OutputUnionTypeName  string
-	SkipInput            bool
-	SkipOutput           bool
+	// Optional. If set, a type by this name resolving each key's
+	// "phantomParamsType" is emitted.
+	ParamsUnionTypeName string
+	SkipInput           bool
+	SkipOutput          bool
 }
 
 func BuildFromCategories(categories []CategorySpecificOptions) string {
@@ -138,6 +141,14 @@ func BuildFromCategories(categories []CategorySpecificOptions) string {
 			extraTSBuilder.WriteString("\n")
 		}
 
+		// PARAMS
+		if c.ParamsUnionTypeName != "" {
+			if err := paramsTmpl.Execute(&extraTSBuilder, c); err != nil {
+				panic(err)
+			}
+			extraTSBuilder.WriteString("\n")
+		}
+
 		if i < len(categories)-1 {
 			extraTSBuilder.WriteString("\n")
 		}
@@ -154,6 +165,7 @@ var (
 	baseTmpl   = template.Must(template.New("extraTS_1").Parse(baseTmplStr))
 	inputTmpl  = template.Must(template.New("extraTS_2").Parse(inputTmplStr))
 	outputTmpl = template.Must(template.New("extraTS_3").Parse(outputTmplStr))
+	paramsTmpl = template.Must(template.New("extraTS_4").Parse(paramsTmplStr))
 )
 
 const (
@@ -164,4 +176,6 @@ export type {{ .KeyUnionTypeName }} = {{ .ItemTypeNameSingular }}["{{ .Discrimin
 	inputTmplStr = `export type {{ .InputUnionTypeName }}<T extends {{ .KeyUnionTypeName }}> = Extract<{{ .ItemTypeNameSingular }}, { {{ .DiscriminatorStr }}: T }>["phantomInputType"];`
 
 	outputTmplStr = `export type {{ .OutputUnionTypeName }}<T extends {{ .KeyUnionTypeName }}> = Extract<{{ .ItemTypeNameSingular }}, { {{ .DiscriminatorStr }}: T }>["phantomOutputType"];`
+
+	paramsTmplStr = `export type {{ .ParamsUnionTypeName }}<T extends {{ .KeyUnionTypeName }}> = Extract<{{ .ItemTypeNameSingular }}, { {{ .DiscriminatorStr }}: T }>["phantomParamsType"];`
 )
@@ -0,0 +1,422 @@
+// Package session provides typed, signed-cookie-backed sessions, either
+// stored entirely in an encrypted cookie or server-side (with only a signed
+// session ID in the cookie), with idle and absolute timeouts, sliding
+// renewal, ID rotation and per-session CSRF tokens.
+//
+// Clients without a session get a new one in memory only. It is persisted
+// (and its cookie set) only once it is saved, so anonymous requests (e.g.,
+// from crawlers) neither write to the store nor set cookies.
+//
+// With a mux.Router, attach the manager's task middleware and read the
+// session from any task handler in the same request:
+//
+//	sessions, _ := session.NewManager(session.Opts[User]{
+//		CookieManager: cookieManager,
+//		Store:         session.NewMemoryStore(100_000),
+//		TasksRegistry: tasksRegistry,
+//	})
+//	mux.SetGlobalTaskMiddleware(router, sessions.TaskMiddleware())
+//	mux.SetGlobalHTTPMiddleware(router, csrftoken.NewMiddleware(csrftoken.Opts{
+//		GetExpectedCSRFToken:  sessions.GetExpectedCSRFToken,
+//		GetSubmittedCSRFToken: getSubmittedToken,
+//	}))
+//
+//	// In a handler
+//	s, err := sessions.Get(rd.TasksCtx())
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sjc5/river/kit/id"
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/signedcookie"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+const (
+	idLen        = 32
+	csrfTokenLen = 32
+)
+
+type Opts[T any] struct {
+	// Required.
+	CookieManager *signedcookie.Manager
+
+	// Required. The registry of the router(s) the task middleware is
+	// attached to.
+	TasksRegistry *tasks.Registry
+
+	// Optional. Server-side store for session data. If nil, sessions are
+	// stored entirely in an encrypted cookie, so T should be small (cookies
+	// are limited to about 4KB).
+	Store Store
+
+	// Optional. Defaults to "session". The Name, HttpOnly, Secure and
+	// Expires fields are ignored. Path defaults to "/" and SameSite to Lax.
+	BaseCookie signedcookie.BaseCookie
+
+	// Optional. Sessions unused for this long expire. Defaults to 24 hours.
+	IdleTimeout time.Duration
+
+	// Optional. Sessions expire this long after creation, however active.
+	// Defaults to 30 days.
+	AbsoluteTimeout time.Duration
+
+	// Optional. Active sessions are renewed (pushing back their idle
+	// expiry) at most this often. Defaults to a quarter of IdleTimeout.
+	RenewInterval time.Duration
+}
+
+type Manager[T any] struct {
+	opts     Opts[T]
+	loadTask *tasks.RegisteredTask[mux.None, *loaded[T]]
+	taskMw   *mux.TaskMiddleware[*Session[T]]
+	now      func() time.Time
+}
+
+var (
+	ErrCookieTooLarge = errors.New("session: encoded cookie exceeds 4096 bytes")
+	errDestroyed      = errors.New("session: cannot save a destroyed session")
+)
+
+func NewManager[T any](opts Opts[T]) (*Manager[T], error) {
+	if opts.CookieManager == nil {
+		return nil, errors.New("session: CookieManager is required")
+	}
+	if opts.TasksRegistry == nil {
+		return nil, errors.New("session: TasksRegistry is required")
+	}
+	if opts.BaseCookie.Name == "" {
+		opts.BaseCookie.Name = "session"
+	}
+	if opts.BaseCookie.Path == "" {
+		opts.BaseCookie.Path = "/"
+	}
+	if opts.BaseCookie.SameSite == 0 {
+		opts.BaseCookie.SameSite = http.SameSiteLaxMode
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = 24 * time.Hour
+	}
+	if opts.AbsoluteTimeout <= 0 {
+		opts.AbsoluteTimeout = 30 * 24 * time.Hour
+	}
+	if opts.RenewInterval <= 0 {
+		opts.RenewInterval = opts.IdleTimeout / 4
+	}
+
+	m := &Manager[T]{opts: opts, now: time.Now}
+
+	m.loadTask = tasks.Register(opts.TasksRegistry, func(c *tasks.ArgNoInput) (*loaded[T], error) {
+		return m.load(c.NativeContext(), c.Request())
+	})
+	tasks.SetName(m.loadTask, "session.load")
+
+	m.taskMw = mux.TaskMiddlewareFromFunc(opts.TasksRegistry, func(rd *mux.ReqData[mux.None]) (*Session[T], error) {
+		l, err := m.loadTask.GetNoInput(rd.TasksCtx())
+		if err != nil {
+			return nil, err
+		}
+		if l.cookie != nil {
+			rd.ResponseProxy().SetCookie(l.cookie)
+		}
+		return l.session, nil
+	})
+	tasks.SetName(m.taskMw, "session.TaskMiddleware")
+
+	return m, nil
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// SESSIONS
+/////////////////////////////////////////////////////////////////////
+
+// Session is a single client's session. Changes to Data are only persisted
+// by Manager.Save (or Manager.RotateID), which is also what persists a new
+// session in the first place. Sessions are not safe for concurrent
+// mutation.
+type Session[T any] struct {
+	Data T
+
+	rec       record[T]
+	isNew     bool
+	destroyed bool
+}
+
+// The persisted form of a session, in the store or the cookie.
+type record[T any] struct {
+	ID         string
+	CSRFToken  string
+	Data       T
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
+
+func (s *Session[T]) ID() string           { return s.rec.ID }
+func (s *Session[T]) CreatedAt() time.Time { return s.rec.CreatedAt }
+
+// Returns the session's CSRF token. A new session's token only outlives the
+// current request once the session is saved, so save new sessions before
+// handing out their tokens (e.g., when rendering a form).
+func (s *Session[T]) CSRFToken() string { return s.rec.CSRFToken }
+
+// Reports whether the session was created during the current request
+// (because the client had none, or its previous one expired).
+func (s *Session[T]) IsNew() bool { return s.isNew }
+
+// Anything cookies can be set on, such as a *response.Proxy (e.g.,
+// rd.ResponseProxy() in a task handler) or, via ResponseWriterCookieSetter,
+// an http.ResponseWriter.
+type CookieSetter interface {
+	SetCookie(cookie *http.Cookie)
+}
+
+type responseWriterCookieSetter struct{ w http.ResponseWriter }
+
+func (s responseWriterCookieSetter) SetCookie(cookie *http.Cookie) { http.SetCookie(s.w, cookie) }
+
+func ResponseWriterCookieSetter(w http.ResponseWriter) CookieSetter {
+	return responseWriterCookieSetter{w: w}
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// MUX INTEGRATION
+/////////////////////////////////////////////////////////////////////
+
+// Returns a task middleware that loads the request's session (creating an
+// unsaved one if needed), setting the session cookie when it is renewed.
+func (m *Manager[T]) TaskMiddleware() *mux.TaskMiddleware[*Session[T]] {
+	return m.taskMw
+}
+
+// Returns the session for the TasksCtx's request, loading it at most once
+// per request. Only the task middleware sets the cookie for renewed
+// sessions, so it should be attached to any route that uses sessions.
+func (m *Manager[T]) Get(c *tasks.TasksCtx) (*Session[T], error) {
+	l, err := m.loadTask.GetNoInput(c)
+	if err != nil {
+		return nil, err
+	}
+	return l.session, nil
+}
+
+// Returns the request's session CSRF token. It matches
+// csrftoken.GetExpectedCSRFToken, and so can be passed directly as
+// csrftoken.Opts.GetExpectedCSRFToken. Returns an empty string if the
+// session can't be loaded.
+func (m *Manager[T]) GetExpectedCSRFToken(r *http.Request) string {
+	c := mux.GetTasksCtx(r)
+	if c == nil {
+		c = m.opts.TasksRegistry.NewCtxFromRequest(r)
+	}
+	s, err := m.Get(c)
+	if err != nil {
+		return ""
+	}
+	return s.CSRFToken()
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// LIFECYCLE
+/////////////////////////////////////////////////////////////////////
+
+type loaded[T any] struct {
+	session *Session[T]
+	cookie  *http.Cookie // to set, if any
+}
+
+func (m *Manager[T]) load(ctx context.Context, r *http.Request) (*loaded[T], error) {
+	now := m.now()
+
+	rec, found, err := m.read(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if found && m.isExpired(rec, now) {
+		if m.opts.Store != nil {
+			if err := m.opts.Store.Delete(ctx, rec.ID); err != nil {
+				return nil, err
+			}
+		}
+		found = false
+	}
+
+	s := &Session[T]{}
+	if !found {
+		// Not persisted until saved
+		if s.rec, err = newRecord[T](now); err != nil {
+			return nil, err
+		}
+		s.isNew = true
+		return &loaded[T]{session: s}, nil
+	}
+
+	s.rec, s.Data = rec, rec.Data
+	if now.Sub(rec.LastSeenAt) < m.opts.RenewInterval {
+		return &loaded[T]{session: s}, nil
+	}
+
+	// Due for renewal
+	s.rec.LastSeenAt = now
+	cookie, err := m.write(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	return &loaded[T]{session: s, cookie: cookie}, nil
+}
+
+// Persists the session's data (and pushes back its idle expiry), setting
+// its cookie. New sessions are only persisted once saved.
+func (m *Manager[T]) Save(ctx context.Context, cs CookieSetter, s *Session[T]) error {
+	if s.destroyed {
+		return errDestroyed
+	}
+	s.rec.LastSeenAt = m.now()
+	cookie, err := m.write(ctx, s)
+	if err != nil {
+		return err
+	}
+	cs.SetCookie(cookie)
+	return nil
+}
+
+// Gives the session a new ID and CSRF token, keeping its data, and saves
+// it. Call it whenever the session's privileges change (e.g., on login,
+// logout or sudo), to prevent session fixation.
+func (m *Manager[T]) RotateID(ctx context.Context, cs CookieSetter, s *Session[T]) error {
+	if s.destroyed {
+		return errDestroyed
+	}
+	oldID := s.rec.ID
+	fresh, err := newRecord[T](m.now())
+	if err != nil {
+		return err
+	}
+	s.rec.ID, s.rec.CSRFToken = fresh.ID, fresh.CSRFToken
+	if err := m.Save(ctx, cs, s); err != nil {
+		return err
+	}
+	if m.opts.Store != nil {
+		return m.opts.Store.Delete(ctx, oldID)
+	}
+	return nil
+}
+
+// Deletes the session and its cookie.
+func (m *Manager[T]) Destroy(ctx context.Context, cs CookieSetter, s *Session[T]) error {
+	if m.opts.Store != nil {
+		if err := m.opts.Store.Delete(ctx, s.rec.ID); err != nil {
+			return err
+		}
+	}
+	s.destroyed = true
+	cs.SetCookie(m.opts.CookieManager.NewDeletionCookie(m.opts.BaseCookie))
+	return nil
+}
+
+func newRecord[T any](now time.Time) (record[T], error) {
+	sessionID, err := id.New(idLen)
+	if err != nil {
+		return record[T]{}, err
+	}
+	csrfToken, err := id.New(csrfTokenLen)
+	if err != nil {
+		return record[T]{}, err
+	}
+	return record[T]{ID: sessionID, CSRFToken: csrfToken, CreatedAt: now, LastSeenAt: now}, nil
+}
+
+func (m *Manager[T]) isExpired(rec record[T], now time.Time) bool {
+	return !now.Before(m.expiresAt(rec))
+}
+
+func (m *Manager[T]) expiresAt(rec record[T]) time.Time {
+	idle := rec.LastSeenAt.Add(m.opts.IdleTimeout)
+	absolute := rec.CreatedAt.Add(m.opts.AbsoluteTimeout)
+	if idle.Before(absolute) {
+		return idle
+	}
+	return absolute
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// COOKIES
+/////////////////////////////////////////////////////////////////////
+
+// Reads the request's session record. Missing, tampered or undecodable
+// cookies (e.g., after a change to T) and missing store entries are all
+// treated as no session.
+func (m *Manager[T]) read(ctx context.Context, r *http.Request) (record[T], bool, error) {
+	if m.opts.Store == nil {
+		rec, err := m.recordCookie(0).VerifyAndReadCookieValue(r)
+		return rec, err == nil, nil
+	}
+
+	sessionID, err := m.idCookie(0).VerifyAndReadCookieValue(r)
+	if err != nil {
+		return record[T]{}, false, nil
+	}
+	data, found, err := m.opts.Store.Get(ctx, sessionID)
+	if err != nil || !found {
+		return record[T]{}, false, err
+	}
+	rec, err := decodeRecord[T](data)
+	if err != nil || rec.ID != sessionID {
+		return record[T]{}, false, nil
+	}
+	return rec, true, nil
+}
+
+// Persists the session, returning the cookie to set.
+func (m *Manager[T]) write(ctx context.Context, s *Session[T]) (*http.Cookie, error) {
+	s.rec.Data = s.Data
+	expiresAt := m.expiresAt(s.rec)
+	ttl := expiresAt.Sub(m.now())
+
+	if m.opts.Store == nil {
+		cookie, err := m.recordCookie(ttl).NewSignedCookie(s.rec, nil)
+		if err != nil {
+			return nil, fmt.Errorf("session: error encoding cookie: %w", err)
+		}
+		if len(cookie.String()) > 4096 {
+			return nil, ErrCookieTooLarge
+		}
+		return cookie, nil
+	}
+
+	data, err := encodeRecord(s.rec)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.opts.Store.Set(ctx, s.rec.ID, data, expiresAt); err != nil {
+		return nil, err
+	}
+	cookie, err := m.idCookie(ttl).NewSignedCookie(s.rec.ID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("session: error encoding cookie: %w", err)
+	}
+	return cookie, nil
+}
+
+func (m *Manager[T]) recordCookie(ttl time.Duration) *signedcookie.SignedCookie[record[T]] {
+	return &signedcookie.SignedCookie[record[T]]{
+		Manager:    m.opts.CookieManager,
+		TTL:        ttl,
+		BaseCookie: m.opts.BaseCookie,
+		Encrypt:    true,
+	}
+}
+
+func (m *Manager[T]) idCookie(ttl time.Duration) *signedcookie.SignedCookie[string] {
+	return &signedcookie.SignedCookie[string]{
+		Manager:    m.opts.CookieManager,
+		TTL:        ttl,
+		BaseCookie: m.opts.BaseCookie,
+	}
+}
@@ -0,0 +1,275 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sjc5/river/kit/bytesutil"
+	"github.com/sjc5/river/kit/middleware/csrftoken"
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/signedcookie"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+type testUser struct {
+	UserID string
+	Admin  bool
+}
+
+type testClock struct{ now time.Time }
+
+func (c *testClock) Now() time.Time { return c.now }
+
+func newTestManager(t *testing.T, store Store) (*Manager[testUser], *testClock) {
+	t.Helper()
+	secret, _ := bytesutil.Random(signedcookie.SecretSize)
+	cookieManager, err := signedcookie.NewManager(signedcookie.Secrets{bytesutil.ToBase64(secret)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewManager(Opts[testUser]{
+		CookieManager:   cookieManager,
+		TasksRegistry:   tasks.NewRegistry(),
+		Store:           store,
+		IdleTimeout:     time.Hour,
+		AbsoluteTimeout: 10 * time.Hour,
+		RenewInterval:   10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &testClock{now: time.Now()}
+	m.now = clock.Now
+	return m, clock
+}
+
+// Loads the session for a request carrying cookies, returning any cookie to
+// set in response.
+func loadWith(t *testing.T, m *Manager[testUser], cookies ...*http.Cookie) (*Session[testUser], *http.Cookie) {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, c := range cookies {
+		if c != nil {
+			r.AddCookie(c)
+		}
+	}
+	l, err := m.load(context.Background(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l.session, l.cookie
+}
+
+type cookieJar struct{ cookie *http.Cookie }
+
+func (j *cookieJar) SetCookie(cookie *http.Cookie) { j.cookie = cookie }
+
+func TestLifecycle(t *testing.T) {
+	for _, mode := range []string{"server", "cookie"} {
+		t.Run(mode, func(t *testing.T) {
+			var store Store
+			if mode == "server" {
+				memoryStore := NewMemoryStore(100)
+				defer memoryStore.Close()
+				store = memoryStore
+			}
+			m, clock := newTestManager(t, store)
+			ctx := context.Background()
+
+			// New sessions live in memory until saved
+			s, cookie := loadWith(t, m)
+			if !s.IsNew() || cookie != nil || s.ID() == "" || s.CSRFToken() == "" {
+				t.Fatalf("expected a new session without a cookie, got %+v %v", s, cookie)
+			}
+			if mode == "server" {
+				if _, found, _ := store.Get(ctx, s.ID()); found {
+					t.Error("expected a new session not to be stored before it is saved")
+				}
+			}
+
+			jar := &cookieJar{}
+			s.Data = testUser{UserID: "u1"}
+			if err := m.Save(ctx, jar, s); err != nil {
+				t.Fatal(err)
+			}
+			if c := jar.cookie; c == nil || !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteLaxMode || c.Path != "/" {
+				t.Errorf("unexpected cookie %+v", c)
+			}
+
+			// Loaded from the cookie, without renewal
+			clock.now = clock.now.Add(time.Minute)
+			loaded, renewal := loadWith(t, m, jar.cookie)
+			if loaded.IsNew() || loaded.ID() != s.ID() || loaded.Data.UserID != "u1" || loaded.CSRFToken() != s.CSRFToken() {
+				t.Fatalf("expected the saved session, got %+v", loaded)
+			}
+			if renewal != nil {
+				t.Error("expected no renewal before RenewInterval")
+			}
+
+			// Sliding renewal keeps an active session alive past its
+			// original idle expiry
+			clock.now = clock.now.Add(50 * time.Minute)
+			loaded, renewal = loadWith(t, m, jar.cookie)
+			if loaded.IsNew() || renewal == nil {
+				t.Fatalf("expected a renewed session, got %+v %v", loaded, renewal)
+			}
+			clock.now = clock.now.Add(50 * time.Minute)
+			if loaded, _ = loadWith(t, m, renewal); loaded.IsNew() {
+				t.Error("expected the renewed session to still be valid")
+			}
+
+			// Rotation keeps data but changes the ID and CSRF token
+			oldID, oldToken := loaded.ID(), loaded.CSRFToken()
+			if err := m.RotateID(ctx, jar, loaded); err != nil {
+				t.Fatal(err)
+			}
+			if loaded.ID() == oldID || loaded.CSRFToken() == oldToken || loaded.Data.UserID != "u1" {
+				t.Errorf("unexpected rotated session %+v", loaded)
+			}
+			if mode == "server" {
+				if _, found, _ := store.Get(ctx, oldID); found {
+					t.Error("expected the old session ID to be deleted")
+				}
+			}
+			rotatedCookie := jar.cookie
+
+			// Idle timeout
+			clock.now = clock.now.Add(2 * time.Hour)
+			if loaded, _ := loadWith(t, m, rotatedCookie); !loaded.IsNew() {
+				t.Error("expected idle session to expire")
+			}
+
+			if err := m.Destroy(ctx, jar, loaded); err != nil {
+				t.Fatal(err)
+			}
+			if jar.cookie.MaxAge != -1 || m.Save(ctx, jar, loaded) == nil {
+				t.Error("expected deletion cookie and no saving of destroyed sessions")
+			}
+		})
+	}
+}
+
+func TestAbsoluteTimeout(t *testing.T) {
+	store := NewMemoryStore(100)
+	defer store.Close()
+	m, clock := newTestManager(t, store)
+
+	s, _ := loadWith(t, m)
+	jar := &cookieJar{}
+	if err := m.Save(context.Background(), jar, s); err != nil {
+		t.Fatal(err)
+	}
+	cookie := jar.cookie
+	for range 11 {
+		clock.now = clock.now.Add(55 * time.Minute)
+		next, renewal := loadWith(t, m, cookie)
+		if renewal != nil {
+			cookie = renewal
+		}
+		if next.IsNew() {
+			if clock.now.Sub(s.CreatedAt()) < 10*time.Hour {
+				t.Fatalf("session expired early, after %v", clock.now.Sub(s.CreatedAt()))
+			}
+			return
+		}
+	}
+	t.Error("expected the session to expire after the absolute timeout")
+}
+
+func TestTamperedCookie(t *testing.T) {
+	m, _ := newTestManager(t, nil)
+	s, _ := loadWith(t, m)
+	jar := &cookieJar{}
+	if err := m.Save(context.Background(), jar, s); err != nil {
+		t.Fatal(err)
+	}
+	cookie := jar.cookie
+	cookie.Value = strings.ToUpper(cookie.Value)
+	if s, _ := loadWith(t, m, cookie); !s.IsNew() {
+		t.Error("expected a tampered cookie to be ignored")
+	}
+}
+
+func TestMuxIntegration(t *testing.T) {
+	store := NewMemoryStore(100)
+	defer store.Close()
+	m, _ := newTestManager(t, store)
+
+	router := mux.NewRouter(&mux.Options{TasksRegistry: m.opts.TasksRegistry})
+	mux.SetGlobalTaskMiddleware(router, m.TaskMiddleware())
+	mux.SetGlobalHTTPMiddleware(router, csrftoken.NewMiddleware(csrftoken.Opts{
+		GetExpectedCSRFToken:  m.GetExpectedCSRFToken,
+		GetSubmittedCSRFToken: func(r *http.Request) string { return r.Header.Get("X-CSRF-Token") },
+	}))
+
+	type out struct{ ID, CSRFToken, UserID string }
+	handler := mux.TaskHandlerFromFunc(m.opts.TasksRegistry, func(rd *mux.ReqData[mux.None]) (out, error) {
+		s, err := m.Get(rd.TasksCtx())
+		if err != nil {
+			return out{}, err
+		}
+		switch {
+		case rd.Request().Method == "POST":
+			s.Data.UserID = "u2"
+			if err := m.RotateID(rd.Request().Context(), rd.ResponseProxy(), s); err != nil {
+				return out{}, err
+			}
+		case rd.Request().URL.Path == "/form" && s.IsNew():
+			// Hands out the CSRF token, so the session must outlive the request
+			if err := m.Save(rd.Request().Context(), rd.ResponseProxy(), s); err != nil {
+				return out{}, err
+			}
+		}
+		return out{s.ID(), s.CSRFToken(), s.Data.UserID}, nil
+	})
+	mux.RegisterTaskHandler(router, "GET", "/me", handler)
+	mux.RegisterTaskHandler(router, "GET", "/form", handler)
+	mux.RegisterTaskHandler(router, "POST", "/login", handler)
+
+	serve := func(method, path, token string, cookies ...*http.Cookie) (*httptest.ResponseRecorder, out) {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Origin", "https://example.com")
+		r.Header.Set("X-CSRF-Token", token)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, r)
+		var o out
+		json.Unmarshal(rr.Body.Bytes(), &o)
+		return rr, o
+	}
+
+	rr, anon := serve("GET", "/me", "")
+	if rr.Code != http.StatusOK || len(rr.Result().Cookies()) != 0 || anon.ID == "" {
+		t.Fatalf("expected an unsaved session and no cookie, got %d %v %s", rr.Code, rr.Result().Cookies(), rr.Body)
+	}
+	if _, found, _ := store.Get(context.Background(), anon.ID); found {
+		t.Error("expected anonymous requests not to write to the store")
+	}
+
+	rr, first := serve("GET", "/form", "")
+	cookies := rr.Result().Cookies()
+	if rr.Code != http.StatusOK || len(cookies) != 1 || first.ID == "" {
+		t.Fatalf("expected a new session and cookie, got %d %v %s", rr.Code, cookies, rr.Body)
+	}
+
+	if rr, _ := serve("POST", "/login", "wrong", cookies[0]); rr.Code != http.StatusForbidden {
+		t.Errorf("expected CSRF mismatch to be rejected, got %d", rr.Code)
+	}
+
+	rr, login := serve("POST", "/login", first.CSRFToken, cookies[0])
+	if rr.Code != http.StatusOK || login.ID == first.ID || login.UserID != "u2" {
+		t.Fatalf("expected a rotated, logged-in session, got %d %+v", rr.Code, login)
+	}
+
+	rr, me := serve("GET", "/me", "", rr.Result().Cookies()...)
+	if me.ID != login.ID || me.UserID != "u2" || len(rr.Result().Cookies()) != 0 {
+		t.Errorf("expected the rotated session with no new cookie, got %+v %v", me, rr.Result().Cookies())
+	}
+}
@@ -0,0 +1,195 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sjc5/river/kit/bytesutil"
+	"github.com/sjc5/river/kit/lru"
+	"github.com/sjc5/river/kit/sqlutil"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// STORES
+/////////////////////////////////////////////////////////////////////
+
+// Store holds server-side session data by session ID. Data is opaque to
+// stores, and entries past their expiry should be treated as missing.
+type Store interface {
+	Get(ctx context.Context, id string) (data []byte, found bool, err error)
+	Set(ctx context.Context, id string, data []byte, expiresAt time.Time) error
+	Delete(ctx context.Context, id string) error
+}
+
+func encodeRecord[T any](rec record[T]) ([]byte, error) {
+	data, err := bytesutil.ToGob(rec)
+	if err != nil {
+		return nil, fmt.Errorf("session: error encoding session: %w", err)
+	}
+	return data, nil
+}
+
+func decodeRecord[T any](data []byte) (record[T], error) {
+	var rec record[T]
+	err := bytesutil.FromGobInto(data, &rec)
+	return rec, err
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// MEMORY STORE
+/////////////////////////////////////////////////////////////////////
+
+// MemoryStore is a Store for single-process deployments (and tests),
+// backed by an LRU cache. When full, the least recently used sessions are
+// evicted first. Sessions do not survive restarts.
+type MemoryStore struct {
+	cache *lru.Cache[string, []byte]
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// Creates a MemoryStore holding up to maxSessions sessions. Call Close when
+// done with it to stop its expired-session cleanup.
+func NewMemoryStore(maxSessions int) *MemoryStore {
+	return &MemoryStore{cache: lru.NewCacheWithTTL[string, []byte](maxSessions, time.Hour)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) ([]byte, bool, error) {
+	data, found := s.cache.Get(id)
+	return data, found, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, id string, data []byte, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		s.cache.Delete(id)
+		return nil
+	}
+	s.cache.SetWithTTL(id, data, false, ttl)
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.cache.Delete(id)
+	return nil
+}
+
+func (s *MemoryStore) Close() {
+	s.cache.Close()
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// SQL STORE
+/////////////////////////////////////////////////////////////////////
+
+type SQLStoreOpts struct {
+	// Optional. Defaults to "sessions". The table must have this shape
+	// (adjusting the data column's type for your database, e.g. bytea in
+	// Postgres):
+	//
+	//	CREATE TABLE sessions (
+	//		id         TEXT PRIMARY KEY,
+	//		data       BLOB NOT NULL,
+	//		expires_at BIGINT NOT NULL -- Unix seconds
+	//	);
+	//	CREATE INDEX sessions_expires_at ON sessions (expires_at);
+	Table string
+
+	// Optional. Use numbered placeholders ($1, $2, ...), as Postgres
+	// requires, instead of question marks.
+	NumberedPlaceholders bool
+}
+
+// SQLStore is a Store backed by a database/sql table, for deployments with
+// several processes. Call DeleteExpired periodically to clean up.
+type SQLStore struct {
+	db *sql.DB
+
+	getQuery           string
+	deleteQuery        string
+	insertQuery        string
+	deleteExpiredQuery string
+}
+
+var _ Store = (*SQLStore)(nil)
+
+func NewSQLStore(db *sql.DB, opts SQLStoreOpts) *SQLStore {
+	table := opts.Table
+	if table == "" {
+		table = "sessions"
+	}
+	p := func(query string) string {
+		if !opts.NumberedPlaceholders {
+			return query
+		}
+		var sb strings.Builder
+		n := 0
+		for _, ch := range query {
+			if ch == '?' {
+				n++
+				fmt.Fprintf(&sb, "$%d", n)
+				continue
+			}
+			sb.WriteRune(ch)
+		}
+		return sb.String()
+	}
+	return &SQLStore{
+		db:                 db,
+		getQuery:           p("SELECT data, expires_at FROM " + table + " WHERE id = ?"),
+		deleteQuery:        p("DELETE FROM " + table + " WHERE id = ?"),
+		insertQuery:        p("INSERT INTO " + table + " (id, data, expires_at) VALUES (?, ?, ?)"),
+		deleteExpiredQuery: p("DELETE FROM " + table + " WHERE expires_at <= ?"),
+	}
+}
+
+func (s *SQLStore) Get(ctx context.Context, id string) ([]byte, bool, error) {
+	var data []byte
+	var expiresAt int64
+	err := s.db.QueryRowContext(ctx, s.getQuery, id).Scan(&data, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("session: error getting session: %w", err)
+	}
+	if expiresAt <= time.Now().Unix() {
+		return nil, false, nil
+	}
+	return data, true, nil
+}
+
+// Upserts portably, by deleting then inserting within a transaction.
+func (s *SQLStore) Set(ctx context.Context, id string, data []byte, expiresAt time.Time) error {
+	err := sqlutil.TransactionContext(s.db, ctx, nil, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.deleteQuery, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, s.insertQuery, id, data, expiresAt.Unix())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("session: error setting session: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, s.deleteQuery, id); err != nil {
+		return fmt.Errorf("session: error deleting session: %w", err)
+	}
+	return nil
+}
+
+// Deletes all expired sessions, returning how many there were.
+func (s *SQLStore) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, s.deleteExpiredQuery, time.Now().Unix())
+	if err != nil {
+		return 0, fmt.Errorf("session: error deleting expired sessions: %w", err)
+	}
+	return result.RowsAffected()
+}
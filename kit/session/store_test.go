@@ -0,0 +1,144 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A minimal database/sql driver understanding just the SQLStore's queries,
+// recording each query it runs.
+
+type fakeRow struct {
+	data      []byte
+	expiresAt int64
+}
+
+type fakeDB struct {
+	mu      sync.Mutex
+	rows    map[string]fakeRow
+	queries []string
+}
+
+type fakeConnector struct{ db *fakeDB }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return c, nil }
+func (c fakeConn) Commit() error                             { return nil }
+func (c fakeConn) Rollback() error                           { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.queries = append(s.db.queries, s.query)
+
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		s.db.rows[args[0].(string)] = fakeRow{data: args[1].([]byte), expiresAt: args[2].(int64)}
+		return driver.RowsAffected(1), nil
+	case strings.Contains(s.query, "WHERE id ="):
+		delete(s.db.rows, args[0].(string))
+		return driver.RowsAffected(1), nil
+	case strings.Contains(s.query, "WHERE expires_at <="):
+		var n int64
+		for id, row := range s.db.rows {
+			if row.expiresAt <= args[0].(int64) {
+				delete(s.db.rows, id)
+				n++
+			}
+		}
+		return driver.RowsAffected(n), nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", s.query)
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	s.db.queries = append(s.db.queries, s.query)
+
+	row, ok := s.db.rows[args[0].(string)]
+	return &fakeRows{row: row, done: !ok}, nil
+}
+
+type fakeRows struct {
+	row  fakeRow
+	done bool
+}
+
+func (r *fakeRows) Columns() []string { return []string{"data", "expires_at"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0], dest[1] = r.row.data, r.row.expiresAt
+	return nil
+}
+
+func TestSQLStore(t *testing.T) {
+	fake := &fakeDB{rows: make(map[string]fakeRow)}
+	db := sql.OpenDB(fakeConnector{fake})
+	defer db.Close()
+
+	store := NewSQLStore(db, SQLStoreOpts{Table: "app_sessions", NumberedPlaceholders: true})
+	ctx := context.Background()
+
+	if _, found, err := store.Get(ctx, "a"); found || err != nil {
+		t.Fatalf("expected missing session, got %v %v", found, err)
+	}
+
+	if err := store.Set(ctx, "a", []byte("one"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(ctx, "a", []byte("two"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if data, found, _ := store.Get(ctx, "a"); !found || string(data) != "two" {
+		t.Errorf("expected updated session, got %q %v", data, found)
+	}
+
+	store.Set(ctx, "expired", []byte("x"), time.Now().Add(-time.Second))
+	if _, found, _ := store.Get(ctx, "expired"); found {
+		t.Error("expected expired session to be treated as missing")
+	}
+	if n, err := store.DeleteExpired(ctx); n != 1 || err != nil {
+		t.Errorf("expected one expired session deleted, got %d %v", n, err)
+	}
+
+	if err := store.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := store.Get(ctx, "a"); found {
+		t.Error("expected deleted session to be missing")
+	}
+
+	if got := fake.queries[0]; got != "SELECT data, expires_at FROM app_sessions WHERE id = $1" {
+		t.Errorf("unexpected query %q", got)
+	}
+	if got := fake.queries[2]; got != "INSERT INTO app_sessions (id, data, expires_at) VALUES ($1, $2, $3)" {
+		t.Errorf("unexpected query %q", got)
+	}
+}
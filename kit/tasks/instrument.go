@@ -0,0 +1,72 @@
+package tasks
+
+import (
+	"context"
+	"time"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// INSTRUMENTATION
+/////////////////////////////////////////////////////////////////////
+
+// TaskEvent describes a task for instrumentation hooks. Start and
+// Duration are set only once a task has actually started, and Attempts
+// and Err only once it has ended (or been canceled).
+type TaskEvent struct {
+	TaskID   int
+	TaskName string
+	Start    time.Time
+	Duration time.Duration
+	Attempts int
+	Err      error
+}
+
+// Instrumentation receives hooks for every task run through a registry.
+// Hooks are called synchronously from the goroutine running (or waiting
+// on) the task, so implementations must be safe for concurrent use and
+// should return quickly. The ctx passed to hooks is the TasksCtx's native
+// context (typically derived from the request's context), except for
+// TaskEnded, which receives whatever TaskStarted returned, letting
+// implementations carry state such as a tracing span from start to end.
+type Instrumentation interface {
+	TaskStarted(ctx context.Context, event TaskEvent) context.Context
+	TaskEnded(ctx context.Context, event TaskEvent)
+	// Called when a task is requested again in the same TasksCtx, and the
+	// result of its first run (completed or in flight) is reused.
+	TaskDeduped(ctx context.Context, event TaskEvent)
+	// Called when a task doesn't run, or stops being waited on, because
+	// its TasksCtx was canceled. Tasks canceled mid-run get TaskEnded
+	// first.
+	TaskCanceled(ctx context.Context, event TaskEvent)
+}
+
+// Adds an instrumentation to the registry. Should be called at init time,
+// before any tasks run.
+func AddInstrumentation(tr *Registry, instrumentation Instrumentation) {
+	tr.instrumentations = append(tr.instrumentations, instrumentation)
+}
+
+func (tr *Registry) taskStarted(ctx context.Context, event TaskEvent) context.Context {
+	for _, instrumentation := range tr.instrumentations {
+		ctx = instrumentation.TaskStarted(ctx, event)
+	}
+	return ctx
+}
+
+func (tr *Registry) taskEnded(ctx context.Context, event TaskEvent) {
+	for _, instrumentation := range tr.instrumentations {
+		instrumentation.TaskEnded(ctx, event)
+	}
+}
+
+func (tr *Registry) taskDeduped(ctx context.Context, event TaskEvent) {
+	for _, instrumentation := range tr.instrumentations {
+		instrumentation.TaskDeduped(ctx, event)
+	}
+}
+
+func (tr *Registry) taskCanceled(ctx context.Context, event TaskEvent) {
+	for _, instrumentation := range tr.instrumentations {
+		instrumentation.TaskCanceled(ctx, event)
+	}
+}
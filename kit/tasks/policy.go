@@ -0,0 +1,307 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/sjc5/river/kit/genericsutil"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// POLICIES
+/////////////////////////////////////////////////////////////////////
+
+// A Policy controls how a task is executed: how long each attempt may take,
+// whether failed attempts are retried, and whether repeated failures trip
+// a circuit breaker. Policies apply wherever the task runs (as a loader, a
+// task middleware, or via an ad hoc Get), and still run at most once per
+// TasksCtx. All fields are optional.
+//
+// A policy only governs its own task. Dependency results are memoized per
+// TasksCtx, so when a dependency fails, every retry of a dependent task sees
+// that same failure. To retry a flaky dependency, give the dependency its own
+// policy.
+type Policy struct {
+	// Maximum duration of a single attempt. Attempts that exceed it fail
+	// with an error wrapping ErrTimeout. The task's TasksCtx (as seen from
+	// within the task) has a matching deadline, which tasks should respect.
+	Timeout        time.Duration
+	Retry          *RetryPolicy
+	CircuitBreaker *CircuitBreakerPolicy
+}
+
+// A RetryPolicy re-runs the task itself, not its dependencies (see Policy).
+type RetryPolicy struct {
+	// Total number of attempts, including the first. Values below 2 mean
+	// no retries.
+	MaxAttempts int
+	// Delay before the first retry. Defaults to 100ms.
+	InitialBackoff time.Duration
+	// Upper bound on the delay between attempts. Defaults to 5s.
+	MaxBackoff time.Duration
+	// Factor by which the delay grows after each retry. Defaults to 2.
+	Multiplier float64
+	// Fraction (0 to 1) of each delay that is randomized. Defaults to 0,
+	// meaning no jitter.
+	Jitter float64
+	// Decides whether an error is worth retrying. Defaults to
+	// IsTransient, which is true for errors wrapped with MarkTransient and
+	// for timeouts.
+	IsRetryable func(error) bool
+}
+
+// A CircuitBreakerPolicy short-circuits a task (shared across all
+// TasksCtxs) after repeated failures. Once FailureThreshold consecutive
+// executions have failed (after retries), further executions fail fast
+// with ErrCircuitOpen until Cooldown has passed. Then a single trial
+// execution is let through: if it succeeds the breaker closes, otherwise
+// it opens again. Parent context cancellations are not counted.
+type CircuitBreakerPolicy struct {
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+var (
+	ErrTimeout     = errors.New("task timed out")
+	ErrCircuitOpen = errors.New("task circuit breaker is open")
+)
+
+type transientError struct{ err error }
+
+func (e *transientError) Error() string { return e.err.Error() }
+func (e *transientError) Unwrap() error { return e.err }
+
+// MarkTransient wraps err so that IsTransient reports true for it (and so
+// that it is retried under a RetryPolicy with the default classifier).
+func MarkTransient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transientError{err: err}
+}
+
+// IsTransient reports whether err was wrapped with MarkTransient or is a
+// task timeout.
+func IsTransient(err error) bool {
+	var te *transientError
+	return errors.As(err, &te) || errors.Is(err, ErrTimeout)
+}
+
+// Sets the execution policy for a task. Should generally be called at init
+// time, but is safe to call while tasks are running (executions already in
+// progress keep the policy they started with). Passing nil removes any
+// existing policy. Replacing a policy resets its circuit breaker.
+func SetPolicy[I any, O any](task *RegisteredTask[I, O], policy *Policy) {
+	if policy != nil && policy.CircuitBreaker != nil && policy.CircuitBreaker.FailureThreshold < 1 {
+		panic("tasks: circuit breaker FailureThreshold must be at least 1")
+	}
+	task.registry.policiesMu.Lock()
+	defer task.registry.policiesMu.Unlock()
+	if policy == nil {
+		delete(task.registry.policies, task.id)
+		return
+	}
+	task.registry.policies[task.id] = &policyState{policy: policy}
+}
+
+func (tr *Registry) getPolicyState(taskID int) *policyState {
+	tr.policiesMu.RLock()
+	defer tr.policiesMu.RUnlock()
+	return tr.policies[taskID]
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// EXECUTION
+/////////////////////////////////////////////////////////////////////
+
+type policyState struct {
+	policy *Policy
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	openedAt            time.Time
+	trialInFlight       bool
+}
+
+// Runs a task under its policy (if any). Returns the data, the number of
+// attempts made and the error.
+func (c *TasksCtx) execute(taskID int, taskHelper genericsutil.AnyIOFunc, input any) (any, int, error) {
+	state := c.registry.getPolicyState(taskID)
+	if state == nil {
+		data, err := taskHelper.ExecuteStrict(&anyArg{input: input, ctx: c})
+		return data, 1, err
+	}
+
+	if !state.allow() {
+		return taskHelper.O(), 0, ErrCircuitOpen
+	}
+
+	p := state.policy
+	maxAttempts := 1
+	if p.Retry != nil && p.Retry.MaxAttempts > 1 {
+		maxAttempts = p.Retry.MaxAttempts
+	}
+
+	var data any
+	var err error
+	attempts := 0
+
+	for attempts < maxAttempts {
+		attempts++
+		data, err = c.executeAttempt(taskHelper, input, p.Timeout)
+		if err == nil || attempts == maxAttempts || c.context.Err() != nil || !p.Retry.isRetryable(err) {
+			break
+		}
+		if !c.sleep(p.Retry.backoff(attempts)) {
+			break
+		}
+	}
+
+	if c.context.Err() == nil || err == nil {
+		state.record(err)
+	} else {
+		state.release()
+	}
+
+	return data, attempts, err
+}
+
+func (c *TasksCtx) executeAttempt(taskHelper genericsutil.AnyIOFunc, input any, timeout time.Duration) (any, error) {
+	if timeout <= 0 {
+		return taskHelper.ExecuteStrict(&anyArg{input: input, ctx: c})
+	}
+
+	attemptCtx, cancel := context.WithTimeout(c.context, timeout)
+	defer cancel()
+
+	resultChan := make(chan *TaskResult, 1)
+	go func() {
+		data, err := taskHelper.ExecuteStrict(&anyArg{input: input, ctx: c.withContext(attemptCtx, cancel)})
+		resultChan <- &TaskResult{Data: data, Err: err}
+	}()
+
+	select {
+	case result := <-resultChan:
+		return result.Data, result.Err
+	case <-attemptCtx.Done():
+		if c.context.Err() != nil {
+			return taskHelper.O(), c.context.Err()
+		}
+		return taskHelper.O(), fmt.Errorf("%w after %s", ErrTimeout, timeout)
+	}
+}
+
+// Returns a view of c (sharing its results and provided values, so tasks and
+// providers still run at most once) whose native context is ctx.
+func (c *TasksCtx) withContext(ctx context.Context, cancel context.CancelFunc) *TasksCtx {
+	return &TasksCtx{
+		mu:            c.mu,
+		request:       c.request,
+		registry:      c.registry,
+		results:       c.results,
+		context:       ctx,
+		cancel:        cancel,
+		instances:     c.instances,
+		taskInstances: c.taskInstances,
+	}
+}
+
+// Returns false if the context was canceled while sleeping.
+func (c *TasksCtx) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.context.Done():
+		return false
+	}
+}
+
+func (rp *RetryPolicy) isRetryable(err error) bool {
+	if rp.IsRetryable != nil {
+		return rp.IsRetryable(err)
+	}
+	return IsTransient(err)
+}
+
+// Delay before the retry following the given (1-indexed) attempt.
+func (rp *RetryPolicy) backoff(attempt int) time.Duration {
+	initial := rp.InitialBackoff
+	if initial <= 0 {
+		initial = 100 * time.Millisecond
+	}
+	maxBackoff := rp.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = 5 * time.Second
+	}
+	multiplier := rp.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	d := float64(initial)
+	for range attempt - 1 {
+		d *= multiplier
+		if d >= float64(maxBackoff) {
+			break
+		}
+	}
+	d = min(d, float64(maxBackoff))
+
+	if jitter := min(max(rp.Jitter, 0), 1); jitter > 0 {
+		d = d*(1-jitter) + d*jitter*rand.Float64()
+	}
+
+	return time.Duration(d)
+}
+
+func (s *policyState) allow() bool {
+	cb := s.policy.CircuitBreaker
+	if cb == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.consecutiveFailures < cb.FailureThreshold {
+		return true
+	}
+	// Open: let a single trial through once the cooldown has passed
+	if time.Since(s.openedAt) < cb.Cooldown || s.trialInFlight {
+		return false
+	}
+	s.trialInFlight = true
+	return true
+}
+
+func (s *policyState) record(err error) {
+	cb := s.policy.CircuitBreaker
+	if cb == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trialInFlight = false
+	if err == nil {
+		s.consecutiveFailures = 0
+		return
+	}
+	s.consecutiveFailures++
+	if s.consecutiveFailures >= cb.FailureThreshold {
+		s.openedAt = time.Now()
+	}
+}
+
+// Releases a half-open trial without recording an outcome.
+func (s *policyState) release() {
+	if s.policy.CircuitBreaker == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trialInFlight = false
+}
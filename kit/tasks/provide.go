@@ -0,0 +1,295 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// DEPENDENCY INJECTION
+/////////////////////////////////////////////////////////////////////
+
+// Providers supply shared services (DB handles, the current user, etc.) to
+// tasks by type. Bind one per type with Provide, declaring the types it
+// resolves in turn with Dep, and get values from within tasks (or anywhere
+// else with a TasksCtx) with Resolve:
+//
+//	tasks.Provide(registry, tasks.PerRequest, func(c *tasks.TasksCtx) (*User, error) {
+//		db, err := tasks.Resolve[*sql.DB](c)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return getUserFromSession(db, c.Request())
+//	}, tasks.Dep[*sql.DB]())
+//
+// Call ValidateBindings at startup to catch missing providers, cycles and
+// lifetime mismatches before serving any requests.
+
+type Lifetime int
+
+const (
+	// Resolved once per registry (on first use), and shared by all
+	// contexts. Provider errors are not cached, so a failed singleton is
+	// retried on its next resolution. Providers should not retain the
+	// TasksCtx they are passed.
+	Singleton Lifetime = iota
+
+	// Resolved at most once per TasksCtx (typically, per request), with the
+	// same once-semantics as tasks: concurrent resolutions wait on the
+	// first, and its value or error is shared.
+	PerRequest
+
+	// Resolved at most once per task run, so every task gets its own value.
+	// Resolutions outside of a task run (e.g., from a plain HTTP handler)
+	// get a fresh value every time.
+	PerTask
+)
+
+func (l Lifetime) String() string {
+	switch l {
+	case Singleton:
+		return "singleton"
+	case PerRequest:
+		return "per-request"
+	case PerTask:
+		return "per-task"
+	default:
+		return fmt.Sprintf("Lifetime(%d)", int(l))
+	}
+}
+
+var (
+	ErrNoProvider         = errors.New("no provider bound for type")
+	ErrUndeclaredDep      = errors.New("provider resolved a type it did not declare with Dep")
+	ErrInvalidBindings    = errors.New("invalid provider bindings")
+	errNilProviderContext = errors.New("cannot resolve without a TasksCtx")
+)
+
+// A Dependency identifies a provided type, for declaring what a provider
+// resolves. Create one with Dep.
+type Dependency struct {
+	t reflect.Type
+}
+
+func Dep[T any]() Dependency {
+	return Dependency{t: reflect.TypeFor[T]()}
+}
+
+type binding struct {
+	t        reflect.Type
+	lifetime Lifetime
+	deps     []reflect.Type
+	provide  func(c *TasksCtx) (any, error)
+
+	// Singletons only
+	mu    sync.Mutex
+	done  bool
+	value any
+}
+
+type instance struct {
+	once  sync.Once
+	value any
+	err   error
+}
+
+// Holds per-request or per-task provided values. Safe for concurrent use.
+type instances struct {
+	mu sync.Mutex
+	m  map[reflect.Type]*instance
+}
+
+func (is *instances) get(t reflect.Type) *instance {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if is.m == nil {
+		is.m = make(map[reflect.Type]*instance)
+	}
+	inst, ok := is.m[t]
+	if !ok {
+		inst = &instance{}
+		is.m[t] = inst
+	}
+	return inst
+}
+
+// Binds a provider for type T to the registry. deps must list every type
+// the provider resolves (see Dep). Should be called at init time. Panics if
+// T already has a provider.
+func Provide[T any](tr *Registry, lifetime Lifetime, provider func(c *TasksCtx) (T, error), deps ...Dependency) {
+	t := reflect.TypeFor[T]()
+	if _, exists := tr.bindings[t]; exists {
+		panic(fmt.Sprintf("tasks: provider already bound for type %s", t))
+	}
+	b := &binding{
+		t:        t,
+		lifetime: lifetime,
+		provide: func(c *TasksCtx) (any, error) {
+			return provider(c)
+		},
+	}
+	for _, dep := range deps {
+		b.deps = append(b.deps, dep.t)
+	}
+	tr.bindings[t] = b
+}
+
+// Resolves the value of type T bound with Provide, according to its
+// lifetime.
+func Resolve[T any](c *TasksCtx) (T, error) {
+	var zero T
+	if c == nil {
+		return zero, errNilProviderContext
+	}
+	v, err := c.resolve(reflect.TypeFor[T]())
+	if err != nil {
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// Checks that every declared dependency has a provider, that there are no
+// resolution cycles, and that no provider depends on a provider with a
+// shorter lifetime (e.g., a singleton capturing a per-request value).
+// Call it at startup. Resolve also runs it (once) before first use, and
+// fails with its error.
+func ValidateBindings(tr *Registry) error {
+	var errs []error
+
+	types := make([]reflect.Type, 0, len(tr.bindings))
+	for t := range tr.bindings {
+		types = append(types, t)
+	}
+	slices.SortFunc(types, func(a, b reflect.Type) int { return strings.Compare(a.String(), b.String()) })
+
+	for _, t := range types {
+		b := tr.bindings[t]
+		for _, dep := range b.deps {
+			depBinding, ok := tr.bindings[dep]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%w %s (a dependency of %s)", ErrNoProvider, dep, t))
+				continue
+			}
+			if depBinding.lifetime > b.lifetime {
+				errs = append(errs, fmt.Errorf("%s provider for %s depends on %s provider for %s", b.lifetime, t, depBinding.lifetime, dep))
+			}
+		}
+	}
+
+	// Depth-first search for cycles, reporting each once
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[reflect.Type]int, len(types))
+	var path []reflect.Type
+	var visit func(t reflect.Type)
+	visit = func(t reflect.Type) {
+		switch state[t] {
+		case visited:
+			return
+		case visiting:
+			start := slices.Index(path, t)
+			names := make([]string, 0, len(path)-start+1)
+			for _, p := range path[start:] {
+				names = append(names, p.String())
+			}
+			names = append(names, t.String())
+			errs = append(errs, fmt.Errorf("resolution cycle: %s", strings.Join(names, " -> ")))
+			return
+		}
+		b, ok := tr.bindings[t]
+		if !ok {
+			return
+		}
+		state[t] = visiting
+		path = append(path, t)
+		for _, dep := range b.deps {
+			visit(dep)
+		}
+		path = path[:len(path)-1]
+		state[t] = visited
+	}
+	for _, t := range types {
+		visit(t)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidBindings, errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c *TasksCtx) resolve(t reflect.Type) (any, error) {
+	tr := c.registry
+
+	tr.validateBindingsOnce.Do(func() {
+		tr.validateBindingsErr = ValidateBindings(tr)
+	})
+	if tr.validateBindingsErr != nil {
+		return nil, tr.validateBindingsErr
+	}
+
+	if c.resolving != nil && !slices.Contains(c.resolving.deps, t) {
+		return nil, fmt.Errorf("%w: %s resolved %s", ErrUndeclaredDep, c.resolving.t, t)
+	}
+
+	b, ok := tr.bindings[t]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrNoProvider, t)
+	}
+
+	switch b.lifetime {
+	case Singleton:
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.done {
+			return b.value, nil
+		}
+		v, err := b.provide(c.withResolving(b))
+		if err != nil {
+			return nil, err
+		}
+		b.value, b.done = v, true
+		return v, nil
+
+	case PerTask:
+		if c.taskInstances == nil {
+			return b.provide(c.withResolving(b))
+		}
+		return c.resolveOnce(c.taskInstances, b)
+
+	default:
+		return c.resolveOnce(c.instances, b)
+	}
+}
+
+func (c *TasksCtx) resolveOnce(is *instances, b *binding) (any, error) {
+	inst := is.get(b.t)
+	inst.once.Do(func() {
+		inst.value, inst.err = b.provide(c.withResolving(b))
+	})
+	return inst.value, inst.err
+}
+
+// Returns a view of c (sharing its results and provided values) for
+// running b's provider, so that its resolutions can be checked against
+// b's declared dependencies.
+func (c *TasksCtx) withResolving(b *binding) *TasksCtx {
+	view := c.withContext(c.context, c.cancel)
+	view.resolving = b
+	return view
+}
+
+// Returns a view of c (sharing its results and per-request values) with
+// its own per-task values, for running a single task.
+func (c *TasksCtx) withTaskScope() *TasksCtx {
+	view := c.withContext(c.context, c.cancel)
+	view.taskInstances = &instances{}
+	return view
+}
@@ -13,7 +13,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/sjc5/river/kit/genericsutil"
 )
@@ -42,6 +46,7 @@ type anyArg struct {
 type AnyRegisteredTask interface {
 	genericsutil.AnyIOFunc
 	getID() int
+	Name() string
 }
 
 type ioFunc[I any, O any] = genericsutil.IOFunc[*Arg[I], O]
@@ -49,11 +54,24 @@ type ioFunc[I any, O any] = genericsutil.IOFunc[*Arg[I], O]
 // returned from tasks.Register(Registry, IOTask)
 type RegisteredTask[I any, O any] struct {
 	ioFunc[I, O]
-	id int
+	id       int
+	registry *Registry
 }
 
 func (task RegisteredTask[I, O]) getID() int { return task.id }
 
+// Returns the task's name: by default, the package-qualified name of the
+// function passed to Register (e.g., "auth.GetCurrentUser", or
+// "main.main.func1" for a closure). Used for introspection only.
+func (task RegisteredTask[I, O]) Name() string { return task.registry.names[task.id] }
+
+// Overrides the task's name, e.g., when Register is passed a wrapper
+// around the function that does the real work. Should be called at init
+// time.
+func SetName[I any, O any](task *RegisteredTask[I, O], name string) {
+	task.registry.names[task.id] = name
+}
+
 // Adds a task to the registry
 func Register[I any, O any](tr *Registry, f genericsutil.IOFunc[*Arg[I], O]) *RegisteredTask[I, O] {
 	id := tr.count
@@ -75,10 +93,12 @@ func Register[I any, O any](tr *Registry, f genericsutil.IOFunc[*Arg[I], O]) *Re
 
 	// add to registry
 	tr.registry[id] = asAnyIOFunc
+	tr.names[id] = funcName(f)
 
 	// This is the function that will be called by the TasksCtx.doOnce method
 	return &RegisteredTask[I, O]{
-		id: id,
+		id:       id,
+		registry: tr,
 		ioFunc: func(c *Arg[I]) (O, error) {
 			c.TasksCtx.doOnce(id, c.TasksCtx, c.Input)
 			c.TasksCtx.mu.Lock()
@@ -93,13 +113,31 @@ func Register[I any, O any](tr *Registry, f genericsutil.IOFunc[*Arg[I], O]) *Re
 	}
 }
 
+// Returns the function's name without its package path, for introspection.
+func funcName(f any) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return ""
+	}
+	name := fn.Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
 /////////////////////////////////////////////////////////////////////
 /////// TASKS REGISTRY
 /////////////////////////////////////////////////////////////////////
 
 type Registry struct {
-	count    int
-	registry map[int]genericsutil.AnyIOFunc
+	count            int
+	registry         map[int]genericsutil.AnyIOFunc
+	names            map[int]string
+	policiesMu       sync.RWMutex
+	policies         map[int]*policyState
+	instrumentations []Instrumentation
+
+	bindings             map[reflect.Type]*binding
+	validateBindingsOnce sync.Once
+	validateBindingsErr  error
 }
 
 func (tr *Registry) NewCtxFromNativeContext(parentContext context.Context) *TasksCtx {
@@ -111,7 +149,12 @@ func (tr *Registry) NewCtxFromRequest(r *http.Request) *TasksCtx {
 }
 
 func NewRegistry() *Registry {
-	return &Registry{registry: make(map[int]genericsutil.AnyIOFunc)}
+	return &Registry{
+		registry: make(map[int]genericsutil.AnyIOFunc),
+		names:    make(map[int]string),
+		policies: make(map[int]*policyState),
+		bindings: make(map[reflect.Type]*binding),
+	}
 }
 
 /////////////////////////////////////////////////////////////////////
@@ -126,6 +169,10 @@ type TasksCtx struct {
 
 	context context.Context
 	cancel  context.CancelFunc
+
+	instances     *instances // per-request provided values
+	taskInstances *instances // per-task provided values (nil outside of task runs)
+	resolving     *binding   // provider being run, if any
 }
 
 func newTasksCtx(registry *Registry, parentContext context.Context, r *http.Request) *TasksCtx {
@@ -137,6 +184,8 @@ func newTasksCtx(registry *Registry, parentContext context.Context, r *http.Requ
 		registry: registry,
 		context:  contextWithCancel,
 		cancel:   cancel,
+
+		instances: &instances{},
 	}
 
 	c.results = newResults(c)
@@ -209,7 +258,17 @@ func (twi *anyPreparedTaskImpl) getTask() AnyRegisteredTask { return twi.task }
 func (twi *anyPreparedTaskImpl) getInput() any              { return twi.input }
 
 func (twi anyPreparedTaskImpl) GetAny() (any, error) {
-	twi.c.ParallelPreload(PrepAny(twi.c, twi.task, twi.input))
+	// Tasks that have already finished (typically because they were just
+	// preloaded) are read directly, so as not to count as dedupe hits
+	twi.c.mu.Lock()
+	result, ok := twi.c.results.results[twi.task.getID()]
+	done := ok && result.done
+	twi.c.mu.Unlock()
+	if !done {
+		twi.c.ParallelPreload(PrepAny(twi.c, twi.task, twi.input))
+	}
+	twi.c.mu.Lock()
+	defer twi.c.mu.Unlock()
 	x := twi.c.results.results[twi.task.getID()]
 	return x.Data, x.Err
 }
@@ -255,39 +314,68 @@ func (c *TasksCtx) doOnce(taskID int, ctx *TasksCtx, input any) {
 		c.mu.Lock()
 		c.results.results[taskID].Data = taskHelper.O()
 		c.results.results[taskID].Err = errors.New("parent context canceled")
+		c.results.results[taskID].done = true
 		c.mu.Unlock()
+		c.registry.taskCanceled(c.context, TaskEvent{TaskID: taskID, TaskName: c.registry.names[taskID], Err: c.context.Err()})
 		return
 	}
 
+	ran := false
+
 	c.getSyncOnce(taskID).Do(func() {
+		ran = true
+		event := TaskEvent{TaskID: taskID, TaskName: c.registry.names[taskID]}
+
 		// check if context is canceled
 		if c.context.Err() != nil {
 			c.mu.Lock()
 			c.results.results[taskID].Data = taskHelper.O()
 			c.results.results[taskID].Err = c.context.Err()
+			c.results.results[taskID].done = true
 			c.mu.Unlock()
+			event.Err = c.context.Err()
+			c.registry.taskCanceled(c.context, event)
 			return
 		}
 
+		event.Start = time.Now()
+		hookCtx := c.registry.taskStarted(c.context, event)
+
 		resultChan := make(chan *TaskResult, 1)
 		go func() {
-			data, err := taskHelper.ExecuteStrict(&anyArg{input: input, ctx: ctx})
-			resultChan <- &TaskResult{Data: data, Err: err}
+			data, attempts, err := ctx.withTaskScope().execute(taskID, taskHelper, input)
+			resultChan <- &TaskResult{Data: data, Err: err, Attempts: attempts}
 		}()
 
 		select {
 		case <-c.context.Done():
+			event.Duration = time.Since(event.Start)
+			event.Err = c.context.Err()
 			c.mu.Lock()
 			c.results.results[taskID].Data = taskHelper.O()
 			c.results.results[taskID].Err = c.context.Err()
+			c.results.results[taskID].Duration = event.Duration
+			c.results.results[taskID].done = true
 			c.mu.Unlock()
+			c.registry.taskEnded(hookCtx, event)
+			c.registry.taskCanceled(c.context, event)
 		case result := <-resultChan:
+			event.Duration = time.Since(event.Start)
+			event.Err, event.Attempts = result.Err, result.Attempts
 			c.mu.Lock()
 			c.results.results[taskID].Data = result.Data
 			c.results.results[taskID].Err = result.Err
+			c.results.results[taskID].Attempts = result.Attempts
+			c.results.results[taskID].Duration = event.Duration
+			c.results.results[taskID].done = true
 			c.mu.Unlock()
+			c.registry.taskEnded(hookCtx, event)
 		}
 	})
+
+	if !ran {
+		c.registry.taskDeduped(c.context, TaskEvent{TaskID: taskID, TaskName: c.registry.names[taskID]})
+	}
 }
 
 func (c *TasksCtx) getSyncOnce(taskID int) *sync.Once {
@@ -308,13 +396,32 @@ func (c *TasksCtx) getSyncOnce(taskID int) *sync.Once {
 type TaskResult struct {
 	Data any
 	Err  error
-	once *sync.Once
+	// Number of times the task function was invoked. Greater than 1 only
+	// if the task has a retry policy, and 0 if it never ran (e.g., because
+	// its circuit breaker was open or the context was canceled).
+	Attempts int
+	// How long the task ran for, or 0 if it never ran.
+	Duration time.Duration
+	once     *sync.Once
+	done     bool
 }
 
 func newTaskResult() *TaskResult {
 	return &TaskResult{once: &sync.Once{}}
 }
 
+// Returns a copy of the result of a task that has run (or been attempted)
+// in this context. The second return value is false if it has not.
+func (c *TasksCtx) GetResult(task AnyRegisteredTask) (TaskResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.results.results[task.getID()]
+	if !ok {
+		return TaskResult{}, false
+	}
+	return TaskResult{Data: result.Data, Err: result.Err, Attempts: result.Attempts, Duration: result.Duration}, true
+}
+
 func (r *TaskResult) OK() bool {
 	return r.Err == nil
 }
@@ -332,6 +439,8 @@ func newResults(c *TasksCtx) *TaskResults {
 }
 
 func (tr TaskResults) AllOK() bool {
+	tr.c.mu.Lock()
+	defer tr.c.mu.Unlock()
 	for _, result := range tr.results {
 		if !result.OK() {
 			return false
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -573,3 +575,464 @@ func TestTasksWithSharedDependencies_MoreThorough(t *testing.T) {
 		t.Logf("User2 tokens: %v", user2Tokens)
 	})
 }
+
+func TestPolicies(t *testing.T) {
+	t.Run("RetriesTransientErrors", func(t *testing.T) {
+		registry := NewRegistry()
+		var calls int
+		task := Register(registry, func(c *ArgNoInput) (int, error) {
+			calls++
+			if calls < 3 {
+				return 0, MarkTransient(errors.New("upstream unavailable"))
+			}
+			return 42, nil
+		})
+		SetPolicy(task, &Policy{Retry: &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond, Jitter: 0.5}})
+
+		ctx := registry.NewCtxFromNativeContext(context.Background())
+		data, err := task.GetNoInput(ctx)
+		if err != nil || data != 42 {
+			t.Fatalf("expected 42, got %d, %v", data, err)
+		}
+		result, ok := ctx.GetResult(task)
+		if !ok || result.Attempts != 3 {
+			t.Errorf("expected 3 attempts, got %+v", result)
+		}
+
+		// Still runs at most once per context
+		task.GetNoInput(ctx)
+		if calls != 3 {
+			t.Errorf("expected no re-run in same context, got %d calls", calls)
+		}
+	})
+
+	t.Run("DoesNotRetryPermanentErrors", func(t *testing.T) {
+		registry := NewRegistry()
+		var calls int
+		task := Register(registry, func(c *ArgNoInput) (int, error) {
+			calls++
+			return 0, errors.New("bad input")
+		})
+		SetPolicy(task, &Policy{Retry: &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond}})
+
+		ctx := registry.NewCtxFromNativeContext(context.Background())
+		if _, err := task.GetNoInput(ctx); err == nil {
+			t.Fatal("expected error")
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("TimeoutIsRetried", func(t *testing.T) {
+		registry := NewRegistry()
+		var calls atomic.Int32
+		task := Register(registry, func(c *ArgNoInput) (string, error) {
+			if calls.Add(1) == 1 {
+				<-c.NativeContext().Done()
+				return "", c.NativeContext().Err()
+			}
+			return "ok", nil
+		})
+		SetPolicy(task, &Policy{
+			Timeout: 20 * time.Millisecond,
+			Retry:   &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond},
+		})
+
+		ctx := registry.NewCtxFromNativeContext(context.Background())
+		data, err := task.GetNoInput(ctx)
+		if err != nil || data != "ok" {
+			t.Fatalf("expected ok after timeout retry, got %q, %v", data, err)
+		}
+		if result, _ := ctx.GetResult(task); result.Attempts != 2 {
+			t.Errorf("expected 2 attempts, got %d", result.Attempts)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		registry := NewRegistry()
+		task := Register(registry, func(c *ArgNoInput) (string, error) {
+			time.Sleep(200 * time.Millisecond)
+			return "late", nil
+		})
+		SetPolicy(task, &Policy{Timeout: 10 * time.Millisecond})
+
+		_, err := task.GetNoInput(registry.NewCtxFromNativeContext(context.Background()))
+		if !errors.Is(err, ErrTimeout) {
+			t.Errorf("expected ErrTimeout, got %v", err)
+		}
+	})
+
+	t.Run("CircuitBreaker", func(t *testing.T) {
+		registry := NewRegistry()
+		var calls int
+		var shouldFail = true
+		task := Register(registry, func(c *ArgNoInput) (string, error) {
+			calls++
+			if shouldFail {
+				return "", errors.New("down")
+			}
+			return "up", nil
+		})
+		SetPolicy(task, &Policy{CircuitBreaker: &CircuitBreakerPolicy{FailureThreshold: 2, Cooldown: 30 * time.Millisecond}})
+
+		run := func() (string, int, error) {
+			ctx := registry.NewCtxFromNativeContext(context.Background())
+			data, err := task.GetNoInput(ctx)
+			result, _ := ctx.GetResult(task)
+			return data, result.Attempts, err
+		}
+
+		run()
+		run()
+		if _, attempts, err := run(); !errors.Is(err, ErrCircuitOpen) || attempts != 0 {
+			t.Fatalf("expected open circuit after 2 failures, got %v (%d attempts)", err, attempts)
+		}
+		if calls != 2 {
+			t.Errorf("expected open circuit to short-circuit, got %d calls", calls)
+		}
+
+		time.Sleep(40 * time.Millisecond)
+		shouldFail = false
+		if data, _, err := run(); err != nil || data != "up" {
+			t.Fatalf("expected trial to succeed after cooldown, got %q, %v", data, err)
+		}
+		if _, _, err := run(); err != nil {
+			t.Errorf("expected closed circuit, got %v", err)
+		}
+	})
+
+	t.Run("RetryDoesNotRerunDependencies", func(t *testing.T) {
+		registry := NewRegistry()
+		var depCalls, taskCalls atomic.Int32
+		dep := Register(registry, func(c *ArgNoInput) (int, error) {
+			if depCalls.Add(1) == 1 {
+				return 0, MarkTransient(errors.New("flaky"))
+			}
+			return 1, nil
+		})
+		task := Register(registry, func(c *ArgNoInput) (int, error) {
+			taskCalls.Add(1)
+			return dep.PrepNoInput(c.TasksCtx).Get()
+		})
+		SetPolicy(task, &Policy{Retry: &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}})
+
+		// The dependency's failure is memoized, so the task's retries see it too
+		if _, err := task.GetNoInput(registry.NewCtxFromNativeContext(context.Background())); err == nil {
+			t.Fatal("expected the dependency's error")
+		}
+		if taskCalls.Load() != 3 || depCalls.Load() != 1 {
+			t.Errorf("expected 3 task calls and 1 dependency call, got %d and %d", taskCalls.Load(), depCalls.Load())
+		}
+
+		// Retrying the dependency itself works
+		depCalls.Store(0)
+		SetPolicy(dep, &Policy{Retry: &RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}})
+		if data, err := task.GetNoInput(registry.NewCtxFromNativeContext(context.Background())); err != nil || data != 1 {
+			t.Errorf("expected 1, got %d, %v", data, err)
+		}
+	})
+
+	t.Run("SetPolicyWhileRunning", func(t *testing.T) {
+		registry := NewRegistry()
+		task := Register(registry, func(c *ArgNoInput) (int, error) { return 1, nil })
+
+		var wg sync.WaitGroup
+		for i := range 20 {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				if i%2 == 0 {
+					SetPolicy(task, &Policy{Timeout: time.Second})
+				} else {
+					SetPolicy(task, nil)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				if _, err := task.GetNoInput(registry.NewCtxFromNativeContext(context.Background())); err != nil {
+					t.Error(err)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func getNamedTaskValue(c *ArgNoInput) (int, error) { return 1, nil }
+
+func TestTaskNames(t *testing.T) {
+	registry := NewRegistry()
+
+	task := Register(registry, getNamedTaskValue)
+	if name := task.Name(); name != "tasks.getNamedTaskValue" {
+		t.Errorf("expected tasks.getNamedTaskValue, got %q", name)
+	}
+
+	SetName(task, "custom")
+	if name := task.Name(); name != "custom" {
+		t.Errorf("expected custom, got %q", name)
+	}
+}
+
+type recordingInstrumentation struct {
+	mu     sync.Mutex
+	events []string
+}
+
+type startKey struct{}
+
+func (ri *recordingInstrumentation) record(kind string, event TaskEvent) {
+	ri.mu.Lock()
+	defer ri.mu.Unlock()
+	ri.events = append(ri.events, kind+":"+event.TaskName)
+}
+
+func (ri *recordingInstrumentation) TaskStarted(ctx context.Context, event TaskEvent) context.Context {
+	ri.record("start", event)
+	return context.WithValue(ctx, startKey{}, event.TaskName)
+}
+
+func (ri *recordingInstrumentation) TaskEnded(ctx context.Context, event TaskEvent) {
+	if ctx.Value(startKey{}) != event.TaskName {
+		ri.record("missing-start-ctx", event)
+	}
+	if event.Err != nil {
+		ri.record("error", event)
+	}
+	ri.record("end", event)
+}
+
+func (ri *recordingInstrumentation) TaskDeduped(ctx context.Context, event TaskEvent) {
+	ri.record("dedupe", event)
+}
+
+func (ri *recordingInstrumentation) TaskCanceled(ctx context.Context, event TaskEvent) {
+	ri.record("cancel", event)
+}
+
+func TestInstrumentation(t *testing.T) {
+	registry := NewRegistry()
+	ri := &recordingInstrumentation{}
+	AddInstrumentation(registry, ri)
+
+	base := Register(registry, func(c *ArgNoInput) (int, error) {
+		time.Sleep(5 * time.Millisecond)
+		return 1, nil
+	})
+	SetName(base, "base")
+	failing := Register(registry, func(c *ArgNoInput) (int, error) {
+		return 0, errors.New("boom")
+	})
+	SetName(failing, "failing")
+
+	ctx := registry.NewCtxFromNativeContext(context.Background())
+	base.GetNoInput(ctx)
+	base.GetNoInput(ctx)
+	failing.GetNoInput(ctx)
+
+	result, _ := ctx.GetResult(base)
+	if result.Duration < 5*time.Millisecond {
+		t.Errorf("expected a duration of at least 5ms, got %v", result.Duration)
+	}
+
+	ctx.CancelNativeContext()
+	failing.GetNoInput(ctx)
+
+	want := []string{
+		"start:base", "end:base", "dedupe:base",
+		"start:failing", "error:failing", "end:failing",
+		"cancel:failing",
+	}
+	if fmt.Sprint(ri.events) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", ri.events, want)
+	}
+}
+
+type testDB struct{ id int64 }
+type testUser struct{ name string }
+type testTx struct{ id int64 }
+
+func TestProviders(t *testing.T) {
+	var dbCount, userCount, txCount atomic.Int64
+
+	newRegistry := func() *Registry {
+		registry := NewRegistry()
+		Provide(registry, Singleton, func(c *TasksCtx) (*testDB, error) {
+			return &testDB{id: dbCount.Add(1)}, nil
+		})
+		Provide(registry, PerRequest, func(c *TasksCtx) (*testUser, error) {
+			if _, err := Resolve[*testDB](c); err != nil {
+				return nil, err
+			}
+			userCount.Add(1)
+			time.Sleep(10 * time.Millisecond)
+			return &testUser{name: "bob"}, nil
+		}, Dep[*testDB]())
+		Provide(registry, PerTask, func(c *TasksCtx) (*testTx, error) {
+			return &testTx{id: txCount.Add(1)}, nil
+		}, Dep[*testDB](), Dep[*testUser]())
+		return registry
+	}
+
+	t.Run("Lifetimes", func(t *testing.T) {
+		dbCount.Store(0)
+		userCount.Store(0)
+		txCount.Store(0)
+
+		registry := newRegistry()
+		if err := ValidateBindings(registry); err != nil {
+			t.Fatalf("expected valid bindings, got %v", err)
+		}
+
+		type seen struct {
+			db   *testDB
+			user *testUser
+			tx   *testTx
+		}
+		resolveAll := func(c *ArgNoInput) (seen, error) {
+			db, _ := Resolve[*testDB](c.TasksCtx)
+			user, _ := Resolve[*testUser](c.TasksCtx)
+			tx, _ := Resolve[*testTx](c.TasksCtx)
+			tx2, _ := Resolve[*testTx](c.TasksCtx)
+			if tx != tx2 {
+				t.Error("expected per-task value to be stable within a task run")
+			}
+			return seen{db, user, tx}, nil
+		}
+		task1 := Register(registry, resolveAll)
+		task2 := Register(registry, resolveAll)
+
+		var users []*testUser
+		for range 2 {
+			ctx := registry.NewCtxFromNativeContext(context.Background())
+			a, _ := task1.GetNoInput(ctx)
+			b, _ := task2.GetNoInput(ctx)
+			if a.db != b.db || a.user != b.user {
+				t.Error("expected singleton and per-request values to be shared within a request")
+			}
+			if a.tx == b.tx {
+				t.Error("expected each task run to get its own per-task value")
+			}
+			users = append(users, a.user)
+		}
+
+		if users[0] == users[1] {
+			t.Error("expected each request to get its own per-request value")
+		}
+		if dbCount.Load() != 1 || userCount.Load() != 2 || txCount.Load() != 4 {
+			t.Errorf("unexpected provider run counts: db=%d user=%d tx=%d", dbCount.Load(), userCount.Load(), txCount.Load())
+		}
+
+		// Outside of a task run, per-task values are not cached
+		ctx := registry.NewCtxFromNativeContext(context.Background())
+		tx1, _ := Resolve[*testTx](ctx)
+		tx2, _ := Resolve[*testTx](ctx)
+		if tx1 == tx2 {
+			t.Error("expected fresh per-task values outside of a task run")
+		}
+	})
+
+	t.Run("PerRequestOnceSemantics", func(t *testing.T) {
+		userCount.Store(0)
+		registry := newRegistry()
+		ctx := registry.NewCtxFromNativeContext(context.Background())
+
+		var wg sync.WaitGroup
+		users := make([]*testUser, 10)
+		for i := range users {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				users[i], _ = Resolve[*testUser](ctx)
+			}()
+		}
+		wg.Wait()
+
+		if userCount.Load() != 1 {
+			t.Errorf("expected per-request provider to run once, ran %d times", userCount.Load())
+		}
+		for _, u := range users {
+			if u != users[0] {
+				t.Fatal("expected all concurrent resolutions to share a value")
+			}
+		}
+	})
+
+	t.Run("ErrorsAreCachedPerRequest", func(t *testing.T) {
+		registry := NewRegistry()
+		var runs atomic.Int64
+		Provide(registry, PerRequest, func(c *TasksCtx) (*testUser, error) {
+			runs.Add(1)
+			return nil, errors.New("no session")
+		})
+		ctx := registry.NewCtxFromNativeContext(context.Background())
+		for range 2 {
+			if _, err := Resolve[*testUser](ctx); err == nil || err.Error() != "no session" {
+				t.Errorf("expected provider error, got %v", err)
+			}
+		}
+		if runs.Load() != 1 {
+			t.Errorf("expected failed per-request provider to run once, ran %d times", runs.Load())
+		}
+	})
+
+	t.Run("NoProvider", func(t *testing.T) {
+		registry := NewRegistry()
+		ctx := registry.NewCtxFromNativeContext(context.Background())
+		if _, err := Resolve[*testUser](ctx); !errors.Is(err, ErrNoProvider) {
+			t.Errorf("expected ErrNoProvider, got %v", err)
+		}
+	})
+
+	t.Run("UndeclaredDep", func(t *testing.T) {
+		registry := NewRegistry()
+		Provide(registry, Singleton, func(c *TasksCtx) (*testDB, error) { return &testDB{}, nil })
+		Provide(registry, PerRequest, func(c *TasksCtx) (*testUser, error) {
+			_, err := Resolve[*testDB](c)
+			return &testUser{}, err
+		})
+		ctx := registry.NewCtxFromNativeContext(context.Background())
+		if _, err := Resolve[*testUser](ctx); !errors.Is(err, ErrUndeclaredDep) {
+			t.Errorf("expected ErrUndeclaredDep, got %v", err)
+		}
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		registry := NewRegistry()
+		Provide(registry, PerRequest, func(c *TasksCtx) (*testDB, error) { return nil, nil }, Dep[*testUser]())
+		Provide(registry, PerRequest, func(c *TasksCtx) (*testUser, error) { return nil, nil }, Dep[*testDB]())
+		Provide(registry, Singleton, func(c *TasksCtx) (*testTx, error) { return nil, nil }, Dep[*testUser](), Dep[string]())
+
+		err := ValidateBindings(registry)
+		if !errors.Is(err, ErrInvalidBindings) || !errors.Is(err, ErrNoProvider) {
+			t.Fatalf("expected ErrInvalidBindings wrapping ErrNoProvider, got %v", err)
+		}
+		for _, want := range []string{
+			"resolution cycle: *tasks.testDB -> *tasks.testUser -> *tasks.testDB",
+			"singleton provider for *tasks.testTx depends on per-request provider for *tasks.testUser",
+			"no provider bound for type string (a dependency of *tasks.testTx)",
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected error to contain %q, got %v", want, err)
+			}
+		}
+
+		// Resolution fails fast with the same error
+		ctx := registry.NewCtxFromNativeContext(context.Background())
+		if _, err := Resolve[*testDB](ctx); !errors.Is(err, ErrInvalidBindings) {
+			t.Errorf("expected ErrInvalidBindings from Resolve, got %v", err)
+		}
+	})
+
+	t.Run("DuplicateBindingPanics", func(t *testing.T) {
+		registry := NewRegistry()
+		Provide(registry, Singleton, func(c *TasksCtx) (*testDB, error) { return nil, nil })
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic on duplicate binding")
+			}
+		}()
+		Provide(registry, PerTask, func(c *TasksCtx) (*testDB, error) { return nil, nil })
+	})
+}
@@ -0,0 +1,239 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+/////////////////////////////////////////////////////////////////////
+/////// METRICS
+/////////////////////////////////////////////////////////////////////
+
+type MetricsOptions struct {
+	// Optional. Prefix for all metric names. Defaults to "river".
+	Namespace string
+
+	// Optional. Histogram bucket upper bounds, in seconds. Defaults to
+	// 5ms through 10s.
+	Buckets []float64
+}
+
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics aggregates task and routing hooks (as a tasks.Instrumentation
+// and a mux.Instrumentation) into counters and histograms, served in the
+// Prometheus text format by Handler:
+//
+//	<ns>_task_duration_seconds{task,status}            histogram (status: ok, error, canceled)
+//	<ns>_task_dedupes_total{task}                      counter
+//	<ns>_task_cancellations_total{task}                counter
+//	<ns>_route_matches_total{method,route}             counter
+//	<ns>_middleware_duration_seconds{level,kind,name}  histogram
+type Metrics struct {
+	buckets []float64
+
+	taskDuration       *family
+	taskDedupes        *family
+	taskCancellations  *family
+	routeMatches       *family
+	middlewareDuration *family
+}
+
+var (
+	_ tasks.Instrumentation = (*Metrics)(nil)
+	_ mux.Instrumentation   = (*Metrics)(nil)
+)
+
+func NewMetrics(opts *MetricsOptions) *Metrics {
+	if opts == nil {
+		opts = new(MetricsOptions)
+	}
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = "river"
+	}
+	buckets := slices.Clone(opts.Buckets)
+	if len(buckets) == 0 {
+		buckets = defaultBuckets
+	}
+	slices.Sort(buckets)
+
+	return &Metrics{
+		buckets: buckets,
+		taskDuration: newFamily(namespace+"_task_duration_seconds", "histogram",
+			"Task run time in seconds.", "task", "status"),
+		taskDedupes: newFamily(namespace+"_task_dedupes_total", "counter",
+			"Task requests served by an earlier run in the same context.", "task"),
+		taskCancellations: newFamily(namespace+"_task_cancellations_total", "counter",
+			"Tasks not run, or abandoned mid-run, because their context was canceled.", "task"),
+		routeMatches: newFamily(namespace+"_route_matches_total", "counter",
+			"Requests matched to each route.", "method", "route"),
+		middlewareDuration: newFamily(namespace+"_middleware_duration_seconds", "histogram",
+			"Middleware run time in seconds, excluding downstream handlers.", "level", "kind", "name"),
+	}
+}
+
+func (m *Metrics) TaskStarted(ctx context.Context, event tasks.TaskEvent) context.Context {
+	return ctx
+}
+
+func (m *Metrics) TaskEnded(ctx context.Context, event tasks.TaskEvent) {
+	status := "ok"
+	switch {
+	case errors.Is(event.Err, context.Canceled) || errors.Is(event.Err, context.DeadlineExceeded):
+		status = "canceled"
+	case event.Err != nil:
+		status = "error"
+	}
+	m.taskDuration.observe(m.buckets, event.Duration, event.TaskName, status)
+}
+
+func (m *Metrics) TaskDeduped(ctx context.Context, event tasks.TaskEvent) {
+	m.taskDedupes.inc(event.TaskName)
+}
+
+func (m *Metrics) TaskCanceled(ctx context.Context, event tasks.TaskEvent) {
+	m.taskCancellations.inc(event.TaskName)
+}
+
+func (m *Metrics) RouteMatched(r *http.Request, event mux.RouteEvent) {
+	m.routeMatches.inc(event.Method, event.Pattern)
+}
+
+func (m *Metrics) MiddlewareEnded(r *http.Request, event mux.MiddlewareEvent) {
+	m.middlewareDuration.observe(m.buckets, event.Duration, event.Level, event.Kind, event.Name)
+}
+
+// Handler serves all metrics in the Prometheus text exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var sb strings.Builder
+		for _, f := range []*family{m.middlewareDuration, m.routeMatches, m.taskCancellations, m.taskDedupes, m.taskDuration} {
+			f.write(&sb, m.buckets)
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write([]byte(sb.String()))
+	})
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// FAMILIES
+/////////////////////////////////////////////////////////////////////
+
+type family struct {
+	name       string
+	kind       string // "counter" or "histogram"
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues  []string
+	value        float64  // counters only
+	count        uint64   // histograms only
+	sum          float64  // histograms only
+	bucketCounts []uint64 // histograms only, non-cumulative
+}
+
+func newFamily(name, kind, help string, labelNames ...string) *family {
+	return &family{name: name, kind: kind, help: help, labelNames: labelNames, series: make(map[string]*series)}
+}
+
+// Must be called with f.mu held.
+func (f *family) get(labelValues []string) *series {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: labelValues}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) inc(labelValues ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.get(labelValues).value++
+}
+
+func (f *family) observe(buckets []float64, d time.Duration, labelValues ...string) {
+	seconds := d.Seconds()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s := f.get(labelValues)
+	if s.bucketCounts == nil {
+		s.bucketCounts = make([]uint64, len(buckets))
+	}
+	s.count++
+	s.sum += seconds
+	if i, _ := slices.BinarySearch(buckets, seconds); i < len(buckets) {
+		s.bucketCounts[i]++
+	}
+}
+
+func (f *family) write(sb *strings.Builder, buckets []float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.series) == 0 {
+		return
+	}
+
+	fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		s := f.series[k]
+		labels := f.labels(s.labelValues)
+		if f.kind == "counter" {
+			fmt.Fprintf(sb, "%s{%s} %s\n", f.name, labels, formatFloat(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, upperBound := range buckets {
+			cumulative += s.bucketCounts[i]
+			fmt.Fprintf(sb, "%s_bucket{%s,le=\"%s\"} %d\n", f.name, labels, formatFloat(upperBound), cumulative)
+		}
+		fmt.Fprintf(sb, "%s_bucket{%s,le=\"+Inf\"} %d\n", f.name, labels, s.count)
+		fmt.Fprintf(sb, "%s_sum{%s} %s\n", f.name, labels, formatFloat(s.sum))
+		fmt.Fprintf(sb, "%s_count{%s} %d\n", f.name, labels, s.count)
+	}
+}
+
+func (f *family) labels(values []string) string {
+	parts := make([]string, len(f.labelNames))
+	for i, name := range f.labelNames {
+		parts[i] = name + `="` + escapeLabelValue(values[i]) + `"`
+	}
+	return strings.Join(parts, ",")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,268 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+type collectorStub struct {
+	mu       sync.Mutex
+	requests []otlpTracesRequest
+	headers  []http.Header
+}
+
+func (c *collectorStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	var req otlpTracesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+	c.headers = append(c.headers, r.Header.Clone())
+}
+
+func (c *collectorStub) spans() []otlpSpan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var spans []otlpSpan
+	for _, req := range c.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+	}
+	return spans
+}
+
+func findSpan(spans []otlpSpan, name, traceID string) (otlpSpan, bool) {
+	for _, s := range spans {
+		if s.Name == name && (traceID == "" || s.TraceID == traceID) {
+			return s, true
+		}
+	}
+	return otlpSpan{}, false
+}
+
+func getUser(rd *mux.ReqData[mux.None]) (string, error) { return "user", nil }
+
+func newTestRouter(instrumentations ...interface {
+	tasks.Instrumentation
+	mux.Instrumentation
+}) http.Handler {
+	tasksRegistry := tasks.NewRegistry()
+	router := mux.NewRouter(&mux.Options{TasksRegistry: tasksRegistry})
+	for _, instrumentation := range instrumentations {
+		tasks.AddInstrumentation(tasksRegistry, instrumentation)
+		mux.AddInstrumentation(router, instrumentation)
+	}
+
+	auth := mux.TaskMiddlewareFromFunc(tasksRegistry, getUser)
+	mux.SetGlobalTaskMiddleware(router, auth)
+	mux.RegisterTaskHandler(router, "GET", "/users/:id", mux.TaskHandlerFromFunc(tasksRegistry, func(rd *mux.ReqData[mux.None]) (string, error) {
+		// Already run as middleware, so this is a dedupe hit
+		user, _ := auth.Get(rd.TasksCtx(), rd)
+		return user, nil
+	}))
+	mux.RegisterTaskHandler(router, "GET", "/fail", mux.TaskHandlerFromFunc(tasksRegistry, func(rd *mux.ReqData[mux.None]) (string, error) {
+		return "", errors.New("boom")
+	}))
+	return router
+}
+
+func TestTracer(t *testing.T) {
+	collector := &collectorStub{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	tracer := NewTracer(&TracerOptions{
+		ServiceName: "test-service",
+		Endpoint:    server.URL,
+		Headers:     map[string]string{"Authorization": "Bearer token"},
+	})
+	handler := tracer.Middleware(newTestRouter(tracer))
+
+	req := httptest.NewRequest("GET", "/users/5", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(collector.headers) == 0 || collector.headers[0].Get("Authorization") != "Bearer token" {
+		t.Errorf("expected auth header on export")
+	}
+	if attrs := collector.requests[0].ResourceSpans[0].Resource.Attributes; len(attrs) != 1 || *attrs[0].Value.StringValue != "test-service" {
+		t.Errorf("unexpected resource attributes %+v", attrs)
+	}
+
+	spans := collector.spans()
+	serverSpan, ok := findSpan(spans, "GET /users/:id", "")
+	if !ok {
+		t.Fatalf("expected a server span named after the route, got %+v", spans)
+	}
+	if serverSpan.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || serverSpan.ParentSpanID != "00f067aa0ba902b7" || serverSpan.Kind != spanKindServer {
+		t.Errorf("expected server span to continue the incoming trace, got %+v", serverSpan)
+	}
+
+	var eventNames []string
+	for _, e := range serverSpan.Events {
+		eventNames = append(eventNames, e.Name)
+	}
+	if strings.Join(eventNames, ",") != "middleware,task.dedupe" {
+		t.Errorf("unexpected server span events %v", eventNames)
+	}
+
+	task, ok := findSpan(spans, "task telemetry.getUser", serverSpan.TraceID)
+	if !ok || task.ParentSpanID != serverSpan.SpanID || task.Kind != spanKindInternal {
+		t.Errorf("expected task span to be a child of the server span, got %+v", task)
+	}
+
+	failing, _ := findSpan(spans, "GET /fail", "")
+	if failing.Status.Code != statusCodeError || failing.TraceID == serverSpan.TraceID || failing.ParentSpanID != "" {
+		t.Errorf("expected a new, failed root trace for the second request, got %+v", failing)
+	}
+	for _, s := range spans {
+		if strings.HasPrefix(s.Name, "task telemetry.newTestRouter") && s.TraceID == failing.TraceID {
+			if s.Status.Code != statusCodeError || s.Status.Message != "boom" {
+				t.Errorf("expected failing task span to have an error status, got %+v", s.Status)
+			}
+			return
+		}
+	}
+	t.Errorf("expected a span for the failing task, got %+v", spans)
+}
+
+func TestTracerWebSocket(t *testing.T) {
+	collector := &collectorStub{}
+	collectorServer := httptest.NewServer(collector)
+	defer collectorServer.Close()
+	tracer := NewTracer(&TracerOptions{ServiceName: "test-service", Endpoint: collectorServer.URL})
+
+	router := mux.NewRouter(&mux.Options{TasksRegistry: tasks.NewRegistry()})
+	mux.AddInstrumentation(router, tracer)
+	mux.RegisterWebSocketHandler(router, "/ws", mux.WebSocketHandlerFromFunc(
+		func(rd *mux.ReqData[mux.None], conn *mux.WebSocketConn[string, string]) error {
+			msg, err := conn.Receive()
+			if err != nil {
+				return err
+			}
+			return conn.Send("echo: " + msg)
+		},
+	))
+	server := httptest.NewServer(tracer.Middleware(router))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("expected the upgrade to succeed through the tracer: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON("hi"); err != nil {
+		t.Fatal(err)
+	}
+	var reply string
+	if err := conn.ReadJSON(&reply); err != nil || reply != "echo: hi" {
+		t.Fatalf("unexpected reply %q, %v", reply, err)
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	conn.Close()
+
+	// The span ends once the handler returns
+	defer tracer.Shutdown(context.Background())
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if err := tracer.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if span, ok := findSpan(collector.spans(), "GET /ws", ""); ok {
+			if span.Status.Code == statusCodeError {
+				t.Errorf("expected a successful span, got %+v", span.Status)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a span for the websocket route, got %+v", collector.spans())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	if _, ok := parseTraceparent("00-00000000000000000000000000000000-00f067aa0ba902b7-01"); ok {
+		t.Error("expected all-zero trace id to be rejected")
+	}
+	p, ok := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok {
+		t.Fatal("expected valid traceparent")
+	}
+	tracer := &Tracer{}
+	s := tracer.startSpan(context.WithValue(context.Background(), remoteParentCtxKey{}, p), "x", spanKindServer, time.Now())
+	got := Traceparent(context.WithValue(context.Background(), spanCtxKey{}, s))
+	if !strings.HasPrefix(got, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || strings.Contains(got, "00f067aa0ba902b7") {
+		t.Errorf("unexpected traceparent %q", got)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics(&MetricsOptions{Namespace: "app", Buckets: []float64{1, 0.5}})
+	handler := newTestRouter(metrics)
+
+	for range 2 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/5", nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+
+	rr := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rr.Body)
+	out := string(body)
+
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	for _, want := range []string{
+		"# TYPE app_task_duration_seconds histogram\n",
+		`app_task_duration_seconds_bucket{task="telemetry.getUser",status="ok",le="0.5"} 3`,
+		`app_task_duration_seconds_bucket{task="telemetry.getUser",status="ok",le="+Inf"} 3`,
+		`app_task_duration_seconds_count{task="telemetry.getUser",status="ok"} 3`,
+		`status="error",le="1"} 1`,
+		`app_task_dedupes_total{task="telemetry.getUser"} 2`,
+		`app_route_matches_total{method="GET",route="/users/:id"} 2`,
+		`app_route_matches_total{method="GET",route="/fail"} 1`,
+		`app_middleware_duration_seconds_count{level="global",kind="task",name="telemetry.getUser"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "app_task_cancellations_total") {
+		t.Errorf("expected no cancellation series, got:\n%s", out)
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if got := escapeLabelValue("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,443 @@
+// Package telemetry provides tracing and metrics adapters for the
+// instrumentation hooks in kit/tasks and kit/mux: a Tracer that exports
+// spans to any OpenTelemetry collector via OTLP/HTTP (JSON), and Metrics
+// that serves aggregates in the Prometheus text format.
+package telemetry
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sjc5/river/kit/colorlog"
+	"github.com/sjc5/river/kit/mux"
+	"github.com/sjc5/river/kit/tasks"
+)
+
+var Log = colorlog.New("telemetry")
+
+/////////////////////////////////////////////////////////////////////
+/////// SPANS
+/////////////////////////////////////////////////////////////////////
+
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+
+	statusCodeError = 2
+)
+
+type traceID [16]byte
+type spanID [8]byte
+
+type attribute struct {
+	key   string
+	value any // string, int64, float64 or bool
+}
+
+type spanEvent struct {
+	name  string
+	time  time.Time
+	attrs []attribute
+}
+
+type span struct {
+	mu sync.Mutex
+
+	traceID  traceID
+	spanID   spanID
+	parentID spanID
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    []attribute
+	events   []spanEvent
+
+	statusCode    int
+	statusMessage string
+}
+
+func (s *span) setName(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *span) setAttrs(attrs ...attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+func (s *span) addEvent(name string, attrs ...attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, spanEvent{name: name, time: time.Now(), attrs: attrs})
+}
+
+func (s *span) setError(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusCode = statusCodeError
+	s.statusMessage = message
+}
+
+type spanCtxKey struct{}
+type remoteParentCtxKey struct{}
+
+type remoteParent struct {
+	traceID traceID
+	spanID  spanID
+}
+
+func spanFromContext(ctx context.Context) *span {
+	s, _ := ctx.Value(spanCtxKey{}).(*span)
+	return s
+}
+
+// Traceparent returns a W3C traceparent header value for the span active
+// in ctx (started by Tracer.Middleware or for a task), for propagating the
+// trace to outgoing requests. Returns an empty string if there is none.
+func Traceparent(ctx context.Context) string {
+	s := spanFromContext(ctx)
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", hex.EncodeToString(s.traceID[:]), hex.EncodeToString(s.spanID[:]))
+}
+
+// Parses a W3C traceparent header ("00-<trace id>-<parent id>-<flags>").
+func parseTraceparent(header string) (*remoteParent, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return nil, false
+	}
+	var p remoteParent
+	if _, err := hex.Decode(p.traceID[:], []byte(parts[1])); err != nil || p.traceID == (traceID{}) {
+		return nil, false
+	}
+	if _, err := hex.Decode(p.spanID[:], []byte(parts[2])); err != nil || p.spanID == (spanID{}) {
+		return nil, false
+	}
+	return &p, true
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// TRACER
+/////////////////////////////////////////////////////////////////////
+
+type TracerOptions struct {
+	// Required. Reported as the "service.name" resource attribute.
+	ServiceName string
+
+	// Required. Base URL of an OTLP/HTTP collector, e.g.,
+	// "http://localhost:4318". Spans are POSTed as JSON to
+	// Endpoint + "/v1/traces".
+	Endpoint string
+
+	// Optional. Extra headers sent with every export, e.g., for auth.
+	Headers map[string]string
+
+	// Optional. Defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+
+	// Optional. Spans are exported in batches of up to this many, whenever
+	// a batch fills up or FlushInterval elapses. Defaults to 512.
+	BatchSize int
+
+	// Optional. Defaults to 5 seconds.
+	FlushInterval time.Duration
+
+	// Optional. Finished spans beyond this many, while waiting to be
+	// exported, are dropped. Defaults to 4 * BatchSize.
+	MaxQueueSize int
+}
+
+// A Tracer records spans for requests (see Middleware), tasks (as a
+// tasks.Instrumentation) and routing (as a mux.Instrumentation), and
+// exports them to an OpenTelemetry collector in the background. Incoming
+// W3C traceparent headers are honored, and every trace is recorded.
+type Tracer struct {
+	opts     TracerOptions
+	resource []attribute
+
+	mu      sync.Mutex
+	queue   []*span
+	dropped int
+
+	flushCh    chan struct{}
+	shutdownCh chan struct{}
+	doneCh     chan struct{}
+	closeOnce  sync.Once
+}
+
+var (
+	_ tasks.Instrumentation = (*Tracer)(nil)
+	_ mux.Instrumentation   = (*Tracer)(nil)
+)
+
+func NewTracer(opts *TracerOptions) *Tracer {
+	if opts == nil || opts.ServiceName == "" || opts.Endpoint == "" {
+		panic("telemetry: tracer requires a ServiceName and an Endpoint")
+	}
+
+	t := &Tracer{
+		opts:       *opts,
+		resource:   []attribute{{key: "service.name", value: opts.ServiceName}},
+		flushCh:    make(chan struct{}, 1),
+		shutdownCh: make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+	if t.opts.HTTPClient == nil {
+		t.opts.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if t.opts.BatchSize <= 0 {
+		t.opts.BatchSize = 512
+	}
+	if t.opts.FlushInterval <= 0 {
+		t.opts.FlushInterval = 5 * time.Second
+	}
+	if t.opts.MaxQueueSize <= 0 {
+		t.opts.MaxQueueSize = 4 * t.opts.BatchSize
+	}
+
+	go t.run()
+
+	return t
+}
+
+// Middleware starts a server span for each request, which becomes the
+// parent of the request's task spans. Use it as the outermost middleware
+// (wrapping the router), so that the span covers the whole request.
+func (t *Tracer) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if p, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+			ctx = context.WithValue(ctx, remoteParentCtxKey{}, p)
+		}
+
+		s := t.startSpan(ctx, r.Method, spanKindServer, time.Now())
+		s.setAttrs(
+			attribute{key: "http.request.method", value: r.Method},
+			attribute{key: "url.path", value: r.URL.Path},
+			attribute{key: "server.address", value: r.Host},
+		)
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(ctx, spanCtxKey{}, s)))
+
+		s.setAttrs(attribute{key: "http.response.status_code", value: int64(sw.status)})
+		if sw.status >= 500 {
+			s.setError(http.StatusText(sw.status))
+		}
+		t.endSpan(s, time.Now())
+	})
+}
+
+// Names the request's server span after the matched route (e.g.,
+// "GET /users/:id"), per OpenTelemetry HTTP semantic conventions.
+func (t *Tracer) RouteMatched(r *http.Request, event mux.RouteEvent) {
+	if s := spanFromContext(r.Context()); s != nil {
+		s.setName(event.Method + " " + event.Pattern)
+		s.setAttrs(attribute{key: "http.route", value: event.Pattern})
+	}
+}
+
+// Records a "middleware" event on the request's server span.
+func (t *Tracer) MiddlewareEnded(r *http.Request, event mux.MiddlewareEvent) {
+	s := spanFromContext(r.Context())
+	if s == nil {
+		return
+	}
+	attrs := []attribute{
+		{key: "middleware.level", value: event.Level},
+		{key: "middleware.kind", value: event.Kind},
+		{key: "middleware.name", value: event.Name},
+		{key: "middleware.duration_ms", value: float64(event.Duration) / float64(time.Millisecond)},
+	}
+	if event.Err != nil {
+		attrs = append(attrs, attribute{key: "error.message", value: event.Err.Error()})
+	}
+	s.addEvent("middleware", attrs...)
+}
+
+// Starts a span for the task, as a child of the span in ctx (if any).
+func (t *Tracer) TaskStarted(ctx context.Context, event tasks.TaskEvent) context.Context {
+	s := t.startSpan(ctx, "task "+event.TaskName, spanKindInternal, event.Start)
+	s.setAttrs(
+		attribute{key: "task.id", value: int64(event.TaskID)},
+		attribute{key: "task.name", value: event.TaskName},
+	)
+	return context.WithValue(ctx, spanCtxKey{}, s)
+}
+
+func (t *Tracer) TaskEnded(ctx context.Context, event tasks.TaskEvent) {
+	s := spanFromContext(ctx)
+	if s == nil {
+		return
+	}
+	s.setAttrs(attribute{key: "task.attempts", value: int64(event.Attempts)})
+	if event.Err != nil {
+		s.setError(event.Err.Error())
+	}
+	t.endSpan(s, event.Start.Add(event.Duration))
+}
+
+// Records a "task.dedupe" event on the span in ctx (typically the
+// request's server span).
+func (t *Tracer) TaskDeduped(ctx context.Context, event tasks.TaskEvent) {
+	if s := spanFromContext(ctx); s != nil {
+		s.addEvent("task.dedupe", attribute{key: "task.name", value: event.TaskName})
+	}
+}
+
+// Records a "task.canceled" event on the span in ctx (typically the
+// request's server span).
+func (t *Tracer) TaskCanceled(ctx context.Context, event tasks.TaskEvent) {
+	if s := spanFromContext(ctx); s != nil {
+		s.addEvent("task.canceled", attribute{key: "task.name", value: event.TaskName})
+	}
+}
+
+func (t *Tracer) startSpan(ctx context.Context, name string, kind int, start time.Time) *span {
+	s := &span{name: name, kind: kind, start: start}
+	switch {
+	case spanFromContext(ctx) != nil:
+		parent := spanFromContext(ctx)
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	case ctx.Value(remoteParentCtxKey{}) != nil:
+		parent := ctx.Value(remoteParentCtxKey{}).(*remoteParent)
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	default:
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.spanID[:])
+	return s
+}
+
+func (t *Tracer) endSpan(s *span, end time.Time) {
+	s.mu.Lock()
+	s.end = end
+	s.mu.Unlock()
+
+	t.mu.Lock()
+	if len(t.queue) >= t.opts.MaxQueueSize {
+		t.dropped++
+		t.mu.Unlock()
+		return
+	}
+	t.queue = append(t.queue, s)
+	full := len(t.queue) >= t.opts.BatchSize
+	t.mu.Unlock()
+
+	if full {
+		select {
+		case t.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush exports all finished spans now.
+func (t *Tracer) Flush(ctx context.Context) error {
+	var errs []error
+	for {
+		t.mu.Lock()
+		n := min(len(t.queue), t.opts.BatchSize)
+		batch := t.queue[:n:n]
+		t.queue = t.queue[n:]
+		dropped := t.dropped
+		t.dropped = 0
+		t.mu.Unlock()
+
+		if dropped > 0 {
+			Log.Warn(fmt.Sprintf("dropped %d spans because the export queue was full", dropped))
+		}
+		if len(batch) == 0 {
+			return errors.Join(errs...)
+		}
+		if err := t.export(ctx, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+}
+
+// Shutdown stops background exports and flushes any remaining spans.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.closeOnce.Do(func() { close(t.shutdownCh) })
+	select {
+	case <-t.doneCh:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.Flush(ctx)
+}
+
+func (t *Tracer) run() {
+	defer close(t.doneCh)
+
+	ticker := time.NewTicker(t.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.shutdownCh:
+			return
+		case <-ticker.C:
+		case <-t.flushCh:
+		}
+		if err := t.Flush(context.Background()); err != nil {
+			Log.Error(fmt.Sprintf("error exporting spans: %v", err))
+		}
+	}
+}
+
+/////////////////////////////////////////////////////////////////////
+/////// HELPERS
+/////////////////////////////////////////////////////////////////////
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	if !sw.wroteHeader {
+		sw.status = status
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(b)
+}
+
+// Lets http.ResponseController reach the underlying writer (e.g., for
+// flushing streams).
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
+// WebSocket libraries (e.g., gorilla/websocket) upgrade connections with a
+// type assertion to http.Hijacker rather than through
+// http.ResponseController, so the writer must implement it directly.
+func (sw *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(sw.ResponseWriter).Hijack()
+	if err == nil && !sw.wroteHeader {
+		sw.status = http.StatusSwitchingProtocols
+		sw.wroteHeader = true
+	}
+	return conn, rw, err
+}
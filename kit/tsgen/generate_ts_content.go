@@ -62,6 +62,10 @@ func GenerateTSContent(opts Opts) (string, error) {
 type CollectionItem struct {
 	ArbitraryProperties map[string]any
 	PhantomTypes        map[string]AdHocType
+	// Like PhantomTypes, but with the TypeScript type given directly as a
+	// string (e.g., "{ id: number; slug?: string }"), for types that have no
+	// Go counterpart.
+	PhantomTSTypes map[string]string
 }
 
 // Anything you'd like to add to a TypeScript type object,
@@ -174,6 +178,10 @@ func getCollectionStr(opts Opts, merged tsgencore.Results) (string, error) {
 			lines = append(lines, phantomTypeLine.String())
 		}
 
+		for propertyName, tsType := range item.PhantomTSTypes {
+			lines = append(lines, "\t\t"+propertyName+": null as unknown as "+tsType+",\n")
+		}
+
 		slices.Sort(lines)
 
 		item := &strings.Builder{}
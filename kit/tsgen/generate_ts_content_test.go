@@ -6,6 +6,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sjc5/river/kit/upload"
 )
 
 func TestGenerateTypeScript(t *testing.T) {
@@ -421,6 +423,55 @@ func TestGenerateTSContent_TypesWithTimeField(t *testing.T) {
 	assertContains(t, content, "Created: ")
 }
 
+func TestGenerateTSContent_UploadFileFields(t *testing.T) {
+	type UploadInput struct {
+		Title       string         `json:"title"`
+		Avatar      *upload.File   `json:"avatar"`
+		Attachments []*upload.File `json:"attachments"`
+	}
+
+	opts := Opts{
+		Collection: []CollectionItem{
+			{
+				ArbitraryProperties: map[string]any{"pattern": "/upload"},
+				PhantomTypes: map[string]AdHocType{
+					"phantomInputType": {TypeInstance: &UploadInput{}, TSTypeName: "UploadInput"},
+				},
+			},
+		},
+		CollectionVarName: "routes",
+	}
+
+	content, err := GenerateTSContent(opts)
+	if err != nil {
+		t.Fatalf("GenerateTSContent failed: %v", err)
+	}
+
+	assertContains(t, content, "avatar?: File | Blob;")
+	assertContains(t, content, "attachments: Array<File | Blob>;")
+	assertNotContains(t, content, "export type File ")
+	assertNotContains(t, content, "Filename")
+}
+
+func TestGenerateTSContent_PhantomTSTypes(t *testing.T) {
+	opts := Opts{
+		Collection: []CollectionItem{
+			{
+				ArbitraryProperties: map[string]any{"pattern": "/users/:id<int>"},
+				PhantomTSTypes:      map[string]string{"phantomParamsType": "{ \"id\": `${number}` }"},
+			},
+		},
+		CollectionVarName: "routes",
+	}
+
+	content, err := GenerateTSContent(opts)
+	if err != nil {
+		t.Fatalf("GenerateTSContent failed: %v", err)
+	}
+
+	assertContains(t, content, "phantomParamsType: null as unknown as { \"id\": `${number}` },")
+}
+
 // TestGenerateTSContent_EmptyNameHandling tests handling of empty or anonymous names
 func TestGenerateTSContent_AnonAndUnnamedShouldBeSkipped(t *testing.T) {
 	opts := Opts{
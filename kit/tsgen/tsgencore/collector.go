@@ -61,6 +61,10 @@ func (c *typeCollector) getOrCreateEntry(t reflect.Type, userDefinedAlias ...str
 func (c *typeCollector) collectType(t reflect.Type, userDefinedAlias ...string) {
 	isRoot := (t == c.rootType)
 
+	if _, ok := GetTypeOverride(t); ok && !isRoot {
+		return
+	}
+
 	if t.Name() != "" || isRoot {
 		entry := c.getOrCreateEntry(t, userDefinedAlias...)
 		if entry.visited {
@@ -140,6 +144,9 @@ func (c *typeCollector) collectStructFields(t reflect.Type) {
 }
 
 func (c *typeCollector) collectFieldType(t reflect.Type) {
+	if _, ok := GetTypeOverride(t); ok {
+		return
+	}
 	switch t.Kind() {
 	case reflect.Struct:
 		entry := c.getOrCreateEntry(t)
@@ -241,6 +248,9 @@ func (c *typeCollector) buildDefinitions() (_results, IDStr) {
 	reflectTypeToID := make(map[reflect.Type]IDStr)
 
 	for t, entry := range c.types {
+		if _, ok := GetTypeOverride(t); ok && t != c.rootType {
+			continue
+		}
 		if t.Kind() == reflect.Struct {
 			if t != c.rootType && entry.usedAsEmbedded && !entry.isReferenced {
 				continue
@@ -441,6 +451,10 @@ func (c *typeCollector) getTypeScriptType(t reflect.Type) string {
 
 	var typeStr string
 
+	if override, ok := GetTypeOverride(t); ok {
+		return override
+	}
+
 	switch t.Kind() {
 	case reflect.Interface:
 		typeStr = "unknown"
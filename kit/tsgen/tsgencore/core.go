@@ -24,6 +24,29 @@ func IsMarkedNullable(t reflect.Type) bool {
 	return t != nil && t.Implements(NullableMarkerReflectType)
 }
 
+// If you want a type to be represented by an arbitrary TypeScript type
+// (e.g., "File | Blob") instead of one derived from its Go type, add a
+// "TSTypeOverride() string" method to the type. The value must not depend
+// on the receiver's state.
+
+type TSTypeOverrider interface{ TSTypeOverride() string }
+
+var TypeOverriderReflectType = reflect.TypeOf((*TSTypeOverrider)(nil)).Elem()
+
+// GetTypeOverride returns the override for t (or for *t), if any.
+func GetTypeOverride(t reflect.Type) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	if t.Kind() != reflect.Ptr {
+		t = reflect.PointerTo(t)
+	}
+	if !t.Implements(TypeOverriderReflectType) {
+		return "", false
+	}
+	return reflect.New(t.Elem()).Interface().(TSTypeOverrider).TSTypeOverride(), true
+}
+
 type IDStr = string
 type _results = map[IDStr]*TypeInfo
 
@@ -0,0 +1,304 @@
+// Package upload parses multipart/form-data request bodies with explicit size
+// limits. Small files are held in memory, larger files are spilled to
+// temporary files, and every file's content type is sniffed from its leading
+// bytes rather than trusted from the client.
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/sjc5/river/kit/ioutil"
+	"github.com/sjc5/river/kit/opt"
+)
+
+const (
+	DefaultMaxFileSize    = 10 * ioutil.OneMB
+	DefaultMaxTotalSize   = 32 * ioutil.OneMB
+	DefaultMaxValueSize   = 64 * ioutil.OneKB
+	DefaultMaxFiles       = 16
+	DefaultSpillThreshold = 1 * ioutil.OneMB
+)
+
+// Number of leading bytes considered by http.DetectContentType.
+const sniffLen = 512
+
+var (
+	// ErrTooLarge is wrapped by all errors caused by a size or count limit.
+	ErrTooLarge = errors.New("upload too large")
+	// ErrContentTypeNotAllowed is wrapped by errors caused by a file whose
+	// sniffed content type is not in Options.AllowedContentTypes.
+	ErrContentTypeNotAllowed = errors.New("upload content type not allowed")
+	// ErrNotMultipart is returned when the request is not multipart/form-data.
+	ErrNotMultipart = errors.New("request is not multipart/form-data")
+)
+
+type Options struct {
+	// Optional. Maximum size of any single file. Defaults to 10 MB.
+	MaxFileSize uint64
+	// Optional. Maximum size of the entire request body. Defaults to 32 MB.
+	MaxTotalSize uint64
+	// Optional. Maximum size of any single non-file value. Defaults to 64 KB.
+	MaxValueSize uint64
+	// Optional. Maximum number of files. Defaults to 16.
+	MaxFiles int
+	// Optional. Files larger than this are written to a temporary file
+	// instead of being held in memory. Defaults to 1 MB.
+	SpillThreshold uint64
+	// Optional. Directory for spilled files. Defaults to os.TempDir().
+	TempDir string
+	// Optional. If set, files whose sniffed content type does not match
+	// any entry are rejected. Entries ending in "/" match by prefix (e.g.,
+	// "image/"), all others must match the media type exactly (e.g.,
+	// "text/csv").
+	AllowedContentTypes []string
+}
+
+// File is an uploaded file. Use Open to stream its contents.
+//
+// When used as an input struct field, File is represented as "File | Blob"
+// in generated TypeScript.
+type File struct {
+	FieldName string
+	Filename  string
+	Size      int64
+	// Sniffed from the file's leading bytes via http.DetectContentType.
+	ContentType string
+	// The content type claimed by the client. Do not trust it.
+	DeclaredContentType string
+	Header              textproto.MIMEHeader
+
+	data []byte
+	path string
+}
+
+// Open returns a reader over the file's contents. Callers must close it.
+func (f *File) Open() (io.ReadCloser, error) {
+	if f.path != "" {
+		return os.Open(f.path)
+	}
+	return io.NopCloser(bytes.NewReader(f.data)), nil
+}
+
+// IsInMemory reports whether the file is held in memory (as opposed to
+// having been spilled to a temporary file).
+func (f *File) IsInMemory() bool {
+	return f.path == ""
+}
+
+// Remove deletes the file's temporary file, if any.
+func (f *File) Remove() error {
+	if f.path == "" {
+		return nil
+	}
+	err := os.Remove(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	return err
+}
+
+// TSTypeOverride is used by kit/tsgen.
+func (f File) TSTypeOverride() string {
+	return "File | Blob"
+}
+
+type Form struct {
+	Values url.Values
+	Files  map[string][]*File
+}
+
+// RemoveAll deletes the temporary files of all files in the form.
+func (f *Form) RemoveAll() error {
+	var errs []error
+	for _, files := range f.Files {
+		for _, file := range files {
+			if err := file.Remove(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Parse reads a multipart/form-data request body, enforcing the limits in
+// opts (which may be nil). Temporary files are removed automatically when
+// the request's context is done (for server requests, when the handler
+// returns), or can be removed earlier via Form.RemoveAll.
+func Parse(r *http.Request, opts *Options) (*Form, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return nil, ErrNotMultipart
+	}
+
+	maxTotalSize := opt.Resolve(opts, getOpt(opts).MaxTotalSize, DefaultMaxTotalSize)
+	r.Body = http.MaxBytesReader(nil, r.Body, int64(maxTotalSize))
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("upload.Parse: %w", err)
+	}
+
+	form := &Form{Values: make(url.Values), Files: make(map[string][]*File)}
+	context.AfterFunc(r.Context(), func() { form.RemoveAll() })
+
+	if err := parseParts(mr, form, opts); err != nil {
+		form.RemoveAll()
+		if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+			return nil, fmt.Errorf("upload.Parse: %w: request body exceeds %d bytes", ErrTooLarge, maxTotalSize)
+		}
+		return nil, fmt.Errorf("upload.Parse: %w", err)
+	}
+
+	return form, nil
+}
+
+func parseParts(mr *multipart.Reader, form *Form, opts *Options) error {
+	o := getOpt(opts)
+	maxValueSize := opt.Resolve(opts, o.MaxValueSize, DefaultMaxValueSize)
+	maxFiles := opt.Resolve(opts, o.MaxFiles, DefaultMaxFiles)
+
+	fileCount := 0
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := part.FormName()
+		if name == "" {
+			part.Close()
+			continue
+		}
+
+		if part.FileName() == "" {
+			value, err := ioutil.ReadLimited(part, maxValueSize)
+			part.Close()
+			if errors.Is(err, ioutil.ErrReadLimitExceeded) {
+				return fmt.Errorf("%w: value %q exceeds %d bytes", ErrTooLarge, name, maxValueSize)
+			}
+			if err != nil {
+				return err
+			}
+			form.Values.Add(name, string(value))
+			continue
+		}
+
+		fileCount++
+		if fileCount > maxFiles {
+			part.Close()
+			return fmt.Errorf("%w: more than %d files", ErrTooLarge, maxFiles)
+		}
+
+		file, err := readFile(part, opts)
+		part.Close()
+		if file != nil {
+			// Track the file even on error so that its temp file is removed
+			form.Files[name] = append(form.Files[name], file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func readFile(part *multipart.Part, opts *Options) (*File, error) {
+	o := getOpt(opts)
+	maxFileSize := opt.Resolve(opts, o.MaxFileSize, DefaultMaxFileSize)
+	spillThreshold := opt.Resolve(opts, o.SpillThreshold, DefaultSpillThreshold)
+
+	file := &File{
+		FieldName:           part.FormName(),
+		Filename:            part.FileName(),
+		DeclaredContentType: part.Header.Get("Content-Type"),
+		Header:              part.Header,
+	}
+
+	// Read enough to both sniff the content type and decide whether to spill
+	var head bytes.Buffer
+	headLimit := max(spillThreshold+1, sniffLen)
+	n, err := io.CopyN(&head, part, int64(headLimit))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if uint64(n) > maxFileSize {
+		return nil, fileTooLargeErr(file, maxFileSize)
+	}
+
+	file.ContentType = http.DetectContentType(head.Bytes()[:min(head.Len(), sniffLen)])
+	if !isAllowedContentType(file.ContentType, o.AllowedContentTypes) {
+		return nil, fmt.Errorf("%w: file %q has content type %q", ErrContentTypeNotAllowed, file.Filename, file.ContentType)
+	}
+
+	if uint64(n) <= spillThreshold {
+		file.data = head.Bytes()
+		file.Size = n
+		return file, nil
+	}
+
+	tmp, err := os.CreateTemp(o.TempDir, "river-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	file.path = tmp.Name()
+	defer tmp.Close()
+
+	if _, err := head.WriteTo(tmp); err != nil {
+		return file, err
+	}
+	rest, err := ioutil.CopyLimited(tmp, part, maxFileSize-uint64(n))
+	if errors.Is(err, ioutil.ErrReadLimitExceeded) {
+		return file, fileTooLargeErr(file, maxFileSize)
+	}
+	if err != nil {
+		return file, err
+	}
+	file.Size = n + rest
+
+	return file, tmp.Close()
+}
+
+func fileTooLargeErr(file *File, maxFileSize uint64) error {
+	return fmt.Errorf("%w: file %q exceeds %d bytes", ErrTooLarge, file.Filename, maxFileSize)
+}
+
+func isAllowedContentType(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		if strings.HasSuffix(a, "/") {
+			if strings.HasPrefix(mediaType, a) {
+				return true
+			}
+		} else if mediaType == a {
+			return true
+		}
+	}
+	return false
+}
+
+func getOpt(opts *Options) *Options {
+	if opts == nil {
+		return &Options{}
+	}
+	return opts
+}
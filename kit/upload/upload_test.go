@@ -0,0 +1,204 @@
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\x0D\x0A\x1A\x0A")
+
+type testPart struct {
+	name     string
+	filename string
+	content  []byte
+}
+
+func newMultipartRequest(t *testing.T, parts ...testPart) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, p := range parts {
+		var w io.Writer
+		var err error
+		if p.filename != "" {
+			w, err = mw.CreateFormFile(p.name, p.filename)
+		} else {
+			w, err = mw.CreateFormField(p.name)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(p.content)
+	}
+	mw.Close()
+	r := httptest.NewRequest("POST", "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func readAll(t *testing.T, f *File) []byte {
+	t.Helper()
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestParse(t *testing.T) {
+	png := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{0}, 100)...)
+	r := newMultipartRequest(t,
+		testPart{name: "title", content: []byte("hello")},
+		testPart{name: "avatar", filename: "a.png", content: png},
+		testPart{name: "docs", filename: "a.txt", content: []byte("one")},
+		testPart{name: "docs", filename: "b.txt", content: []byte("two")},
+	)
+
+	form, err := Parse(r, nil)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	defer form.RemoveAll()
+
+	if form.Values.Get("title") != "hello" {
+		t.Errorf("expected title value, got %q", form.Values.Get("title"))
+	}
+	avatar := form.Files["avatar"][0]
+	if avatar.Filename != "a.png" || avatar.Size != int64(len(png)) {
+		t.Errorf("unexpected avatar metadata: %+v", avatar)
+	}
+	if avatar.ContentType != "image/png" {
+		t.Errorf("expected sniffed content type image/png, got %q", avatar.ContentType)
+	}
+	if avatar.DeclaredContentType != "application/octet-stream" {
+		t.Errorf("expected declared content type to be kept, got %q", avatar.DeclaredContentType)
+	}
+	if !avatar.IsInMemory() {
+		t.Error("expected small file to be held in memory")
+	}
+	if !bytes.Equal(readAll(t, avatar), png) {
+		t.Error("avatar contents mismatch")
+	}
+	if len(form.Files["docs"]) != 2 || string(readAll(t, form.Files["docs"][1])) != "two" {
+		t.Errorf("expected two docs, got %v", form.Files["docs"])
+	}
+}
+
+func TestParseSpillsLargeFiles(t *testing.T) {
+	content := bytes.Repeat([]byte("abcdefgh"), 1024)
+	r := newMultipartRequest(t, testPart{name: "f", filename: "big.txt", content: content})
+
+	form, err := Parse(r, &Options{SpillThreshold: 1024, TempDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	f := form.Files["f"][0]
+	if f.IsInMemory() {
+		t.Fatal("expected large file to be spilled to disk")
+	}
+	if f.Size != int64(len(content)) || !bytes.Equal(readAll(t, f), content) {
+		t.Errorf("spilled file contents mismatch (size %d)", f.Size)
+	}
+	if !strings.HasPrefix(f.ContentType, "text/plain") {
+		t.Errorf("expected text/plain, got %q", f.ContentType)
+	}
+
+	path := f.path
+	if err := form.RemoveAll(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected temp file to be removed")
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    *Options
+		parts   []testPart
+		wantErr error
+	}{
+		{
+			name:    "file too large in memory",
+			opts:    &Options{MaxFileSize: 10},
+			parts:   []testPart{{name: "f", filename: "f.txt", content: bytes.Repeat([]byte("x"), 11)}},
+			wantErr: ErrTooLarge,
+		},
+		{
+			name:    "file too large after spilling",
+			opts:    &Options{MaxFileSize: 4096, SpillThreshold: 1024, TempDir: os.TempDir()},
+			parts:   []testPart{{name: "f", filename: "f.txt", content: bytes.Repeat([]byte("x"), 4097)}},
+			wantErr: ErrTooLarge,
+		},
+		{
+			name:    "value too large",
+			opts:    &Options{MaxValueSize: 3},
+			parts:   []testPart{{name: "v", content: []byte("abcd")}},
+			wantErr: ErrTooLarge,
+		},
+		{
+			name: "too many files",
+			opts: &Options{MaxFiles: 1},
+			parts: []testPart{
+				{name: "f", filename: "a.txt", content: []byte("a")},
+				{name: "f", filename: "b.txt", content: []byte("b")},
+			},
+			wantErr: ErrTooLarge,
+		},
+		{
+			name:    "request too large",
+			opts:    &Options{MaxTotalSize: 100},
+			parts:   []testPart{{name: "v", content: bytes.Repeat([]byte("x"), 200)}},
+			wantErr: ErrTooLarge,
+		},
+		{
+			name:    "content type not allowed",
+			opts:    &Options{AllowedContentTypes: []string{"image/"}},
+			parts:   []testPart{{name: "f", filename: "f.txt", content: []byte("plain text")}},
+			wantErr: ErrContentTypeNotAllowed,
+		},
+		{
+			name:  "content type allowed by prefix",
+			opts:  &Options{AllowedContentTypes: []string{"image/"}},
+			parts: []testPart{{name: "f", filename: "f.png", content: pngHeader}},
+		},
+		{
+			name:  "content type allowed exactly",
+			opts:  &Options{AllowedContentTypes: []string{"text/plain"}},
+			parts: []testPart{{name: "f", filename: "f.txt", content: []byte("plain text")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form, err := Parse(newMultipartRequest(t, tt.parts...), tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if form != nil {
+				form.RemoveAll()
+			}
+		})
+	}
+}
+
+func TestParseNotMultipart(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader("a=1"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := Parse(r, nil); !errors.Is(err, ErrNotMultipart) {
+		t.Errorf("expected ErrNotMultipart, got %v", err)
+	}
+}
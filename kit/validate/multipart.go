@@ -0,0 +1,76 @@
+package validate
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sjc5/river/kit/upload"
+)
+
+var (
+	uploadFilePtrType   = reflect.TypeOf((*upload.File)(nil))
+	uploadFileSliceType = reflect.TypeOf([]*upload.File(nil))
+)
+
+func isUploadFileType(t reflect.Type) bool {
+	return t == uploadFilePtrType || t == uploadFileSliceType
+}
+
+func setFileFields(v reflect.Value, files map[string][]*upload.File) error {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("validate.setFileFields: destination must point to a struct")
+	}
+
+	t := v.Type()
+	for i := range v.NumField() {
+		field := t.Field(i)
+		fieldValue := v.Field(i)
+
+		if field.Anonymous {
+			if fieldValue.Kind() == reflect.Ptr {
+				if fieldValue.IsNil() || fieldValue.Elem().Kind() != reflect.Struct {
+					continue
+				}
+				fieldValue = fieldValue.Elem()
+			}
+			if fieldValue.Kind() == reflect.Struct {
+				if err := setFileFields(fieldValue, files); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if !fieldValue.CanSet() || !isUploadFileType(field.Type) {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldFiles := files[name]
+		if len(fieldFiles) == 0 {
+			continue
+		}
+
+		if field.Type == uploadFilePtrType {
+			if len(fieldFiles) > 1 {
+				return fmt.Errorf("field %s accepts a single file, got %d", field.Name, len(fieldFiles))
+			}
+			fieldValue.Set(reflect.ValueOf(fieldFiles[0]))
+		} else {
+			fieldValue.Set(reflect.ValueOf(fieldFiles))
+		}
+	}
+
+	return nil
+}
@@ -33,6 +33,11 @@ func setNestedField(v reflect.Value, values map[string][]string) error {
 		field := t.Field(i)
 		fieldValue := v.Field(i)
 
+		// Uploaded files are bound separately (see MultipartInto)
+		if isUploadFileType(field.Type) {
+			continue
+		}
+
 		if fieldValue.Kind() == reflect.Ptr {
 			kind := fieldValue.Type().Elem().Kind()
 			if kind == reflect.Struct || kind == reflect.Map || kind == reflect.Slice {
@@ -7,6 +7,9 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"strings"
+
+	"github.com/sjc5/river/kit/upload"
 )
 
 // JSONBodyInto decodes an HTTP request body into a struct and validates it.
@@ -53,6 +56,56 @@ func URLSearchParamsInto(r *http.Request, destStructPtr any) error {
 	return nil
 }
 
+// DefaultMaxFormMemory is the maximum number of bytes of a multipart form
+// that FormInto will hold in memory (the remainder is stored in temporary
+// files).
+const DefaultMaxFormMemory = 32 << 20
+
+// FormInto parses the form body (application/x-www-form-urlencoded or
+// multipart/form-data) of an HTTP request into a struct and validates it.
+// URL search params are not included. File parts of multipart forms are
+// left on r.MultipartForm.
+func FormInto(r *http.Request, destStructPtr any) error {
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err = r.ParseMultipartForm(DefaultMaxFormMemory)
+	} else {
+		err = r.ParseForm()
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing form: %w", err)
+	}
+	if err := parseURLValues(r.PostForm, destStructPtr); err != nil {
+		return fmt.Errorf("error parsing form values: %w", err)
+	}
+	if err := attemptValidation("validate.FormInto", destStructPtr); err != nil {
+		return fmt.Errorf("error validating form values: %w", err)
+	}
+	return nil
+}
+
+// MultipartInto parses a multipart/form-data request body into a struct and
+// validates it. Non-file values are bound like FormInto. Uploaded files are
+// bound to top-level fields of type *upload.File or []*upload.File, matched
+// by JSON tag (or field name). The limits in opts (which may be nil) are
+// enforced while reading the body.
+func MultipartInto(r *http.Request, destStructPtr any, opts *upload.Options) error {
+	form, err := upload.Parse(r, opts)
+	if err != nil {
+		return err
+	}
+	if err := parseURLValues(form.Values, destStructPtr); err != nil {
+		return fmt.Errorf("error parsing form values: %w", err)
+	}
+	if err := setFileFields(reflect.ValueOf(destStructPtr).Elem(), form.Files); err != nil {
+		return fmt.Errorf("error setting file fields: %w", err)
+	}
+	if err := attemptValidation("validate.MultipartInto", destStructPtr); err != nil {
+		return fmt.Errorf("error validating form values: %w", err)
+	}
+	return nil
+}
+
 func attemptValidation(label string, x any) error {
 	if errs := safeRunOwnValidate(label, x, getTypeState(reflect.ValueOf(x))); len(errs) > 0 {
 		return &ValidationError{Err: errors.Join(errs...)}
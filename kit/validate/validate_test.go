@@ -1,12 +1,17 @@
 package validate
 
 import (
+	"bytes"
 	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+
+	"github.com/sjc5/river/kit/upload"
 )
 
 type TestStruct struct {
@@ -144,6 +149,119 @@ func TestURLSearchParamsIntoHighLevel(t *testing.T) {
 	}
 }
 
+func TestFormIntoHighLevel(t *testing.T) {
+	// Test with valid urlencoded form values (query params must be ignored)
+	form := url.Values{}
+	form.Add("name", "John")
+	form.Add("email", "john@example.com")
+	form.Add("age", "30")
+	r := httptest.NewRequest("POST", "/?name=Query", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	dest := &TestStruct{}
+	if err := FormInto(r, dest); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if dest.Name != "John" || dest.Email != "john@example.com" || dest.Age != 30 {
+		t.Errorf("unexpected values in struct after parsing form: %+v", dest)
+	}
+
+	// Test with valid multipart form values
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("name", "Jane")
+	mw.WriteField("email", "jane@example.com")
+	mw.WriteField("age", "40")
+	mw.Close()
+	r = httptest.NewRequest("POST", "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	dest = &TestStruct{}
+	if err := FormInto(r, dest); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if dest.Name != "Jane" || dest.Email != "jane@example.com" || dest.Age != 40 {
+		t.Errorf("unexpected values in struct after parsing multipart form: %+v", dest)
+	}
+
+	// Test with missing required fields
+	form = url.Values{}
+	form.Add("name", "John")
+	r = httptest.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	err := FormInto(r, &TestStruct{})
+	if err == nil || !IsValidationError(err) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+}
+
+type testUploadStruct struct {
+	Title  string         `json:"title"`
+	Avatar *upload.File   `json:"avatar"`
+	Docs   []*upload.File `json:"docs"`
+	Cover  *upload.File   `json:"cover,omitempty"`
+	Secret *upload.File   `json:"-"`
+}
+
+func (t *testUploadStruct) Validate() error {
+	if t.Avatar == nil {
+		return errors.New("avatar is required")
+	}
+	return nil
+}
+
+func TestMultipartInto(t *testing.T) {
+	newRequest := func(withAvatar bool) *http.Request {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("title", "Hello")
+		if withAvatar {
+			w, _ := mw.CreateFormFile("avatar", "a.txt")
+			w.Write([]byte("avatar"))
+		}
+		for _, name := range []string{"one.txt", "two.txt"} {
+			w, _ := mw.CreateFormFile("docs", name)
+			w.Write([]byte(name))
+		}
+		for _, field := range []string{"cover", "-"} {
+			w, _ := mw.CreateFormFile(field, field+".txt")
+			w.Write([]byte(field))
+		}
+		mw.Close()
+		r := httptest.NewRequest("POST", "/", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		return r
+	}
+
+	dest := &testUploadStruct{}
+	if err := MultipartInto(newRequest(true), dest, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dest.Title != "Hello" {
+		t.Errorf("expected title to be bound, got %q", dest.Title)
+	}
+	if dest.Avatar == nil || dest.Avatar.Filename != "a.txt" {
+		t.Errorf("expected avatar to be bound, got %+v", dest.Avatar)
+	}
+	if len(dest.Docs) != 2 || dest.Docs[1].Filename != "two.txt" {
+		t.Errorf("expected two docs to be bound, got %v", dest.Docs)
+	}
+	if dest.Cover == nil || dest.Cover.Filename != "cover.txt" {
+		t.Errorf("expected cover to be bound despite its tag options, got %+v", dest.Cover)
+	}
+	if dest.Secret != nil {
+		t.Errorf("expected a field tagged json:\"-\" not to be bound, got %+v", dest.Secret)
+	}
+
+	err := MultipartInto(newRequest(false), &testUploadStruct{}, nil)
+	if err == nil || !IsValidationError(err) {
+		t.Errorf("expected validation error, got %v", err)
+	}
+
+	err = MultipartInto(newRequest(true), &testUploadStruct{}, &upload.Options{MaxFileSize: 3})
+	if !errors.Is(err, upload.ErrTooLarge) {
+		t.Errorf("expected upload.ErrTooLarge, got %v", err)
+	}
+}
+
 func TestEdgeCases(t *testing.T) {
 	// Test with empty JSON
 	emptyJSON := `{}`
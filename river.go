@@ -10,12 +10,21 @@ import (
 /////////////////////////////////////////////////////////////////////
 
 type (
-	River[C any] = framework.River[C]
-	HeadBlock    = htmlutil.Element
-	AdHocType    = framework.AdHocType
-	BuildOptions = framework.BuildOptions
+	River[C any]           = framework.River[C]
+	HeadBlock              = htmlutil.Element
+	AdHocType              = framework.AdHocType
+	BuildOptions           = framework.BuildOptions
+	LoaderError            = framework.LoaderError
+	UIRouteOutput          = framework.UIRouteOutput
+	SSRRenderer            = framework.SSRRenderer
+	NodeSSRRenderer        = framework.NodeSSRRenderer
+	NodeSSRRendererOptions = framework.NodeSSRRendererOptions
+	RoutesDebugOptions     = framework.RoutesDebugOptions
 )
 
 var (
-	GetIsJSONRequest = framework.GetIsJSONRequest
+	GetIsJSONRequest     = framework.GetIsJSONRequest
+	NewLoaderError       = framework.NewLoaderError
+	NewNodeSSRRenderer   = framework.NewNodeSSRRenderer
+	NewFormActionsRouter = framework.NewFormActionsRouter
 )